@@ -5,6 +5,8 @@ sed -i "s~{{CMDB_MYSQL_PORT}}~$CMDB_MYSQL_PORT~g" /app/cmdb/conf/default.json
 sed -i "s~{{CMDB_MYSQL_SCHEMA}}~$CMDB_MYSQL_SCHEMA~g" /app/cmdb/conf/default.json
 sed -i "s~{{CMDB_MYSQL_USER}}~$CMDB_MYSQL_USER~g" /app/cmdb/conf/default.json
 sed -i "s~{{CMDB_MYSQL_PWD}}~$CMDB_MYSQL_PWD~g" /app/cmdb/conf/default.json
+sed -i "s~{{CMDB_DB_TYPE}}~$CMDB_DB_TYPE~g" /app/cmdb/conf/default.json
+sed -i "s~{{CMDB_DB_SSL_MODE}}~$CMDB_DB_SSL_MODE~g" /app/cmdb/conf/default.json
 sed -i "s~{{CMDB_LOG_LEVEL}}~$CMDB_LOG_LEVEL~g" /app/cmdb/conf/default.json
 sed -i "s~{{GATEWAY_URL}}~$GATEWAY_URL~g" /app/cmdb/conf/default.json
 sed -i "s~{{JWT_SIGNING_KEY}}~$JWT_SIGNING_KEY~g" /app/cmdb/conf/default.json
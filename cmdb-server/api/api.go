@@ -52,9 +52,23 @@ func init() {
 		&handlerFuncObj{Url: "/ci-types/apply/:ciType", Method: "POST", HandlerFunc: ci.CiTypesApply, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-types/rollback/:ciType", Method: "POST", HandlerFunc: ci.CiTypesRollback, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-types/references/:ciType", Method: "GET", HandlerFunc: ci.CiTypesReferences},
+		&handlerFuncObj{Url: "/ci-types/confirm-policy/:ciType", Method: "PUT", HandlerFunc: ci.CiTypesConfirmPolicyUpdate, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-template", Method: "GET", HandlerFunc: ci.GetCiTemplate},
 		&handlerFuncObj{Url: "/state-machine", Method: "GET", HandlerFunc: ci.GetStateMachine},
+		&handlerFuncObj{Url: "/state-machine", Method: "POST", HandlerFunc: ci.CreateStateMachine, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine", Method: "GET", HandlerFunc: ci.GetStateMachineDetail},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine", Method: "PUT", HandlerFunc: ci.UpdateStateMachine, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine", Method: "DELETE", HandlerFunc: ci.DeleteStateMachine, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/impact", Method: "GET", HandlerFunc: ci.GetStateMachineImpact},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/states", Method: "POST", HandlerFunc: ci.CreateState, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/states/:state", Method: "PUT", HandlerFunc: ci.UpdateState, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/states/:state", Method: "DELETE", HandlerFunc: ci.DeleteState, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/transitions", Method: "POST", HandlerFunc: ci.CreateStateTransition, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/transitions/:transition", Method: "PUT", HandlerFunc: ci.UpdateStateTransition, LogOperation: true},
+		&handlerFuncObj{Url: "/state-machine/:stateMachine/transitions/:transition", Method: "DELETE", HandlerFunc: ci.DeleteStateTransition, LogOperation: true},
 		&handlerFuncObj{Url: "/state-transition/:ciType", Method: "GET", HandlerFunc: ci.GetStateTransition},
+		&handlerFuncObj{Url: "/model-bundle/export", Method: "GET", HandlerFunc: ci.ModelBundleExport},
+		&handlerFuncObj{Url: "/model-bundle/import", Method: "POST", HandlerFunc: ci.ModelBundleImport, LogOperation: true},
 	)
 	// ciAttributes
 	httpHandlerFuncList = append(httpHandlerFuncList,
@@ -64,20 +78,43 @@ func init() {
 		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/:ciAttr", Method: "DELETE", HandlerFunc: ci.AttrDelete, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/apply/:ciAttr", Method: "POST", HandlerFunc: ci.AttrApply, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/rollback/:ciAttr", Method: "POST", HandlerFunc: ci.AttrRollback, LogOperation: true},
+		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/migrate/:ciAttr", Method: "POST", HandlerFunc: ci.AttrMigrate, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/swap-position", Method: "POST", HandlerFunc: ci.AttrPositionSwap, LogOperation: true},
+		&handlerFuncObj{Url: "/ci-types-attr/:ciType/attributes/autofill-lineage/:ciAttr", Method: "GET", HandlerFunc: ci.AttrAutofillLineage},
+		&handlerFuncObj{Url: "/ci-types-attr/autofill-graph", Method: "GET", HandlerFunc: ci.AttrAutofillGraph},
 	)
 	// ciData
 	httpHandlerFuncList = append(httpHandlerFuncList,
 		&handlerFuncObj{Url: "/ci-data/query/:ciType", Method: "POST", HandlerFunc: ci.DataQuery},
+		&handlerFuncObj{Url: "/ci-data/export/:ciType", Method: "POST", HandlerFunc: ci.DataExport},
 		&handlerFuncObj{Url: "/ci-data/do/:operation/:ciType", Method: "POST", HandlerFunc: ci.DataOperation, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-data/reference-data/query/:ciAttr", Method: "POST", HandlerFunc: ci.DataReferenceQuery},
 		&handlerFuncObj{Url: "/ci-data/rollback/query/:guid", Method: "GET", HandlerFunc: ci.DataRollbackList},
+		&handlerFuncObj{Url: "/ci-data/diff/:guid", Method: "GET", HandlerFunc: ci.DataDiff},
+		&handlerFuncObj{Url: "/ci-data/topology/:guid", Method: "POST", HandlerFunc: ci.DataTopology},
+		&handlerFuncObj{Url: "/ci-data/expression/explain", Method: "POST", HandlerFunc: ci.ExpressionExplain},
+		&handlerFuncObj{Url: "/ci-data/autofill/explain/:guid/:ciAttr", Method: "GET", HandlerFunc: ci.AutofillValueExplain},
 		&handlerFuncObj{Url: "/ci-data/query-password/:ciType/:guid/:field", Method: "GET", HandlerFunc: ci.DataPasswordQuery},
 		&handlerFuncObj{Url: "/ci-data/action-query/:operation/:ciType/:guid", Method: "GET", HandlerFunc: ci.GetActionQueryData},
 		&handlerFuncObj{Url: "/ci-data/import/:ciType", Method: "POST", HandlerFunc: ci.DataImport},
+		&handlerFuncObj{Url: "/search", Method: "GET", HandlerFunc: ci.DataSearch},
+		&handlerFuncObj{Url: "/search/rebuild", Method: "POST", HandlerFunc: ci.DataSearchIndexRebuild, LogOperation: true},
 		&handlerFuncObj{Url: "/ci-data/simple/import/:ciType", Method: "POST", HandlerFunc: ci.SimpleCiDataImport},
 		&handlerFuncObj{Url: "/ci-data/password/encrypt-key", Method: "GET", HandlerFunc: ci.GetCiPasswordAESKey},
 	)
+	// webhook
+	httpHandlerFuncList = append(httpHandlerFuncList,
+		&handlerFuncObj{Url: "/webhooks/query", Method: "POST", HandlerFunc: ci.WebhookQuery},
+		&handlerFuncObj{Url: "/webhooks", Method: "POST", HandlerFunc: ci.WebhookCreate, LogOperation: true},
+		&handlerFuncObj{Url: "/webhooks/:webhookId", Method: "PUT", HandlerFunc: ci.WebhookUpdate, LogOperation: true},
+		&handlerFuncObj{Url: "/webhooks/:webhookId", Method: "DELETE", HandlerFunc: ci.WebhookDelete, LogOperation: true},
+		&handlerFuncObj{Url: "/webhook-events/query", Method: "POST", HandlerFunc: ci.WebhookEventQuery},
+		&handlerFuncObj{Url: "/webhook-events/retry/:eventId", Method: "POST", HandlerFunc: ci.WebhookEventRetry, LogOperation: true},
+		// autofill job
+		&handlerFuncObj{Url: "/autofill-jobs/query", Method: "POST", HandlerFunc: ci.AutofillJobQuery},
+		&handlerFuncObj{Url: "/autofill-jobs", Method: "POST", HandlerFunc: ci.AutofillJobCreate, LogOperation: true},
+		&handlerFuncObj{Url: "/autofill-jobs/:jobId", Method: "GET", HandlerFunc: ci.AutofillJobGet},
+	)
 	// log
 	httpHandlerFuncList = append(httpHandlerFuncList,
 		&handlerFuncObj{Url: "/log/query", Method: "POST", HandlerFunc: ci.QueryOperationLog},
@@ -195,6 +232,7 @@ func InitHttpServer() {
 	r.POST(urlPrefix+"/entities/:ciType/create", middleware.AuthCoreRequestToken(), ci.HandleCiModelRequest, ci.HandleOperationLog)
 	r.POST(urlPrefix+"/entities/:ciType/update", middleware.AuthCoreRequestToken(), ci.HandleCiModelRequest, ci.HandleOperationLog)
 	r.POST(urlPrefix+"/entities/:ciType/delete", middleware.AuthCoreRequestToken(), ci.HandleCiModelRequest, ci.HandleOperationLog)
+	r.POST(urlPrefix+"/entities/:ciType/upsert", middleware.AuthCoreRequestToken(), ci.HandleCiModelRequest, ci.HandleOperationLog)
 	r.GET(urlPrefix+"/data-model", middleware.AuthToken(), ci.GetAllDataModel)
 	r.POST(urlPrefix+"/plugin/ci-data/operation", middleware.AuthCorePluginToken(), ci.PluginCiDataOperationHandle, ci.HandleOperationLog)
 	r.POST(urlPrefix+"/plugin/ci-data/attr-value", middleware.AuthCorePluginToken(), ci.PluginCiDataAttrValueHandle, ci.HandleOperationLog)
@@ -0,0 +1,54 @@
+package ci
+
+import (
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/api/middleware"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/services/db"
+	"github.com/gin-gonic/gin"
+)
+
+func AutofillJobQuery(c *gin.Context) {
+	var param models.QueryRequestParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	pageInfo, rowData, err := db.AutofillJobQuery(&param)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		if param.Paging {
+			middleware.ReturnPageData(c, pageInfo, rowData)
+		} else {
+			middleware.ReturnData(c, rowData)
+		}
+	}
+}
+
+func AutofillJobCreate(c *gin.Context) {
+	var param models.AutofillJobParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	job, err := db.CreateAutofillJob(param, middleware.GetRequestUser(c))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, job)
+	}
+}
+
+func AutofillJobGet(c *gin.Context) {
+	jobId := c.Param("jobId")
+	if jobId == "" {
+		middleware.ReturnParamEmptyError(c, "jobId")
+		return
+	}
+	result, err := db.GetAutofillJob(jobId)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
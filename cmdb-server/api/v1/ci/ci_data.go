@@ -50,6 +50,51 @@ func DataQuery(c *gin.Context) {
 	}
 }
 
+func DataExport(c *gin.Context) {
+	var param models.QueryRequestParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		middleware.ReturnParamValidateError(c, fmt.Errorf("Url param format:%s is illegal,only support csv and xlsx ", format))
+		return
+	}
+	ciTypeId := c.Param("ciType")
+	permissions, tmpErr := db.GetRoleCiDataPermission(middleware.GetRequestRoles(c), ciTypeId)
+	if tmpErr != nil {
+		middleware.ReturnDataPermissionError(c, tmpErr)
+		return
+	}
+	legalGuidList, tmpErr := db.GetCiDataPermissionGuidList(&permissions, "query")
+	if tmpErr != nil {
+		middleware.ReturnDataPermissionError(c, tmpErr)
+		return
+	}
+	if !legalGuidList.Disable && len(legalGuidList.GuidList) == 0 {
+		middleware.ReturnDataPermissionDenyError(c)
+		return
+	}
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", ciTypeId, format))
+	if err := db.CiDataExport(ciTypeId, &param, &legalGuidList, format, c.Writer); err != nil {
+		// 已经开始写文件后无法再返回错误信息
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			c.Header("Content-Disposition", "")
+			middleware.ReturnServerHandleError(c, err)
+		}
+	}
+}
+
 func DataOperation(c *gin.Context) {
 	var interfaceParam []map[string]interface{}
 	var err error
@@ -92,10 +137,30 @@ func DataOperation(c *gin.Context) {
 	}
 	handleParam := models.HandleCiDataParam{InputData: param, CiTypeId: c.Param("ciType"), Operation: c.Param("operation"), Operator: middleware.GetRequestUser(c), Roles: middleware.GetRequestRoles(c), Permission: true}
 	handleParam.UserToken = c.GetHeader("Authorization")
+	if strings.ToLower(c.Query("dryRun")) == "true" {
+		plan, newInputData, planErr := db.PlanCiDataOperation(handleParam)
+		c.Set("requestBody", newInputData)
+		if planErr != nil {
+			middleware.ReturnServerHandleError(c, planErr)
+		} else {
+			middleware.ReturnData(c, plan)
+		}
+		return
+	}
+	if strings.ToLower(c.Query("partial")) == "true" {
+		batchResult, newInputData := db.HandleCiDataOperationPartial(handleParam)
+		c.Set("requestBody", newInputData)
+		middleware.ReturnData(c, batchResult)
+		return
+	}
 	//resultData, err := db.HandleCiDataOperation(param, c.Param("ciType"), c.Param("operation"), middleware.GetRequestUser(c), "", middleware.GetRequestRoles(c), true, false)
 	resultData, newInputData, err := db.HandleCiDataOperation(handleParam)
 	c.Set("requestBody", newInputData)
 	if err != nil {
+		if conflictErr, ok := err.(*models.CiDataConflictError); ok {
+			middleware.ReturnError(c, "DATA_CONFLICT", conflictErr.Error(), conflictErr.Rows)
+			return
+		}
 		middleware.ReturnServerHandleError(c, err)
 	} else {
 		middleware.ReturnData(c, resultData)
@@ -137,6 +202,16 @@ func DataRollbackList(c *gin.Context) {
 	}
 }
 
+func DataDiff(c *gin.Context) {
+	guid := c.Param("guid")
+	resultData, err := db.CiDataDiff(guid, c.Query("from"), c.Query("to"), middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, resultData)
+	}
+}
+
 func DataPasswordQuery(c *gin.Context) {
 	ciTypeId := c.Param("ciType")
 	guid := c.Param("guid")
@@ -221,80 +296,112 @@ func SimpleCiDataImport(c *gin.Context) {
 		middleware.ReturnServerHandleError(c, fmt.Errorf("data row empty"))
 		return
 	}
-	ciAttrList, getAttrErr := db.GetCiAttrByCiType(ciTypeId, true)
-	if getAttrErr != nil {
-		middleware.ReturnServerHandleError(c, getAttrErr)
+	param := models.SimpleImportParam{CiType: ciTypeId, Mode: c.Query("mode"), Action: strings.ToLower(c.Query("action")), AddOperation: c.Query("addOperation"), UpdateOperation: c.Query("updateOperation")}
+	param.ValidateOnly = strings.ToLower(c.Query("validateOnly")) == "true"
+	param.Operator = middleware.GetRequestUser(c)
+	param.Roles = middleware.GetRequestRoles(c)
+	param.UserToken = c.GetHeader("Authorization")
+	result, importErr := db.SimpleCiDataImport(dataRowList, param)
+	if importErr != nil {
+		middleware.ReturnServerHandleError(c, importErr)
 		return
 	}
-	attrIndexMap := make(map[int]*models.SysCiTypeAttrTable)
-	for i, attrDisplayName := range dataRowList[0] {
-		for _, attrObj := range ciAttrList {
-			if attrObj.DisplayName == attrDisplayName {
-				attrIndexMap[i] = attrObj
-				break
+	// 只导入合法行时,有行导入成功就不算失败
+	importFail := len(result.Errors) > 0 && (result.InsertRows+result.UpdateRows == 0 || param.Mode != models.ImportModeValidOnly)
+	// 带上report=true才返回逐行的校验报告,否则保持原来的返回格式:出错时返回错误信息,成功时返回导入的数据
+	if strings.ToLower(c.Query("report")) != "true" {
+		if importFail {
+			errorMessageList := []string{}
+			for _, errObj := range result.Errors {
+				errorMessageList = append(errorMessageList, fmt.Sprintf("row:%d column:%s %s", errObj.Row, errObj.Column, errObj.Message))
 			}
+			middleware.ReturnServerHandleError(c, fmt.Errorf("Import validate fail with %d errors,%s ", len(result.Errors), strings.Join(errorMessageList, "; ")))
+		} else {
+			middleware.ReturnData(c, result.Data)
 		}
+		return
 	}
-	var param []models.CiDataMapObj
-	for i, inputRow := range dataRowList {
-		if i == 0 {
-			continue
-		}
-		stringMap := make(map[string]string)
-		for k, v := range inputRow {
-			if v == "" {
-				continue
-			}
-			if attrObj, ok := attrIndexMap[k]; ok {
-				if attrObj.InputType == "ref" {
-					if tmpGuidList, tmpErr := db.GetGuidByKeyName(attrObj.RefCiType, []string{v}); tmpErr != nil {
-						err = tmpErr
-						break
-					} else {
-						if len(tmpGuidList) == 0 {
-							err = fmt.Errorf("can not find ciType:%s with name:%s ", attrObj.RefCiType, v)
-							break
-						} else {
-							stringMap[attrObj.Name] = tmpGuidList[0]
-						}
-					}
-				} else if attrObj.InputType == "multiRef" {
-					if tmpGuidList, tmpErr := db.GetGuidByKeyName(attrObj.RefCiType, strings.Split(v, ",")); tmpErr != nil {
-						err = tmpErr
-						break
-					} else {
-						guidListBytes, _ := json.Marshal(tmpGuidList)
-						stringMap[attrObj.Name] = string(guidListBytes)
-					}
-				} else {
-					stringMap[attrObj.Name] = v
-				}
-			}
-		}
-		if err != nil {
-			break
-		}
-		param = append(param, stringMap)
+	if importFail {
+		middleware.ReturnError(c, "IMPORT_VALIDATE_ERROR", fmt.Sprintf("Import validate fail with %d errors ", len(result.Errors)), result)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func GetCiPasswordAESKey(c *gin.Context) {
+	md5sum := cipher.Md5Encode(models.Config.Wecube.EncryptSeed)
+	middleware.ReturnData(c, md5sum[0:16])
+}
+
+func DataSearch(c *gin.Context) {
+	var param models.CiSearchParam
+	if err := c.ShouldBindQuery(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
 	}
+	if strings.TrimSpace(param.Query) == "" {
+		middleware.ReturnParamEmptyError(c, "q")
+		return
+	}
+	result, err := db.CiSearch(param, middleware.GetRequestRoles(c))
 	if err != nil {
 		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func DataSearchIndexRebuild(c *gin.Context) {
+	if err := db.RebuildCiSearchIndex(c.Query("ciType")); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func DataTopology(c *gin.Context) {
+	var param models.CiTopologyParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
 		return
 	}
-	if len(param) == 0 {
-		middleware.ReturnServerHandleError(c, fmt.Errorf("data row empty"))
+	result, err := db.CiDataTopology(c.Param("guid"), param, middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func ExpressionExplain(c *gin.Context) {
+	var param models.CiExpressionExplainParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
 		return
 	}
-	handleParam := models.HandleCiDataParam{InputData: param, CiTypeId: ciTypeId, Operation: "Add", Operator: middleware.GetRequestUser(c), Roles: middleware.GetRequestRoles(c), Permission: false}
-	handleParam.UserToken = c.GetHeader("Authorization")
-	resultData, _, handleErr := db.HandleCiDataOperation(handleParam)
-	if handleErr != nil {
-		middleware.ReturnServerHandleError(c, handleErr)
+	result, err := db.CiExpressionExplain(param, middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
 	} else {
-		middleware.ReturnData(c, resultData)
+		middleware.ReturnData(c, result)
 	}
 }
 
-func GetCiPasswordAESKey(c *gin.Context) {
-	md5sum := cipher.Md5Encode(models.Config.Wecube.EncryptSeed)
-	middleware.ReturnData(c, md5sum[0:16])
+func AutofillValueExplain(c *gin.Context) {
+	guid := c.Param("guid")
+	if guid == "" {
+		middleware.ReturnParamEmptyError(c, "guid")
+		return
+	}
+	ciAttr := c.Param("ciAttr")
+	if ciAttr == "" {
+		middleware.ReturnParamEmptyError(c, "ciAttr")
+		return
+	}
+	result, err := db.CiAutofillValueExplain(guid, ciAttr, middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
 }
@@ -189,6 +189,19 @@ func CiTypesRollback(c *gin.Context) {
 	}
 }
 
+func CiTypesConfirmPolicyUpdate(c *gin.Context) {
+	var param models.CiTypeConfirmPolicyParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	if err := db.CiTypeConfirmPolicyUpdate(c.Param("ciType"), &param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, param)
+	}
+}
+
 func CiTypesReferences(c *gin.Context) {
 	ciTypeId := c.Param("ciType")
 	result, err := db.GetCiTypesReference(ciTypeId)
@@ -169,6 +169,39 @@ func AttrRollback(c *gin.Context) {
 	}
 }
 
+// 修改已创建属性的类型或长度并迁移数据,dryRun=true时只校验数据不执行
+// POST /ci-types-attr/:ciType/attributes/migrate/:ciAttr?dryRun=true
+func AttrMigrate(c *gin.Context) {
+	ciTypeGuid := c.Param("ciType")
+	if ciTypeGuid == "" {
+		middleware.ReturnParamEmptyError(c, "ciType")
+		return
+	}
+	ciAttrId := c.Param("ciAttr")
+	if ciAttrId == "" {
+		middleware.ReturnParamEmptyError(c, "ciAttr")
+		return
+	}
+	var param models.SysCiTypeAttrTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.Id = ciAttrId
+	param.CiType = ciTypeGuid
+	dryRun := strings.ToLower(c.Query("dryRun")) == "true"
+	result, err := db.CiAttrMigrate(&param, dryRun)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+		return
+	}
+	if len(result.FailRows) > 0 && !dryRun {
+		middleware.ReturnError(c, "ATTR_MIGRATE_ERROR", fmt.Sprintf("Attribute:%s has %d rows can not migrate ", ciAttrId, len(result.FailRows)), result)
+		return
+	}
+	middleware.ReturnData(c, result)
+}
+
 func AttrPositionSwap(c *gin.Context) {
 	ciTypeGuid := c.Param("ciType")
 	if ciTypeGuid == "" {
@@ -187,3 +220,26 @@ func AttrPositionSwap(c *gin.Context) {
 		middleware.ReturnData(c, []string{})
 	}
 }
+
+func AttrAutofillGraph(c *gin.Context) {
+	result, err := db.GetCiAutofillGraph(c.Query("ciType"))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func AttrAutofillLineage(c *gin.Context) {
+	ciAttrId := c.Param("ciAttr")
+	if ciAttrId == "" {
+		middleware.ReturnParamEmptyError(c, "ciAttr")
+		return
+	}
+	result, err := db.GetCiAttrAutofillLineage(ciAttrId)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
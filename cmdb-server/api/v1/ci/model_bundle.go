@@ -0,0 +1,44 @@
+package ci
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/api/middleware"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/services/db"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//导出数据模型
+//GET /model-bundle/export?ciType=a,b
+func ModelBundleExport(c *gin.Context) {
+	var ciTypeList []string
+	if c.Query("ciType") != "" {
+		ciTypeList = strings.Split(c.Query("ciType"), ",")
+	}
+	bundle, err := db.ExportModelBundle(ciTypeList)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=wecmdb-model-%s.json", time.Now().Format("20060102150405")))
+	c.JSON(http.StatusOK, bundle)
+}
+
+//导入数据模型,默认只返回差异,apply=true时执行导入
+//POST /model-bundle/import?apply=true
+func ModelBundleImport(c *gin.Context) {
+	var param models.ModelBundle
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	result, err := db.ImportModelBundle(&param, strings.ToLower(c.Query("apply")) == "true")
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
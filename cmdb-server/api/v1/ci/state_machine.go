@@ -0,0 +1,135 @@
+package ci
+
+import (
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/api/middleware"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/services/db"
+	"github.com/gin-gonic/gin"
+)
+
+func GetStateMachineDetail(c *gin.Context) {
+	result, err := db.GetStateMachineDetail(c.Param("stateMachine"))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func GetStateMachineImpact(c *gin.Context) {
+	result, err := db.GetStateMachineImpact(c.Param("stateMachine"))
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func CreateStateMachine(c *gin.Context) {
+	var param models.GetStateMachineList
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	if err := db.CreateStateMachine(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, param)
+	}
+}
+
+func UpdateStateMachine(c *gin.Context) {
+	var param models.GetStateMachineList
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.Id = c.Param("stateMachine")
+	if err := db.UpdateStateMachine(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, param)
+	}
+}
+
+func DeleteStateMachine(c *gin.Context) {
+	if err := db.DeleteStateMachine(c.Param("stateMachine")); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func CreateState(c *gin.Context) {
+	var param models.StateMachineStateParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.StateMachine = c.Param("stateMachine")
+	if err := db.CreateState(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func UpdateState(c *gin.Context) {
+	var param models.SysStateTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.StateMachine = c.Param("stateMachine")
+	param.Id = c.Param("state")
+	if err := db.UpdateState(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, param)
+	}
+}
+
+func DeleteState(c *gin.Context) {
+	if err := db.DeleteState(c.Param("stateMachine"), c.Param("state")); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func CreateStateTransition(c *gin.Context) {
+	var param models.SysStateTransitionTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.StateMachine = c.Param("stateMachine")
+	if err := db.CreateStateTransition(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func UpdateStateTransition(c *gin.Context) {
+	var param models.SysStateTransitionTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.StateMachine = c.Param("stateMachine")
+	param.Guid = c.Param("transition")
+	if err := db.UpdateStateTransition(&param); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnData(c, param)
+	}
+}
+
+func DeleteStateTransition(c *gin.Context) {
+	if err := db.DeleteStateTransition(c.Param("stateMachine"), c.Param("transition")); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
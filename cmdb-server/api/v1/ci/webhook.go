@@ -0,0 +1,98 @@
+package ci
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/api/middleware"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/services/db"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+func WebhookQuery(c *gin.Context) {
+	var param models.QueryRequestParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	pageInfo, rowData, err := db.WebhookQuery(&param)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		if param.Paging {
+			middleware.ReturnPageData(c, pageInfo, rowData)
+		} else {
+			middleware.ReturnData(c, rowData)
+		}
+	}
+}
+
+func WebhookCreate(c *gin.Context) {
+	var param models.SysWebhookTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	if err := db.WebhookCreate(&param, middleware.GetRequestUser(c)); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		// 保存后的密钥是密文,和查询接口一样只返回掩码
+		if param.Secret != "" {
+			param.Secret = models.PasswordDisplay
+		}
+		middleware.ReturnData(c, param)
+	}
+}
+
+func WebhookUpdate(c *gin.Context) {
+	var param models.SysWebhookTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	param.Id = c.Param("webhookId")
+	if err := db.WebhookUpdate(&param, middleware.GetRequestUser(c)); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func WebhookDelete(c *gin.Context) {
+	if err := db.WebhookDelete(c.Param("webhookId")); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func WebhookEventQuery(c *gin.Context) {
+	var param models.QueryRequestParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnParamValidateError(c, err)
+		return
+	}
+	pageInfo, rowData, err := db.WebhookEventQuery(&param)
+	if err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		if param.Paging {
+			middleware.ReturnPageData(c, pageInfo, rowData)
+		} else {
+			middleware.ReturnData(c, rowData)
+		}
+	}
+}
+
+func WebhookEventRetry(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		middleware.ReturnParamValidateError(c, fmt.Errorf("Url param eventId:%s is illegal ", c.Param("eventId")))
+		return
+	}
+	if err = db.WebhookEventRetry(eventId); err != nil {
+		middleware.ReturnServerHandleError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
@@ -16,7 +16,7 @@ import (
 
 func HandleCiModelRequest(c *gin.Context) {
 	ciType := c.Param("ciType")
-	operation := c.Request.RequestURI[strings.LastIndex(c.Request.RequestURI, "/")+1:]
+	operation := c.Request.URL.Path[strings.LastIndex(c.Request.URL.Path, "/")+1:]
 	var resp, logResp models.EntityResponse
 	var bodyBytes []byte
 	var err error
@@ -47,6 +47,8 @@ func HandleCiModelRequest(c *gin.Context) {
 		resp.Data, logResp.Data, newInputData, err = ciModelCreate(ciType, bodyBytes)
 	} else if operation == "update" {
 		resp.Data, logResp.Data, newInputData, dataGuidList, err = ciModeUpdate(ciType, bodyBytes)
+	} else if operation == "upsert" {
+		resp.Data, logResp.Data, newInputData, dataGuidList, err = ciModelUpsert(ciType, c.Query("uniqueAttr"), bodyBytes)
 	} else if operation == "delete" {
 		newInputData, err = ciModeDelete(ciType, bodyBytes)
 	} else {
@@ -69,6 +71,11 @@ func HandleCiModelRequest(c *gin.Context) {
 		log.Logger.Error("Request entity data fail", log.Error(err))
 		resp.Status = "ERROR"
 		resp.Message = err.Error()
+		// 和DataOperation一样,数据被其他人修改过时返回冲突的行和期间变化的字段
+		if conflictErr, ok := err.(*models.CiDataConflictError); ok {
+			resp.Status = "DATA_CONFLICT"
+			resp.Data = buildEntityConflictData(conflictErr)
+		}
 		logResp.Status, logResp.Message = resp.Status, resp.Message
 		if operation == "query" {
 			logResp.Data = resp.Data
@@ -223,6 +230,14 @@ func ciModelCreate(ciType string, bodyBytes []byte) (result, logResult []map[str
 	return
 }
 
+func buildEntityConflictData(conflictErr *models.CiDataConflictError) (result []map[string]interface{}) {
+	for _, row := range conflictErr.Rows {
+		result = append(result, map[string]interface{}{"id": row.Guid, "displayName": row.KeyName, "inputUpdateTime": row.InputUpdateTime,
+			"updateTime": row.UpdateTime, "updateUser": row.UpdateUser, "columns": row.Columns})
+	}
+	return
+}
+
 func ciModeUpdate(ciType string, bodyBytes []byte) (result, logResult []map[string]interface{}, newInputData string, dataGuidList []string, err error) {
 	newInputData = string(bodyBytes)
 	var param []map[string]interface{}
@@ -303,6 +318,88 @@ func ciModeUpdate(ciType string, bodyBytes []byte) (result, logResult []map[stri
 	return
 }
 
+// ciModelUpsert 有id的数据按id更新,否则按uniqueAttr匹配已有数据,匹配不到时新增
+func ciModelUpsert(ciType, uniqueAttr string, bodyBytes []byte) (result, logResult []map[string]interface{}, newInputData string, dataGuidList []string, err error) {
+	newInputData = string(bodyBytes)
+	var param []map[string]interface{}
+	var stringParam []models.CiDataMapObj
+	err = json.Unmarshal(bodyBytes, &param)
+	if err != nil {
+		return
+	}
+	if uniqueAttr == "displayName" {
+		uniqueAttr = "key_name"
+	}
+	for i, tmpMap := range param {
+		if _, b := tmpMap["id"]; b {
+			tmpMap["guid"] = tmpMap["id"]
+			delete(tmpMap, "id")
+		}
+		if _, b := tmpMap["displayName"]; b {
+			tmpMap["key_name"] = tmpMap["displayName"]
+			delete(tmpMap, "displayName")
+		}
+		tmpStringMap := make(map[string]string)
+		for k, v := range tmpMap {
+			if v == nil {
+				continue
+			}
+			valueType := reflect.TypeOf(v).String()
+			if valueType == "string" {
+				tmpStringMap[k] = v.(string)
+			} else if valueType == "int" {
+				tmpStringMap[k] = fmt.Sprintf("%d", v.(int))
+			} else {
+				tmpJsonByte, tmpErr := json.Marshal(v)
+				if tmpErr != nil {
+					err = fmt.Errorf("Row:%d column:%s value type not support ", i, k)
+					break
+				}
+				tmpStringMap[k] = string(tmpJsonByte)
+			}
+		}
+		if err != nil {
+			break
+		}
+		stringParam = append(stringParam, tmpStringMap)
+	}
+	if err != nil {
+		return
+	}
+	handleParam := models.HandleCiDataParam{InputData: stringParam, CiTypeId: ciType, Operator: "wecube", Roles: []string{}, Permission: false, FromCore: true}
+	output, newInput, tmpErr := db.CiDataUpsert(handleParam, uniqueAttr)
+	newInputData = newInput
+	if tmpErr != nil {
+		err = tmpErr
+		return
+	}
+	for _, outputObj := range output {
+		tmpResultMap := make(map[string]interface{})
+		tmpLogResultMap := make(map[string]interface{})
+		for k, v := range outputObj {
+			if strings.HasPrefix(v, "******^") {
+				tmpLogResultMap[k] = "******"
+				tmpResultMap[k] = v[7:]
+				continue
+			}
+			tmpResultMap[k] = v
+			tmpLogResultMap[k] = v
+		}
+		if v, b := tmpResultMap["guid"]; b {
+			tmpResultMap["id"] = v
+			tmpLogResultMap["id"] = v
+			dataGuidList = append(dataGuidList, outputObj["guid"])
+		}
+		if v, b := tmpResultMap["key_name"]; b {
+			tmpResultMap["displayName"] = v
+			tmpLogResultMap["displayName"] = v
+		}
+		result = append(result, tmpResultMap)
+		logResult = append(logResult, tmpLogResultMap)
+	}
+	return
+}
+
 func ciModeDelete(ciType string, bodyBytes []byte) (newInputData string, err error) {
 	newInputData = string(bodyBytes)
 	var param []map[string]interface{}
@@ -579,8 +676,17 @@ func PluginViewConfirmHandle(c *gin.Context) {
 		return
 	}
 	userToken := c.GetHeader("Authorization")
+	// 插件调用不校验数据权限,但确认策略需要用真实的操作人和token中的角色校验,编排传入的operator优先于token中的用户
+	requestUser := middleware.GetRequestUser(c)
+	if requestUser == "" {
+		requestUser = "SYSTEM"
+	}
 	for _, input := range param.Inputs {
-		output, tmpErr := pluginViewConfirm(input, userToken)
+		operator := input.Operator
+		if operator == "" {
+			operator = requestUser
+		}
+		output, tmpErr := pluginViewConfirm(input, userToken, operator, middleware.GetRequestRoles(c))
 		if tmpErr != nil {
 			output.ErrorCode = "1"
 			output.ErrorMessage = tmpErr.Error()
@@ -592,13 +698,13 @@ func PluginViewConfirmHandle(c *gin.Context) {
 	c.Set("requestBody", string(logParam))
 }
 
-func pluginViewConfirm(input *models.PluginViewConfirmRequestObj, userToken string) (result *models.PluginViewConfirmOutputObj, err error) {
+func pluginViewConfirm(input *models.PluginViewConfirmRequestObj, userToken, operator string, roles []string) (result *models.PluginViewConfirmOutputObj, err error) {
 	result = &models.PluginViewConfirmOutputObj{CallbackParameter: input.CallbackParameter, ErrorCode: "0", ErrorMessage: ""}
 	if input.ViewId == "" || input.RootCi == "" {
 		err = fmt.Errorf("Param validate fail,viewId && rootCi can not empty ")
 		return
 	}
-	confirmResult, confirmErr := db.ViewConfirmAction(models.ViewData{ViewId: input.ViewId, RootCi: input.RootCi}, userToken, "SYSTEM", []string{})
+	confirmResult, confirmErr := db.ViewConfirmAction(models.ViewData{ViewId: input.ViewId, RootCi: input.RootCi}, userToken, operator, roles, false)
 	if confirmErr != nil {
 		err = confirmErr
 		return
@@ -192,7 +192,7 @@ func ConfirmView(c *gin.Context) {
 		middleware.ReturnParamValidateError(c, err)
 		return
 	}
-	result, err := db.ViewConfirmAction(param, c.GetHeader("Authorization"), middleware.GetRequestUser(c), middleware.GetRequestRoles(c))
+	result, err := db.ViewConfirmAction(param, c.GetHeader("Authorization"), middleware.GetRequestUser(c), middleware.GetRequestRoles(c), true)
 	if err != nil {
 		middleware.ReturnServerHandleError(c, err)
 		return
@@ -6,6 +6,8 @@ require (
 	github.com/WeBankPartners/go-common-lib v1.1.5
 	github.com/gin-gonic/gin v1.8.1
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.0
 	go.uber.org/zap v1.23.0
 	xorm.io/core v0.7.3
 	xorm.io/xorm v1.0.7
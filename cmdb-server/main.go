@@ -27,9 +27,11 @@ func main() {
 	//start cron job
 	go ci.StartConsumeOperationLog()
 	go db.StartSyncImageFile()
-	go db.StartConsumeAffectGuidMap()
-	go db.StartConsumeAffectCiType()
+	go db.StartAutofillJob()
 	go db.StartConsumeUniquePathHandle()
+	go db.StartConsumeSearchIndex()
+	go db.StartWebhookDelivery()
+	go db.StartTimeTrigger()
 	//start http
 	api.InitHttpServer()
 }
@@ -0,0 +1,43 @@
+package models
+
+type SysAutofillJobTable struct {
+	Id            string `json:"id" xorm:"id"`
+	JobType       string `json:"jobType" xorm:"job_type"`
+	CiType        string `json:"ciType" xorm:"ci_type"`
+	CiAttr        string `json:"ciAttr" xorm:"ci_attr"`
+	GuidList      string `json:"-" xorm:"guid_list"`
+	ChainMap      string `json:"-" xorm:"chain_map"`
+	Status        string `json:"status" xorm:"status"`
+	TotalNum      int    `json:"totalNum" xorm:"total_num"`
+	DoneNum       int    `json:"doneNum" xorm:"done_num"`
+	UpdateNum     int    `json:"updateNum" xorm:"update_num"`
+	FailNum       int    `json:"failNum" xorm:"fail_num"`
+	LastGuid      string `json:"lastGuid" xorm:"last_guid"`
+	ErrorMessage  string `json:"errorMessage" xorm:"error_message"`
+	Owner         string `json:"owner" xorm:"owner"`
+	HeartbeatTime string `json:"heartbeatTime" xorm:"heartbeat_time"`
+	CreateUser    string `json:"createUser" xorm:"create_user"`
+	CreateTime    string `json:"createTime" xorm:"create_time"`
+	StartTime     string `json:"startTime" xorm:"start_time"`
+	EndTime       string `json:"endTime" xorm:"end_time"`
+	UpdateTime    string `json:"updateTime" xorm:"update_time"`
+}
+
+type SysAutofillJobErrorTable struct {
+	Id         int    `json:"id" xorm:"id"`
+	JobId      string `json:"jobId" xorm:"job_id"`
+	CiType     string `json:"ciType" xorm:"ci_type"`
+	RowGuid    string `json:"rowGuid" xorm:"row_guid"`
+	Message    string `json:"message" xorm:"message"`
+	CreateTime string `json:"createTime" xorm:"create_time"`
+}
+
+type AutofillJobParam struct {
+	CiType string `json:"ciType"`
+	CiAttr string `json:"ciAttr"`
+}
+
+type AutofillJobDetail struct {
+	Job    *SysAutofillJobTable        `json:"job"`
+	Errors []*SysAutofillJobErrorTable `json:"errors"`
+}
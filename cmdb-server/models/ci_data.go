@@ -84,6 +84,7 @@ type SysStateTransitionTable struct {
 	Action            string `json:"action" xorm:"action"`
 	OperationFormType string `json:"operationFormType" xorm:"operation_form_type"`
 	OperationMultiple string `json:"operationMultiple" xorm:"operation_multiple"`
+	Guard             string `json:"guard" xorm:"guard"`
 }
 
 type SysStateTransitionQuery struct {
@@ -101,6 +102,7 @@ type SysStateTransitionQuery struct {
 	Action           string `json:"action" xorm:"action"`
 	StartState       string `json:"start_state" xorm:"start_state"`
 	FinalState       string `json:"final_state" xorm:"final_state"`
+	Guard            string `json:"guard" xorm:"guard"`
 }
 
 type BuildAttrValueParam struct {
@@ -129,6 +131,7 @@ type ActionFuncParam struct {
 	DeleteList          []string
 	FromCore            bool
 	MultiColumnDelMap   map[string][]string
+	DryRun              bool
 }
 
 type MultiCiDataObj struct {
@@ -321,3 +324,299 @@ type SysCiImportGuidMap struct {
 	Source string `json:"source" xorm:"source"`
 	Target string `json:"target" xorm:"target"`
 }
+
+type CiDataDiffPoint struct {
+	Id                    string `json:"id"`
+	HistoryTime           string `json:"history_time"`
+	HistoryAction         string `json:"history_action"`
+	HistoryStateConfirmed string `json:"history_state_confirmed"`
+	State                 string `json:"state"`
+}
+
+type CiDataDiffAttrObj struct {
+	Name        string      `json:"name"`
+	DisplayName string      `json:"displayName"`
+	InputType   string      `json:"inputType"`
+	Changed     bool        `json:"changed"`
+	From        interface{} `json:"from"`
+	To          interface{} `json:"to"`
+}
+
+type CiDataDiffResult struct {
+	Guid       string               `json:"guid"`
+	CiType     string               `json:"ciType"`
+	From       *CiDataDiffPoint     `json:"from"`
+	To         *CiDataDiffPoint     `json:"to"`
+	Attributes []*CiDataDiffAttrObj `json:"attributes"`
+}
+
+type CiDataPlanChangeObj struct {
+	Name     string `json:"name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Autofill bool   `json:"autofill"`
+}
+
+type CiDataPlanRowObj struct {
+	CiType       string                 `json:"ciType"`
+	Guid         string                 `json:"guid"`
+	KeyName      string                 `json:"keyName"`
+	Action       string                 `json:"action"`
+	CurrentState string                 `json:"currentState"`
+	TargetState  string                 `json:"targetState"`
+	Changes      []*CiDataPlanChangeObj `json:"changes"`
+}
+
+type CiDataPlanAutofillObj struct {
+	CiType       string   `json:"ciType"`
+	Attribute    string   `json:"attribute"`
+	TriggerGuids []string `json:"triggerGuids"`
+}
+
+type CiDataPlanUniquePathObj struct {
+	CiType    string   `json:"ciType"`
+	Operation string   `json:"operation"`
+	GuidList  []string `json:"guidList"`
+}
+
+type CiDataOperationPlan struct {
+	Rows               []*CiDataPlanRowObj        `json:"rows"`
+	AutofillAttributes []*CiDataPlanAutofillObj   `json:"autofillAttributes"`
+	UniquePaths        []*CiDataPlanUniquePathObj `json:"uniquePaths"`
+}
+
+type SimpleImportParam struct {
+	CiType          string
+	Mode            string
+	Action          string
+	AddOperation    string
+	UpdateOperation string
+	ValidateOnly    bool
+	Operator        string
+	Roles           []string
+	UserToken       string
+}
+
+type SimpleImportErrorObj struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column"`
+	KeyName string `json:"keyName"`
+	Message string `json:"message"`
+}
+
+type SimpleImportResult struct {
+	TotalRows  int                     `json:"totalRows"`
+	ValidRows  int                     `json:"validRows"`
+	InsertRows int                     `json:"insertRows"`
+	UpdateRows int                     `json:"updateRows"`
+	Errors     []*SimpleImportErrorObj `json:"errors"`
+	Data       []CiDataMapObj          `json:"data"`
+}
+
+// CiDataConflictRow 数据读取后被其他人修改过,Columns为期间变化的字段
+type CiDataConflictRow struct {
+	Guid            string   `json:"guid"`
+	KeyName         string   `json:"keyName"`
+	InputUpdateTime string   `json:"inputUpdateTime"`
+	UpdateTime      string   `json:"updateTime"`
+	UpdateUser      string   `json:"updateUser"`
+	Columns         []string `json:"columns"`
+}
+
+func (r *CiDataConflictRow) Error() string {
+	return fmt.Sprintf("Row:%s update_time:%s is diff with database,columns:[%s] have been changed by %s at %s", r.KeyName, r.InputUpdateTime, strings.Join(r.Columns, ","), r.UpdateUser, r.UpdateTime)
+}
+
+type CiDataConflictError struct {
+	Rows []*CiDataConflictRow
+}
+
+func (e *CiDataConflictError) Error() string {
+	var messageList []string
+	for _, row := range e.Rows {
+		messageList = append(messageList, row.Error())
+	}
+	return fmt.Sprintf("Data conflict with %d rows: %s ", len(e.Rows), strings.Join(messageList, "; "))
+}
+
+// CiDataRowError 数据行本身校验不通过,和数据库、权限等整体的错误区分开,分批模式下只有这类错误才逐行重试
+type CiDataRowError struct {
+	Message string
+}
+
+func (e *CiDataRowError) Error() string {
+	return e.Message
+}
+
+// CiDataBatchResult 分批模式下每行单独校验和提交的结果
+type CiDataBatchResult struct {
+	TotalRows   int                     `json:"totalRows"`
+	SuccessRows int                     `json:"successRows"`
+	FailRows    int                     `json:"failRows"`
+	Rows        []*CiDataBatchRowResult `json:"rows"`
+}
+
+type CiDataBatchRowResult struct {
+	Index   int                  `json:"index"`
+	Guid    string               `json:"guid"`
+	KeyName string               `json:"keyName"`
+	Status  string               `json:"status"`
+	State   string               `json:"state"`
+	Data    CiDataMapObj         `json:"data"`
+	Error   *CiDataBatchRowError `json:"error"`
+}
+
+type CiDataBatchRowError struct {
+	Code     string             `json:"code"`
+	Message  string             `json:"message"`
+	Conflict *CiDataConflictRow `json:"conflict"`
+}
+
+type CiSearchParam struct {
+	Query  string `json:"q" form:"q"`
+	CiType string `json:"ciType" form:"ciType"`
+	Limit  int    `json:"limit" form:"limit"`
+}
+
+// CiSearchResultObj 全局搜索结果,Attr为该行数据分数最高的命中属性
+type CiSearchResultObj struct {
+	CiType     string `json:"ciType"`
+	CiTypeName string `json:"ciTypeName"`
+	Guid       string `json:"guid"`
+	KeyName    string `json:"keyName"`
+	State      string `json:"state"`
+	Attr       string `json:"attr"`
+	AttrName   string `json:"attrName"`
+	Value      string `json:"value"`
+	Score      int    `json:"score"`
+	MatchNum   int    `json:"matchNum"`
+}
+
+// CiTopologyParam 从一条数据出发按引用关系遍历,Direction为out(该数据引用的)、in(引用该数据的)或both
+type CiTopologyParam struct {
+	Direction string   `json:"direction"`
+	CiTypes   []string `json:"ciTypes"`
+	Attrs     []string `json:"attrs"`
+	MaxDepth  int      `json:"maxDepth"`
+}
+
+type CiTopologyResult struct {
+	Nodes     []*CiTopologyNode `json:"nodes"`
+	Edges     []*CiTopologyEdge `json:"edges"`
+	Truncated bool              `json:"truncated"`
+}
+
+type CiTopologyNode struct {
+	Guid    string `json:"guid"`
+	CiType  string `json:"ciType"`
+	KeyName string `json:"keyName"`
+	State   string `json:"state"`
+	Depth   int    `json:"depth"`
+}
+
+// CiTopologyEdge 边的方向始终是引用方指向被引用方
+type CiTopologyEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Attr      string `json:"attr"`
+	AttrName  string `json:"attrName"`
+	InputType string `json:"inputType"`
+}
+
+// CiExpressionError 表达式语法或标识符错误,Position为出错字符在表达式中的位置,从1开始
+type CiExpressionError struct {
+	Expression string `json:"expression"`
+	Position   int    `json:"position"`
+	Message    string `json:"message"`
+}
+
+func (e *CiExpressionError) Error() string {
+	return fmt.Sprintf("Expression:%s illegal at position %d,%s ", e.Expression, e.Position, e.Message)
+}
+
+// CiExpressionExplainParam Expression和CiAttr二选一,Guid为样例数据,用它的数据作为表达式第一段的过滤值
+type CiExpressionExplainParam struct {
+	Expression string `json:"expression"`
+	CiAttr     string `json:"ciAttr"`
+	Guid       string `json:"guid"`
+	Permission bool   `json:"permission"`
+}
+
+type CiExpressionExplainResult struct {
+	CiAttr      string                    `json:"ciAttr"`
+	Guid        string                    `json:"guid"`
+	RowData     map[string]string         `json:"rowData"`
+	Expressions []*CiExpressionExplainObj `json:"expressions"`
+	Autofill    []*CiAutofillExplainRule  `json:"autofill"`
+	Values      []string                  `json:"values"`
+	Error       string                    `json:"error"`
+}
+
+// CiExpressionExplainObj 单个表达式的解析结果、生成的sql、每一跳的guid集合和最终结果
+type CiExpressionExplainObj struct {
+	Name       string                        `json:"name"`
+	Expression string                        `json:"expression"`
+	Permission bool                          `json:"permission"`
+	Segments   []*CiExpressionExplainSegment `json:"segments"`
+	Sql        string                        `json:"sql"`
+	Params     []interface{}                 `json:"params"`
+	Hops       []*CiExpressionExplainHop     `json:"hops"`
+	Values     []string                      `json:"values"`
+	Error      *CiExpressionError            `json:"error"`
+}
+
+type CiExpressionExplainSegment struct {
+	Index        int      `json:"index"`
+	CiType       string   `json:"ciType"`
+	JoinType     string   `json:"joinType"`
+	JoinColumn   string   `json:"joinColumn"`
+	MultiRef     bool     `json:"multiRef"`
+	Filters      []string `json:"filters"`
+	RefAttr      string   `json:"refAttr"`
+	ResultColumn string   `json:"resultColumn"`
+	Skipped      bool     `json:"skipped"`
+}
+
+type CiExpressionExplainHop struct {
+	Index    int           `json:"index"`
+	CiType   string        `json:"ciType"`
+	JoinType string        `json:"joinType"`
+	Sql      string        `json:"sql"`
+	Params   []interface{} `json:"params"`
+	Count    int           `json:"count"`
+	GuidList []string      `json:"guidList"`
+}
+
+// CiAutofillExplainRule 自动填充规则中每个rule段的每一跳
+type CiAutofillExplainRule struct {
+	Index  int                     `json:"index"`
+	Type   string                  `json:"type"`
+	Value  string                  `json:"value"`
+	Hops   []*CiAutofillExplainHop `json:"hops"`
+	Values []string                `json:"values"`
+	Error  string                  `json:"error"`
+}
+
+type CiAutofillExplainHop struct {
+	Index     int      `json:"index"`
+	CiType    string   `json:"ciType"`
+	Attr      string   `json:"attr"`
+	Direction string   `json:"direction"`
+	Filters   []string `json:"filters"`
+	Count     int      `json:"count"`
+	GuidList  []string `json:"guidList"`
+	KeyNames  []string `json:"keyNames"`
+}
+
+// CiAutofillValueExplainResult 按当前数据重新计算自动填充属性,和库里保存的值对比
+type CiAutofillValueExplainResult struct {
+	Guid          string                   `json:"guid"`
+	CiAttr        string                   `json:"ciAttr"`
+	AutofillType  string                   `json:"autofillType"`
+	AutofillRule  string                   `json:"autofillRule"`
+	Rules         []*CiAutofillExplainRule `json:"rules"`
+	StoredValue   string                   `json:"storedValue"`
+	ComputedValue string                   `json:"computedValue"`
+	Stale         bool                     `json:"stale"`
+	Error         string                   `json:"error"`
+}
@@ -5,17 +5,25 @@ var (
 )
 
 type SysCiTypeTable struct {
-	Id           string `json:"ciTypeId" xorm:"id" binding:"required"`
-	DisplayName  string `json:"name" xorm:"display_name"`
-	Description  string `json:"description" xorm:"description"`
-	Status       string `json:"status" xorm:"status"`
-	ImageFile    string `json:"imageFile" xorm:"image_file"`
-	FileName     string `json:"fileName" xorm:"file_name"`
-	CiGroup      string `json:"ciGroup" xorm:"ci_group"`
-	CiLayer      string `json:"ciLayer" xorm:"ci_layer"`
-	CiTemplate   string `json:"ciTemplate" xorm:"ci_template"`
-	StateMachine string `json:"stateMachine" xorm:"state_machine"`
-	SeqNo        string `json:"seqNo" xorm:"seq_no"`
+	Id            string `json:"ciTypeId" xorm:"id" binding:"required"`
+	DisplayName   string `json:"name" xorm:"display_name"`
+	Description   string `json:"description" xorm:"description"`
+	Status        string `json:"status" xorm:"status"`
+	ImageFile     string `json:"imageFile" xorm:"image_file"`
+	FileName      string `json:"fileName" xorm:"file_name"`
+	CiGroup       string `json:"ciGroup" xorm:"ci_group"`
+	CiLayer       string `json:"ciLayer" xorm:"ci_layer"`
+	CiTemplate    string `json:"ciTemplate" xorm:"ci_template"`
+	StateMachine  string `json:"stateMachine" xorm:"state_machine"`
+	SeqNo         string `json:"seqNo" xorm:"seq_no"`
+	ConfirmPolicy string `json:"confirmPolicy" xorm:"confirm_policy"`
+	ConfirmRole   string `json:"confirmRole" xorm:"confirm_role"`
+}
+
+// CiTypeConfirmPolicyParam 确认策略,fourEyes时确认人不能是最后一次新增或修改数据的人,confirmRole不为空时确认人需要有其中一个角色
+type CiTypeConfirmPolicyParam struct {
+	ConfirmPolicy string `json:"confirmPolicy" binding:"required"`
+	ConfirmRole   string `json:"confirmRole"`
 }
 
 type DatabaseTableList struct {
@@ -35,6 +35,7 @@ type SysCiTypeAttrTable struct {
 	AutofillType            string `json:"autoFillType" xorm:"autofill_type"`
 	EditGroupControl        string `json:"editGroupControl" xorm:"edit_group_control"`
 	EditGroupValues         string `json:"editGroupValues" xorm:"edit_group_value"`
+	TriggerOperation        string `json:"triggerOperation" xorm:"trigger_operation"`
 }
 
 type CiAttrSwapPositionParam struct {
@@ -92,3 +93,55 @@ type CiTypeReferenceObj struct {
 	RefName        string `json:"referenceName" xorm:"ref_name"`
 	RefType        string `json:"referenceType" xorm:"ref_type"`
 }
+
+type CiAttrMigrateResult struct {
+	CiAttr        string                  `json:"ciTypeAttrId"`
+	FromInputType string                  `json:"fromInputType"`
+	ToInputType   string                  `json:"toInputType"`
+	FromDataType  string                  `json:"fromPropertyType"`
+	ToDataType    string                  `json:"toPropertyType"`
+	FromLength    int                     `json:"fromLength"`
+	ToLength      int                     `json:"toLength"`
+	TotalRows     int                     `json:"totalRows"`
+	ConvertRows   int                     `json:"convertRows"`
+	FailRows      []*CiAttrMigrateFailRow `json:"failRows"`
+	DryRun        bool                    `json:"dryRun"`
+	Migrated      bool                    `json:"migrated"`
+}
+
+type CiAttrMigrateFailRow struct {
+	Table   string `json:"table"`
+	Id      string `json:"id"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+type CiAutofillGraphNode struct {
+	Id          string `json:"id"`
+	CiType      string `json:"ciType"`
+	Name        string `json:"attr"`
+	DisplayName string `json:"name"`
+	InputType   string `json:"inputType"`
+	Status      string `json:"status"`
+	Autofill    bool   `json:"autofill"`
+	Depth       int    `json:"depth"`
+}
+
+type CiAutofillGraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Usage string `json:"usage"`
+}
+
+type CiAutofillGraph struct {
+	Nodes  []*CiAutofillGraphNode `json:"nodes"`
+	Edges  []*CiAutofillGraphEdge `json:"edges"`
+	Cycles [][]string             `json:"cycles"`
+}
+
+type CiAutofillLineage struct {
+	CiAttr     string                 `json:"ciAttr"`
+	Upstream   []*CiAutofillGraphNode `json:"upstream"`
+	Downstream []*CiAutofillGraphNode `json:"downstream"`
+	Edges      []*CiAutofillGraphEdge `json:"edges"`
+}
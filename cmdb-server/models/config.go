@@ -27,14 +27,17 @@ type LogConfig struct {
 }
 
 type DatabaseConfig struct {
-	Server   string `json:"server"`
-	Port     string `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	DataBase string `json:"database"`
-	MaxOpen  int    `json:"maxOpen"`
-	MaxIdle  int    `json:"maxIdle"`
-	Timeout  int    `json:"timeout"`
+	Type        string `json:"type"`
+	Server      string `json:"server"`
+	Port        string `json:"port"`
+	User        string `json:"user"`
+	Password    string `json:"password"`
+	DataBase    string `json:"database"`
+	MaxOpen     int    `json:"maxOpen"`
+	MaxIdle     int    `json:"maxIdle"`
+	Timeout     int    `json:"timeout"`
+	SslMode     string `json:"sslMode"`
+	InitSqlFile string `json:"initSqlFile"`
 }
 
 type WecubeConfig struct {
@@ -57,6 +60,27 @@ type MenuApiMapConfig struct {
 	File   string `json:"file"`
 }
 
+type WebhookConfig struct {
+	Enable        bool `json:"enable"`
+	IntervalSec   int  `json:"interval_sec"`
+	TimeoutSec    int  `json:"timeout_sec"`
+	MaxRetry      int  `json:"max_retry"`
+	RetryDelaySec int  `json:"retry_delay_sec"`
+	BatchSize     int  `json:"batch_size"`
+}
+
+type TimeTriggerConfig struct {
+	Enable        bool `json:"enable"`
+	IntervalSec   int  `json:"interval_sec"`
+	MaxRetry      int  `json:"max_retry"`
+	RetryDelaySec int  `json:"retry_delay_sec"`
+	BatchSize     int  `json:"batch_size"`
+}
+
+type AutofillJobConfig struct {
+	Concurrency int `json:"concurrency"`
+}
+
 type GlobalConfig struct {
 	IsPluginMode         string                        `json:"is_plugin_mode"`
 	DefaultLanguage      string                        `json:"default_language"`
@@ -68,6 +92,9 @@ type GlobalConfig struct {
 	Auth                 AuthConfig                    `json:"auth"`
 	MenuApiMap           MenuApiMapConfig              `json:"menu_api_map"`
 	DefaultReportObjAttr []*DefaultReportObjAttrConfig `json:"default_report_obj_attr"`
+	Webhook              WebhookConfig                 `json:"webhook"`
+	TimeTrigger          TimeTriggerConfig             `json:"time_trigger"`
+	AutofillJob          AutofillJobConfig             `json:"autofill_job"`
 	// default json
 }
 
@@ -22,6 +22,37 @@ const (
 	RollbackAction       = "rollback"
 	FilterTypeExpression = "expression"
 	FilterTypeSelectList = "selectList"
+	WebhookStatusPending = "pending"
+	WebhookStatusSending = "sending"
+	WebhookStatusSuccess = "success"
+	WebhookStatusFailed  = "failed"
+	TimeTriggerRunning   = "running"
+	TimeTriggerSuccess   = "success"
+	TimeTriggerFailed    = "failed"
+	ConfirmPolicyNone    = "none"
+	ConfirmPolicy4Eyes   = "fourEyes"
+	BatchRowSuccess      = "success"
+	BatchRowFailed       = "failed"
+	TopologyDirectionOut = "out"
+	TopologyDirectionIn  = "in"
+	TopologyDirectionAll = "both"
+	AutofillDependRef    = "ref"
+	AutofillDependValue  = "value"
+	AutofillDependFilter = "filter"
+	AutofillJobPending   = "pending"
+	AutofillJobRunning   = "running"
+	AutofillJobSuccess   = "success"
+	AutofillJobFailed    = "failed"
+	AutofillJobCiType    = "ciType"
+	AutofillJobAttr      = "attr"
+	AutofillJobChain     = "chain"
+	WebhookSignHeader    = "X-Cmdb-Signature"
+	WebhookEventHeader   = "X-Cmdb-Event-Id"
+	ImportModeAll        = "allOrNothing"
+	ImportModeValidOnly  = "validOnly"
+	ImportActionAdd      = "add"
+	ImportActionUpdate   = "update"
+	ImportActionUpsert   = "upsert"
 )
 
 var (
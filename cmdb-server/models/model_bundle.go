@@ -0,0 +1,35 @@
+package models
+
+const (
+	ModelBundleVersion = "1"
+
+	ModelBundleItemCiType      = "ciType"
+	ModelBundleItemCiTypeAttr  = "ciTypeAttr"
+	ModelBundleItemBaseKeyCat  = "baseKeyCat"
+	ModelBundleItemBaseKeyCode = "baseKeyCode"
+)
+
+// ModelBundle 数据模型导出包,用于在不同环境间迁移ci类型、属性、状态机和基础数据
+type ModelBundle struct {
+	Version       string                 `json:"version"`
+	ExportTime    string                 `json:"exportTime"`
+	CiTypes       []*SysCiTypeTable      `json:"ciTypes"`
+	CiTypeAttrs   []*SysCiTypeAttrTable  `json:"ciTypeAttrs"`
+	StateMachines []*GetStateMachineList `json:"stateMachines"`
+	BaseKeyCats   []*SysBaseKeyCatTable  `json:"baseKeyCats"`
+	BaseKeyCodes  []*SysBaseKeyCodeTable `json:"baseKeyCodes"`
+}
+
+type ModelBundleDiffObj struct {
+	Type    string   `json:"type"`
+	Id      string   `json:"id"`
+	Action  string   `json:"action"`
+	Columns []string `json:"columns"`
+}
+
+type ModelBundleImportResult struct {
+	DiffFlag      bool                        `json:"diffFlag"`
+	Applied       bool                        `json:"applied"`
+	Items         []*ModelBundleDiffObj       `json:"items"`
+	StateMachines []*ImportStateMachineResult `json:"stateMachines"`
+}
@@ -14,6 +14,7 @@ type QueryRequestSorting struct {
 type QueryRequestDialect struct {
 	AssociatedData map[string]string `json:"associatedData"`
 	QueryMode      string            `json:"queryMode"`
+	AsOf           string            `json:"asOf"`
 }
 
 type QueryRequestParam struct {
@@ -23,6 +24,8 @@ type QueryRequestParam struct {
 	Pageable      *PageInfo                `json:"pageable"`
 	Sorting       *QueryRequestSorting     `json:"sorting"`
 	ResultColumns []string                 `json:"resultColumns"`
+	// SkipCount 分页时不统计总数,用于按批次遍历数据
+	SkipCount bool `json:"-"`
 }
 
 type TransFiltersParam struct {
@@ -0,0 +1,30 @@
+package models
+
+// StateMachineStateParam 新增状态时需要同时带上连到该状态的迁移,否则状态不可达
+type StateMachineStateParam struct {
+	SysStateTable
+	Transitions []*SysStateTransitionTable `json:"transitions"`
+}
+
+// StateMachineImpact 状态机被ci类型和数据使用的情况,修改或删除状态机前用于评估影响
+type StateMachineImpact struct {
+	StateMachine string                      `json:"stateMachine"`
+	CiTemplates  []string                    `json:"ciTemplates"`
+	CiTypes      []*StateMachineImpactCiType `json:"ciTypes"`
+	TotalRows    int                         `json:"totalRows"`
+}
+
+type StateMachineImpactCiType struct {
+	CiType    string         `json:"ciType"`
+	Status    string         `json:"status"`
+	TotalRows int            `json:"totalRows"`
+	StateRows map[string]int `json:"stateRows"`
+}
+
+// StateTransitionGuardObj 迁移守卫条件,left为当前数据的属性名或以当前ci类型开头的表达式,条件不满足时返回message
+type StateTransitionGuardObj struct {
+	Left     string               `json:"left"`
+	Operator string               `json:"operator"`
+	Right    CiDataRefFilterRight `json:"right"`
+	Message  string               `json:"message"`
+}
@@ -0,0 +1,45 @@
+package models
+
+type SysWebhookTable struct {
+	Id          string `json:"id" xorm:"id"`
+	Name        string `json:"name" xorm:"name" binding:"required"`
+	Url         string `json:"url" xorm:"url" binding:"required"`
+	Secret      string `json:"secret" xorm:"secret"`
+	CiType      string `json:"ciType" xorm:"ci_type"`
+	Operation   string `json:"operation" xorm:"operation"`
+	TargetState string `json:"targetState" xorm:"target_state"`
+	Enable      string `json:"enable" xorm:"enable"`
+	CreateUser  string `json:"createUser" xorm:"create_user"`
+	CreateTime  string `json:"createTime" xorm:"create_time"`
+	UpdateUser  string `json:"updateUser" xorm:"update_user"`
+	UpdateTime  string `json:"updateTime" xorm:"update_time"`
+}
+
+type SysWebhookEventTable struct {
+	Id         int    `json:"id" xorm:"id"`
+	EventId    string `json:"eventId" xorm:"event_id"`
+	Webhook    string `json:"webhook" xorm:"webhook"`
+	CiType     string `json:"ciType" xorm:"ci_type"`
+	RowGuid    string `json:"rowGuid" xorm:"row_guid"`
+	Payload    string `json:"payload" xorm:"payload"`
+	Status     string `json:"status" xorm:"status"`
+	RetryCount int    `json:"retryCount" xorm:"retry_count"`
+	NextTime   string `json:"nextTime" xorm:"next_time"`
+	LastError  string `json:"lastError" xorm:"last_error"`
+	CreateTime string `json:"createTime" xorm:"create_time"`
+	UpdateTime string `json:"updateTime" xorm:"update_time"`
+}
+
+type CiDataWebhookEvent struct {
+	EventId   string       `json:"eventId"`
+	EventTime string       `json:"eventTime"`
+	CiType    string       `json:"ciType"`
+	Guid      string       `json:"guid"`
+	KeyName   string       `json:"keyName"`
+	Operation string       `json:"operation"`
+	Action    string       `json:"action"`
+	FromState string       `json:"fromState"`
+	ToState   string       `json:"toState"`
+	Operator  string       `json:"operator"`
+	Data      CiDataMapObj `json:"data"`
+}
@@ -107,6 +107,7 @@ type PluginViewConfirmRequestObj struct {
 	CallbackParameter string `json:"callbackParameter"`
 	ViewId            string `json:"viewId"`
 	RootCi            string `json:"rootCi"`
+	Operator          string `json:"operator"`
 }
 
 type PluginViewConfirmResp struct {
@@ -0,0 +1,462 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const (
+	autofillJobIntervalSec = 10
+	// 每处理这么多行保存一次进度,重启后从最后保存的guid继续
+	autofillJobBatchSize   = 100
+	autofillJobMaxErrorNum = 500
+	// 执行中的任务定时刷新心跳,心跳超时的任务认为执行实例已退出,重新放回队列
+	autofillJobHeartbeatSec = 30
+	autofillJobStaleSec     = 120
+)
+
+var (
+	autofillJobNotifyChan = make(chan bool, 1)
+	// autofillJobWorkerChan 限制同时执行的任务数,拿不到位置的任务留在队列里等下一轮
+	autofillJobWorkerChan chan bool
+	// jobInstanceId 多实例部署时标记后台任务由哪个实例执行
+	jobInstanceId = buildJobInstanceId()
+)
+
+func buildJobInstanceId() string {
+	hostname, _ := os.Hostname()
+	instanceId := fmt.Sprintf("%s_%d", hostname, os.Getpid())
+	if len(instanceId) > 64 {
+		instanceId = instanceId[len(instanceId)-64:]
+	}
+	return instanceId
+}
+
+func notifyAutofillJob() {
+	select {
+	case autofillJobNotifyChan <- true:
+	default:
+	}
+}
+
+func AutofillJobQuery(param *models.QueryRequestParam) (pageInfo models.PageInfo, rowData []*models.SysAutofillJobTable, err error) {
+	rowData = []*models.SysAutofillJobTable{}
+	filterSql, queryColumn, queryParam := transFiltersToSQL(param, &models.TransFiltersParam{IsStruct: true, StructObj: models.SysAutofillJobTable{}})
+	baseSql := fmt.Sprintf("SELECT %s FROM sys_autofill_job WHERE 1=1 %s ", queryColumn, filterSql)
+	if param.Paging {
+		pageInfo.StartIndex = param.Pageable.StartIndex
+		pageInfo.PageSize = param.Pageable.PageSize
+		pageInfo.TotalRows = queryCount(baseSql, queryParam...)
+		pageSql, pageParam := transPageInfoToSQL(*param.Pageable)
+		baseSql += pageSql
+		queryParam = append(queryParam, pageParam...)
+	}
+	err = x.SQL(baseSql, queryParam...).Find(&rowData)
+	if err != nil {
+		err = fmt.Errorf("Autofill job query fail,%s ", err.Error())
+	}
+	return
+}
+
+// GetAutofillJob 返回任务进度和最近的失败数据
+func GetAutofillJob(jobId string) (result models.AutofillJobDetail, err error) {
+	var jobList []*models.SysAutofillJobTable
+	if err = x.SQL("select * from sys_autofill_job where id=?", jobId).Find(&jobList); err != nil {
+		err = fmt.Errorf("Try to query autofill job fail,%s ", err.Error())
+		return
+	}
+	if len(jobList) == 0 {
+		err = fmt.Errorf("Can not find autofill job:%s ", jobId)
+		return
+	}
+	result = models.AutofillJobDetail{Job: jobList[0], Errors: []*models.SysAutofillJobErrorTable{}}
+	if err = x.SQL("select * from sys_autofill_job_error where job_id=? order by id desc limit ?", jobId, autofillJobMaxErrorNum).Find(&result.Errors); err != nil {
+		err = fmt.Errorf("Try to query autofill job error fail,%s ", err.Error())
+	}
+	return
+}
+
+// CreateAutofillJob ciAttr不为空时只重算该属性以及同ci类型中依赖它的强制自动填充属性,否则重算ci类型所有强制自动填充属性
+func CreateAutofillJob(param models.AutofillJobParam, operator string) (job *models.SysAutofillJobTable, err error) {
+	job = &models.SysAutofillJobTable{Id: "autofill_job_" + guid.CreateGuidList(1)[0], JobType: models.AutofillJobCiType, CiType: param.CiType, Status: models.AutofillJobPending, CreateUser: operator}
+	if param.CiAttr != "" {
+		ciAttr, getErr := getCiAttrById(param.CiAttr)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if ciAttr.Status != "created" || ciAttr.AutofillAble != "yes" || ciAttr.AutofillType == "suggest" {
+			return nil, fmt.Errorf("Attribute:%s is not created forced autofill attribute ", param.CiAttr)
+		}
+		job.JobType, job.CiType, job.CiAttr = models.AutofillJobAttr, ciAttr.CiType, ciAttr.Id
+	}
+	if job.CiType == "" {
+		return nil, fmt.Errorf("Param ciType and ciAttr can not both empty ")
+	}
+	ciTypeData, err := GetCiTypeById(job.CiType)
+	if err != nil {
+		return nil, err
+	}
+	if ciTypeData.Status != "created" {
+		return nil, fmt.Errorf("Ci type %s is not created ", job.CiType)
+	}
+	countRows, err := x.QueryString(fmt.Sprintf("select count(1) as num from %s", job.CiType))
+	if err != nil {
+		return nil, fmt.Errorf("Try to count ci:%s data fail,%s ", job.CiType, err.Error())
+	}
+	if len(countRows) > 0 {
+		job.TotalNum, _ = strconv.Atoi(countRows[0]["num"])
+	}
+	if err = insertAutofillJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// createAutofillChainJob 数据更新后把受影响的源数据作为连锁重算任务保存下来,没有可能影响其他数据的更新时不建任务
+func createAutofillChainJob(autofillChainMap map[string][]*models.AutofillChainObj) (job *models.SysAutofillJobTable, err error) {
+	action := buildAutofillChainJobAction(autofillChainMap)
+	if action == nil {
+		return
+	}
+	if err = transaction([]*execAction{action}); err != nil {
+		return nil, fmt.Errorf("Try to insert autofill chain job fail,%s ", err.Error())
+	}
+	notifyAutofillJob()
+	return &models.SysAutofillJobTable{Id: action.Param[0].(string), JobType: models.AutofillJobChain, Status: models.AutofillJobPending}, nil
+}
+
+// buildAutofillChainJobAction 生成保存连锁重算任务的语句,调用方把它和数据修改放在同一个事务里
+// 任务只保存源数据和更新列,受影响的数据在执行任务时再查,返回空表示不需要建任务
+func buildAutofillChainJobAction(autofillChainMap map[string][]*models.AutofillChainObj) *execAction {
+	chainMap := filterAutofillChainMap(autofillChainMap)
+	if len(chainMap) == 0 {
+		return nil
+	}
+	var ciTypeList []string
+	for ciType := range chainMap {
+		ciTypeList = append(ciTypeList, ciType)
+	}
+	sort.Strings(ciTypeList)
+	chainMapBytes, _ := json.Marshal(chainMap)
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	return &execAction{Sql: "insert into sys_autofill_job(id,job_type,ci_type,chain_map,status,total_num,done_num,update_num,fail_num,create_user,create_time,update_time) values (?,?,?,?,?,0,0,0,0,?,?,?)",
+		Param: []interface{}{"autofill_job_" + guid.CreateGuidList(1)[0], models.AutofillJobChain, strings.Join(ciTypeList, ","), string(chainMapBytes), models.AutofillJobPending, "system", nowTime, nowTime}}
+}
+
+// filterAutofillChainMap 只保留更新列被其他ci类型自动填充规则用到,或者删除了多选引用的数据
+func filterAutofillChainMap(autofillChainMap map[string][]*models.AutofillChainObj) map[string][]*models.AutofillChainObj {
+	result := make(map[string][]*models.AutofillChainObj)
+	for ciType, rows := range autofillChainMap {
+		ciDepColumnList, _ := getCiTypeAutofillDepColumn(ciType)
+		for _, row := range rows {
+			affectFlag := false
+			for _, delGuidList := range row.MultiColumnDelMap {
+				if len(delGuidList) > 0 {
+					affectFlag = true
+					break
+				}
+			}
+			for _, ciColumnObj := range ciDepColumnList {
+				if affectFlag {
+					break
+				}
+				affectFlag = compareListIsJoin(row.UpdateColumn, ciColumnObj.UsedColumn)
+			}
+			if affectFlag {
+				result[ciType] = append(result[ciType], row)
+			}
+		}
+	}
+	return result
+}
+
+func insertAutofillJob(job *models.SysAutofillJobTable) error {
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	job.CreateTime, job.UpdateTime = nowTime, nowTime
+	_, err := x.Exec("insert into sys_autofill_job(id,job_type,ci_type,ci_attr,guid_list,status,total_num,done_num,update_num,fail_num,create_user,create_time,update_time) values (?,?,?,?,?,?,?,0,0,0,?,?,?)",
+		job.Id, job.JobType, job.CiType, job.CiAttr, job.GuidList, job.Status, job.TotalNum, job.CreateUser, job.CreateTime, job.UpdateTime)
+	if err != nil {
+		return fmt.Errorf("Try to insert autofill job fail,%s ", err.Error())
+	}
+	notifyAutofillJob()
+	return nil
+}
+
+func StartAutofillJob() {
+	autofillJobWorkerChan = make(chan bool, getAutofillJobConcurrency())
+	log.Logger.Info("Start autofill job cron job", log.String("instance", jobInstanceId), log.Int("concurrency", cap(autofillJobWorkerChan)))
+	t := time.NewTicker(autofillJobIntervalSec * time.Second).C
+	for {
+		reclaimStaleAutofillJobs()
+		runPendingAutofillJobs()
+		select {
+		case <-t:
+		case <-autofillJobNotifyChan:
+		}
+	}
+}
+
+// reclaimStaleAutofillJobs 心跳超时的执行中任务说明执行实例已经退出,重新放回队列,从保存的进度继续
+func reclaimStaleAutofillJobs() {
+	staleTime := time.Now().Add(-autofillJobStaleSec * time.Second).Format(models.DateTimeFormat)
+	execResult, err := x.Exec("update sys_autofill_job set status=?,owner=NULL where status=? and (heartbeat_time is null or heartbeat_time<?)", models.AutofillJobPending, models.AutofillJobRunning, staleTime)
+	if err != nil {
+		log.Logger.Error("Try to reclaim stale autofill job fail", log.Error(err))
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		log.Logger.Info("Reclaim stale autofill job", log.Int("num", int(affectNum)))
+	}
+}
+
+func runPendingAutofillJobs() {
+	var jobList []*models.SysAutofillJobTable
+	if err := x.SQL("select * from sys_autofill_job where status=? order by create_time", models.AutofillJobPending).Find(&jobList); err != nil {
+		log.Logger.Error("Try to query pending autofill job fail", log.Error(err))
+		return
+	}
+	for _, job := range jobList {
+		select {
+		case autofillJobWorkerChan <- true:
+		default:
+			// 没有空闲位置,剩下的任务等执行中的任务结束后再抢占
+			return
+		}
+		if !claimAutofillJob(job) {
+			<-autofillJobWorkerChan
+			continue
+		}
+		go func(job *models.SysAutofillJobTable) {
+			defer func() {
+				<-autofillJobWorkerChan
+				notifyAutofillJob()
+			}()
+			runAutofillJob(job)
+		}(job)
+	}
+}
+
+// claimAutofillJob 先抢占任务,避免多实例时重复执行
+func claimAutofillJob(job *models.SysAutofillJobTable) bool {
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	if job.StartTime == "" {
+		job.StartTime = nowTime
+	}
+	execResult, err := x.Exec("update sys_autofill_job set status=?,owner=?,heartbeat_time=?,start_time=?,update_time=? where id=? and status=?", models.AutofillJobRunning, jobInstanceId, nowTime, job.StartTime, nowTime, job.Id, models.AutofillJobPending)
+	if err != nil {
+		log.Logger.Error("Try to lock autofill job fail", log.String("id", job.Id), log.Error(err))
+		return false
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		return false
+	}
+	job.Status, job.Owner = models.AutofillJobRunning, jobInstanceId
+	return true
+}
+
+func getAutofillJobConcurrency() int {
+	if models.Config.AutofillJob.Concurrency <= 0 {
+		return 1
+	}
+	return models.Config.AutofillJob.Concurrency
+}
+
+type autofillJobRunner struct {
+	job          *models.SysAutofillJobTable
+	attrNameList []string
+	errorList    []*models.SysAutofillJobErrorTable
+	chainMap     map[string][]*models.AutofillChainObj
+}
+
+func runAutofillJob(job *models.SysAutofillJobTable) {
+	log.Logger.Info("Start autofill job", log.String("id", job.Id), log.String("type", job.JobType), log.String("ciType", job.CiType), log.Int("done", job.DoneNum))
+	runner := autofillJobRunner{job: job, chainMap: make(map[string][]*models.AutofillChainObj)}
+	stopHeartbeat := make(chan bool)
+	go keepAutofillJobHeartbeat(job.Id, stopHeartbeat)
+	err := runner.run()
+	close(stopHeartbeat)
+	job.Status = models.AutofillJobSuccess
+	if err != nil {
+		log.Logger.Error("Autofill job fail", log.String("id", job.Id), log.Error(err))
+		job.Status, job.ErrorMessage = models.AutofillJobFailed, err.Error()
+	} else if job.FailNum > 0 {
+		job.Status, job.ErrorMessage = models.AutofillJobFailed, fmt.Sprintf("%d rows recompute fail ", job.FailNum)
+	}
+	job.EndTime = time.Now().Format(models.DateTimeFormat)
+	if saveErr := runner.saveProgress(); saveErr != nil {
+		log.Logger.Error("Try to save autofill job progress fail", log.String("id", job.Id), log.Error(saveErr))
+	}
+	log.Logger.Info("Autofill job done", log.String("id", job.Id), log.String("status", job.Status), log.Int("done", job.DoneNum), log.Int("update", job.UpdateNum), log.Int("fail", job.FailNum))
+}
+
+// keepAutofillJobHeartbeat 任务执行期间定时刷新心跳,任务被其他实例接管后不再刷新
+func keepAutofillJobHeartbeat(jobId string, stop chan bool) {
+	t := time.NewTicker(autofillJobHeartbeatSec * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if _, err := x.Exec("update sys_autofill_job set heartbeat_time=? where id=? and owner=?", time.Now().Format(models.DateTimeFormat), jobId, jobInstanceId); err != nil {
+				log.Logger.Error("Try to update autofill job heartbeat fail", log.String("id", jobId), log.Error(err))
+			}
+		}
+	}
+}
+
+func (r *autofillJobRunner) run() (err error) {
+	if r.job.JobType == models.AutofillJobAttr {
+		if r.attrNameList, err = getAutofillJobAttrList(r.job.CiType, r.job.CiAttr); err != nil {
+			return
+		}
+	}
+	if r.job.JobType == models.AutofillJobChain {
+		if r.job.GuidList == "" {
+			if err = r.expandChainMap(); err != nil {
+				return
+			}
+		}
+		var guidList []string
+		if err = json.Unmarshal([]byte(r.job.GuidList), &guidList); err != nil {
+			return fmt.Errorf("Json unmarshal autofill job guid list fail,%s ", err.Error())
+		}
+		var batchGuidList []string
+		for _, rowGuid := range guidList {
+			if rowGuid <= r.job.LastGuid {
+				continue
+			}
+			batchGuidList = append(batchGuidList, rowGuid)
+			if len(batchGuidList) >= autofillJobBatchSize {
+				if err = r.handleBatch(batchGuidList); err != nil {
+					return
+				}
+				batchGuidList = []string{}
+			}
+		}
+		if len(batchGuidList) > 0 {
+			err = r.handleBatch(batchGuidList)
+		}
+		return
+	}
+	// 按guid顺序分批处理整个ci类型的数据
+	for {
+		queryRows, queryErr := x.QueryString(fmt.Sprintf("select guid from %s where guid>? order by guid limit ?", r.job.CiType), r.job.LastGuid, autofillJobBatchSize)
+		if queryErr != nil {
+			return fmt.Errorf("Try to query ci:%s data fail,%s ", r.job.CiType, queryErr.Error())
+		}
+		if len(queryRows) == 0 {
+			break
+		}
+		var batchGuidList []string
+		for _, row := range queryRows {
+			batchGuidList = append(batchGuidList, row["guid"])
+		}
+		if err = r.handleBatch(batchGuidList); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// expandChainMap 按保存的源数据查出受影响的数据,保存下来后重启不用再查
+func (r *autofillJobRunner) expandChainMap() error {
+	var chainMap map[string][]*models.AutofillChainObj
+	if err := json.Unmarshal([]byte(r.job.ChainMap), &chainMap); err != nil {
+		return fmt.Errorf("Json unmarshal autofill job chain map fail,%s ", err.Error())
+	}
+	guidList := getAutofillChainGuidList(chainMap)
+	sort.Strings(guidList)
+	var ciTypeList []string
+	for _, rowGuid := range guidList {
+		if ciType := rowGuid[:strings.LastIndex(rowGuid, "_")]; !inStringList(ciType, ciTypeList) {
+			ciTypeList = append(ciTypeList, ciType)
+		}
+	}
+	guidListBytes, _ := json.Marshal(guidList)
+	r.job.GuidList, r.job.TotalNum, r.job.CiType = string(guidListBytes), len(guidList), strings.Join(ciTypeList, ",")
+	_, err := x.Exec("update sys_autofill_job set guid_list=?,total_num=?,ci_type=? where id=?", r.job.GuidList, r.job.TotalNum, r.job.CiType, r.job.Id)
+	if err != nil {
+		return fmt.Errorf("Try to save autofill job guid list fail,%s ", err.Error())
+	}
+	return nil
+}
+
+// handleBatch 逐行重算,单行失败只记录错误,一批处理完保存进度并为有更新的数据建连锁重算任务
+func (r *autofillJobRunner) handleBatch(guidList []string) error {
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	for _, rowGuid := range guidList {
+		ciType := rowGuid[:strings.LastIndex(rowGuid, "_")]
+		updateColumn, err := autofillAffectAction(ciType, rowGuid, r.attrNameList, nowTime)
+		r.job.DoneNum += 1
+		r.job.LastGuid = rowGuid
+		if err != nil {
+			r.job.FailNum += 1
+			r.errorList = append(r.errorList, &models.SysAutofillJobErrorTable{JobId: r.job.Id, CiType: ciType, RowGuid: rowGuid, Message: err.Error(), CreateTime: nowTime})
+		}
+		if len(updateColumn) > 0 {
+			r.job.UpdateNum += 1
+			r.chainMap[ciType] = append(r.chainMap[ciType], &models.AutofillChainObj{Guid: rowGuid, UpdateColumn: updateColumn})
+		}
+	}
+	// 有更新的数据建连锁重算任务,和进度一起保存
+	chainJobAction := buildAutofillChainJobAction(r.chainMap)
+	r.chainMap = make(map[string][]*models.AutofillChainObj)
+	if err := r.saveProgress(chainJobAction); err != nil {
+		return err
+	}
+	if chainJobAction != nil {
+		notifyAutofillJob()
+	}
+	return nil
+}
+
+// saveProgress 只能保存自己执行的任务,任务被其他实例接管后返回错误
+func (r *autofillJobRunner) saveProgress(extraActions ...*execAction) error {
+	var actions []*execAction
+	for _, action := range extraActions {
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+	for _, errObj := range r.errorList {
+		actions = append(actions, &execAction{Sql: "insert into sys_autofill_job_error(job_id,ci_type,row_guid,message,create_time) values (?,?,?,?,?)", Param: []interface{}{errObj.JobId, errObj.CiType, errObj.RowGuid, errObj.Message, errObj.CreateTime}})
+	}
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	actions = append(actions, &execAction{Sql: "update sys_autofill_job set status=?,done_num=?,update_num=?,fail_num=?,last_guid=?,error_message=?,end_time=?,heartbeat_time=?,update_time=? where id=? and owner=?",
+		Param:         []interface{}{r.job.Status, r.job.DoneNum, r.job.UpdateNum, r.job.FailNum, r.job.LastGuid, r.job.ErrorMessage, r.job.EndTime, nowTime, nowTime, r.job.Id, r.job.Owner},
+		CheckAffected: true, AffectedError: fmt.Errorf("Autofill job:%s has been taken over by other instance ", r.job.Id)})
+	if err := transaction(actions); err != nil {
+		return fmt.Errorf("Try to save autofill job:%s progress fail,%s ", r.job.Id, err.Error())
+	}
+	r.errorList = []*models.SysAutofillJobErrorTable{}
+	return nil
+}
+
+// getAutofillJobAttrList 按依赖顺序返回该属性以及同ci类型中依赖它的属性
+func getAutofillJobAttrList(ciType, ciAttr string) (attrNameList []string, err error) {
+	graph, err := loadCiAutofillGraph(nil)
+	if err != nil {
+		return
+	}
+	attr, b := graph.attrMap[ciAttr]
+	if !b {
+		return nil, fmt.Errorf("Can not find ci attribute:%s ", ciAttr)
+	}
+	attrNameList = append(attrNameList, attr.Name)
+	for _, node := range graph.walkLineage(ciAttr, false, make(map[string]*models.CiAutofillGraphEdge)) {
+		if node.CiType == ciType {
+			attrNameList = append(attrNameList, node.Name)
+		}
+	}
+	return
+}
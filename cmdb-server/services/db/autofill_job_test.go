@@ -0,0 +1,90 @@
+//go:build sqlite
+
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestAutofillChainJobCommitWithData(t *testing.T) {
+	target := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "a1", "asset_id": "asset-a1", "key_name": "a1"})
+	source := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "a2", "asset_id": "asset-a2", "key_name": "a2", "depend_host": target[0]["guid"]})
+	beforeNum := countTestRows(t, "select id from sys_autofill_job where job_type=?", models.AutofillJobChain)
+	// 删除多选引用后被删除的引用数据要重算,任务和数据修改一起提交
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": source[0]["guid"], "depend_host": "[]"})
+	job := queryTestRow(t, "select * from sys_autofill_job where job_type=? order by create_time,id", models.AutofillJobChain)
+	if countTestRows(t, "select id from sys_autofill_job where job_type=?", models.AutofillJobChain) != beforeNum+1 || job["status"] != models.AutofillJobPending {
+		t.Fatalf("chain job not match:%v", job)
+	}
+	if !strings.Contains(job["chain_map"], source[0]["guid"]) || job["guid_list"] != "" {
+		t.Fatalf("chain job should save source data:%v", job)
+	}
+	// 没有影响其他数据的更新不建任务
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": source[0]["guid"], "code": "a2new"})
+	if num := countTestRows(t, "select id from sys_autofill_job where job_type=?", models.AutofillJobChain); num != beforeNum+1 {
+		t.Fatalf("update without affect should not create chain job,num:%d", num)
+	}
+	runner := autofillJobRunner{job: &models.SysAutofillJobTable{Id: job["id"], ChainMap: job["chain_map"]}}
+	if err := runner.expandChainMap(); err != nil {
+		t.Fatalf("expand chain map fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from sys_autofill_job where id=?", job["id"]); row["total_num"] != "1" || !strings.Contains(row["guid_list"], target[0]["guid"]) {
+		t.Fatalf("expanded chain job not match:%v", row)
+	}
+}
+
+func TestReclaimStaleAutofillJobs(t *testing.T) {
+	nowTime := time.Now()
+	for _, job := range []struct {
+		Id            string
+		HeartbeatTime string
+	}{
+		{"autofill_job_stale", nowTime.Add(-autofillJobStaleSec * 2 * time.Second).Format(models.DateTimeFormat)},
+		{"autofill_job_alive", nowTime.Format(models.DateTimeFormat)},
+	} {
+		if _, err := x.Exec("insert into sys_autofill_job(id,job_type,ci_type,status,owner,heartbeat_time) values (?,?,?,?,?,?)", job.Id, models.AutofillJobCiType, testCiType, models.AutofillJobRunning, "other_instance", job.HeartbeatTime); err != nil {
+			t.Fatalf("insert job fail,%s", err.Error())
+		}
+	}
+	reclaimStaleAutofillJobs()
+	if row := queryTestRow(t, "select * from sys_autofill_job where id=?", "autofill_job_stale"); row["status"] != models.AutofillJobPending || row["owner"] != "" {
+		t.Fatalf("stale job should be reclaimed:%v", row)
+	}
+	if row := queryTestRow(t, "select * from sys_autofill_job where id=?", "autofill_job_alive"); row["status"] != models.AutofillJobRunning {
+		t.Fatalf("alive job should keep running:%v", row)
+	}
+	runner := autofillJobRunner{job: &models.SysAutofillJobTable{Id: "autofill_job_alive", Status: models.AutofillJobRunning, Owner: jobInstanceId}}
+	if err := runner.saveProgress(); err == nil {
+		t.Fatalf("save progress of job owned by other instance should fail")
+	}
+	x.Exec("delete from sys_autofill_job where id in (?,?)", "autofill_job_stale", "autofill_job_alive")
+}
+
+func TestRunPendingAutofillJobsLimit(t *testing.T) {
+	if _, err := x.Exec("insert into sys_autofill_job(id,job_type,ci_type,status,create_time) values (?,?,?,?,?)", "autofill_job_limit", models.AutofillJobCiType, testCiType, models.AutofillJobPending, "2000-01-01 00:00:00"); err != nil {
+		t.Fatalf("insert job fail,%s", err.Error())
+	}
+	defer x.Exec("delete from sys_autofill_job where id=?", "autofill_job_limit")
+	// 执行位置都被占用时,待执行任务留在队列里不抢占
+	autofillJobWorkerChan = make(chan bool, 1)
+	autofillJobWorkerChan <- true
+	defer func() { autofillJobWorkerChan = nil }()
+	runPendingAutofillJobs()
+	if row := queryTestRow(t, "select * from sys_autofill_job where id=?", "autofill_job_limit"); row["status"] != models.AutofillJobPending || row["owner"] != "" {
+		t.Fatalf("job should keep pending when worker pool is full:%v", row)
+	}
+	if len(autofillJobWorkerChan) != 1 {
+		t.Fatalf("worker pool should not be released,num:%d", len(autofillJobWorkerChan))
+	}
+	job := &models.SysAutofillJobTable{Id: "autofill_job_limit"}
+	if !claimAutofillJob(job) || job.Owner != jobInstanceId || job.StartTime == "" {
+		t.Fatalf("claim pending job fail:%+v", job)
+	}
+	if claimAutofillJob(&models.SysAutofillJobTable{Id: "autofill_job_limit"}) {
+		t.Fatalf("running job should not be claimed again")
+	}
+}
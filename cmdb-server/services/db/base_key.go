@@ -11,7 +11,8 @@ func BaseKeyCatQuery(page, pageSize int) (pageInfo models.PageInfo, rowData []*m
 	pageInfo.StartIndex = page
 	pageInfo.PageSize = pageSize
 	baseSql := "SELECT * FROM sys_basekey_cat "
-	err = x.SQL(baseSql+" LIMIT ?,?", page*pageSize, pageSize).Find(&rowData)
+	pageSql, pageParam := dbDialect.PageSql(page*pageSize, pageSize)
+	err = x.SQL(baseSql+pageSql, pageParam...).Find(&rowData)
 	if err != nil {
 		return
 	}
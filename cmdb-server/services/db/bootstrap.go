@@ -0,0 +1,211 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const defaultInitSqlFile = "../wiki/db/init.sql"
+
+var (
+	mysqlCommentRegexp       = regexp.MustCompile(`(?i)\s+COMMENT\s+'(?:[^']|'')*'`)
+	mysqlCharsetRegexp       = regexp.MustCompile(`(?i)\s+(CHARACTER SET|COLLATE)\s+\w+`)
+	mysqlOnUpdateRegexp      = regexp.MustCompile(`(?i)\s+ON UPDATE CURRENT_TIMESTAMP`)
+	mysqlColumnTypeRegexp    = regexp.MustCompile("(?i)^(`?\\w+`?\\s+)([a-z]+)(\\(([0-9,]+)\\))?(\\s+unsigned)?")
+	mysqlAutoIncrementRegexp = regexp.MustCompile("(?i)^`(\\w+)`.*AUTO_INCREMENT")
+	mysqlKeyRegexp           = regexp.MustCompile("(?i)^(UNIQUE\\s+)?(KEY|INDEX)\\s+`(\\w+)`\\s*(\\(.+\\))")
+	mysqlPrimaryKeyRegexp    = regexp.MustCompile("(?i)^PRIMARY KEY\\s*\\(`(\\w+)`\\)$")
+	mysqlCreateTableRegexp   = regexp.MustCompile("(?is)^CREATE TABLE\\s+`(\\w+)`\\s*\\((.*)\\)[^)]*$")
+	mysqlAddColumnRegexp     = regexp.MustCompile("(?is)^ALTER TABLE\\s+`?(\\w+)`?\\s+ADD\\s+COLUMN\\s+(.+)$")
+	mysqlModifyColumnRegexp  = regexp.MustCompile("(?is)^ALTER TABLE\\s+`?(\\w+)`?\\s+MODIFY\\s+COLUMN\\s+`?(\\w+)`?\\s+([a-z]+)(\\((\\d+)\\))?")
+	mysqlHexLiteralRegexp    = regexp.MustCompile(`([,(]\s*)0x([0-9A-Fa-f]+)\b`)
+)
+
+// bootstrapSchema 数据库里还没有系统表时,把mysql的初始化脚本转换成当前数据库的语句执行,用于sqlite和postgres
+func bootstrapSchema() error {
+	tableListSql, tableListParam := dbDialect.TableListSql(models.Config.Database.DataBase)
+	existTables, err := x.QueryString(append([]interface{}{tableListSql}, tableListParam...)...)
+	if err != nil {
+		return fmt.Errorf("Try to check system table fail,%s ", err.Error())
+	}
+	for _, row := range existTables {
+		if row["TABLE_NAME"] == "sys_ci_type" {
+			return nil
+		}
+	}
+	initSqlFile := models.Config.Database.InitSqlFile
+	if initSqlFile == "" {
+		initSqlFile = defaultInitSqlFile
+	}
+	scriptBytes, err := ioutil.ReadFile(initSqlFile)
+	if err != nil {
+		return fmt.Errorf("Try to read init sql file:%s fail,%s ", initSqlFile, err.Error())
+	}
+	var actions []*execAction
+	for _, statement := range convertMysqlScript(dbDialect, string(scriptBytes)) {
+		actions = append(actions, &execAction{Sql: statement})
+	}
+	if err = transaction(actions); err != nil {
+		return fmt.Errorf("Try to init database schema with %s fail,%s ", initSqlFile, err.Error())
+	}
+	log.Logger.Info("Init database schema success", log.String("file", initSqlFile), log.Int("statements", len(actions)))
+	return nil
+}
+
+// convertMysqlScript 只处理init.sql里出现的mysql写法:建表语句、insert和alter table add/modify column
+func convertMysqlScript(dialect sqlDialect, script string) (result []string) {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		trimLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimLine, "#") || strings.HasPrefix(trimLine, "--") || strings.HasPrefix(trimLine, "/*!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	// postgres建表时要求外键引用的表已经存在,外键统一放到最后创建
+	var foreignKeyList []string
+	var autoIncrementList [][2]string
+	for _, statement := range splitSqlStatements(strings.Join(lines, "\n")) {
+		upperStatement := strings.ToUpper(statement)
+		switch {
+		case strings.HasPrefix(upperStatement, "SET "), strings.HasPrefix(upperStatement, "LOCK TABLES"), strings.HasPrefix(upperStatement, "UNLOCK TABLES"):
+			continue
+		case strings.HasPrefix(upperStatement, "CREATE TABLE"):
+			tableSqlList, tableForeignKeys, autoIncrementColumn := convertMysqlCreateTable(dialect, statement)
+			result = append(result, tableSqlList...)
+			foreignKeyList = append(foreignKeyList, tableForeignKeys...)
+			if autoIncrementColumn != "" {
+				autoIncrementList = append(autoIncrementList, [2]string{mysqlCreateTableRegexp.FindStringSubmatch(statement)[1], autoIncrementColumn})
+			}
+		case strings.HasPrefix(upperStatement, "ALTER TABLE"):
+			statement = mysqlCommentRegexp.ReplaceAllString(statement, "")
+			statement = mysqlCharsetRegexp.ReplaceAllString(statement, "")
+			if modifyMatch := mysqlModifyColumnRegexp.FindStringSubmatch(statement); len(modifyMatch) > 0 {
+				// sqlite不限制字段类型和长度,modify column不需要执行
+				if dialect.Name() == DialectSqlite {
+					continue
+				}
+				dataLength, _ := strconv.Atoi(modifyMatch[5])
+				result = append(result, dialect.ModifyColumnSql(modifyMatch[1], modifyMatch[2], dialect.ColumnType(modifyMatch[3], dataLength), "")...)
+				continue
+			}
+			if addMatch := mysqlAddColumnRegexp.FindStringSubmatch(statement); len(addMatch) > 0 {
+				statement = dialect.AddColumnSql(addMatch[1], convertMysqlColumnType(dialect, addMatch[2]))
+			}
+			result = append(result, dialect.RewriteSql(statement))
+		default:
+			// mysql的0x十六进制二进制数据在sqlite里要写成X'...',postgres里用decode转成bytea
+			if dialect.Name() == DialectPostgres {
+				statement = mysqlHexLiteralRegexp.ReplaceAllString(statement, "${1}decode('${2}','hex')")
+			} else {
+				statement = mysqlHexLiteralRegexp.ReplaceAllString(statement, "${1}X'${2}'")
+			}
+			result = append(result, dialect.RewriteSql(statement))
+		}
+	}
+	result = append(result, foreignKeyList...)
+	if dialect.Name() == DialectPostgres {
+		// 初始化数据里写死了自增id,要把序列调到最大id之后
+		for _, tableColumn := range autoIncrementList {
+			tableName, column := tableColumn[0], tableColumn[1]
+			result = append(result, fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s','%s'),COALESCE(MAX("%s"),0)+1,false) FROM "%s"`, tableName, column, column, tableName))
+		}
+	}
+	return
+}
+
+// splitSqlStatements 按分号拆分语句,忽略单引号里的分号
+func splitSqlStatements(script string) (result []string) {
+	var buf strings.Builder
+	inQuote := false
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		if c == '\'' {
+			inQuote = !inQuote
+		}
+		if c == ';' && !inQuote {
+			if statement := strings.TrimSpace(buf.String()); statement != "" {
+				result = append(result, statement)
+			}
+			buf.Reset()
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	if statement := strings.TrimSpace(buf.String()); statement != "" {
+		result = append(result, statement)
+	}
+	return
+}
+
+// convertMysqlCreateTable 返回建表和建索引语句,postgres的外键单独返回,同时返回自增字段名
+func convertMysqlCreateTable(dialect sqlDialect, statement string) (result, foreignKeyList []string, autoIncrementColumn string) {
+	matchList := mysqlCreateTableRegexp.FindStringSubmatch(statement)
+	if len(matchList) < 3 {
+		return []string{dialect.RewriteSql(statement)}, nil, ""
+	}
+	tableName := matchList[1]
+	var columnList, indexList []string
+	for _, define := range strings.Split(matchList[2], "\n") {
+		define = strings.TrimSuffix(strings.TrimSpace(define), ",")
+		if define == "" {
+			continue
+		}
+		define = mysqlCommentRegexp.ReplaceAllString(define, "")
+		define = mysqlCharsetRegexp.ReplaceAllString(define, "")
+		define = mysqlOnUpdateRegexp.ReplaceAllString(define, "")
+		upperDefine := strings.ToUpper(define)
+		if keyMatch := mysqlKeyRegexp.FindStringSubmatch(define); len(keyMatch) > 0 {
+			if keyMatch[1] != "" {
+				columnList = append(columnList, "UNIQUE "+keyMatch[4])
+			} else {
+				// sqlite和postgres的索引名在整个库内唯一,加上表名前缀
+				indexList = append(indexList, dialect.RewriteSql(fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s_%s` ON `%s` %s", tableName, keyMatch[3], tableName, keyMatch[4])))
+			}
+			continue
+		}
+		if strings.HasPrefix(upperDefine, "PRIMARY KEY") {
+			if pkMatch := mysqlPrimaryKeyRegexp.FindStringSubmatch(define); len(pkMatch) == 0 || pkMatch[1] != autoIncrementColumn {
+				columnList = append(columnList, define)
+			}
+			continue
+		}
+		if strings.HasPrefix(upperDefine, "CONSTRAINT") {
+			if dialect.Name() == DialectPostgres {
+				foreignKeyList = append(foreignKeyList, dialect.RewriteSql(fmt.Sprintf("ALTER TABLE `%s` ADD %s DEFERRABLE INITIALLY IMMEDIATE", tableName, define)))
+			} else {
+				columnList = append(columnList, define)
+			}
+			continue
+		}
+		if autoMatch := mysqlAutoIncrementRegexp.FindStringSubmatch(define); len(autoMatch) > 0 {
+			autoIncrementColumn = autoMatch[1]
+			columnList = append(columnList, dialect.AutoIncrementColumn(autoIncrementColumn))
+			continue
+		}
+		columnList = append(columnList, convertMysqlColumnType(dialect, define))
+	}
+	result = append(result, dialect.RewriteSql(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n  %s\n)", tableName, strings.Join(columnList, ",\n  "))))
+	result = append(result, indexList...)
+	return
+}
+
+// convertMysqlColumnType 把字段定义里的mysql类型换成当前数据库的类型,带精度的类型保持不变
+func convertMysqlColumnType(dialect sqlDialect, define string) string {
+	typeMatch := mysqlColumnTypeRegexp.FindStringSubmatch(define)
+	if len(typeMatch) == 0 || strings.Contains(typeMatch[4], ",") {
+		return define
+	}
+	dataLength, _ := strconv.Atoi(typeMatch[4])
+	columnType := dialect.ColumnType(typeMatch[2], dataLength)
+	if typeMatch[3] == "" {
+		columnType = strings.TrimSuffix(columnType, "(0)")
+	}
+	return typeMatch[1] + columnType + define[len(typeMatch[0]):]
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var updateInitSql = flag.Bool("update", false, "重新生成wiki/db下由init.sql转换的初始化脚本")
+
+func TestConvertMysqlScriptToPostgres(t *testing.T) {
+	scriptBytes, err := ioutil.ReadFile("../../../wiki/db/init.sql")
+	if err != nil {
+		t.Fatalf("read init sql fail,%s", err.Error())
+	}
+	statements := convertMysqlScript(&postgresDialect{}, string(scriptBytes))
+	for _, statement := range statements {
+		if strings.HasPrefix(statement, "INSERT") {
+			continue
+		}
+		if strings.Contains(statement, "`") || strings.Contains(strings.ToUpper(statement), "AUTO_INCREMENT") || strings.Contains(strings.ToLower(statement), "datetime") {
+			t.Fatalf("statement still has mysql syntax:%s", statement)
+		}
+	}
+	// postgres的初始化脚本由init.sql转换生成,init.sql修改后用go test -run TestConvertMysqlScriptToPostgres -update重新生成
+	content := "-- 由init.sql转换生成,不要直接修改\n" + strings.Join(statements, ";\n") + ";\n"
+	postgresFile := "../../../wiki/db/init_postgres.sql"
+	if *updateInitSql {
+		if err = ioutil.WriteFile(postgresFile, []byte(content), 0644); err != nil {
+			t.Fatalf("write postgres init sql fail,%s", err.Error())
+		}
+	}
+	existBytes, err := ioutil.ReadFile(postgresFile)
+	if err != nil {
+		t.Fatalf("read postgres init sql fail,%s", err.Error())
+	}
+	if string(existBytes) != content {
+		t.Fatalf("%s is out of date with init.sql,run the test with -update", postgresFile)
+	}
+}
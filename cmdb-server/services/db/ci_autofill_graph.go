@@ -0,0 +1,317 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// ciAutofillGraph 全模型的自动填充依赖图,边的方向是被用到的属性->自动填充属性
+type ciAutofillGraph struct {
+	attrMap       map[string]*models.SysCiTypeAttrTable
+	attrIdList    []string
+	upstreamMap   map[string][]*models.CiAutofillGraphEdge
+	downstreamMap map[string][]*models.CiAutofillGraphEdge
+	edgeList      []*models.CiAutofillGraphEdge
+}
+
+// loadCiAutofillGraph 查出所有未删除的属性构建依赖图,replaceAttrList中的属性用它的自动填充规则代替库里的(用于保存前检查)
+func loadCiAutofillGraph(replaceAttrList []*models.SysCiTypeAttrTable) (graph *ciAutofillGraph, err error) {
+	var attrList []*models.SysCiTypeAttrTable
+	if err = x.SQL("select id,ci_type,name,display_name,input_type,status,autofillable,autofill_rule from sys_ci_type_attr where status<>'deleted' order by ci_type,ui_form_order").Find(&attrList); err != nil {
+		return nil, fmt.Errorf("Try to query ci attributes fail,%s ", err.Error())
+	}
+	graph = &ciAutofillGraph{attrMap: make(map[string]*models.SysCiTypeAttrTable), upstreamMap: make(map[string][]*models.CiAutofillGraphEdge), downstreamMap: make(map[string][]*models.CiAutofillGraphEdge)}
+	for _, attr := range attrList {
+		graph.attrMap[attr.Id] = attr
+		graph.attrIdList = append(graph.attrIdList, attr.Id)
+	}
+	replaceAttrMap := make(map[string]bool)
+	for _, replaceAttr := range replaceAttrList {
+		replaceAttrMap[replaceAttr.Id] = true
+		if existAttr, b := graph.attrMap[replaceAttr.Id]; b {
+			tmpAttr := *existAttr
+			tmpAttr.AutofillAble, tmpAttr.AutofillRule = replaceAttr.AutofillAble, replaceAttr.AutofillRule
+			graph.attrMap[replaceAttr.Id] = &tmpAttr
+		} else {
+			graph.attrMap[replaceAttr.Id] = replaceAttr
+			graph.attrIdList = append(graph.attrIdList, replaceAttr.Id)
+		}
+	}
+	for _, attrId := range graph.attrIdList {
+		attr := graph.attrMap[attrId]
+		if attr.AutofillAble != "yes" || attr.AutofillRule == "" {
+			continue
+		}
+		dependList, tmpErr := getAutofillRuleDependList(attr.AutofillRule)
+		if tmpErr != nil {
+			if replaceAttrMap[attrId] {
+				return nil, fmt.Errorf("Attribute:%s autofill rule is illegal,%s ", attrId, tmpErr.Error())
+			}
+			log.Logger.Warn("Skip illegal autofill rule in dependency graph", log.String("attr", attrId), log.Error(tmpErr))
+			continue
+		}
+		for _, depend := range dependList {
+			edge := &models.CiAutofillGraphEdge{From: depend.From, To: attrId, Usage: depend.Usage}
+			graph.edgeList = append(graph.edgeList, edge)
+			graph.upstreamMap[attrId] = append(graph.upstreamMap[attrId], edge)
+			graph.downstreamMap[depend.From] = append(graph.downstreamMap[depend.From], edge)
+		}
+	}
+	return
+}
+
+// getAutofillRuleDependList 解析自动填充规则用到的属性,包括每一跳的引用属性、最后取值的属性、过滤条件的属性以及过滤条件里嵌套的自动填充规则
+func getAutofillRuleDependList(ruleString string) (result []*models.CiAutofillGraphEdge, err error) {
+	var ruleList []*models.AutofillObj
+	if err = json.Unmarshal([]byte(ruleString), &ruleList); err != nil {
+		return nil, fmt.Errorf("Json unmarshal autofill rule fail,%s ", err.Error())
+	}
+	existMap := make(map[string]bool)
+	appendDepend := func(attrId, usage string) {
+		if attrId == "" || existMap[attrId] {
+			return
+		}
+		existMap[attrId] = true
+		result = append(result, &models.CiAutofillGraphEdge{From: attrId, Usage: usage})
+	}
+	for _, ruleObj := range ruleList {
+		if ruleObj.Type != "rule" {
+			continue
+		}
+		var valueList []*models.AutofillValueObj
+		if err = json.Unmarshal([]byte(ruleObj.Value), &valueList); err != nil {
+			return nil, fmt.Errorf("Json unmarshal autofill rule value:%s fail,%s ", ruleObj.Value, err.Error())
+		}
+		for i, valueObj := range valueList {
+			if i > 0 && valueObj.ParentRs != nil && strings.Contains(strings.ReplaceAll(valueObj.ParentRs.AttrId, models.SysTableIdConnector, "#"), "#") {
+				attrId := strings.Replace(strings.ReplaceAll(valueObj.ParentRs.AttrId, models.SysTableIdConnector, "#"), "#", models.SysTableIdConnector, 1)
+				if i == len(valueList)-1 && valueObj.ParentRs.IsReferedFromParent == 1 {
+					appendDepend(attrId, models.AutofillDependValue)
+				} else {
+					appendDepend(attrId, models.AutofillDependRef)
+				}
+			}
+			for _, filter := range valueObj.Filters {
+				if filter.Name != "" {
+					appendDepend(valueObj.CiTypeId+models.SysTableIdConnector+filter.Name, models.AutofillDependFilter)
+				}
+				if filter.Type != "autoFill" {
+					continue
+				}
+				subRule, _ := filter.Value.(string)
+				if subRule == "" {
+					continue
+				}
+				subDependList, subErr := getAutofillRuleDependList(subRule)
+				if subErr != nil {
+					return nil, subErr
+				}
+				for _, subDepend := range subDependList {
+					appendDepend(subDepend.From, models.AutofillDependFilter)
+				}
+			}
+		}
+	}
+	return
+}
+
+// findCycle 沿依赖方向从attrId出发找回到自身的路径,找到时返回 attrId -> ... -> attrId
+func (g *ciAutofillGraph) findCycle(attrId string) []string {
+	visited := make(map[string]bool)
+	var path []string
+	var walk func(current string) bool
+	walk = func(current string) bool {
+		for _, edge := range g.upstreamMap[current] {
+			if edge.From == attrId {
+				path = append(path, edge.From)
+				return true
+			}
+			if visited[edge.From] {
+				continue
+			}
+			visited[edge.From] = true
+			path = append(path, edge.From)
+			if walk(edge.From) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	path = append(path, attrId)
+	if walk(attrId) {
+		return path
+	}
+	return nil
+}
+
+// findAllCycles 每个环只返回一次,从环上id最小的属性开始
+func (g *ciAutofillGraph) findAllCycles() (cycles [][]string) {
+	cycles = [][]string{}
+	existMap := make(map[string]bool)
+	for _, attrId := range g.attrIdList {
+		cyclePath := g.findCycle(attrId)
+		if len(cyclePath) == 0 {
+			continue
+		}
+		minIndex := 0
+		for i, v := range cyclePath[:len(cyclePath)-1] {
+			if v < cyclePath[minIndex] {
+				minIndex = i
+			}
+		}
+		normalPath := append(append([]string{}, cyclePath[minIndex:len(cyclePath)-1]...), cyclePath[:minIndex+1]...)
+		cycleKey := strings.Join(normalPath, ",")
+		if existMap[cycleKey] {
+			continue
+		}
+		existMap[cycleKey] = true
+		cycles = append(cycles, normalPath)
+	}
+	return
+}
+
+func (g *ciAutofillGraph) buildNode(attrId string, depth int) *models.CiAutofillGraphNode {
+	node := models.CiAutofillGraphNode{Id: attrId, Depth: depth}
+	if attr, b := g.attrMap[attrId]; b {
+		node.CiType, node.Name, node.DisplayName, node.InputType, node.Status = attr.CiType, attr.Name, attr.DisplayName, attr.InputType, attr.Status
+		node.Autofill = attr.AutofillAble == "yes" && attr.AutofillRule != ""
+	} else if splitIndex := strings.Index(attrId, models.SysTableIdConnector); splitIndex > 0 {
+		// 规则里用到了已删除或不存在的属性
+		node.CiType, node.Name = attrId[:splitIndex], attrId[splitIndex+len(models.SysTableIdConnector):]
+	}
+	return &node
+}
+
+// walkLineage 按层遍历,isUpstream为true时找填充该属性用到的属性,否则找该属性变化会影响到的自动填充属性
+func (g *ciAutofillGraph) walkLineage(attrId string, isUpstream bool, edgeMap map[string]*models.CiAutofillGraphEdge) (nodes []*models.CiAutofillGraphNode) {
+	nodes = []*models.CiAutofillGraphNode{}
+	visited := map[string]bool{attrId: true}
+	frontier := []string{attrId}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var nextFrontier []string
+		for _, current := range frontier {
+			edgeList := g.downstreamMap[current]
+			if isUpstream {
+				edgeList = g.upstreamMap[current]
+			}
+			for _, edge := range edgeList {
+				edgeMap[edge.From+models.SEPERATOR+edge.To] = edge
+				nextId := edge.To
+				if isUpstream {
+					nextId = edge.From
+				}
+				if visited[nextId] {
+					continue
+				}
+				visited[nextId] = true
+				nodes = append(nodes, g.buildNode(nextId, depth))
+				nextFrontier = append(nextFrontier, nextId)
+			}
+		}
+		frontier = nextFrontier
+	}
+	return
+}
+
+// checkAutofillRuleCycle 属性新增或修改前检查自动填充规则是否会形成循环依赖
+func checkAutofillRuleCycle(param *models.SysCiTypeAttrTable) error {
+	if param.AutofillAble != "yes" || param.AutofillRule == "" {
+		return nil
+	}
+	graph, err := loadCiAutofillGraph([]*models.SysCiTypeAttrTable{param})
+	if err != nil {
+		return err
+	}
+	if cyclePath := graph.findCycle(param.Id); len(cyclePath) > 0 {
+		return fmt.Errorf("Attribute:%s autofill rule has cyclic dependency:%s ", param.Id, strings.Join(cyclePath, " -> "))
+	}
+	return nil
+}
+
+// checkAutofillRuleCycleList 模型包导入前用包里所有属性的自动填充规则替换后检查,只报和包里属性有关的环
+func checkAutofillRuleCycleList(attrList []*models.SysCiTypeAttrTable) error {
+	if len(attrList) == 0 {
+		return nil
+	}
+	graph, err := loadCiAutofillGraph(attrList)
+	if err != nil {
+		return err
+	}
+	attrMap := make(map[string]bool)
+	for _, attr := range attrList {
+		attrMap[attr.Id] = true
+	}
+	for _, cyclePath := range graph.findAllCycles() {
+		for _, attrId := range cyclePath {
+			if attrMap[attrId] {
+				return fmt.Errorf("Attribute:%s autofill rule has cyclic dependency:%s ", attrId, strings.Join(cyclePath, " -> "))
+			}
+		}
+	}
+	return nil
+}
+
+// GetCiAutofillGraph ciType不为空时只返回和该ci类型属性相关的边
+func GetCiAutofillGraph(ciType string) (result models.CiAutofillGraph, err error) {
+	result = models.CiAutofillGraph{Nodes: []*models.CiAutofillGraphNode{}, Edges: []*models.CiAutofillGraphEdge{}}
+	graph, err := loadCiAutofillGraph(nil)
+	if err != nil {
+		return
+	}
+	nodeMap := make(map[string]bool)
+	var nodeIdList []string
+	for _, edge := range graph.edgeList {
+		if ciType != "" && !strings.HasPrefix(edge.From, ciType+models.SysTableIdConnector) && !strings.HasPrefix(edge.To, ciType+models.SysTableIdConnector) {
+			continue
+		}
+		result.Edges = append(result.Edges, edge)
+		for _, attrId := range []string{edge.From, edge.To} {
+			if !nodeMap[attrId] {
+				nodeMap[attrId] = true
+				nodeIdList = append(nodeIdList, attrId)
+			}
+		}
+	}
+	sort.Strings(nodeIdList)
+	for _, attrId := range nodeIdList {
+		result.Nodes = append(result.Nodes, graph.buildNode(attrId, 0))
+	}
+	result.Cycles = [][]string{}
+	for _, cyclePath := range graph.findAllCycles() {
+		for _, attrId := range cyclePath {
+			if nodeMap[attrId] {
+				result.Cycles = append(result.Cycles, cyclePath)
+				break
+			}
+		}
+	}
+	return
+}
+
+// GetCiAttrAutofillLineage 返回填充该属性用到的所有上游属性,以及该属性变化后会连锁重算的所有下游自动填充属性
+func GetCiAttrAutofillLineage(ciAttr string) (result models.CiAutofillLineage, err error) {
+	graph, err := loadCiAutofillGraph(nil)
+	if err != nil {
+		return
+	}
+	if _, b := graph.attrMap[ciAttr]; !b {
+		err = fmt.Errorf("Can not find ci attribute:%s ", ciAttr)
+		return
+	}
+	edgeMap := make(map[string]*models.CiAutofillGraphEdge)
+	result = models.CiAutofillLineage{CiAttr: ciAttr, Edges: []*models.CiAutofillGraphEdge{}}
+	result.Upstream = graph.walkLineage(ciAttr, true, edgeMap)
+	result.Downstream = graph.walkLineage(ciAttr, false, edgeMap)
+	for _, edge := range graph.edgeList {
+		if _, b := edgeMap[edge.From+models.SEPERATOR+edge.To]; b {
+			result.Edges = append(result.Edges, edge)
+		}
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// buildTestAutofillRule 按界面保存的格式生成自动填充规则,valueList是每一跳的配置
+func buildTestAutofillRule(t *testing.T, valueList []*models.AutofillValueObj) string {
+	t.Helper()
+	valueBytes, err := json.Marshal(valueList)
+	if err != nil {
+		t.Fatalf("json marshal rule value fail,%s", err.Error())
+	}
+	ruleBytes, err := json.Marshal([]*models.AutofillObj{{Type: "rule", Value: string(valueBytes)}, {Type: "delimiter", Value: "-"}})
+	if err != nil {
+		t.Fatalf("json marshal rule fail,%s", err.Error())
+	}
+	return string(ruleBytes)
+}
+
+// buildTestAutofillGraph 手工构建依赖图,edges中每一项是 被用到的属性->自动填充属性
+func buildTestAutofillGraph(edges [][2]string) *ciAutofillGraph {
+	graph := &ciAutofillGraph{attrMap: make(map[string]*models.SysCiTypeAttrTable), upstreamMap: make(map[string][]*models.CiAutofillGraphEdge), downstreamMap: make(map[string][]*models.CiAutofillGraphEdge)}
+	for _, edgeObj := range edges {
+		for _, attrId := range edgeObj {
+			if _, b := graph.attrMap[attrId]; b {
+				continue
+			}
+			splitIndex := strings.Index(attrId, models.SysTableIdConnector)
+			graph.attrMap[attrId] = &models.SysCiTypeAttrTable{Id: attrId, CiType: attrId[:splitIndex], Name: attrId[splitIndex+len(models.SysTableIdConnector):]}
+			graph.attrIdList = append(graph.attrIdList, attrId)
+		}
+		edge := &models.CiAutofillGraphEdge{From: edgeObj[0], To: edgeObj[1], Usage: models.AutofillDependValue}
+		graph.edgeList = append(graph.edgeList, edge)
+		graph.upstreamMap[edge.To] = append(graph.upstreamMap[edge.To], edge)
+		graph.downstreamMap[edge.From] = append(graph.downstreamMap[edge.From], edge)
+	}
+	return graph
+}
+
+func TestGetAutofillRuleDependList(t *testing.T) {
+	subRule := buildTestAutofillRule(t, []*models.AutofillValueObj{{CiTypeId: "host"}, {CiTypeId: "host", ParentRs: &models.AutofillValueAttrObj{AttrId: "host__env", IsReferedFromParent: 1}}})
+	rule := buildTestAutofillRule(t, []*models.AutofillValueObj{
+		{CiTypeId: "app"},
+		{CiTypeId: "unit", ParentRs: &models.AutofillValueAttrObj{AttrId: "app__unit", IsReferedFromParent: 1}, Filters: []*models.AutofillFilterObj{{Name: "env", Type: "autoFill", Value: subRule}}},
+		{CiTypeId: "unit", ParentRs: &models.AutofillValueAttrObj{AttrId: "unit__code", IsReferedFromParent: 1}},
+	})
+	dependList, err := getAutofillRuleDependList(rule)
+	if err != nil {
+		t.Fatalf("get depend list fail,%s", err.Error())
+	}
+	var dependStringList []string
+	for _, depend := range dependList {
+		dependStringList = append(dependStringList, depend.From+":"+depend.Usage)
+	}
+	// 中间跳是引用,最后一跳是取值,过滤条件和其中嵌套的规则用到的属性都算过滤
+	if dependString := strings.Join(dependStringList, ","); dependString != "app__unit:ref,unit__env:filter,host__env:filter,unit__code:value" {
+		t.Fatalf("depend list not match:%s", dependString)
+	}
+	if _, err = getAutofillRuleDependList("[{"); err == nil {
+		t.Fatalf("illegal rule should return error")
+	}
+}
+
+func TestCiAutofillGraphCycle(t *testing.T) {
+	graph := buildTestAutofillGraph([][2]string{{"a__x", "b__x"}, {"b__x", "c__x"}, {"c__x", "a__x"}, {"d__x", "a__x"}})
+	if cyclePath := strings.Join(graph.findCycle("a__x"), ","); cyclePath != "a__x,c__x,b__x,a__x" {
+		t.Fatalf("cycle path not match:%s", cyclePath)
+	}
+	if cyclePath := graph.findCycle("d__x"); len(cyclePath) != 0 {
+		t.Fatalf("attribute out of cycle should not find cycle:%v", cyclePath)
+	}
+	// 环上的每个属性都能找到同一个环,只返回一次并从id最小的属性开始
+	cycles := graph.findAllCycles()
+	if len(cycles) != 1 || strings.Join(cycles[0], ",") != "a__x,c__x,b__x,a__x" {
+		t.Fatalf("all cycles not match:%v", cycles)
+	}
+	if cycles = buildTestAutofillGraph([][2]string{{"a__x", "b__x"}}).findAllCycles(); len(cycles) != 0 {
+		t.Fatalf("graph without cycle should return empty:%v", cycles)
+	}
+}
+
+func TestCiAutofillGraphLineage(t *testing.T) {
+	graph := buildTestAutofillGraph([][2]string{{"a__x", "b__x"}, {"b__x", "c__x"}, {"a__x", "c__x"}, {"c__x", "d__x"}})
+	nodeString := func(nodes []*models.CiAutofillGraphNode) string {
+		var nodeList []string
+		for _, node := range nodes {
+			nodeList = append(nodeList, node.Id+":"+strings.Repeat("*", node.Depth))
+		}
+		return strings.Join(nodeList, ",")
+	}
+	edgeMap := make(map[string]*models.CiAutofillGraphEdge)
+	// 按层遍历,已经在上一层出现过的属性不会重复
+	if upstream := nodeString(graph.walkLineage("c__x", true, edgeMap)); upstream != "b__x:*,a__x:*" {
+		t.Fatalf("upstream not match:%s", upstream)
+	}
+	if downstream := nodeString(graph.walkLineage("a__x", false, edgeMap)); downstream != "b__x:*,c__x:*,d__x:**" {
+		t.Fatalf("downstream not match:%s", downstream)
+	}
+	if len(edgeMap) != 4 {
+		t.Fatalf("lineage edge num:%d", len(edgeMap))
+	}
+	if node := graph.buildNode("e__deleted", 0); node.CiType != "e" || node.Name != "deleted" {
+		t.Fatalf("node of missing attribute not match:%+v", node)
+	}
+}
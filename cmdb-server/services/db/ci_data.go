@@ -17,6 +17,104 @@ import (
 )
 
 func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	return handleCiDataOperation(param, nil)
+}
+
+// HandleCiDataOperationList 多个操作分别校验生成语句后放在同一个事务里提交,任意一个失败都不会写入
+func HandleCiDataOperationList(paramList []models.HandleCiDataParam) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	return handleCiDataOperationList(paramList, nil)
+}
+
+// handleCiDataOperationList preActions会在事务开始时先执行,用于加锁和检查
+func handleCiDataOperationList(paramList []models.HandleCiDataParam, preActions []*execAction) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	opList := []*ciDataOperationObj{{Actions: preActions}}
+	newInputList := []interface{}{}
+	for _, param := range paramList {
+		op, buildErr := buildCiDataOperation(param, nil)
+		if buildErr != nil {
+			err = buildErr
+			return
+		}
+		opList = append(opList, op)
+		var tmpInputList []interface{}
+		if json.Unmarshal([]byte(op.NewInputBody), &tmpInputList) == nil {
+			newInputList = append(newInputList, tmpInputList...)
+		}
+	}
+	newInputBytes, _ := json.Marshal(newInputList)
+	newInputBody = string(newInputBytes)
+	if err = commitCiDataOperationList(opList); err != nil {
+		return
+	}
+	outputData = []models.CiDataMapObj{}
+	for _, op := range opList {
+		outputData = append(outputData, op.OutputData...)
+	}
+	return
+}
+
+// ciDataOperationObj 一次操作校验后生成的事务语句,以及提交成功后要做的处理
+type ciDataOperationObj struct {
+	FirstAction      string
+	MultiCiData      []*models.MultiCiDataObj
+	Actions          []*execAction
+	AutofillChainMap map[string][]*models.AutofillChainObj
+	UniquePathList   []*models.AutoActiveHandleParam
+	OutputData       []models.CiDataMapObj
+	NewInputBody     string
+}
+
+// handleCiDataOperation plan不为空时为dryRun模式,只做校验和计算不提交
+func handleCiDataOperation(param models.HandleCiDataParam, plan *models.CiDataOperationPlan) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	op, err := buildCiDataOperation(param, plan)
+	outputData, newInputBody = op.OutputData, op.NewInputBody
+	if err != nil || plan != nil {
+		return
+	}
+	if err = commitCiDataOperationList([]*ciDataOperationObj{op}); err == nil {
+		outputData = op.OutputData
+	}
+	return
+}
+
+// commitCiDataOperationList 把所有操作的语句和连锁重算任务放在一个事务里提交,成功后再通知搜索索引、自动填充任务和唯一路径
+func commitCiDataOperationList(opList []*ciDataOperationObj) (err error) {
+	var actions []*execAction
+	chainJobNum := 0
+	for _, op := range opList {
+		actions = append(actions, op.Actions...)
+		// 搜索索引的待更新记录也一起提交,提交成功后索引一定会更新
+		actions = append(actions, buildCiSearchDirtyActionsByCiData(op.MultiCiData)...)
+		// 连锁重算任务和数据修改一起提交,不会因为进程退出丢失
+		if chainJobAction := buildAutofillChainJobAction(op.AutofillChainMap); chainJobAction != nil {
+			actions = append(actions, chainJobAction)
+			chainJobNum += 1
+		}
+	}
+	if err = transaction(actions); err != nil {
+		err = rebuildCiDataConflictError(err)
+		return
+	}
+	if chainJobNum > 0 {
+		notifyAutofillJob()
+	}
+	notifySearchIndex()
+	for _, op := range opList {
+		if len(op.UniquePathList) > 0 {
+			uniquePathHandleChan <- op.UniquePathList
+		}
+		if op.FirstAction == "insert" {
+			if op.OutputData, err = fetchNewRowData(op.MultiCiData); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// buildCiDataOperation 校验输入并生成事务语句,返回的op不为空
+func buildCiDataOperation(param models.HandleCiDataParam, plan *models.CiDataOperationPlan) (op *ciDataOperationObj, err error) {
+	op = &ciDataOperationObj{}
 	var multiCiData []*models.MultiCiDataObj
 	var firstAction string
 	var deleteList []string
@@ -78,7 +176,7 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 					inputRowData["state"] = tmpHistoryObj["state"]
 				}
 				if tmpRowGuid == "" {
-					err = fmt.Errorf("Row:%d data can not find guid ", i)
+					err = &models.CiDataRowError{Message: fmt.Sprintf("Row:%d data can not find guid ", i)}
 					break
 				}
 			}
@@ -117,7 +215,7 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 	if err = getMultiCiAttributes(multiCiData); err != nil {
 		return
 	}
-	outputData, newInputBody = buildRequestBodyWithoutPwd(multiCiData, param.BareAction, tNow, param.Operation)
+	op.OutputData, op.NewInputBody = buildRequestBodyWithoutPwd(multiCiData, param.BareAction, tNow, param.Operation)
 	// 获取状态机
 	if param.BareAction == "" {
 		if err = getMultiCiTransition(multiCiData); err != nil {
@@ -152,10 +250,17 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 	var insertPermissionMap = make(map[string]*InsertPermissionObj)
 	var autofillChainMap = make(map[string][]*models.AutofillChainObj)
 	var uniquePathList []*models.AutoActiveHandleParam
+	var webhookEventList []*models.CiDataWebhookEvent
+	var guardRejectList []string
+	var confirmRejectList []string
+	var conflictRowList []*models.CiDataConflictRow
+	confirmCiTypeMap := make(map[string]*models.SysCiTypeTable)
 	deleteUniquePath := models.AutoActiveHandleParam{User: models.SystemUser}
+	outputIndex := -1
 	for _, ciObj := range multiCiData {
 		for i, inputRowData := range ciObj.InputData {
-			actionParam := models.ActionFuncParam{CiType: ciObj.CiTypeId, InputData: inputRowData, Attributes: ciObj.Attributes, ReferenceAttributes: ciObj.ReferenceAttributes, Operator: param.Operator, Operation: param.Operation, NowTime: tNow, RefCiTypeMap: ciObj.RefCiTypeMap, DeleteList: deleteList, FromCore: param.FromCore}
+			outputIndex += 1
+			actionParam := models.ActionFuncParam{CiType: ciObj.CiTypeId, InputData: inputRowData, Attributes: ciObj.Attributes, ReferenceAttributes: ciObj.ReferenceAttributes, Operator: param.Operator, Operation: param.Operation, NowTime: tNow, RefCiTypeMap: ciObj.RefCiTypeMap, DeleteList: deleteList, FromCore: param.FromCore, DryRun: plan != nil}
 			// 检查数据目标状态
 			if param.BareAction != "" {
 				if param.BareAction == "insert" {
@@ -195,18 +300,65 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 					}
 				}
 				if actionParam.Transition == nil {
-					err = fmt.Errorf("CiType:%s key_name:%s guid:%s data can not find target state from transition ", ciObj.CiTypeId, inputRowData["key_name"], inputRowData["guid"])
+					err = &models.CiDataRowError{Message: fmt.Sprintf("CiType:%s key_name:%s guid:%s data can not find target state from transition ", ciObj.CiTypeId, inputRowData["key_name"], inputRowData["guid"])}
 					break
 				}
+				// 迁移守卫条件不满足的行先记录原因,所有行检查完再统一拒绝
+				rejectMessage, tmpErr := checkTransitionGuard(&actionParam)
+				if tmpErr != nil {
+					err = fmt.Errorf("CiType:%s key_name:%s check transition guard fail,%s ", ciObj.CiTypeId, inputRowData["key_name"], tmpErr.Error())
+					break
+				}
+				if rejectMessage != "" {
+					rejectKeyName := inputRowData["key_name"]
+					if rejectKeyName == "" && actionParam.NowData != nil {
+						rejectKeyName = actionParam.NowData["key_name"]
+					}
+					guardRejectList = append(guardRejectList, fmt.Sprintf("Row:%s guid:%s operation:%s reject,%s", rejectKeyName, inputRowData["guid"], param.Operation, rejectMessage))
+					continue
+				}
+				if actionParam.Transition.Action == "confirm" {
+					if _, b := confirmCiTypeMap[ciObj.CiTypeId]; !b {
+						if confirmCiTypeMap[ciObj.CiTypeId], err = GetCiTypeById(ciObj.CiTypeId); err != nil {
+							break
+						}
+					}
+					rejectMessage, tmpErr = checkConfirmPolicy(confirmCiTypeMap[ciObj.CiTypeId], inputRowData["guid"], param.Operator, param.Roles)
+					if tmpErr != nil {
+						err = fmt.Errorf("CiType:%s guid:%s check confirm policy fail,%s ", ciObj.CiTypeId, inputRowData["guid"], tmpErr.Error())
+						break
+					}
+					if rejectMessage != "" {
+						confirmRejectList = append(confirmRejectList, fmt.Sprintf("Row:%s guid:%s reject,%s", actionParam.NowData["key_name"], inputRowData["guid"], rejectMessage))
+						continue
+					}
+				}
+			}
+			var planBeforeData, planInputData models.CiDataMapObj
+			if plan != nil {
+				planBeforeData, planInputData = copyCiDataMap(actionParam.NowData), copyCiDataMap(inputRowData)
 			}
 			// 处理输入,把参数变成对应的SQL加进事务里
 			tmpAction, tmpErr := doActionFunc(&actionParam)
 			if tmpErr != nil {
-				err = fmt.Errorf("CiType:%s do action:%s fail,%s ", ciObj.CiTypeId, actionParam.Transition.Action, tmpErr.Error())
+				// 并发修改冲突的行先记录,所有行检查完再统一返回
+				if conflictRow, ok := tmpErr.(*models.CiDataConflictRow); ok {
+					conflictRowList = append(conflictRowList, conflictRow)
+					continue
+				}
+				err = &models.CiDataRowError{Message: fmt.Sprintf("CiType:%s do action:%s fail,%s ", ciObj.CiTypeId, actionParam.Transition.Action, tmpErr.Error())}
 				break
 			}
 			//outputData = append(outputData, actionParam.InputData)
 			actions = append(actions, tmpAction...)
+			// 同一秒内修改过的数据update_time会往后顺延,返回实际保存的值
+			if actionParam.Transition.Action == "update" && outputIndex < len(op.OutputData) {
+				op.OutputData[outputIndex]["update_time"] = actionParam.InputData["update_time"]
+			}
+			webhookEventList = append(webhookEventList, buildCiDataWebhookEvent(&actionParam))
+			if plan != nil {
+				plan.Rows = append(plan.Rows, buildCiDataPlanRow(&actionParam, planBeforeData, planInputData))
+			}
 			if actionParam.Transition.Action == "insert" && param.Permission {
 				if _, b := insertPermissionMap[ciObj.CiTypeId]; b {
 					insertPermissionMap[ciObj.CiTypeId].GuidList = append(insertPermissionMap[ciObj.CiTypeId].GuidList, actionParam.InputData["guid"])
@@ -256,6 +408,15 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 			break
 		}
 	}
+	if err == nil && len(guardRejectList) > 0 {
+		err = &models.CiDataRowError{Message: fmt.Sprintf("Transition guard reject %d rows: %s ", len(guardRejectList), strings.Join(guardRejectList, "; "))}
+	}
+	if err == nil && len(confirmRejectList) > 0 {
+		err = &models.CiDataRowError{Message: fmt.Sprintf("Confirm policy reject %d rows: %s ", len(confirmRejectList), strings.Join(confirmRejectList, "; "))}
+	}
+	if err == nil && len(conflictRowList) > 0 {
+		err = &models.CiDataConflictError{Rows: conflictRowList}
+	}
 	if err == nil {
 		if len(insertPermissionMap) > 0 {
 			err = ValidateInsertPermission(insertPermissionMap, param.Roles)
@@ -263,21 +424,24 @@ func HandleCiDataOperation(param models.HandleCiDataParam) (outputData []models.
 				return
 			}
 		}
-		err = transaction(actions)
-	}
-	if err == nil {
-		if len(autofillChainMap) > 0 {
-			affectGuidListChan <- autofillChainMap
+		if plan != nil {
+			if len(deleteUniquePath.Data) > 0 {
+				uniquePathList = append(uniquePathList, &deleteUniquePath)
+			}
+			plan.UniquePaths = buildCiDataPlanUniquePath(uniquePathList)
+			plan.AutofillAttributes, err = buildCiDataPlanAutofill(autofillChainMap)
+			return
+		}
+		webhookActions, tmpErr := getWebhookEventActions(webhookEventList)
+		if tmpErr != nil {
+			err = tmpErr
+			return
 		}
 		if len(deleteUniquePath.Data) > 0 {
 			uniquePathList = append(uniquePathList, &deleteUniquePath)
 		}
-		if len(uniquePathList) > 0 {
-			uniquePathHandleChan <- uniquePathList
-		}
-		if firstAction == "insert" {
-			outputData, err = fetchNewRowData(multiCiData)
-		}
+		op.FirstAction, op.MultiCiData, op.AutofillChainMap, op.UniquePathList = firstAction, multiCiData, autofillChainMap, uniquePathList
+		op.Actions = append(actions, webhookActions...)
 	}
 	return
 }
@@ -378,6 +542,7 @@ func insertActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 
 func updateActionFunc(param *models.ActionFuncParam) (result []*execAction, err error) {
 	rollbackFlag := false
+	checkUpdateTime := ""
 	if strings.ToLower(param.Operation) == models.RollbackAction {
 		rollbackFlag = true
 	}
@@ -405,9 +570,10 @@ func updateActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 	} else {
 		if param.InputData["update_time"] != "" {
 			if param.InputData["update_time"] != param.NowData["update_time"] {
-				err = fmt.Errorf("Row:%s update_time is diff with database ", param.NowData["key_name"])
+				err = buildCiDataConflictRow(param)
 				return
 			}
+			checkUpdateTime = param.InputData["update_time"]
 		}
 	}
 	if param.BareAction == "" {
@@ -444,7 +610,7 @@ func updateActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 			buildValueParam.IsSystem = true
 		}
 		if ciAttr.Name == "update_time" {
-			param.InputData["update_time"] = param.NowTime
+			param.InputData["update_time"] = nextCiDataUpdateTime(param.NowTime, param.NowData["update_time"])
 			buildValueParam.IsSystem = true
 		}
 		if ciAttr.Name == "state" {
@@ -503,7 +669,15 @@ func updateActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 		columnList = append(columnList, &models.CiDataColumnObj{ColumnName: "confirm_time", ColumnValue: param.InputData["confirm_time"]})
 	}
 	if err == nil {
-		result = append(result, getUpdateActionByColumnList(columnList, param.CiType, param.InputData["guid"]))
+		updateAction := getUpdateActionByColumnList(columnList, param.CiType, param.InputData["guid"])
+		if checkUpdateTime != "" {
+			// 读取数据到提交之间可能被其他请求修改,提交时再用update_time做一次检查
+			updateAction.Sql += " and update_time=?"
+			updateAction.Param = append(updateAction.Param, checkUpdateTime)
+			updateAction.CheckAffected = true
+			updateAction.AffectedError = &ciDataUpdateConflict{CiType: param.CiType, Guid: param.InputData["guid"], InputUpdateTime: checkUpdateTime, Attributes: param.Attributes}
+		}
+		result = append(result, updateAction)
 		result = append(result, getHistoryActionByData(param.InputData, param.CiType, param.NowTime, param.Transition))
 	}
 	if !rollbackFlag && param.BareAction == "" {
@@ -532,8 +706,8 @@ func confirmActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 		}
 		if ciAttr.Name == "update_time" {
 			if oldConfirmTime == "" {
-				param.NowData["update_time"] = param.NowTime
-				columnList = append(columnList, &models.CiDataColumnObj{ColumnName: "update_time", ColumnValue: param.NowTime})
+				param.NowData["update_time"] = nextCiDataUpdateTime(param.NowTime, param.NowData["update_time"])
+				columnList = append(columnList, &models.CiDataColumnObj{ColumnName: "update_time", ColumnValue: param.NowData["update_time"]})
 			}
 			continue
 		}
@@ -631,16 +805,35 @@ func executeActionFunc(param *models.ActionFuncParam) (result []*execAction, err
 	param.NowData = cleanInputData(param.NowData, param.Attributes)
 	result = append(result, getUpdateActionByColumnList(columnList, param.CiType, param.InputData["guid"]))
 	result = append(result, getHistoryActionByData(param.NowData, param.CiType, param.NowTime, param.Transition))
+	if param.DryRun {
+		return
+	}
 	err = StartCiDataCallback(models.CiDataCallbackParam{RowGuid: param.InputData["guid"], ProcessName: param.InputData["procDefName"], ProcessKey: param.InputData["procDefKey"], CiType: param.CiType, UserToken: param.InputData["Authorization"]})
 	return
 }
 
 func autofillAffectActionFunc(ciTypeId, guid, nowTime string) {
+	updateColumn, err := autofillAffectAction(ciTypeId, guid, nil, nowTime)
+	if err != nil {
+		log.Logger.Error("Try to auto refresh autofill data fail", log.String("guid", guid), log.Error(err))
+	}
+	if len(updateColumn) > 0 {
+		var autofillChainMap = make(map[string][]*models.AutofillChainObj)
+		autofillChainMap[ciTypeId] = []*models.AutofillChainObj{&models.AutofillChainObj{Guid: guid, UpdateColumn: updateColumn}}
+		if _, err = createAutofillChainJob(autofillChainMap); err != nil {
+			log.Logger.Error("Try to create autofill chain job fail", log.String("guid", guid), log.Error(err))
+		}
+	}
+}
+
+// autofillAffectAction 重算一行数据的强制自动填充属性,attrNameList不为空时只按顺序重算这些属性
+// 部分属性计算失败时其它属性照常更新,返回更新了的列和计算失败的错误
+func autofillAffectAction(ciTypeId, guid string, attrNameList []string, nowTime string) (updateColumn []string, err error) {
 	// get attribute
 	var attrTable []*models.SysCiTypeAttrTable
-	err := x.SQL("select * from sys_ci_type_attr where ci_type=?", ciTypeId).Find(&attrTable)
+	err = x.SQL("select * from sys_ci_type_attr where ci_type=?", ciTypeId).Find(&attrTable)
 	if err != nil {
-		log.Logger.Error("Try to auto refresh autofill,get attributes data fail", log.String("ciTypeId", ciTypeId), log.Error(err))
+		err = fmt.Errorf("Try to get ci:%s attributes fail,%s ", ciTypeId, err.Error())
 		return
 	}
 	if len(attrTable) == 0 {
@@ -648,10 +841,9 @@ func autofillAffectActionFunc(ciTypeId, guid, nowTime string) {
 		return
 	}
 	// get now data
-	//nowDataList, err := x.QueryString(fmt.Sprintf("select * from %s%s where guid='%s' order by id desc limit 1", HistoryTablePrefix, ciTypeId, guid))
-	nowDataList, err := x.QueryString(fmt.Sprintf("select * from %s where guid='%s'", ciTypeId, guid))
+	nowDataList, err := x.QueryString(fmt.Sprintf("select * from %s where guid=?", ciTypeId), guid)
 	if err != nil {
-		log.Logger.Error("Try to auto refresh autofill,get ci data fail", log.String("guid", guid), log.Error(err))
+		err = fmt.Errorf("Try to get ci data fail,%s ", err.Error())
 		return
 	}
 	if len(nowDataList) == 0 {
@@ -662,17 +854,15 @@ func autofillAffectActionFunc(ciTypeId, guid, nowTime string) {
 	nowData := nowDataList[0]
 	log.Logger.Info("autofill now data", log.JsonObj("nowData", nowData))
 	var updateColumnList []*models.CiDataColumnObj
-	var multiRefColumn, updateColumn []string
+	var multiRefColumn, buildErrList []string
 	for _, attr := range attrTable {
 		if attr.InputType == models.MultiRefType {
 			multiRefData, tmpErr := queryMultiRefMapData(ciTypeId, attr.Name, []string{guid})
-			//multiRefData, tmpErr := x.QueryString(fmt.Sprintf("select GROUP_CONCAT(to_guid) as to_guid from %s$%s where from_guid=? group by from_guid", ciTypeId, attr.Name), guid)
 			if tmpErr != nil {
 				log.Logger.Error("Try to auto refresh autofill data error when get multi ref data", log.Error(tmpErr))
 				continue
 			}
 			if len(multiRefData) > 0 {
-				//nowData[attr.Name] = multiRefData[0]["to_guid"]
 				if tmpMultiRefList, b := multiRefData[guid]; b {
 					nowData[attr.Name] = strings.Join(tmpMultiRefList, ",")
 				} else {
@@ -686,26 +876,45 @@ func autofillAffectActionFunc(ciTypeId, guid, nowTime string) {
 		if attr.DataType == "datetime" && nowData[attr.Name] == "" {
 			delete(nowData, attr.Name)
 		}
-		if attr.AutofillAble == "no" || attr.AutofillType == "suggest" {
+	}
+	autofillAttrList := attrTable
+	if len(attrNameList) > 0 {
+		autofillAttrList = []*models.SysCiTypeAttrTable{}
+		for _, attrName := range attrNameList {
+			for _, attr := range attrTable {
+				if attr.Name == attrName {
+					autofillAttrList = append(autofillAttrList, attr)
+					break
+				}
+			}
+		}
+	}
+	for _, attr := range autofillAttrList {
+		if attr.AutofillAble == "no" || attr.AutofillRule == "" || attr.AutofillType == "suggest" {
 			continue
 		}
 		autofillValueList, tmpErr := buildAutofillValue(nowData, attr.AutofillRule, attr.InputType)
 		if tmpErr != nil {
-			log.Logger.Error("Try to auto refresh autofill data fail,build value error", log.String("guid", guid), log.String("attr", attr.Name), log.Error(err))
+			log.Logger.Error("Try to auto refresh autofill data fail,build value error", log.String("guid", guid), log.String("attr", attr.Name), log.Error(tmpErr))
+			buildErrList = append(buildErrList, fmt.Sprintf("attr:%s %s", attr.Name, tmpErr.Error()))
 			continue
 		}
 		afterAutoBuildData := getAutofillValueString(autofillValueList, attr.InputType)
 		if afterAutoBuildData != nowData[attr.Name] {
 			updateColumn = append(updateColumn, attr.Name)
-			nowData[attr.Name] = getAutofillValueString(autofillValueList, attr.InputType)
+			nowData[attr.Name] = afterAutoBuildData
 			updateColumnList = append(updateColumnList, &models.CiDataColumnObj{ColumnName: attr.Name, ColumnValue: nowData[attr.Name]})
 		}
 	}
+	if len(buildErrList) > 0 {
+		err = fmt.Errorf("Build autofill value fail,%s ", strings.Join(buildErrList, ";"))
+	}
 	if len(updateColumnList) == 0 {
 		log.Logger.Warn("Try to auto refresh autofill data break,no column in update list", log.String("guid", guid))
 		return
 	}
-	updateColumnList = append(updateColumnList, &models.CiDataColumnObj{ColumnName: "update_time", ColumnValue: nowTime})
+	newUpdateTime := nextCiDataUpdateTime(nowTime, nowData["update_time"])
+	updateColumnList = append(updateColumnList, &models.CiDataColumnObj{ColumnName: "update_time", ColumnValue: newUpdateTime})
 	// update now && insert history
 	var actions []*execAction
 	actions = append(actions, getUpdateActionByColumnList(updateColumnList, ciTypeId, guid))
@@ -716,17 +925,15 @@ func autofillAffectActionFunc(ciTypeId, guid, nowTime string) {
 	for _, col := range multiRefColumn {
 		delete(nowData, col)
 	}
-	nowData["update_time"] = nowTime
+	nowData["update_time"] = newUpdateTime
 	actions = append(actions, getHistoryActionByData(nowData, ciTypeId, nowTime, &models.SysStateTransitionQuery{Action: "autofill", TargetIsConfirm: isConfirm}))
-	err = transaction(actions)
-	if err != nil {
-		log.Logger.Error("Try to auto refresh autofill data,update database fail", log.Error(err))
-	} else {
-		log.Logger.Info("Refresh autofill data success", log.String("guid", guid))
-		var autofillChainMap = make(map[string][]*models.AutofillChainObj)
-		autofillChainMap[ciTypeId] = []*models.AutofillChainObj{&models.AutofillChainObj{Guid: guid, UpdateColumn: updateColumn}}
-		affectGuidListChan <- autofillChainMap
+	actions = append(actions, buildCiSearchDirtyActions(map[string][]string{ciTypeId: {guid}})...)
+	if tmpErr := transaction(actions); tmpErr != nil {
+		return nil, fmt.Errorf("Try to update autofill data fail,%s ", tmpErr.Error())
 	}
+	log.Logger.Info("Refresh autofill data success", log.String("guid", guid))
+	notifySearchIndex()
+	return
 }
 
 func buildAttrValue(param *models.BuildAttrValueParam) (result *models.CiDataColumnObj, multiRefAction []*execAction, deleteGuidList []string, err error) {
@@ -781,22 +988,7 @@ func buildAttrValue(param *models.BuildAttrValueParam) (result *models.CiDataCol
 		needValidateText = false
 	}
 	if needValidateText {
-		textReg, tmpErr := pcre.Compile(param.AttributeConfig.TextValidate, 0)
-		if tmpErr != nil {
-			err = fmt.Errorf("Try to validate column:%s fail,init regexp rule error:%s ", param.AttributeConfig.Name, tmpErr.Message)
-			return
-		}
-		tmpMultiValueList := []string{inputValue}
-		if strings.HasPrefix(param.AttributeConfig.InputType, "multi") {
-			tmpMultiValueList = getMultiStringInputTypeValue(param.AttributeConfig.InputType, inputValue)
-		}
-		for _, v := range tmpMultiValueList {
-			if !textReg.MatcherString(v, 0).Matches() {
-				err = fmt.Errorf("Attribute:%s validate text:%s fail ", param.AttributeConfig.Name, v)
-				break
-			}
-		}
-		if err != nil {
+		if err = validateAttrTextValue(param.AttributeConfig, inputValue); err != nil {
 			return
 		}
 	}
@@ -847,20 +1039,25 @@ func buildAttrValue(param *models.BuildAttrValueParam) (result *models.CiDataCol
 
 func getCiRowDataByGuid(ciTypeId string, rowGuidList []string, filters []*models.AutofillFilterObj, inputType string, startRowData map[string]string) (rowMapList []map[string]string, err error) {
 	var filterSqlList []string
+	var filterParams []interface{}
 	for _, f := range filters {
 		f.CiType = ciTypeId
-		tmpSql, tmpErr := getFilterSql(f, "", inputType, startRowData)
+		tmpSql, tmpParams, tmpErr := getFilterSql(f, "", inputType, startRowData)
 		if tmpErr != nil {
 			err = fmt.Errorf("Get filter:%s sql error:%s ", f, tmpErr.Error())
 			break
 		}
 		filterSqlList = append(filterSqlList, tmpSql)
+		filterParams = append(filterParams, tmpParams...)
+	}
+	if err != nil {
+		return
 	}
 	sql := fmt.Sprintf("SELECT * FROM %s WHERE guid in ('%s') ", ciTypeId, strings.Join(rowGuidList, "','"))
 	if len(filterSqlList) > 0 {
 		sql += " AND " + strings.Join(filterSqlList, " AND ")
 	}
-	rowMapList, err = x.QueryString(sql)
+	rowMapList, err = x.QueryString(append([]interface{}{sql}, filterParams...)...)
 	if err != nil {
 		log.Logger.Error("Get ci row data by guid list error", log.Error(err))
 	}
@@ -869,20 +1066,25 @@ func getCiRowDataByGuid(ciTypeId string, rowGuidList []string, filters []*models
 
 func getMultiRefRowData(ciTypeId, attrName, refCiTypeId string, rowGuidList []string, filters []*models.AutofillFilterObj, inputType string, startRowData map[string]string) (rowMapList []map[string]string, err error) {
 	var filterSqlList []string
+	var filterParams []interface{}
 	for _, f := range filters {
 		f.CiType = ciTypeId
-		tmpSql, tmpErr := getFilterSql(f, "t2", inputType, startRowData)
+		tmpSql, tmpParams, tmpErr := getFilterSql(f, "t2", inputType, startRowData)
 		if tmpErr != nil {
 			err = fmt.Errorf("Get filter:%s sql error:%s ", f, tmpErr.Error())
 			break
 		}
 		filterSqlList = append(filterSqlList, tmpSql)
+		filterParams = append(filterParams, tmpParams...)
+	}
+	if err != nil {
+		return
 	}
 	sql := fmt.Sprintf("select distinct t2.* from %s$%s t1 left join %s t2 on t1.to_guid=t2.guid where t1.from_guid in ('%s') ", ciTypeId, attrName, refCiTypeId, strings.Join(rowGuidList, "','"))
 	if len(filterSqlList) > 0 {
 		sql += " AND " + strings.Join(filterSqlList, " AND ")
 	}
-	rowMapList, err = x.QueryString(sql)
+	rowMapList, err = x.QueryString(append([]interface{}{sql}, filterParams...)...)
 	if err != nil {
 		log.Logger.Error("Get ci row data by guid list error", log.Error(err))
 	}
@@ -982,7 +1184,7 @@ func validateMultiRefFilterData(multiCiData []*models.MultiCiDataObj) error {
 						break
 					}
 					if len(fetchRows) == 0 {
-						err = fmt.Errorf("Row:%s column:%s value illegal with refFilter rule ", inputRow["key_name"], attr.Name)
+						err = &models.CiDataRowError{Message: fmt.Sprintf("Row:%s column:%s value illegal with refFilter rule ", inputRow["key_name"], attr.Name)}
 						break
 					}
 					fetchGuidMap := make(map[string]bool)
@@ -1001,7 +1203,7 @@ func validateMultiRefFilterData(multiCiData []*models.MultiCiDataObj) error {
 					}
 					for _, tmpValueObj := range inputRowValueList {
 						if _, b := fetchGuidMap[tmpValueObj]; !b {
-							err = fmt.Errorf("Row:%s column:%s value illegal with refFilter rule ", inputRow["key_name"], attr.Name)
+							err = &models.CiDataRowError{Message: fmt.Sprintf("Row:%s column:%s value illegal with refFilter rule ", inputRow["key_name"], attr.Name)}
 							break
 						}
 					}
@@ -1044,9 +1246,9 @@ func getMultiCiTransition(multiCiData []*models.MultiCiDataObj) error {
 	for _, ciDataObj := range multiCiData {
 		ciTypeList = append(ciTypeList, ciDataObj.CiTypeId)
 	}
-	querySql := `select t1.id as 'ci_type',t3.*,t4.name as 'target_state_name',t4.is_confirm as 'target_is_confirm',t4.unique_path_trigger as 'target_unique_path',t2.start_state,t2.final_state from sys_ci_type t1 
+	querySql := `select t1.id as ci_type,t3.*,t4.name as target_state_name,t4.is_confirm as target_is_confirm,t4.unique_path_trigger as target_unique_path,t2.start_state,t2.final_state from sys_ci_type t1 
 		left join sys_state_machine t2 on t1.state_machine=t2.id 
-		right join sys_state_transition t3 on t3.state_machine=t2.id 
+		join sys_state_transition t3 on t3.state_machine=t2.id 
 		left join sys_state t4 on t3.target_state=t4.id ` + fmt.Sprintf("where t1.id in ('%s') order by t1.id", strings.Join(ciTypeList, "','"))
 	err := x.SQL(querySql).Find(&transList)
 	if err != nil {
@@ -1081,7 +1283,7 @@ func getMultiCiTransition(multiCiData []*models.MultiCiDataObj) error {
 
 func getMultiCiStartTransition(multiCiData []*models.MultiCiDataObj) error {
 	var err error
-	querySql := `select t1.*,t2.name as 'target_state_name' from sys_state_transition t1 left join sys_state t2 on t1.target_state=t2.id where t1.current_state in (
+	querySql := `select t1.*,t2.name as target_state_name from sys_state_transition t1 left join sys_state t2 on t1.target_state=t2.id where t1.current_state in (
 		select id from sys_state where id in (select start_state from sys_state_machine where id in (
 		select state_machine from sys_ci_type where id=?)))`
 	for _, ciDataObj := range multiCiData {
@@ -1304,7 +1506,7 @@ func validateUniqueColumn(multiCiData []*models.MultiCiDataObj) error {
 				tmpColumnName := queryRow["unique_c"]
 				for _, inputRow := range ciDataObj.InputData {
 					if inputRow["guid"] != queryRow["guid"] && inputRow[tmpColumnName] == queryRow[tmpColumnName] {
-						err = fmt.Errorf("Unique validate fail,row:%s column:%s is same with row:%s ", inputRow["key_name"], tmpColumnName, queryRow["key_name"])
+						err = &models.CiDataRowError{Message: fmt.Sprintf("Unique validate fail,row:%s column:%s is same with row:%s ", inputRow["key_name"], tmpColumnName, queryRow["key_name"])}
 						break
 					}
 				}
@@ -1689,6 +1891,9 @@ func validateRightStateTrans(param *models.ActionFuncParam) error {
 
 func transStringValueToList(inputValue string) (valueList []string, err error) {
 	valueList = []string{}
+	if strings.TrimSpace(inputValue) == "" {
+		return
+	}
 	if strings.Contains(inputValue, "[") {
 		err = json.Unmarshal([]byte(inputValue), &valueList)
 		if err != nil {
@@ -1735,6 +1940,11 @@ func buildMultiRefActions(param *models.BuildAttrValueParam) (actions []*execAct
 	tableName := fmt.Sprintf("%s$%s", param.AttributeConfig.CiType, param.AttributeConfig.Name)
 	actions = append(actions, &execAction{Sql: fmt.Sprintf("delete from %s where from_guid=?", tableName), Param: []interface{}{rowGuid}})
 	if len(valueList) == 0 {
+		// 清空时记录一条to_guid为空的历史,按时间点查询时才能知道这之后已经没有引用
+		if param.Action != "delete" {
+			actions = append(actions, &execAction{Sql: fmt.Sprintf("insert into %s%s(from_guid,to_guid,seq_no,history_to_id,history_time) value (?,'',0,0,?)", HistoryTablePrefix, tableName), Param: []interface{}{
+				rowGuid, param.NowTime}})
+		}
 		return
 	}
 	for i, to := range valueList {
@@ -1765,6 +1975,25 @@ func buildMultiRefActions(param *models.BuildAttrValueParam) (actions []*execAct
 	return
 }
 
+func validateAttrTextValue(attr *models.SysCiTypeAttrTable, inputValue string) (err error) {
+	textReg, tmpErr := pcre.Compile(attr.TextValidate, 0)
+	if tmpErr != nil {
+		err = fmt.Errorf("Try to validate column:%s fail,init regexp rule error:%s ", attr.Name, tmpErr.Message)
+		return
+	}
+	tmpMultiValueList := []string{inputValue}
+	if strings.HasPrefix(attr.InputType, "multi") {
+		tmpMultiValueList = getMultiStringInputTypeValue(attr.InputType, inputValue)
+	}
+	for _, v := range tmpMultiValueList {
+		if !textReg.MatcherString(v, 0).Matches() {
+			err = fmt.Errorf("Attribute:%s validate text:%s fail ", attr.Name, v)
+			break
+		}
+	}
+	return
+}
+
 func isAttributeMultiRef(ciTypeId, ciAttrName string) bool {
 	rowData, err := x.QueryString("select name,input_type from sys_ci_type_attr where ci_type=? and name=?", ciTypeId, ciAttrName)
 	if err != nil {
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// HandleCiDataOperationPartial 分批模式,先整批提交,失败时每行作为独立单元校验和提交,返回每行的结果
+func HandleCiDataOperationPartial(param models.HandleCiDataParam) (result models.CiDataBatchResult, newInputBody string) {
+	inputData := param.InputData
+	result = models.CiDataBatchResult{TotalRows: len(inputData), Rows: []*models.CiDataBatchRowResult{}}
+	if len(inputData) == 0 {
+		return
+	}
+	param.InputData = copyCiDataList(inputData)
+	outputData, newInputBody, err := HandleCiDataOperation(param)
+	if err == nil {
+		outputMap := make(map[string]models.CiDataMapObj)
+		for _, row := range outputData {
+			outputMap[row["guid"]] = row
+		}
+		for i, row := range param.InputData {
+			result.Rows = append(result.Rows, &models.CiDataBatchRowResult{Index: i, Guid: row["guid"], KeyName: inputData[i]["key_name"], Status: models.BatchRowSuccess, Data: outputMap[row["guid"]]})
+		}
+	} else if !isCiDataRowError(err) {
+		// 数据库、权限等整体的错误逐行重试也不会成功,所有行都按失败返回
+		log.Logger.Warn("Handle ci data batch fail", log.String("ciType", param.CiTypeId), log.Int("rows", len(inputData)), log.Error(err))
+		for i, row := range inputData {
+			result.Rows = append(result.Rows, &models.CiDataBatchRowResult{Index: i, Guid: row["guid"], KeyName: row["key_name"], Status: models.BatchRowFailed, Error: buildCiDataBatchRowError(err)})
+		}
+	} else {
+		log.Logger.Warn("Handle ci data batch fail,try to handle row by row", log.String("ciType", param.CiTypeId), log.Int("rows", len(inputData)), log.Error(err))
+		for i, row := range inputData {
+			param.InputData = copyCiDataList([]models.CiDataMapObj{row})
+			rowResult := models.CiDataBatchRowResult{Index: i, Guid: row["guid"], KeyName: row["key_name"], Status: models.BatchRowSuccess}
+			outputData, _, err = HandleCiDataOperation(param)
+			if err != nil {
+				rowResult.Status = models.BatchRowFailed
+				rowResult.Error = buildCiDataBatchRowError(err)
+			} else {
+				rowResult.Guid = param.InputData[0]["guid"]
+				if len(outputData) > 0 {
+					rowResult.Data = outputData[0]
+				}
+			}
+			result.Rows = append(result.Rows, &rowResult)
+		}
+	}
+	fillCiDataBatchState(param.CiTypeId, &result)
+	return
+}
+
+func copyCiDataList(input []models.CiDataMapObj) (result []models.CiDataMapObj) {
+	for _, row := range input {
+		result = append(result, copyCiDataMap(row))
+	}
+	return
+}
+
+// isCiDataRowError 数据行校验不通过或者并发修改冲突时,其它行可以单独提交
+func isCiDataRowError(err error) bool {
+	var rowErr *models.CiDataRowError
+	var conflictErr *models.CiDataConflictError
+	return errors.As(err, &rowErr) || errors.As(err, &conflictErr)
+}
+
+func buildCiDataBatchRowError(err error) *models.CiDataBatchRowError {
+	if conflictErr, ok := err.(*models.CiDataConflictError); ok && len(conflictErr.Rows) > 0 {
+		return &models.CiDataBatchRowError{Code: "DATA_CONFLICT", Message: conflictErr.Rows[0].Error(), Conflict: conflictErr.Rows[0]}
+	}
+	if _, ok := err.(*models.CiDataRowError); ok {
+		return &models.CiDataBatchRowError{Code: "DATA_VALIDATE_ERROR", Message: err.Error()}
+	}
+	return &models.CiDataBatchRowError{Code: "SERVER_HANDLE_ERROR", Message: err.Error()}
+}
+
+// fillCiDataBatchState 统计成功失败行数并查出成功行的最新状态,删除的数据状态为空
+func fillCiDataBatchState(ciType string, result *models.CiDataBatchResult) {
+	var guidList []string
+	for _, row := range result.Rows {
+		if row.Status == models.BatchRowSuccess {
+			result.SuccessRows += 1
+			guidList = append(guidList, row.Guid)
+		} else {
+			result.FailRows += 1
+		}
+	}
+	if len(guidList) == 0 {
+		return
+	}
+	specSql, queryParams := createListParams(guidList, "")
+	queryRows, err := x.QueryString(append([]interface{}{fmt.Sprintf("select guid,state from %s where guid in (%s)", ciType, specSql)}, queryParams...)...)
+	if err != nil {
+		log.Logger.Error("Try to query batch row state fail", log.String("ciType", ciType), log.Error(err))
+		return
+	}
+	stateMap := make(map[string]string)
+	for _, row := range queryRows {
+		stateMap[row["guid"]] = row["state"]
+	}
+	for _, row := range result.Rows {
+		if row.Status == models.BatchRowSuccess {
+			row.State = stateMap[row.Guid]
+		}
+	}
+}
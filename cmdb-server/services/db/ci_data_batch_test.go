@@ -0,0 +1,47 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataOperationPartial(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "w0", "asset_id": "asset-w0", "key_name": "w0"})
+	// 第二行资产ID和已有数据重复,其它行单独提交
+	result, _ := HandleCiDataOperationPartial(models.HandleCiDataParam{CiTypeId: testCiType, Operation: "Add", Operator: "tester", InputData: []models.CiDataMapObj{
+		{"code": "w1", "asset_id": "asset-w1", "key_name": "w1"},
+		{"code": "w2", "asset_id": "asset-w0", "key_name": "w2"},
+		{"code": "w3", "asset_id": "asset-w3", "key_name": "w3"},
+	}})
+	if result.TotalRows != 3 || result.SuccessRows != 2 || result.FailRows != 1 || len(result.Rows) != 3 {
+		t.Fatalf("partial result not match:%+v", result)
+	}
+	for _, i := range []int{0, 2} {
+		if row := result.Rows[i]; row.Index != i || row.Status != models.BatchRowSuccess || row.Guid == "" || row.State != "created_0" {
+			t.Fatalf("row:%d should success:%+v", i, row)
+		}
+		if dbRow := queryTestRow(t, "select * from test_host where guid=?", result.Rows[i].Guid); dbRow["key_name"] != result.Rows[i].KeyName {
+			t.Fatalf("row:%d should be committed:%v", i, dbRow)
+		}
+	}
+	if row := result.Rows[1]; row.Status != models.BatchRowFailed || row.Error == nil || row.Error.Code != "DATA_VALIDATE_ERROR" || row.State != "" {
+		t.Fatalf("row with duplicate asset id should fail:%+v", row)
+	}
+	if num := countTestRows(t, "select * from test_host where key_name=?", "w2"); num != 0 {
+		t.Fatalf("failed row should not be committed,num:%d", num)
+	}
+	// 不是数据行的错误不逐行重试,所有行都失败
+	result, _ = HandleCiDataOperationPartial(models.HandleCiDataParam{CiTypeId: testCiType, Operation: "NotExistOperation", Operator: "tester", InputData: []models.CiDataMapObj{
+		{"code": "w4", "asset_id": "asset-w4", "key_name": "w4"},
+		{"code": "w5", "asset_id": "asset-w5", "key_name": "w5"},
+	}})
+	if result.SuccessRows != 0 || result.FailRows != 2 || result.Rows[0].Error.Code != "SERVER_HANDLE_ERROR" || result.Rows[1].Error.Code != "SERVER_HANDLE_ERROR" {
+		t.Fatalf("batch error should fail all rows:%+v", result)
+	}
+	if num := countTestRows(t, "select * from test_host where key_name in (?,?)", "w4", "w5"); num != 0 {
+		t.Fatalf("rows should not be committed,num:%d", num)
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func CiTypeConfirmPolicyUpdate(ciTypeId string, param *models.CiTypeConfirmPolicyParam) error {
+	if param.ConfirmPolicy != models.ConfirmPolicyNone && param.ConfirmPolicy != models.ConfirmPolicy4Eyes {
+		return fmt.Errorf("ConfirmPolicy:%s is illegal,must be one of %s,%s ", param.ConfirmPolicy, models.ConfirmPolicyNone, models.ConfirmPolicy4Eyes)
+	}
+	if _, err := GetCiTypeById(ciTypeId); err != nil {
+		return err
+	}
+	var roleList []string
+	for _, role := range strings.Split(param.ConfirmRole, ",") {
+		if role = strings.TrimSpace(role); role != "" && !inStringList(role, roleList) {
+			roleList = append(roleList, role)
+		}
+	}
+	param.ConfirmRole = strings.Join(roleList, ",")
+	_, err := x.Exec("UPDATE sys_ci_type SET confirm_policy=?,confirm_role=? WHERE id=?", param.ConfirmPolicy, param.ConfirmRole, ciTypeId)
+	if err != nil {
+		return fmt.Errorf("Try to update ci type confirm policy fail,%s ", err.Error())
+	}
+	return nil
+}
+
+// checkConfirmPolicy 校验确认操作是否满足ci类型的确认策略,不满足时返回拒绝原因
+func checkConfirmPolicy(ciType *models.SysCiTypeTable, guid, operator string, roles []string) (rejectMessage string, err error) {
+	if ciType.ConfirmRole != "" {
+		matchRole := false
+		for _, role := range roles {
+			if inStringList(role, strings.Split(ciType.ConfirmRole, ",")) {
+				matchRole = true
+				break
+			}
+		}
+		if !matchRole {
+			rejectMessage = fmt.Sprintf("user:%s need one of role:%s to confirm", operator, ciType.ConfirmRole)
+			return
+		}
+	}
+	if ciType.ConfirmPolicy != models.ConfirmPolicy4Eyes {
+		return
+	}
+	// 插件调用没有传操作人时用的是系统用户,无法判断是不是另一个人
+	if operator == "" || strings.EqualFold(operator, models.SystemUser) {
+		rejectMessage = "4eyes confirm need a real operator,system user can not confirm"
+		return
+	}
+	// 取历史表中最后一次新增或修改的人,确认人不能是同一个人
+	queryRows, err := x.QueryString(fmt.Sprintf("select update_user from %s%s where guid=? and history_action in ('insert','update') order by id desc limit 1", HistoryTablePrefix, ciType.Id), guid)
+	if err != nil {
+		err = fmt.Errorf("Try to query last update user fail,%s ", err.Error())
+		return
+	}
+	if len(queryRows) > 0 && queryRows[0]["update_user"] == operator {
+		rejectMessage = fmt.Sprintf("user:%s is the last update user,need another user to confirm", operator)
+	}
+	return
+}
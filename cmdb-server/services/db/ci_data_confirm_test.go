@@ -0,0 +1,39 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCheckConfirmPolicy(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "c1", "asset_id": "asset-c1", "key_name": "c1"})
+	guid := inserted[0]["guid"]
+	ciType := &models.SysCiTypeTable{Id: testCiType, ConfirmPolicy: models.ConfirmPolicy4Eyes, ConfirmRole: "confirmer"}
+	for _, testObj := range []struct {
+		Operator string
+		Roles    []string
+		Reject   bool
+	}{
+		{"tester", []string{"confirmer"}, true},
+		{"other", []string{"confirmer"}, false},
+		{"other", []string{"tester"}, true},
+		// 插件没有传操作人时不能确认
+		{"SYSTEM", []string{"confirmer"}, true},
+		{"", []string{"confirmer"}, true},
+	} {
+		rejectMessage, err := checkConfirmPolicy(ciType, guid, testObj.Operator, testObj.Roles)
+		if err != nil {
+			t.Fatalf("check confirm policy fail,%s", err.Error())
+		}
+		if (rejectMessage != "") != testObj.Reject {
+			t.Fatalf("confirm policy of operator:%s roles:%v not match,reject message:%s", testObj.Operator, testObj.Roles, rejectMessage)
+		}
+	}
+	ciType.ConfirmPolicy = models.ConfirmPolicyNone
+	if rejectMessage, err := checkConfirmPolicy(ciType, guid, "tester", []string{"confirmer"}); err != nil || rejectMessage != "" {
+		t.Fatalf("confirm without 4eyes should pass,%s %v", rejectMessage, err)
+	}
+}
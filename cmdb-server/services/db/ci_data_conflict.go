@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// buildCiDataConflictRow 输入的update_time和数据库不一致时,用历史表中该update_time的快照和当前数据对比出期间变化的字段
+func buildCiDataConflictRow(param *models.ActionFuncParam) *models.CiDataConflictRow {
+	result := models.CiDataConflictRow{Guid: param.NowData["guid"], KeyName: param.NowData["key_name"], InputUpdateTime: param.InputData["update_time"],
+		UpdateTime: param.NowData["update_time"], UpdateUser: param.NowData["update_user"], Columns: []string{}}
+	queryRows, err := x.QueryString(fmt.Sprintf("select * from %s%s where guid=? and update_time=? order by id desc limit 1", HistoryTablePrefix, param.CiType), result.Guid, result.InputUpdateTime)
+	if err != nil {
+		log.Logger.Error("Try to query history data with update_time fail", log.String("guid", result.Guid), log.Error(err))
+		return &result
+	}
+	if len(queryRows) == 0 {
+		return &result
+	}
+	for _, attr := range param.Attributes {
+		if attr.InputType == models.MultiRefType || attr.Name == "update_time" || attr.Name == "update_user" || attr.Name == "confirm_time" {
+			continue
+		}
+		if historyValue, b := queryRows[0][attr.Name]; b && historyValue != param.NowData[attr.Name] {
+			result.Columns = append(result.Columns, attr.Name)
+		}
+	}
+	return &result
+}
+
+// ciDataUpdateConflict 带update_time条件的更新没有更新到数据,说明数据在读取后提交前被修改或删除
+type ciDataUpdateConflict struct {
+	CiType          string
+	Guid            string
+	InputUpdateTime string
+	Attributes      []*models.SysCiTypeAttrTable
+}
+
+func (c *ciDataUpdateConflict) Error() string {
+	return fmt.Sprintf("Row:%s update_time:%s is diff with database when commit", c.Guid, c.InputUpdateTime)
+}
+
+// rebuildCiDataConflictError 事务回滚后重新读取当前数据,生成和提交前检查一样的冲突信息
+func rebuildCiDataConflictError(err error) error {
+	var updateConflict *ciDataUpdateConflict
+	if !errors.As(err, &updateConflict) {
+		return err
+	}
+	nowRows, queryErr := x.QueryString(fmt.Sprintf("select * from %s where guid=?", updateConflict.CiType), updateConflict.Guid)
+	if queryErr != nil {
+		log.Logger.Error("Try to query conflict data fail", log.String("guid", updateConflict.Guid), log.Error(queryErr))
+		return err
+	}
+	if len(nowRows) == 0 {
+		return &models.CiDataConflictError{Rows: []*models.CiDataConflictRow{{Guid: updateConflict.Guid, InputUpdateTime: updateConflict.InputUpdateTime, Columns: []string{}}}}
+	}
+	param := models.ActionFuncParam{CiType: updateConflict.CiType, Attributes: updateConflict.Attributes, NowData: nowRows[0],
+		InputData: models.CiDataMapObj{"update_time": updateConflict.InputUpdateTime}}
+	return &models.CiDataConflictError{Rows: []*models.CiDataConflictRow{buildCiDataConflictRow(&param)}}
+}
+
+// nextCiDataUpdateTime update_time只精确到秒,同一秒内的多次修改会得到相同的update_time,
+// 每次修改都让update_time至少比原来晚一秒,update_time相当于数据行的版本号,乐观锁检查不会漏掉同一秒内的修改
+func nextCiDataUpdateTime(nowTime, oldUpdateTime string) string {
+	if oldUpdateTime == "" || nowTime > oldUpdateTime {
+		return nowTime
+	}
+	oldTime, err := time.ParseInLocation(models.DateTimeFormat, oldUpdateTime, time.Local)
+	if err != nil {
+		return nowTime
+	}
+	return oldTime.Add(time.Second).Format(models.DateTimeFormat)
+}
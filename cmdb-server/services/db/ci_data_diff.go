@@ -0,0 +1,186 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CiDataDiff 比较同一条数据两个历史点的差异,from和to可以是历史记录id或者时间
+func CiDataDiff(inputGuid, from, to string, roles []string) (result models.CiDataDiffResult, err error) {
+	if !strings.Contains(inputGuid, "_") {
+		err = fmt.Errorf("Guid:%s is illegal ", inputGuid)
+		return
+	}
+	// guid前缀会用作历史表名,先确认是已创建的ci类型
+	ciTypeId := inputGuid[:strings.LastIndex(inputGuid, "_")]
+	if !isCiExprIdentifier(ciTypeId) {
+		err = fmt.Errorf("Guid:%s is illegal ", inputGuid)
+		return
+	}
+	ciTypeRows, err := x.QueryString("select id from sys_ci_type where id=? and status='created'", ciTypeId)
+	if err != nil {
+		err = fmt.Errorf("Try to query ci type:%s fail,%s ", ciTypeId, err.Error())
+		return
+	}
+	if len(ciTypeRows) == 0 {
+		err = fmt.Errorf("Guid:%s is illegal,can not find created ci type:%s ", inputGuid, ciTypeId)
+		return
+	}
+	permission, err := GetRoleCiDataPermission(roles, ciTypeId)
+	if err != nil {
+		return
+	}
+	legalGuidList, err := GetCiDataPermissionGuidList(&permission, "query")
+	if err != nil {
+		return
+	}
+	if !legalGuidList.Disable && !inStringList(inputGuid, legalGuidList.GuidList) {
+		err = fmt.Errorf("Permission deny with data:%s ", inputGuid)
+		return
+	}
+	result = models.CiDataDiffResult{Guid: inputGuid, CiType: ciTypeId, Attributes: []*models.CiDataDiffAttrObj{}}
+	toRow, err := getCiDataHistoryPoint(ciTypeId, inputGuid, to, "")
+	if err != nil {
+		return
+	}
+	if len(toRow) == 0 {
+		err = fmt.Errorf("Can not find history data with guid:%s to:%s ", inputGuid, to)
+		return
+	}
+	fromRow, err := getCiDataHistoryPoint(ciTypeId, inputGuid, from, toRow["id"])
+	if err != nil {
+		return
+	}
+	// 没传from时可以没有更早的记录,传了from就必须能找到
+	if from != "" && len(fromRow) == 0 {
+		err = fmt.Errorf("Can not find history data with guid:%s from:%s ", inputGuid, from)
+		return
+	}
+	if len(fromRow) > 0 {
+		fromId, _ := strconv.Atoi(fromRow["id"])
+		toId, _ := strconv.Atoi(toRow["id"])
+		if fromId > toId {
+			fromRow, toRow = toRow, fromRow
+		}
+		result.From = buildCiDataDiffPoint(fromRow)
+	}
+	result.To = buildCiDataDiffPoint(toRow)
+	ciAttrs, err := GetCiAttrByCiType(ciTypeId, true)
+	if err != nil {
+		err = fmt.Errorf("Try to get ci attribute with ciType:%s error,%s ", ciTypeId, err.Error())
+		return
+	}
+	for _, attr := range ciAttrs {
+		if attr.Name == "guid" {
+			continue
+		}
+		diffObj := models.CiDataDiffAttrObj{Name: attr.Name, DisplayName: attr.DisplayName, InputType: attr.InputType}
+		if attr.InputType == models.MultiRefType {
+			fromList, tmpErr := getMultiRefHistoryValue(attr, fromRow)
+			if tmpErr != nil {
+				err = tmpErr
+				return
+			}
+			toList, tmpErr := getMultiRefHistoryValue(attr, toRow)
+			if tmpErr != nil {
+				err = tmpErr
+				return
+			}
+			diffObj.Changed = joinRefDataGuid(fromList) != joinRefDataGuid(toList)
+			diffObj.From, diffObj.To = fromList, toList
+		} else if attr.RefCiType != "" {
+			diffObj.Changed = fromRow[attr.Name] != toRow[attr.Name]
+			if diffObj.From, err = getRefHistoryValue(attr, fromRow); err != nil {
+				return
+			}
+			if diffObj.To, err = getRefHistoryValue(attr, toRow); err != nil {
+				return
+			}
+		} else {
+			diffObj.Changed = fromRow[attr.Name] != toRow[attr.Name]
+			diffObj.From, diffObj.To = fromRow[attr.Name], toRow[attr.Name]
+			if attr.InputType == models.PasswordInputType {
+				displayRow := make(map[string]interface{})
+				handleQueryRowPassword(attr.Name, displayRow)
+				diffObj.From, diffObj.To = displayRow[attr.Name], displayRow[attr.Name]
+			}
+		}
+		result.Attributes = append(result.Attributes, &diffObj)
+	}
+	return
+}
+
+// getCiDataHistoryPoint 根据id或时间获取历史记录,point为空时取最新一条或before之前的一条
+func getCiDataHistoryPoint(ciTypeId, guid, point, before string) (row map[string]string, err error) {
+	var queryRows []map[string]string
+	tableName := HistoryTablePrefix + ciTypeId
+	if point == "" {
+		if before != "" {
+			queryRows, err = x.QueryString(fmt.Sprintf("select * from %s where guid=? and id<? order by id desc limit 1", tableName), guid, before)
+		} else {
+			queryRows, err = x.QueryString(fmt.Sprintf("select * from %s where guid=? order by id desc limit 1", tableName), guid)
+		}
+	} else if _, parseErr := strconv.Atoi(point); parseErr == nil {
+		queryRows, err = x.QueryString(fmt.Sprintf("select * from %s where guid=? and id=?", tableName), guid, point)
+	} else {
+		if _, parseErr = time.Parse(models.DateTimeFormat, point); parseErr != nil {
+			err = fmt.Errorf("Param:%s is illegal,must be history id or time format like %s ", point, models.DateTimeFormat)
+			return
+		}
+		queryRows, err = x.QueryString(fmt.Sprintf("select * from %s where guid=? and history_time<=? order by id desc limit 1", tableName), guid, point)
+	}
+	if err != nil {
+		err = fmt.Errorf("Try to query history table %s fail,%s ", tableName, err.Error())
+		return
+	}
+	row = make(map[string]string)
+	if len(queryRows) > 0 {
+		row = queryRows[0]
+	}
+	return
+}
+
+func buildCiDataDiffPoint(row map[string]string) *models.CiDataDiffPoint {
+	return &models.CiDataDiffPoint{Id: row["id"], HistoryTime: row["history_time"], HistoryAction: row["history_action"], HistoryStateConfirmed: row["history_state_confirmed"], State: row["state"]}
+}
+
+func getRefHistoryValue(attr *models.SysCiTypeAttrTable, row map[string]string) (result *models.CiDataRefDataObj, err error) {
+	if row[attr.Name] == "" {
+		return
+	}
+	refAttrs := []*models.CiDataQueryRefAttrObj{{Attribute: attr}}
+	if err = fetchRefAttrAsOfData([]map[string]interface{}{{attr.Name: row[attr.Name]}}, refAttrs, row["history_time"]); err != nil {
+		return
+	}
+	result = refAttrs[0].RefObj[row[attr.Name]]
+	if result == nil {
+		result = &models.CiDataRefDataObj{Guid: row[attr.Name]}
+	}
+	return
+}
+
+func getMultiRefHistoryValue(attr *models.SysCiTypeAttrTable, row map[string]string) (result []*models.CiDataRefDataObj, err error) {
+	result = []*models.CiDataRefDataObj{}
+	if len(row) == 0 {
+		return
+	}
+	refAttrs := []*models.CiDataQueryRefAttrObj{{Attribute: attr}}
+	if err = fetchMultiRefAttrAsOfData([]map[string]interface{}{{"guid": row["guid"], "id": row["id"]}}, refAttrs); err != nil {
+		return
+	}
+	if tmpList, b := refAttrs[0].MultiRefObj[row["guid"]]; b {
+		result = tmpList
+	}
+	return
+}
+
+func joinRefDataGuid(refList []*models.CiDataRefDataObj) string {
+	guidList := []string{}
+	for _, v := range refList {
+		guidList = append(guidList, v.Guid)
+	}
+	return strings.Join(guidList, ",")
+}
@@ -0,0 +1,34 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataDiffPoint(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "d1", "asset_id": "asset-d1", "key_name": "d1"})
+	guid := inserted[0]["guid"]
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": guid, "code": "d1new"})
+	result, err := CiDataDiff(guid, "", "", []string{"tester"})
+	if err != nil {
+		t.Fatalf("diff fail,%s", err.Error())
+	}
+	if result.From == nil || result.To == nil || result.From.Id == result.To.Id {
+		t.Fatalf("diff point not match:%+v", result)
+	}
+	// 传了from但找不到历史记录时要报错,不能当成和空数据比较
+	for _, from := range []string{"999999999", "2000-01-01 00:00:00"} {
+		if _, err = CiDataDiff(guid, from, "", []string{"tester"}); err == nil {
+			t.Fatalf("diff from:%s should return error", from)
+		}
+	}
+	// guid前缀不是已创建的ci类型时不能拿来拼历史表名
+	for _, illegalGuid := range []string{"sys_ci_type_abc", "test_host where 1=1 --_abc"} {
+		if _, err = CiDataDiff(illegalGuid, "", "", []string{"tester"}); err == nil {
+			t.Fatalf("guid:%s should be illegal", illegalGuid)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"io"
+	"strings"
+)
+
+// ciDataExportBatchSize 每批查询的行数
+var ciDataExportBatchSize = 1000
+
+type ciDataExportWriter interface {
+	WriteRow(row []string) error
+	Flush() error
+	Close() error
+}
+
+// CiDataExport 按查询条件分批查询并写出csv或xlsx,表头使用属性显示名,引用类型输出key_name,导出的文件可以直接用于简单导入
+func CiDataExport(ciType string, param *models.QueryRequestParam, permission *models.CiDataLegalGuidList, format string, w io.Writer) (err error) {
+	ciAttrs, err := GetCiAttrByCiType(ciType, true)
+	if err != nil {
+		err = fmt.Errorf("Try to get ci attribute with ciType:%s error,%s ", ciType, err.Error())
+		return
+	}
+	var exportAttrs []*models.SysCiTypeAttrTable
+	for _, attr := range ciAttrs {
+		if len(param.ResultColumns) == 0 {
+			if attr.Name != "guid" {
+				exportAttrs = append(exportAttrs, attr)
+			}
+			continue
+		}
+		for _, column := range param.ResultColumns {
+			if column == attr.Name {
+				exportAttrs = append(exportAttrs, attr)
+				break
+			}
+		}
+	}
+	if len(exportAttrs) == 0 {
+		err = fmt.Errorf("CiType:%s have no column to export ", ciType)
+		return
+	}
+	// 按guid做游标分批,不用offset翻页,数据多时后面的批次也不会变慢;历史模式同一个guid有多行,只能按偏移翻页
+	keysetFlag := param.Dialect == nil || param.Dialect.QueryMode != "all"
+	if keysetFlag || param.Sorting == nil {
+		param.Sorting = &models.QueryRequestSorting{Asc: true, Field: "guid"}
+	}
+	// 先查第一批数据,确保出错时还没有开始写文件
+	rowData, err := queryCiDataExportBatch(ciType, param, permission, 0, "")
+	if err != nil {
+		return
+	}
+	var writer ciDataExportWriter
+	if format == "xlsx" {
+		writer, err = newXlsxExportWriter(w, ciType)
+		if err != nil {
+			return
+		}
+	} else {
+		writer = &csvExportWriter{writer: csv.NewWriter(w)}
+	}
+	headerRow := []string{}
+	for _, attr := range exportAttrs {
+		headerRow = append(headerRow, attr.DisplayName)
+	}
+	if err = writer.WriteRow(headerRow); err != nil {
+		return
+	}
+	startIndex := 0
+	for len(rowData) > 0 {
+		for _, row := range rowData {
+			if err = writer.WriteRow(buildCiDataExportRow(exportAttrs, row)); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		if err = writer.Flush(); err != nil {
+			break
+		}
+		if len(rowData) < ciDataExportBatchSize {
+			break
+		}
+		lastGuid := ""
+		if keysetFlag {
+			lastGuid = fmt.Sprintf("%v", rowData[len(rowData)-1]["guid"])
+		} else {
+			startIndex += ciDataExportBatchSize
+		}
+		if rowData, err = queryCiDataExportBatch(ciType, param, permission, startIndex, lastGuid); err != nil {
+			break
+		}
+	}
+	if closeErr := writer.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Logger.Error("Export ci data fail", log.String("ciType", ciType), log.Error(err))
+	}
+	return
+}
+
+// queryCiDataExportBatch 查一批导出数据,lastGuid不为空时查这个guid之后的数据,不统计总数
+func queryCiDataExportBatch(ciType string, param *models.QueryRequestParam, permission *models.CiDataLegalGuidList, startIndex int, lastGuid string) (rowData []map[string]interface{}, err error) {
+	// CiDataQuery会修改入参,每批都用新的参数
+	batchParam := models.QueryRequestParam{Dialect: param.Dialect, Sorting: &models.QueryRequestSorting{Asc: param.Sorting.Asc, Field: param.Sorting.Field}, Paging: true, SkipCount: true,
+		Pageable: &models.PageInfo{StartIndex: startIndex, PageSize: ciDataExportBatchSize}}
+	batchParam.Filters = append(batchParam.Filters, param.Filters...)
+	if lastGuid != "" {
+		// 过滤条件的gt是大于等于,再排除上一批的最后一条
+		batchParam.Filters = append(batchParam.Filters, &models.QueryRequestFilterObj{Name: "guid", Operator: "gt", Value: lastGuid}, &models.QueryRequestFilterObj{Name: "guid", Operator: "ne", Value: lastGuid})
+	}
+	batchParam.ResultColumns = append(batchParam.ResultColumns, param.ResultColumns...)
+	_, rowData, err = CiDataQuery(ciType, &batchParam, permission, false)
+	return
+}
+
+func buildCiDataExportRow(attrs []*models.SysCiTypeAttrTable, row map[string]interface{}) []string {
+	result := []string{}
+	for _, attr := range attrs {
+		value := row[attr.Name]
+		cellValue := ""
+		if value == nil || attr.InputType == models.PasswordInputType {
+			result = append(result, cellValue)
+			continue
+		}
+		switch tmpValue := value.(type) {
+		case string:
+			cellValue = tmpValue
+		case *models.CiDataRefDataObj:
+			if tmpValue != nil {
+				cellValue = tmpValue.KeyName
+			}
+		case []*models.CiDataRefDataObj:
+			keyNameList := []string{}
+			for _, refObj := range tmpValue {
+				keyNameList = append(keyNameList, refObj.KeyName)
+			}
+			cellValue = strings.Join(keyNameList, ",")
+		default:
+			valueBytes, _ := json.Marshal(tmpValue)
+			cellValue = string(valueBytes)
+		}
+		result = append(result, cellValue)
+	}
+	return result
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvExportWriter) WriteRow(row []string) error {
+	return c.writer.Write(row)
+}
+
+func (c *csvExportWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvExportWriter) Close() error {
+	return c.Flush()
+}
+
+// xlsxExportWriter 只写一个sheet,单元格用inlineStr,边写边压缩不需要把数据留在内存里
+type xlsxExportWriter struct {
+	zipWriter   *zip.Writer
+	sheetWriter io.Writer
+}
+
+func newXlsxExportWriter(w io.Writer, sheetName string) (writer *xlsxExportWriter, err error) {
+	writer = &xlsxExportWriter{zipWriter: zip.NewWriter(w)}
+	escapeSheetName := strings.Builder{}
+	xml.EscapeText(&escapeSheetName, []byte(sheetName))
+	staticFiles := [][]string{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + escapeSheetName.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+	}
+	for _, staticFile := range staticFiles {
+		fileWriter, createErr := writer.zipWriter.Create(staticFile[0])
+		if createErr != nil {
+			err = fmt.Errorf("Try to create xlsx file %s fail,%s ", staticFile[0], createErr.Error())
+			return
+		}
+		if _, err = io.WriteString(fileWriter, staticFile[1]); err != nil {
+			return
+		}
+	}
+	// sheet必须是最后一个文件,zip同一时间只能写一个文件
+	if writer.sheetWriter, err = writer.zipWriter.Create("xl/worksheets/sheet1.xml"); err != nil {
+		err = fmt.Errorf("Try to create xlsx sheet fail,%s ", err.Error())
+		return
+	}
+	_, err = io.WriteString(writer.sheetWriter, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return
+}
+
+func (w *xlsxExportWriter) WriteRow(row []string) error {
+	rowBuilder := strings.Builder{}
+	rowBuilder.WriteString("<row>")
+	for _, cell := range row {
+		rowBuilder.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(&rowBuilder, []byte(cell))
+		rowBuilder.WriteString("</t></is></c>")
+	}
+	rowBuilder.WriteString("</row>")
+	_, err := io.WriteString(w.sheetWriter, rowBuilder.String())
+	return err
+}
+
+func (w *xlsxExportWriter) Flush() error {
+	return w.zipWriter.Flush()
+}
+
+func (w *xlsxExportWriter) Close() error {
+	if _, err := io.WriteString(w.sheetWriter, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return w.zipWriter.Close()
+}
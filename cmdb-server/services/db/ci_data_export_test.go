@@ -0,0 +1,80 @@
+//go:build sqlite
+
+package db
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataExport(t *testing.T) {
+	target := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "o1", "asset_id": "asset-o1", "key_name": "export-target"})
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "o2", "asset_id": "asset-o2", "key_name": "export-s1", "depend_host": target[0]["guid"]},
+		models.CiDataMapObj{"code": "o3", "asset_id": "asset-o3", "key_name": "export-s2", "depend_host": target[0]["guid"]})
+	// 批次调小,三行数据要按guid游标分两批查
+	ciDataExportBatchSize = 2
+	defer func() { ciDataExportBatchSize = 1000 }()
+	buildParam := func() *models.QueryRequestParam {
+		return &models.QueryRequestParam{Filters: []*models.QueryRequestFilterObj{{Name: "key_name", Operator: "contains", Value: "export-"}},
+			ResultColumns: []string{"key_name", "code", "depend_host"}}
+	}
+	csvBuffer := bytes.Buffer{}
+	if err := CiDataExport(testCiType, buildParam(), &models.CiDataLegalGuidList{Disable: true}, "csv", &csvBuffer); err != nil {
+		t.Fatalf("export csv fail,%s", err.Error())
+	}
+	csvRows, err := csv.NewReader(&csvBuffer).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv fail,%s", err.Error())
+	}
+	if len(csvRows) != 4 {
+		t.Fatalf("csv row num:%d", len(csvRows))
+	}
+	// 表头是显示名,引用列输出被引用数据的key_name
+	columnIndex := make(map[string]int)
+	for i, header := range csvRows[0] {
+		columnIndex[header] = i
+	}
+	for _, header := range []string{"唯一名称", "编码简称", "依赖主机"} {
+		if _, b := columnIndex[header]; !b {
+			t.Fatalf("csv header not match:%v", csvRows[0])
+		}
+	}
+	refValueMap := make(map[string]string)
+	for _, row := range csvRows[1:] {
+		refValueMap[row[columnIndex["唯一名称"]]] = row[columnIndex["依赖主机"]]
+	}
+	if len(refValueMap) != 3 || refValueMap["export-s1"] != "export-target" || refValueMap["export-s2"] != "export-target" || refValueMap["export-target"] != "" {
+		t.Fatalf("csv ref value not match:%v", refValueMap)
+	}
+
+	xlsxBuffer := bytes.Buffer{}
+	if err = CiDataExport(testCiType, buildParam(), &models.CiDataLegalGuidList{Disable: true}, "xlsx", &xlsxBuffer); err != nil {
+		t.Fatalf("export xlsx fail,%s", err.Error())
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(xlsxBuffer.Bytes()), int64(xlsxBuffer.Len()))
+	if err != nil {
+		t.Fatalf("read xlsx fail,%s", err.Error())
+	}
+	sheetContent := ""
+	for _, file := range zipReader.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		fileReader, openErr := file.Open()
+		if openErr != nil {
+			t.Fatalf("open sheet fail,%s", openErr.Error())
+		}
+		sheetBytes, _ := io.ReadAll(fileReader)
+		fileReader.Close()
+		sheetContent = string(sheetBytes)
+	}
+	if strings.Count(sheetContent, "<row>") != 4 || !strings.Contains(sheetContent, ">依赖主机</t>") || strings.Count(sheetContent, ">export-target</t>") != 3 {
+		t.Fatalf("xlsx sheet not match:%s", sheetContent)
+	}
+}
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"strings"
+)
+
+// 这些字段由状态机动作自动赋值,导入时不校验必填
+var importSystemColumnMap = map[string]bool{"guid": true, "state": true, "key_name": true, "create_user": true, "create_time": true, "update_user": true, "update_time": true, "confirm_time": true}
+
+type simpleImportRowObj struct {
+	Line   int
+	Insert bool
+	Data   models.CiDataMapObj
+	Valid  bool
+}
+
+// SimpleCiDataImport 先对所有行做校验并生成逐行逐列的错误报告,再按模式导入,dataRowList第一行为属性显示名表头
+func SimpleCiDataImport(dataRowList [][]string, param models.SimpleImportParam) (result models.SimpleImportResult, err error) {
+	result = models.SimpleImportResult{Errors: []*models.SimpleImportErrorObj{}, Data: []models.CiDataMapObj{}}
+	if param.Mode == "" {
+		param.Mode = models.ImportModeAll
+	}
+	if param.Mode != models.ImportModeAll && param.Mode != models.ImportModeValidOnly {
+		err = fmt.Errorf("Param mode:%s illegal,must be %s or %s ", param.Mode, models.ImportModeAll, models.ImportModeValidOnly)
+		return
+	}
+	if param.Action == "" {
+		param.Action = models.ImportActionAdd
+	}
+	if param.Action != models.ImportActionAdd && param.Action != models.ImportActionUpdate && param.Action != models.ImportActionUpsert {
+		err = fmt.Errorf("Param action:%s illegal ", param.Action)
+		return
+	}
+	if param.AddOperation == "" {
+		param.AddOperation = "Add"
+	}
+	if param.UpdateOperation == "" {
+		param.UpdateOperation = "Update"
+	}
+	if len(dataRowList) < 2 {
+		err = fmt.Errorf("data row empty")
+		return
+	}
+	ciAttrList, err := GetCiAttrByCiType(param.CiType, true)
+	if err != nil {
+		return
+	}
+	attrIndexMap := make(map[int]*models.SysCiTypeAttrTable)
+	keyNameIndex := -1
+	for i, attrDisplayName := range dataRowList[0] {
+		for _, attrObj := range ciAttrList {
+			if attrObj.DisplayName == attrDisplayName {
+				attrIndexMap[i] = attrObj
+				if attrObj.Name == "key_name" {
+					keyNameIndex = i
+				}
+				break
+			}
+		}
+		if _, b := attrIndexMap[i]; !b {
+			result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Row: 1, Column: attrDisplayName, Message: "Unknown header,can not match any attribute display name"})
+		}
+	}
+	if param.Action != models.ImportActionAdd && keyNameIndex < 0 {
+		err = fmt.Errorf("Import with action:%s need key_name column ", param.Action)
+		return
+	}
+	// 批量解析引用数据和已存在的数据
+	refKeyNameMap := make(map[string][]string)
+	var existKeyNameList []string
+	for i, inputRow := range dataRowList {
+		if i == 0 {
+			continue
+		}
+		for k, v := range inputRow {
+			attrObj, ok := attrIndexMap[k]
+			if !ok || v == "" {
+				continue
+			}
+			if attrObj.InputType == "ref" {
+				refKeyNameMap[attrObj.RefCiType] = append(refKeyNameMap[attrObj.RefCiType], v)
+			} else if attrObj.InputType == models.MultiRefType {
+				refKeyNameMap[attrObj.RefCiType] = append(refKeyNameMap[attrObj.RefCiType], strings.Split(v, ",")...)
+			}
+		}
+		if keyNameIndex >= 0 && keyNameIndex < len(inputRow) && inputRow[keyNameIndex] != "" {
+			existKeyNameList = append(existKeyNameList, inputRow[keyNameIndex])
+		}
+	}
+	refGuidMap := make(map[string]map[string]string)
+	for refCiType, keyNameList := range refKeyNameMap {
+		if refGuidMap[refCiType], err = getGuidMapByKeyName(refCiType, keyNameList); err != nil {
+			return
+		}
+	}
+	existGuidMap := make(map[string]string)
+	if param.Action != models.ImportActionAdd {
+		if existGuidMap, err = getGuidMapByKeyName(param.CiType, existKeyNameList); err != nil {
+			return
+		}
+	}
+	var rowList []*simpleImportRowObj
+	for i, inputRow := range dataRowList {
+		if i == 0 {
+			continue
+		}
+		rowObj := simpleImportRowObj{Line: i + 1, Data: make(models.CiDataMapObj), Valid: true}
+		addRowError := func(column, message string) {
+			rowObj.Valid = false
+			keyName := ""
+			if keyNameIndex >= 0 && keyNameIndex < len(inputRow) {
+				keyName = inputRow[keyNameIndex]
+			}
+			result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Row: rowObj.Line, Column: column, KeyName: keyName, Message: message})
+		}
+		emptyRow := true
+		for k, v := range inputRow {
+			if v == "" {
+				continue
+			}
+			emptyRow = false
+			attrObj, ok := attrIndexMap[k]
+			if !ok {
+				continue
+			}
+			if attrObj.InputType == "ref" {
+				if refGuid, b := refGuidMap[attrObj.RefCiType][v]; b {
+					rowObj.Data[attrObj.Name] = refGuid
+				} else {
+					addRowError(attrObj.DisplayName, fmt.Sprintf("Can not find ciType:%s with key_name:%s ", attrObj.RefCiType, v))
+				}
+			} else if attrObj.InputType == models.MultiRefType {
+				tmpGuidList := []string{}
+				for _, tmpKeyName := range strings.Split(v, ",") {
+					if refGuid, b := refGuidMap[attrObj.RefCiType][tmpKeyName]; b {
+						tmpGuidList = append(tmpGuidList, refGuid)
+					} else {
+						addRowError(attrObj.DisplayName, fmt.Sprintf("Can not find ciType:%s with key_name:%s ", attrObj.RefCiType, tmpKeyName))
+					}
+				}
+				guidListBytes, _ := json.Marshal(tmpGuidList)
+				rowObj.Data[attrObj.Name] = string(guidListBytes)
+			} else {
+				if attrObj.TextValidate != "" {
+					if validateErr := validateAttrTextValue(attrObj, v); validateErr != nil {
+						addRowError(attrObj.DisplayName, validateErr.Error())
+					}
+				}
+				rowObj.Data[attrObj.Name] = v
+			}
+		}
+		if emptyRow {
+			continue
+		}
+		result.TotalRows += 1
+		rowObj.Insert = true
+		if param.Action != models.ImportActionAdd {
+			if existGuid, b := existGuidMap[rowObj.Data["key_name"]]; b {
+				rowObj.Insert = false
+				rowObj.Data["guid"] = existGuid
+			} else if param.Action == models.ImportActionUpdate {
+				addRowError(dataRowList[0][keyNameIndex], fmt.Sprintf("Can not find exist data with key_name:%s ", rowObj.Data["key_name"]))
+			}
+		}
+		if rowObj.Insert {
+			// 更新时空值代表不修改,只有新增需要校验必填
+			for _, attrObj := range ciAttrList {
+				if attrObj.Nullable != "no" || attrObj.AutofillAble == "yes" || attrObj.DataType == "datetime" || importSystemColumnMap[attrObj.Name] {
+					continue
+				}
+				if rowObj.Data[attrObj.Name] == "" {
+					addRowError(attrObj.DisplayName, fmt.Sprintf("Attribute:%s can not empty ", attrObj.Name))
+				}
+			}
+		}
+		rowList = append(rowList, &rowObj)
+	}
+	if err = validateImportUniqueColumn(param.CiType, ciAttrList, rowList, &result); err != nil {
+		return
+	}
+	var insertRows, updateRows []models.CiDataMapObj
+	var insertLines, updateLines []int
+	for _, rowObj := range rowList {
+		if !rowObj.Valid {
+			continue
+		}
+		result.ValidRows += 1
+		if rowObj.Insert {
+			insertRows = append(insertRows, rowObj.Data)
+			insertLines = append(insertLines, rowObj.Line)
+		} else {
+			updateRows = append(updateRows, rowObj.Data)
+			updateLines = append(updateLines, rowObj.Line)
+		}
+	}
+	if param.ValidateOnly || result.ValidRows == 0 || (param.Mode != models.ImportModeValidOnly && len(result.Errors) > 0) {
+		return
+	}
+	if param.Mode == models.ImportModeAll {
+		// 全部成功才导入,新增和更新放在同一个事务里提交
+		var paramList []models.HandleCiDataParam
+		if len(insertRows) > 0 {
+			paramList = append(paramList, buildImportHandleParam(param, insertRows, param.AddOperation))
+		}
+		if len(updateRows) > 0 {
+			paramList = append(paramList, buildImportHandleParam(param, updateRows, param.UpdateOperation))
+		}
+		outputData, _, handleErr := HandleCiDataOperationList(paramList)
+		if handleErr != nil {
+			result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Message: handleErr.Error()})
+			return
+		}
+		result.Data = append(result.Data, outputData...)
+		result.InsertRows, result.UpdateRows = len(insertRows), len(updateRows)
+		return
+	}
+	result.InsertRows = importCiDataRows(param, insertRows, insertLines, param.AddOperation, &result)
+	result.UpdateRows = importCiDataRows(param, updateRows, updateLines, param.UpdateOperation, &result)
+	return
+}
+
+func buildImportHandleParam(param models.SimpleImportParam, rows []models.CiDataMapObj, operation string) models.HandleCiDataParam {
+	inputData := []models.CiDataMapObj{}
+	for _, row := range rows {
+		inputData = append(inputData, copyCiDataMap(row))
+	}
+	return models.HandleCiDataParam{InputData: inputData, CiTypeId: param.CiType, Operation: operation, Operator: param.Operator, Roles: param.Roles, Permission: false, UserToken: param.UserToken}
+}
+
+// importCiDataRows validOnly模式先整批导入,整批失败时逐行导入并记录每行的错误
+func importCiDataRows(param models.SimpleImportParam, rows []models.CiDataMapObj, lines []int, operation string, result *models.SimpleImportResult) (successNum int) {
+	if len(rows) == 0 {
+		return
+	}
+	outputData, _, err := HandleCiDataOperation(buildImportHandleParam(param, rows, operation))
+	if err == nil {
+		result.Data = append(result.Data, outputData...)
+		return len(rows)
+	}
+	log.Logger.Warn("Simple import batch fail,try to import row by row", log.String("ciType", param.CiType), log.Error(err))
+	for i, row := range rows {
+		outputData, _, err = HandleCiDataOperation(buildImportHandleParam(param, []models.CiDataMapObj{row}, operation))
+		if err != nil {
+			result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Row: lines[i], KeyName: row["key_name"], Message: err.Error()})
+			continue
+		}
+		result.Data = append(result.Data, outputData...)
+		successNum += 1
+	}
+	return
+}
+
+func validateImportUniqueColumn(ciType string, ciAttrList []*models.SysCiTypeAttrTable, rowList []*simpleImportRowObj, result *models.SimpleImportResult) error {
+	for _, attrObj := range ciAttrList {
+		if attrObj.UniqueConstraint != "yes" || attrObj.AutofillAble == "yes" || attrObj.Name == "guid" {
+			continue
+		}
+		fileValueMap := make(map[string]int)
+		valueList := []string{}
+		for _, rowObj := range rowList {
+			tmpValue := rowObj.Data[attrObj.Name]
+			if tmpValue == "" {
+				continue
+			}
+			if existLine, b := fileValueMap[tmpValue]; b {
+				rowObj.Valid = false
+				result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Row: rowObj.Line, Column: attrObj.DisplayName, KeyName: rowObj.Data["key_name"], Message: fmt.Sprintf("Unique validate fail,value:%s is same with row:%d ", tmpValue, existLine)})
+				continue
+			}
+			fileValueMap[tmpValue] = rowObj.Line
+			valueList = append(valueList, tmpValue)
+		}
+		if len(valueList) == 0 {
+			continue
+		}
+		specSql, queryParam := createListParams(valueList, "")
+		queryParam = append([]interface{}{fmt.Sprintf("select guid,key_name,%s from %s where %s in (%s)", attrObj.Name, ciType, attrObj.Name, specSql)}, queryParam...)
+		queryRows, err := x.QueryString(queryParam...)
+		if err != nil {
+			return fmt.Errorf("Try to validate unique column value fail,%s ", err.Error())
+		}
+		for _, queryRow := range queryRows {
+			for _, rowObj := range rowList {
+				if rowObj.Data[attrObj.Name] == queryRow[attrObj.Name] && rowObj.Data["guid"] != queryRow["guid"] {
+					rowObj.Valid = false
+					result.Errors = append(result.Errors, &models.SimpleImportErrorObj{Row: rowObj.Line, Column: attrObj.DisplayName, KeyName: rowObj.Data["key_name"], Message: fmt.Sprintf("Unique validate fail,value:%s is same with exist data:%s ", queryRow[attrObj.Name], queryRow["key_name"])})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func getGuidMapByKeyName(ciType string, keyNameList []string) (guidMap map[string]string, err error) {
+	guidMap = make(map[string]string)
+	if len(keyNameList) == 0 {
+		return
+	}
+	specSql, queryParam := createListParams(keyNameList, "")
+	queryParam = append([]interface{}{fmt.Sprintf("select guid,key_name from %s where key_name in (%s)", ciType, specSql)}, queryParam...)
+	dataRows, queryErr := x.QueryString(queryParam...)
+	if queryErr != nil {
+		err = fmt.Errorf("query %s table fail,%s ", ciType, queryErr.Error())
+		return
+	}
+	for _, v := range dataRows {
+		guidMap[v["key_name"]] = v["guid"]
+	}
+	return
+}
@@ -0,0 +1,38 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestSimpleCiDataImportAllOrNothing(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "i1", "asset_id": "asset-i1", "key_name": "i1"})
+	dataRowList := [][]string{{"唯一名称", "编码简称", "资产ID"}, {"i1", "i1", "asset-i1"}, {"i2", "i2", "asset-i2"}}
+	param := models.SimpleImportParam{CiType: testCiType, Action: models.ImportActionUpsert, Mode: "unknown", Operator: "tester"}
+	if _, err := SimpleCiDataImport(dataRowList, param); err == nil {
+		t.Fatalf("import with unknown mode should fail")
+	}
+	// 新建的数据不能直接销毁,更新失败时新增的行也不能写入
+	param.Mode, param.UpdateOperation = models.ImportModeAll, "Destroy"
+	result, err := SimpleCiDataImport(dataRowList, param)
+	if err != nil {
+		t.Fatalf("import fail,%s", err.Error())
+	}
+	if len(result.Errors) == 0 || result.InsertRows != 0 || result.UpdateRows != 0 {
+		t.Fatalf("import should fail as a whole:%+v", result)
+	}
+	if row := queryTestRow(t, "select * from test_host where key_name=?", "i2"); row != nil {
+		t.Fatalf("insert row should roll back with failed update:%v", row)
+	}
+	param.UpdateOperation = "Change"
+	result, err = SimpleCiDataImport(dataRowList, param)
+	if err != nil || len(result.Errors) > 0 || result.InsertRows != 1 || result.UpdateRows != 1 {
+		t.Fatalf("import result not match:%+v err:%v", result, err)
+	}
+	if row := queryTestRow(t, "select * from test_host where key_name=?", "i2"); row == nil || row["state"] != "created_0" {
+		t.Fatalf("import insert row not match:%v", row)
+	}
+}
@@ -0,0 +1,316 @@
+//go:build sqlite
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"go.uber.org/zap"
+)
+
+// 集成测试使用临时的sqlite库,需要cgo和sqlite编译标签:
+// CGO_ENABLED=1 go test -mod=vendor -tags sqlite ./services/db/
+const testCiType = "test_host"
+
+// testStateMachine 测试专用状态机,在创销类的基础上加了一个唯一路径触发的检查状态,
+// 避免测试依赖初始化脚本里的状态机数据
+const testStateMachine = "test_machine"
+
+func TestMain(m *testing.M) {
+	log.Logger = zap.NewNop()
+	log.DatabaseLogger = zap.NewNop()
+	tmpDir, err := os.MkdirTemp("", "wecmdb-test")
+	if err != nil {
+		panic(err)
+	}
+	models.Config = &models.GlobalConfig{Database: models.DatabaseConfig{
+		Type:        DialectSqlite,
+		DataBase:    filepath.Join(tmpDir, "cmdb.db"),
+		MaxOpen:     1,
+		MaxIdle:     1,
+		Timeout:     60,
+		InitSqlFile: "../../../wiki/db/init.sql",
+	}}
+	if err = InitDatabase(); err != nil {
+		panic(err)
+	}
+	if err = seedTestCiType(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	x.Close()
+	os.RemoveAll(tmpDir)
+	os.Exit(code)
+}
+
+// seedTestStateMachine 建测试状态机:
+// null_0 -Add-> created_0 -Confirm-> created_1 -Change-> changed_0 -Confirm-> changed_1,
+// created_0 -Check-> checked_0,checked_0是唯一路径触发状态,只能Pass到created_1
+func seedTestStateMachine() error {
+	stateList := [][]string{{"null_0", "no", "no"}, {"null_1", "no", "no"}, {"created_0", "no", "no"}, {"created_1", "no", "yes"}, {"changed_0", "no", "no"},
+		{"changed_1", "no", "yes"}, {"checked_0", "yes", "no"}}
+	transitionList := [][]string{
+		{"null_0", "created_0", "Add", "insert", "insert"},
+		{"created_0", "null_0", "Delete", "delete", "delete"},
+		{"created_0", "created_0", "Change", "update", "update"},
+		{"created_0", "created_0", "Rollback", "update", "update"},
+		{"created_0", "created_1", "Confirm", "update", "confirm"},
+		{"created_0", "checked_0", "Check", "update", "update"},
+		{"checked_0", "created_1", "Pass", "update", "confirm"},
+		{"created_1", "changed_0", "Change", "update", "update"},
+		{"changed_0", "changed_0", "Change", "update", "update"},
+		{"changed_0", "created_1", "Rollback", "update", "update"},
+		{"changed_0", "changed_1", "Confirm", "update", "confirm"},
+		{"changed_1", "changed_0", "Change", "update", "update"},
+	}
+	actions := []*execAction{{Sql: "insert into sys_state_machine(id,description,start_state,final_state) values (?,?,?,?)",
+		Param: []interface{}{testStateMachine, "测试状态机", testStateMachine + "__null_0", testStateMachine + "__null_1"}}}
+	for _, state := range stateList {
+		actions = append(actions, &execAction{Sql: "insert into sys_state(id,name,state_machine,unique_path_trigger,is_confirm) values (?,?,?,?,?)",
+			Param: []interface{}{testStateMachine + "__" + state[0], state[0], testStateMachine, state[1], state[2]}})
+	}
+	for i, transition := range transitionList {
+		actions = append(actions, &execAction{Sql: "insert into sys_state_transition(guid,state_machine,current_state,target_state,operation,operation_en,permission,action,operation_form_type,operation_multiple) values (?,?,?,?,?,?,?,?,?,?)",
+			Param: []interface{}{fmt.Sprintf("test-transition-%d", i), testStateMachine, testStateMachine + "__" + transition[0], testStateMachine + "__" + transition[1],
+				transition[2], transition[2], transition[3], transition[4], "editable_form", "yes"}})
+	}
+	return transaction(actions)
+}
+
+// seedTestCiType 用创销类模版建一个带自引用多选属性的ci类型并建表,状态机换成测试状态机
+func seedTestCiType() error {
+	if err := seedTestStateMachine(); err != nil {
+		return err
+	}
+	if err := CiTypesCreate(&models.SysCiTypeTable{Id: testCiType, DisplayName: "测试主机", CiTemplate: "create_destroy", CiGroup: "ci_group__app", CiLayer: "ci_layer__biz"}); err != nil {
+		return err
+	}
+	if _, err := x.Exec("update sys_ci_type set state_machine=? where id=?", testStateMachine, testCiType); err != nil {
+		return err
+	}
+	if err := CiAttrCreate(&models.SysCiTypeAttrTable{CiType: testCiType, Name: "depend_host", DisplayName: "依赖主机", InputType: models.MultiRefType,
+		DataType: "varchar", DataLength: 64, RefCiType: testCiType, RefName: "depend", RefType: "link", UniqueConstraint: "no", UiNullable: "yes",
+		Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "no"}); err != nil {
+		return err
+	}
+	if err := CreateCiTable(testCiType); err != nil {
+		return err
+	}
+	UpdateCiTypesStatus(testCiType, "created")
+	return nil
+}
+
+func handleTestOperation(t *testing.T, operation string, inputData ...models.CiDataMapObj) []models.CiDataMapObj {
+	t.Helper()
+	outputData, _, err := HandleCiDataOperation(models.HandleCiDataParam{InputData: inputData, CiTypeId: testCiType, Operation: operation, Operator: "tester"})
+	if err != nil {
+		t.Fatalf("%s fail,%s", operation, err.Error())
+	}
+	return outputData
+}
+
+func queryTestRow(t *testing.T, sql string, params ...interface{}) map[string]string {
+	t.Helper()
+	queryRows, err := x.QueryString(append([]interface{}{sql}, params...)...)
+	if err != nil {
+		t.Fatalf("query %s fail,%s", sql, err.Error())
+	}
+	if len(queryRows) == 0 {
+		return nil
+	}
+	return queryRows[len(queryRows)-1]
+}
+
+func countTestRows(t *testing.T, sql string, params ...interface{}) int {
+	t.Helper()
+	queryRows, err := x.QueryString(append([]interface{}{sql}, params...)...)
+	if err != nil {
+		t.Fatalf("query %s fail,%s", sql, err.Error())
+	}
+	return len(queryRows)
+}
+
+func TestCiDataOperationLifecycle(t *testing.T) {
+	// 新增两行,第二行多选引用第一行
+	first := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "h1", "asset_id": "asset-h1", "key_name": "h1"})
+	firstGuid := first[0]["guid"]
+	second := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "h2", "asset_id": "asset-h2", "key_name": "h2", "depend_host": firstGuid})
+	secondGuid := second[0]["guid"]
+	if row := queryTestRow(t, "select * from test_host where guid=?", firstGuid); row == nil || row["state"] != "created_0" || row["code"] != "h1" {
+		t.Fatalf("insert data row not match:%v", row)
+	}
+	if num := countTestRows(t, "select * from history_test_host where guid=? and history_action='insert'", firstGuid); num != 1 {
+		t.Fatalf("insert history row num:%d", num)
+	}
+	if row := queryTestRow(t, "select * from `test_host$depend_host` where from_guid=?", secondGuid); row == nil || row["to_guid"] != firstGuid {
+		t.Fatalf("insert multi ref row not match:%v", row)
+	}
+	if num := countTestRows(t, "select * from `history_test_host$depend_host` where from_guid=?", secondGuid); num != 1 {
+		t.Fatalf("insert multi ref history row num:%d", num)
+	}
+
+	// 确认后变更,再回退到确认时的数据
+	handleTestOperation(t, "Confirm", models.CiDataMapObj{"guid": firstGuid})
+	if row := queryTestRow(t, "select * from test_host where guid=?", firstGuid); row["state"] != "created_1" {
+		t.Fatalf("confirm state not match:%s", row["state"])
+	}
+	if row := queryTestRow(t, "select * from history_test_host where guid=? order by id", firstGuid); row["history_state_confirmed"] != "1" {
+		t.Fatalf("confirm history not mark confirmed:%v", row)
+	}
+	nowRow := queryTestRow(t, "select * from test_host where guid=?", firstGuid)
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": firstGuid, "code": "h1new", "update_time": nowRow["update_time"]})
+	if row := queryTestRow(t, "select * from test_host where guid=?", firstGuid); row["state"] != "changed_0" || row["code"] != "h1new" {
+		t.Fatalf("update data row not match:%v", row)
+	}
+	if num := countTestRows(t, "select * from history_test_host where guid=? and history_action='update'", firstGuid); num != 1 {
+		t.Fatalf("update history row num:%d", num)
+	}
+	rollbackData, err := GetRollbackLastConfirmData(firstGuid)
+	if err != nil {
+		t.Fatalf("get rollback data fail,%s", err.Error())
+	}
+	handleTestOperation(t, "Rollback", rollbackData)
+	if row := queryTestRow(t, "select * from test_host where guid=?", firstGuid); row["state"] != "created_1" || row["code"] != "h1" {
+		t.Fatalf("rollback data row not match:%v", row)
+	}
+
+	// 清空多选引用后删除未确认的数据
+	nowRow = queryTestRow(t, "select * from test_host where guid=?", secondGuid)
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": secondGuid, "depend_host": "[]", "update_time": nowRow["update_time"]})
+	if num := countTestRows(t, "select * from `test_host$depend_host` where from_guid=?", secondGuid); num != 0 {
+		t.Fatalf("multi ref row not clear,num:%d", num)
+	}
+	handleTestOperation(t, "Delete", models.CiDataMapObj{"guid": secondGuid})
+	if row := queryTestRow(t, "select * from test_host where guid=?", secondGuid); row != nil {
+		t.Fatalf("delete data row still exist:%v", row)
+	}
+	if num := countTestRows(t, "select * from history_test_host where guid=? and history_action='delete'", secondGuid); num != 1 {
+		t.Fatalf("delete history row num:%d", num)
+	}
+}
+
+func TestCiDataOperationRejectStaleUpdate(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "h3", "asset_id": "asset-h3", "key_name": "h3"})
+	_, _, err := HandleCiDataOperation(models.HandleCiDataParam{InputData: []models.CiDataMapObj{{"guid": inserted[0]["guid"], "code": "h3new", "update_time": "2000-01-01 00:00:00"}},
+		CiTypeId: testCiType, Operation: "Change", Operator: "tester"})
+	if err == nil {
+		t.Fatalf("update with stale update_time should fail")
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", inserted[0]["guid"]); row["code"] != "h3" {
+		t.Fatalf("stale update should not change data:%v", row)
+	}
+}
+
+func TestCiDataOperationRejectSameSecondUpdate(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "h5", "asset_id": "asset-h5", "key_name": "h5"})
+	guid := inserted[0]["guid"]
+	readTime := queryTestRow(t, "select * from test_host where guid=?", guid)["update_time"]
+	// 两个人读到同一个update_time,同一秒内先后提交,后提交的要冲突
+	output := handleTestOperation(t, "Change", models.CiDataMapObj{"guid": guid, "code": "h5a", "update_time": readTime})
+	nowRow := queryTestRow(t, "select * from test_host where guid=?", guid)
+	if nowRow["update_time"] == readTime || output[0]["update_time"] != nowRow["update_time"] {
+		t.Fatalf("update_time should move on and be returned,read:%s now:%s output:%s", readTime, nowRow["update_time"], output[0]["update_time"])
+	}
+	_, _, err := HandleCiDataOperation(models.HandleCiDataParam{InputData: []models.CiDataMapObj{{"guid": guid, "code": "h5b", "update_time": readTime}},
+		CiTypeId: testCiType, Operation: "Change", Operator: "tester"})
+	var conflictErr *models.CiDataConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("same second update should return conflict error,%v", err)
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["code"] != "h5a" {
+		t.Fatalf("conflict update should not change data:%v", row)
+	}
+	if nextTime := nextCiDataUpdateTime("2021-01-01 00:00:00", "2021-01-01 00:00:00"); nextTime != "2021-01-01 00:00:01" {
+		t.Fatalf("next update time not match:%s", nextTime)
+	}
+	if nextTime := nextCiDataUpdateTime("2021-01-01 00:00:05", "2021-01-01 00:00:00"); nextTime != "2021-01-01 00:00:05" {
+		t.Fatalf("next update time not match:%s", nextTime)
+	}
+}
+
+func TestCiDataOperationRejectConcurrentUpdate(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "h4", "asset_id": "asset-h4", "key_name": "h4"})
+	guid := inserted[0]["guid"]
+	nowRow := queryTestRow(t, "select * from test_host where guid=?", guid)
+	op, err := buildCiDataOperation(models.HandleCiDataParam{InputData: []models.CiDataMapObj{{"guid": guid, "code": "h4new", "update_time": nowRow["update_time"]}},
+		CiTypeId: testCiType, Operation: "Change", Operator: "tester"}, nil)
+	if err != nil {
+		t.Fatalf("build update operation fail,%s", err.Error())
+	}
+	// 校验通过后提交前,数据被其他请求修改
+	if _, err = x.Exec("update test_host set code=?,update_time=? where guid=?", "h4other", "2099-01-01 00:00:00", guid); err != nil {
+		t.Fatalf("update data fail,%s", err.Error())
+	}
+	err = commitCiDataOperationList([]*ciDataOperationObj{op})
+	var conflictErr *models.CiDataConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Rows) != 1 {
+		t.Fatalf("commit should return conflict error,%v", err)
+	}
+	if conflictRow := conflictErr.Rows[0]; conflictRow.UpdateTime != "2099-01-01 00:00:00" || len(conflictRow.Columns) != 1 || conflictRow.Columns[0] != "code" {
+		t.Fatalf("conflict row not match:%+v", conflictRow)
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["code"] != "h4other" {
+		t.Fatalf("conflict update should roll back:%v", row)
+	}
+	if num := countTestRows(t, "select * from history_test_host where guid=?", guid); num != 1 {
+		t.Fatalf("conflict update should not write history,num:%d", num)
+	}
+}
+
+func TestCiDataOperationAutofill(t *testing.T) {
+	rule := buildTestAutofillRule(t, []*models.AutofillValueObj{{CiTypeId: testCiType},
+		{CiTypeId: testCiType, ParentRs: &models.AutofillValueAttrObj{AttrId: testCiType + models.SysTableIdConnector + "code", IsReferedFromParent: 1}}})
+	attr := models.SysCiTypeAttrTable{CiType: testCiType, Name: "fill_code", DisplayName: "填充编码", InputType: "text", DataType: "varchar", DataLength: 255,
+		UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "no", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no",
+		AutofillAble: "yes", AutofillRule: rule, AutofillType: "forced"}
+	if err := CiAttrCreate(&attr); err != nil {
+		t.Fatalf("create autofill attr fail,%s", err.Error())
+	}
+	if err := CiAttrApply(testCiType, testCiType+models.SysTableIdConnector+"fill_code", false); err != nil {
+		t.Fatalf("apply autofill attr fail,%s", err.Error())
+	}
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "f1", "asset_id": "asset-f1", "key_name": "f1"})
+	guid := inserted[0]["guid"]
+	// 规则后面带了分隔符
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["fill_code"] != "f1-" {
+		t.Fatalf("insert autofill value not match:%v", row)
+	}
+	// 被依赖的属性变更后,自动填充列跟着刷新
+	nowRow := queryTestRow(t, "select * from test_host where guid=?", guid)
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": guid, "code": "f1new", "update_time": nowRow["update_time"]})
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["fill_code"] != "f1new-" {
+		t.Fatalf("update autofill value not match:%v", row)
+	}
+}
+
+func TestCiDataOperationUniquePath(t *testing.T) {
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "k1", "asset_id": "asset-k1", "key_name": "k1"})
+	guid := inserted[0]["guid"]
+	handleTestOperation(t, "Check", models.CiDataMapObj{"guid": guid})
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["state"] != "checked_0" {
+		t.Fatalf("check state not match:%v", row)
+	}
+	// 进入唯一路径触发状态后,提交时把下一步操作放进队列,这里直接消费
+	var uniquePathList []*models.AutoActiveHandleParam
+	select {
+	case uniquePathList = <-uniquePathHandleChan:
+	default:
+		t.Fatalf("unique path trigger should send next operation")
+	}
+	if len(uniquePathList) != 1 || uniquePathList[0].Operation != "Pass" || uniquePathList[0].User != models.SystemUser || uniquePathList[0].Data[0]["guid"] != guid {
+		t.Fatalf("unique path operation not match:%+v", uniquePathList)
+	}
+	consumeUniquePathHandle(uniquePathList)
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["state"] != "created_1" {
+		t.Fatalf("unique path auto transition state not match:%v", row)
+	}
+	if row := queryTestRow(t, "select * from history_test_host where guid=? order by id", guid); row["history_action"] != "confirm" || row["history_state_confirmed"] != "1" {
+		t.Fatalf("unique path auto transition history not match:%v", row)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"strings"
+)
+
+// PlanCiDataOperation 执行所有校验和计算但不提交事务,返回数据将要发生的变化
+func PlanCiDataOperation(param models.HandleCiDataParam) (plan models.CiDataOperationPlan, newInputBody string, err error) {
+	plan = models.CiDataOperationPlan{Rows: []*models.CiDataPlanRowObj{}, AutofillAttributes: []*models.CiDataPlanAutofillObj{}, UniquePaths: []*models.CiDataPlanUniquePathObj{}}
+	_, newInputBody, err = handleCiDataOperation(param, &plan)
+	return
+}
+
+func copyCiDataMap(input models.CiDataMapObj) models.CiDataMapObj {
+	result := make(models.CiDataMapObj)
+	for k, v := range input {
+		result[k] = v
+	}
+	return result
+}
+
+// buildCiDataPlanRow 对比行数据执行前后的值,beforeData为数据库现有数据,inputData为原始输入
+func buildCiDataPlanRow(param *models.ActionFuncParam, beforeData, inputData models.CiDataMapObj) *models.CiDataPlanRowObj {
+	row := models.CiDataPlanRowObj{CiType: param.CiType, Guid: param.InputData["guid"], Action: param.Transition.Action, CurrentState: beforeData["state"], TargetState: param.Transition.TargetStateName, Changes: []*models.CiDataPlanChangeObj{}}
+	var afterData models.CiDataMapObj
+	switch param.Transition.Action {
+	case "insert", "update":
+		afterData = param.InputData
+	case "delete":
+		// 删除只体现状态变化
+		afterData = models.CiDataMapObj{"state": param.Transition.TargetStateName}
+	default:
+		afterData = param.NowData
+	}
+	row.KeyName = afterData["key_name"]
+	if row.KeyName == "" {
+		row.KeyName = beforeData["key_name"]
+	}
+	for _, attr := range param.Attributes {
+		if attr.Name == "guid" {
+			continue
+		}
+		fromValue := beforeData[attr.Name]
+		toValue, b := afterData[attr.Name]
+		if attr.InputType == models.MultiRefType && param.Transition.Action != "delete" {
+			toValue, b = inputData[attr.Name]
+			fromList, _ := transStringValueToList(fromValue)
+			fromValue = strings.Join(fromList, ",")
+			toList, _ := transStringValueToList(toValue)
+			toValue = strings.Join(toList, ",")
+		}
+		if !b {
+			continue
+		}
+		if toValue == "reset_null^" {
+			toValue = ""
+		}
+		if fromValue == toValue {
+			continue
+		}
+		if attr.InputType == models.PasswordInputType {
+			fromValue, toValue = models.PasswordDisplay, models.PasswordDisplay
+		}
+		row.Changes = append(row.Changes, &models.CiDataPlanChangeObj{Name: attr.Name, From: fromValue, To: toValue, Autofill: attr.AutofillAble == "yes"})
+	}
+	return &row
+}
+
+// buildCiDataPlanAutofill 找出下游会被重新计算的自动填充属性
+func buildCiDataPlanAutofill(autofillChainMap map[string][]*models.AutofillChainObj) (result []*models.CiDataPlanAutofillObj, err error) {
+	result = []*models.CiDataPlanAutofillObj{}
+	affectMap := make(map[string]*models.CiDataPlanAutofillObj)
+	for ciType, rows := range autofillChainMap {
+		ciDepColumnList, tmpErr := getCiTypeAutofillDepColumn(ciType)
+		if tmpErr != nil {
+			err = tmpErr
+			return
+		}
+		for _, row := range rows {
+			for _, ciColumnObj := range ciDepColumnList {
+				if !compareListIsJoin(row.UpdateColumn, ciColumnObj.UsedColumn) {
+					continue
+				}
+				if _, b := affectMap[ciColumnObj.AttrId]; !b {
+					affectMap[ciColumnObj.AttrId] = &models.CiDataPlanAutofillObj{CiType: ciColumnObj.CiTypeId, Attribute: ciColumnObj.CiAttrName}
+					result = append(result, affectMap[ciColumnObj.AttrId])
+				}
+				affectMap[ciColumnObj.AttrId].TriggerGuids = append(affectMap[ciColumnObj.AttrId].TriggerGuids, row.Guid)
+			}
+		}
+	}
+	return
+}
+
+func buildCiDataPlanUniquePath(uniquePathList []*models.AutoActiveHandleParam) (result []*models.CiDataPlanUniquePathObj) {
+	result = []*models.CiDataPlanUniquePathObj{}
+	for _, uniquePathObj := range uniquePathList {
+		tmpObj := models.CiDataPlanUniquePathObj{CiType: uniquePathObj.CiType, Operation: uniquePathObj.Operation, GuidList: []string{}}
+		for _, v := range uniquePathObj.Data {
+			tmpObj.GuidList = append(tmpObj.GuidList, v["guid"])
+		}
+		result = append(result, &tmpObj)
+	}
+	return
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
 	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
 	"strings"
+	"time"
 )
 
 func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *models.CiDataLegalGuidList, fromCore bool) (pageInfo models.PageInfo, rowData []map[string]interface{}, err error) {
@@ -101,7 +102,7 @@ func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *mod
 			filterSql += " and tt.guid in ('" + strings.Join(permission.GuidList, "','") + "') "
 		}
 	}
-	historyFlag := false
+	historyFlag, asOfFlag := false, false
 	if param.Dialect == nil {
 		param.Dialect = &models.QueryRequestDialect{QueryMode: "new"}
 	}
@@ -122,13 +123,29 @@ func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *mod
 		subBaseSql := fmt.Sprintf("select * from %s%s where id in (select max(id) from %s%s where history_state_confirmed=1 and guid in (select guid from %s) group by guid)",
 			HistoryTablePrefix, ciType, HistoryTablePrefix, ciType, ciType)
 		baseSql = fmt.Sprintf("SELECT %s FROM (%s) tt WHERE 1=1 %s ", queryColumn, subBaseSql, filterSql)
+	} else if param.Dialect.QueryMode == "asOf" {
+		// 按时间点从历史表还原数据,已删除的数据不返回
+		if _, parseErr := time.Parse(models.DateTimeFormat, param.Dialect.AsOf); parseErr != nil {
+			err = fmt.Errorf("Param asOf:%s is illegal,must be format like %s ", param.Dialect.AsOf, models.DateTimeFormat)
+			return
+		}
+		asOfFlag = true
+		if queryColumn != " * " {
+			queryColumn += ",tt.history_action,tt.history_state_confirmed,tt.history_time,tt.id"
+		}
+		subBaseSql := fmt.Sprintf("select * from %s%s where id in (select max(id) from %s%s where history_time<=? group by guid) and history_action<>'delete'",
+			HistoryTablePrefix, ciType, HistoryTablePrefix, ciType)
+		baseSql = fmt.Sprintf("SELECT %s FROM (%s) tt WHERE 1=1 %s ", queryColumn, subBaseSql, filterSql)
+		queryParam = append([]interface{}{param.Dialect.AsOf}, queryParam...)
 	} else {
 		baseSql = fmt.Sprintf("SELECT %s FROM %s tt WHERE 1=1 %s ", queryColumn, ciType, filterSql)
 	}
 	if param.Paging {
 		pageInfo.StartIndex = param.Pageable.StartIndex
 		pageInfo.PageSize = param.Pageable.PageSize
-		pageInfo.TotalRows = queryCount(baseSql, queryParam...)
+		if !param.SkipCount {
+			pageInfo.TotalRows = queryCount(baseSql, queryParam...)
+		}
 		pageSql, pageParam := transPageInfoToSQL(*param.Pageable)
 		baseSql += pageSql
 		queryParam = append(queryParam, pageParam...)
@@ -178,7 +195,9 @@ func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *mod
 		rowData = append(rowData, tmpMapObj)
 	}
 	if len(refAttrs) > 0 && !fromCore {
-		if historyFlag {
+		if asOfFlag {
+			err = fetchRefAttrAsOfData(rowData, refAttrs, param.Dialect.AsOf)
+		} else if historyFlag {
 			err = fetchRefAttrHistoryData(rowData, refAttrs)
 		} else {
 			err = fetchRefAttrData(rowData, refAttrs)
@@ -188,7 +207,7 @@ func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *mod
 		}
 		for i, row := range rowData {
 			for _, refAttr := range refAttrs {
-				if historyFlag {
+				if historyFlag && !asOfFlag {
 					rowData[i][refAttr.Attribute.Name] = refAttr.RefObj[fmt.Sprintf("%s^%s", rowData[i][refAttr.Attribute.Name], rowData[i]["history_time"])]
 				} else {
 					rowData[i][refAttr.Attribute.Name] = refAttr.RefObj[row[refAttr.Attribute.Name].(string)]
@@ -197,7 +216,11 @@ func CiDataQuery(ciType string, param *models.QueryRequestParam, permission *mod
 		}
 	}
 	if len(multiRefAttrs) > 0 {
-		err = fetchMultiRefAttrData(rowData, multiRefAttrs, historyFlag)
+		if asOfFlag {
+			err = fetchMultiRefAttrAsOfData(rowData, multiRefAttrs)
+		} else {
+			err = fetchMultiRefAttrData(rowData, multiRefAttrs, historyFlag)
+		}
 		if err != nil {
 			return
 		}
@@ -402,3 +425,56 @@ func fetchMultiRefAttrData(rowData []map[string]interface{}, multiRefAttrs []*mo
 	}
 	return err
 }
+
+func fetchRefAttrAsOfData(rowData []map[string]interface{}, refAttrs []*models.CiDataQueryRefAttrObj, asOf string) error {
+	var err error
+	for _, row := range rowData {
+		for _, refAttr := range refAttrs {
+			refAttr.GuidList = append(refAttr.GuidList, row[refAttr.Attribute.Name].(string))
+		}
+	}
+	for _, refAttr := range refAttrs {
+		refRowDatas := []*models.CiDataRefDataObj{}
+		tmpErr := x.SQL(fmt.Sprintf("select guid,key_name,history_time from %s%s where id in (select max(id) from %s%s where guid in ('%s') and history_time<=? group by guid)",
+			HistoryTablePrefix, refAttr.Attribute.RefCiType, HistoryTablePrefix, refAttr.Attribute.RefCiType, strings.Join(refAttr.GuidList, "','")), asOf).Find(&refRowDatas)
+		if tmpErr != nil {
+			err = fmt.Errorf("Try to query ref attr:%s refCiType:%s asOf:%s fail,%s ", refAttr.Attribute.Name, refAttr.Attribute.RefCiType, asOf, tmpErr.Error())
+			break
+		}
+		refRowMap := make(map[string]*models.CiDataRefDataObj)
+		for _, refRow := range refRowDatas {
+			refRowMap[refRow.Guid] = refRow
+		}
+		refAttr.RefObj = refRowMap
+	}
+	return err
+}
+
+// fetchMultiRefAttrAsOfData rowData是主历史表的记录,需要带guid和历史记录id,取不晚于该历史记录时间的最后一次多对多记录
+func fetchMultiRefAttrAsOfData(rowData []map[string]interface{}, multiRefAttrs []*models.CiDataQueryRefAttrObj) error {
+	var err error
+	if len(rowData) == 0 {
+		return err
+	}
+	var historyIdList []string
+	for _, row := range rowData {
+		historyIdList = append(historyIdList, fmt.Sprintf("%v", row["id"]))
+	}
+	specSql, queryParams := createListParams(historyIdList, "")
+	for _, attr := range multiRefAttrs {
+		// 多对多历史表每次变更都会全量记录一次,清空时记录一条to_guid为空的记录
+		tableName := fmt.Sprintf("%s%s$%s", HistoryTablePrefix, attr.Attribute.CiType, attr.Attribute.Name)
+		tmpQueryData, tmpErr := x.QueryString(append([]interface{}{fmt.Sprintf("select t1.from_guid,t1.to_guid,t2.key_name from %s t1 join (select m.from_guid,max(m.history_time) history_time from %s m join %s%s h on h.guid=m.from_guid and m.history_time<=h.history_time where h.id in (%s) group by m.from_guid) t3 on t1.from_guid=t3.from_guid and t1.history_time=t3.history_time left join %s%s t2 on t1.history_to_id=t2.id where t1.to_guid<>'' order by t1.from_guid,t1.seq_no",
+			tableName, tableName, HistoryTablePrefix, attr.Attribute.CiType, specSql, HistoryTablePrefix, attr.Attribute.RefCiType)}, queryParams...)...)
+		if tmpErr != nil {
+			err = fmt.Errorf("Try to query multi ref attr:%s refCiType:%s history fail,%s ", attr.Attribute.Name, attr.Attribute.RefCiType, tmpErr.Error())
+			break
+		}
+		guidGroupMap := make(map[string][]*models.CiDataRefDataObj)
+		for _, row := range tmpQueryData {
+			guidGroupMap[row["from_guid"]] = append(guidGroupMap[row["from_guid"]], &models.CiDataRefDataObj{Guid: row["to_guid"], KeyName: row["key_name"]})
+		}
+		attr.MultiRefObj = guidGroupMap
+	}
+	return err
+}
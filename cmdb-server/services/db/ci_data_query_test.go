@@ -0,0 +1,52 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataQueryAsOfMultiRef(t *testing.T) {
+	target := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "q1", "asset_id": "asset-q1", "key_name": "q1"})
+	source := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "q2", "asset_id": "asset-q2", "key_name": "q2", "depend_host": target[0]["guid"]})
+	guid := source[0]["guid"]
+	// 把新增的历史挪到过去,后面清空引用的历史才能按时间点区分
+	for _, tableName := range []string{"history_test_host", "history_test_host$depend_host"} {
+		column := "guid"
+		if tableName != "history_test_host" {
+			column = "from_guid"
+		}
+		if _, err := x.Exec("update "+tableName+" set history_time=? where "+column+"=?", "2020-01-01 00:00:00", guid); err != nil {
+			t.Fatalf("update history time fail,%s", err.Error())
+		}
+	}
+	handleTestOperation(t, "Change", models.CiDataMapObj{"guid": guid, "depend_host": "[]"})
+	if num := countTestRows(t, "select * from test_host$depend_host where from_guid=?", guid); num != 0 {
+		t.Fatalf("clear multi ref should not keep any row,num:%d", num)
+	}
+	queryDependHost := func(asOf string) []*models.CiDataRefDataObj {
+		param := models.QueryRequestParam{Filters: []*models.QueryRequestFilterObj{{Name: "guid", Operator: "eq", Value: guid}},
+			Dialect: &models.QueryRequestDialect{QueryMode: "asOf", AsOf: asOf}}
+		_, rowData, err := CiDataQuery(testCiType, &param, &models.CiDataLegalGuidList{Disable: true}, false)
+		if err != nil {
+			t.Fatalf("query asOf:%s fail,%s", asOf, err.Error())
+		}
+		if len(rowData) != 1 {
+			t.Fatalf("query asOf:%s row num:%d", asOf, len(rowData))
+		}
+		return rowData[0]["depend_host"].([]*models.CiDataRefDataObj)
+	}
+	if refList := queryDependHost("2021-01-01 00:00:00"); len(refList) != 1 || refList[0].Guid != target[0]["guid"] {
+		t.Fatalf("multi ref before clear not match:%v", refList)
+	}
+	if refList := queryDependHost(time.Now().Add(time.Minute).Format(models.DateTimeFormat)); len(refList) != 0 {
+		t.Fatalf("multi ref after clear should be empty:%v", refList)
+	}
+	if _, _, err := CiDataQuery(testCiType, &models.QueryRequestParam{Dialect: &models.QueryRequestDialect{QueryMode: "asOf", AsOf: "2021-01-01' or '1'='1"}},
+		&models.CiDataLegalGuidList{Disable: true}, false); err == nil {
+		t.Fatalf("illegal asOf should return error")
+	}
+}
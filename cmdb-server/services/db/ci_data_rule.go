@@ -7,12 +7,9 @@ import (
 	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
 	"strconv"
 	"strings"
-	"time"
 )
 
 var (
-	affectGuidListChan   = make(chan map[string][]*models.AutofillChainObj, 100)
-	affectCiTypeChan     = make(chan string, 10)
 	uniquePathHandleChan = make(chan []*models.AutoActiveHandleParam, 100)
 	specialEqualChar     = models.SEPERATOR + "=" + models.SEPERATOR
 	specialSeparateChar  = "," + models.SEPERATOR
@@ -167,6 +164,11 @@ func buildAutofillValue(columnMap map[string]string, rule, attrInputType string)
 }
 
 func getRuleValue(rowData map[string]string, ruleString string) (resultValueList []string, isTypeAutofill bool, err error) {
+	return getRuleValueWithTrace(rowData, ruleString, nil)
+}
+
+// getRuleValueWithTrace trace不为空时每一跳查出的数据都会回调,用于解释自动填充规则
+func getRuleValueWithTrace(rowData map[string]string, ruleString string, trace func(hop *models.CiAutofillExplainHop, rowDataList []map[string]string)) (resultValueList []string, isTypeAutofill bool, err error) {
 	var ruleList []*models.AutofillValueObj
 	err = json.Unmarshal([]byte(ruleString), &ruleList)
 	if err != nil {
@@ -249,6 +251,16 @@ func getRuleValue(rowData map[string]string, ruleString string) (resultValueList
 		if err != nil {
 			break
 		}
+		if trace != nil {
+			hop := models.CiAutofillExplainHop{Index: i, CiType: rule.CiTypeId, Attr: rule.ParentRs.AttrId, Direction: ciExprJoinReverse, Filters: []string{}}
+			if rule.ParentRs.IsReferedFromParent == 1 {
+				hop.Direction = ciExprJoinRef
+			}
+			for _, f := range rule.Filters {
+				hop.Filters = append(hop.Filters, fmt.Sprintf("%s %s %v", f.Name, f.Operator, f.Value))
+			}
+			trace(&hop, rowDataList)
+		}
 		//if len(rowDataList) == 0 {
 		//	resultValueList = []string{specialNullChar}
 		//	break
@@ -259,14 +271,16 @@ func getRuleValue(rowData map[string]string, ruleString string) (resultValueList
 
 func getReferRowDataByFilter(ciTypeId, attr string, filters []*models.AutofillFilterObj, rowDataList []map[string]string, multiRef bool, inputType string, startRowData map[string]string) (rowMapList []map[string]string, err error) {
 	var filterSqlList, rowGuidList []string
+	var filterParams []interface{}
 	for _, f := range filters {
 		f.CiType = ciTypeId
-		tmpSql, tmpErr := getFilterSql(f, "t1", inputType, startRowData)
+		tmpSql, tmpParams, tmpErr := getFilterSql(f, "t1", inputType, startRowData)
 		if tmpErr != nil {
 			err = fmt.Errorf("Get filter:%s sql error:%s ", f, tmpErr.Error())
 			break
 		}
 		filterSqlList = append(filterSqlList, tmpSql)
+		filterParams = append(filterParams, tmpParams...)
 	}
 	if err != nil {
 		return
@@ -274,85 +288,65 @@ func getReferRowDataByFilter(ciTypeId, attr string, filters []*models.AutofillFi
 	for _, rowData := range rowDataList {
 		rowGuidList = append(rowGuidList, rowData["guid"])
 	}
+	if len(rowGuidList) == 0 {
+		rowGuidList = []string{""}
+	}
+	guidSpecSql, queryParams := createListParams(rowGuidList, "")
 	var sql string
 	if !multiRef {
-		sql = fmt.Sprintf("select * from %s t1 where t1.%s in ('%s')", ciTypeId, attr, strings.Join(rowGuidList, "','"))
+		sql = fmt.Sprintf("select * from %s t1 where t1.%s in (%s)", ciTypeId, attr, guidSpecSql)
 	} else {
-		sql = fmt.Sprintf("select distinct t1.* from %s t1 join %s$%s t2 on t1.guid=t2.from_guid where t2.to_guid in ('%s')", ciTypeId, ciTypeId, attr, strings.Join(rowGuidList, "','"))
+		sql = fmt.Sprintf("select distinct t1.* from %s t1 join %s$%s t2 on t1.guid=t2.from_guid where t2.to_guid in (%s)", ciTypeId, ciTypeId, attr, guidSpecSql)
 	}
 	if len(filterSqlList) > 0 {
 		sql += " AND " + strings.Join(filterSqlList, " AND ")
+		queryParams = append(queryParams, filterParams...)
 	}
 	log.Logger.Debug("getReferRowDataByFilter", log.String("sql", sql))
-	rowMapList, err = x.QueryString(sql)
+	rowMapList, err = x.QueryString(append([]interface{}{sql}, queryParams...)...)
 	if err != nil {
 		log.Logger.Error("Get reference row data by filter fail", log.Error(err))
 	}
 	return
 }
 
-func getFilterSql(filter *models.AutofillFilterObj, prefix, inputType string, startRowData map[string]string) (sql string, err error) {
-	var valueString, columnString string
+func getFilterSql(filter *models.AutofillFilterObj, prefix, inputType string, startRowData map[string]string) (sql string, queryParams []interface{}, err error) {
+	if !isCiExprIdentifier(filter.Name) {
+		err = fmt.Errorf("Filter name:%s is illegal ", filter.Name)
+		return
+	}
+	if !inStringList(filter.Operator, ciExprConditionOperatorList) {
+		err = fmt.Errorf("Filter operator:%s is illegal ", filter.Operator)
+		return
+	}
 	var valueList []string
 	if filter.Type == "autoFill" {
-		tmpValueString := filter.Value.(string)
+		tmpValueString, _ := filter.Value.(string)
 		valueList, err = buildAutofillValue(startRowData, tmpValueString, inputType)
 		log.Logger.Debug("getFilterSql value", log.StringList("valueList", valueList))
 		if err != nil {
 			err = fmt.Errorf("Build filter value error:%s ", err.Error())
 			return
 		}
-		if len(valueList) > 0 {
-			valueString = valueList[0]
+		if len(valueList) > 1 && filter.Operator != "in" {
+			valueList = valueList[:1]
 		}
-	} else if filter.Operator == "in" {
-		for _, rv := range filter.Value.([]interface{}) {
-			valueList = append(valueList, rv.(string))
+	} else if rightValueList, ok := filter.Value.([]interface{}); ok {
+		for _, rv := range rightValueList {
+			valueList = append(valueList, fmt.Sprintf("%v", rv))
 		}
 	}
-	if valueString == "" {
-		valueString = fmt.Sprintf("%s", filter.Value)
+	if len(valueList) == 0 && filter.Value != nil {
+		valueList = []string{fmt.Sprintf("%v", filter.Value)}
 	}
-	if prefix != "" {
-		columnString = filter.Name
-		filter.Name = fmt.Sprintf("%s.%s", prefix, filter.Name)
-	}
-	var multiSql string
-	switch filter.Operator {
-	case "in":
-		sql = fmt.Sprintf("%s IN ('%s')", filter.Name, strings.Join(valueList, "','"))
-		multiSql = fmt.Sprintf("to_guid IN ('%s')", strings.Join(valueList, "','"))
-		break
-	case "contains":
-		sql = fmt.Sprintf("%s LIKE '%%%s%%'", filter.Name, valueString)
-		break
-	case "eq":
-		sql = fmt.Sprintf("%s='%s'", filter.Name, valueString)
-		multiSql = fmt.Sprintf("to_guid='%s'", valueString)
-		break
-	case "gt":
-		sql = fmt.Sprintf("%s>'%s'", filter.Name, valueString)
-		break
-	case "lt":
-		sql = fmt.Sprintf("%s<'%s'", filter.Name, valueString)
-		break
-	case "ne":
-		sql = fmt.Sprintf("%s!='%s'", filter.Name, valueString)
-		multiSql = fmt.Sprintf("to_guid!='%s'", valueString)
-		break
-	case "notNull":
-		sql = fmt.Sprintf("%s IS NOT NULL", filter.Name)
-		break
-	case "null":
-		sql = fmt.Sprintf("%s IS NULL", filter.Name)
-		break
-	}
-	if isAttributeMultiRef(filter.CiType, columnString) {
-		if multiSql != "" {
+	if isAttributeMultiRef(filter.CiType, filter.Name) {
+		multiSql := ""
+		var multiParams []interface{}
+		if filter.Operator == "in" || filter.Operator == "eq" || filter.Operator == "ne" {
+			multiSql, multiParams = buildCiExprConditionSql("to_guid", filter.Operator, valueList)
 			multiSql = " and " + multiSql
 		}
-		log.Logger.Debug("query multi sql", log.String("multiSql", multiSql))
-		queryRows, queryErr := x.QueryString(fmt.Sprintf("select * from %s$%s where 1=1 %s", filter.CiType, columnString, multiSql))
+		queryRows, queryErr := x.QueryString(append([]interface{}{fmt.Sprintf("select from_guid from %s$%s where 1=1 %s", filter.CiType, filter.Name, multiSql)}, multiParams...)...)
 		if queryErr != nil {
 			err = fmt.Errorf("getFilterSql:Try to query multiRef fail,%s ", queryErr.Error())
 			return
@@ -361,8 +355,18 @@ func getFilterSql(filter *models.AutofillFilterObj, prefix, inputType string, st
 		for _, v := range queryRows {
 			guidList = append(guidList, v["from_guid"])
 		}
-		sql = fmt.Sprintf("guid in ('%s')", strings.Join(guidList, "','"))
+		column := "guid"
+		if prefix != "" {
+			column = prefix + ".guid"
+		}
+		sql, queryParams = buildCiExprConditionSql(column, "in", guidList)
+		return
+	}
+	column := filter.Name
+	if prefix != "" {
+		column = prefix + "." + filter.Name
 	}
+	sql, queryParams = buildCiExprConditionSql(column, filter.Operator, valueList)
 	return
 }
 
@@ -445,13 +449,15 @@ func GetCiDataByFilters(attrId string, filterMap map[string]string, reqParam mod
 		delete(filterMap, attrTable[0].Name)
 	}
 	var filterSqlList []string
+	var filterParams []interface{}
 	for _, filter := range filters[0] {
-		tmpFilterSql, tmpErr := getRefFilterSql(&filter, filterMap)
+		tmpFilterSql, tmpFilterParams, tmpErr := getRefFilterSql(&filter, filterMap)
 		if tmpErr != nil {
 			err = tmpErr
 			break
 		}
 		filterSqlList = append(filterSqlList, tmpFilterSql)
+		filterParams = append(filterParams, tmpFilterParams...)
 	}
 	if err != nil {
 		err = fmt.Errorf("Get ci reference data fail when build filter sql,%s ", err.Error())
@@ -461,7 +467,7 @@ func GetCiDataByFilters(attrId string, filterMap map[string]string, reqParam mod
 	if len(filterSqlList) > 0 {
 		querySql = fmt.Sprintf("select guid,key_name from %s where 1=1 AND (%s) order by update_time desc", attrTable[0].RefCiType, strings.Join(filterSqlList, ") AND ("))
 	}
-	rowStringData, queryErr := x.QueryString(querySql)
+	rowStringData, queryErr := x.QueryString(append([]interface{}{querySql}, filterParams...)...)
 	if queryErr != nil {
 		err = queryErr
 		return
@@ -490,91 +496,83 @@ func GetCiDataByFilters(attrId string, filterMap map[string]string, reqParam mod
 	return
 }
 
-func getRefFilterSql(filter *models.CiDataRefFilterObj, filterMap map[string]string) (sql string, err error) {
-	startCiType := filter.Left[:strings.LastIndex(filter.Left, "[")]
-	//if _, b := filterMap[startCiType]; b {
-	//	delete(filterMap, startCiType)
-	//}
-	column := filter.Left[strings.LastIndex(filter.Left, "[")+1 : strings.LastIndex(filter.Left, "]")]
+func getRefFilterSql(filter *models.CiDataRefFilterObj, filterMap map[string]string) (sql string, queryParams []interface{}, err error) {
+	leftExpr, _, err := parseAndValidateCiExpression(filter.Left)
+	if err != nil {
+		return
+	}
+	column := leftExpr.Segments[len(leftExpr.Segments)-1].ResultColumn
+	if column == "" {
+		err = fmt.Errorf("Filter left:%s must end with :[attribute] ", filter.Left)
+		return
+	}
+	if !inStringList(filter.Operator, ciExprConditionOperatorList) {
+		err = fmt.Errorf("Filter operator:%s is illegal ", filter.Operator)
+		return
+	}
+	rightValue := ""
+	if filter.Right.Value != nil {
+		rightValue = fmt.Sprintf("%v", filter.Right.Value)
+	}
 	var valueList []string
 	if filter.Right.Type == "expression" {
 		//Example: {"type":"expression","value":"app_instance.unit>unit.resource_set>resource_set~(resource_set)host_resource:[guid]"}
-		valueList, err = getExpressResultList(fmt.Sprintf("%s", filter.Right.Value), startCiType, filterMap, false)
+		valueList, err = getExpressResultList(rightValue, "", filterMap, false)
 		if err != nil {
 			return
 		}
 		if len(valueList) == 0 {
-			if _, b := filterMap[column]; b {
-				if filterMap[column] != "" {
-					valueList = append(valueList, filterMap[column])
-				}
+			if filterMap[column] != "" {
+				valueList = append(valueList, filterMap[column])
 			}
 		}
 	} else if filter.Right.Type == "array" {
 		//Example: ["JAVA","NGINX","MYSQL"]
-		for _, rv := range filter.Right.Value.([]interface{}) {
-			valueList = append(valueList, rv.(string))
+		if rightValueList, ok := filter.Right.Value.([]interface{}); ok {
+			for _, rv := range rightValueList {
+				valueList = append(valueList, fmt.Sprintf("%v", rv))
+			}
 		}
+	} else if rightValue != "" {
+		valueList = getCiExprConditionValueList(filter.Operator, []string{strings.ReplaceAll(rightValue, "'", "")})
 	}
-	if strings.Contains(filter.Left, ">") || strings.Contains(filter.Left, "~") {
-		valueList, err = getLeftFilterResultList(filter.Left, filter.Operator, fmt.Sprintf("%s", filter.Right.Value), valueList, filterMap)
-		sql = buildConditionSql("guid", "in", "", valueList)
+	if len(leftExpr.Segments) > 1 {
+		valueList, err = getLeftFilterResultList(leftExpr, filter.Operator, valueList, filterMap)
+		if err != nil {
+			return
+		}
+		sql, queryParams = buildCiExprConditionSql("guid", "in", valueList)
 	} else {
-		sql = buildConditionSql(column, filter.Operator, fmt.Sprintf("%s", filter.Right.Value), valueList)
+		sql, queryParams = buildCiExprConditionSql(column, filter.Operator, valueList)
 	}
 	log.Logger.Debug("getRefFilterSql", log.String("sql", sql))
 	return
 }
 
-type expressionSqlObj struct {
-	Table           string
-	IndexTableName  string
-	LeftJoinColumn  string
-	RightJoinColumn string
-	WhereSql        string
-	ResultColumn    string
-	RefColumn       string
-	MultiRefTable   string
-}
-
 func getConditionExpressResult(express, startCiType string, filterMap map[string]string, permission bool) (result []string, err error) {
-	var filterParams, tmpSplitList []string
-	tmpExpress := express
-	if strings.Contains(tmpExpress, "'") {
-		tmpSplitList = strings.Split(tmpExpress, "'")
-		tmpExpress = ""
-		for i, v := range tmpSplitList {
-			if i%2 == 0 {
-				if i == len(tmpSplitList)-1 {
-					tmpExpress += v
-				} else {
-					tmpExpress += fmt.Sprintf("%s'$%d'", v, i/2)
-				}
-			} else {
-				filterParams = append(filterParams, strings.ReplaceAll(v, "'", ""))
-			}
-		}
+	exprList, err := parseCiExpressionList(express)
+	if err != nil {
+		return
 	}
-	if strings.Contains(tmpExpress, ",") {
-		tmpList := [][]string{}
-		for _, v := range strings.Split(tmpExpress, ",") {
-			for ii, vv := range filterParams {
-				v = strings.ReplaceAll(v, fmt.Sprintf("$%d", ii), vv)
-			}
-			tmpResult, tmpErr := getExpressResultList(v, startCiType, filterMap, permission)
-			if tmpErr != nil {
-				err = tmpErr
-				break
-			}
-			tmpList = append(tmpList, tmpResult)
+	tmpList := [][]string{}
+	for _, expr := range exprList {
+		attrMap, tmpErr := validateCiExpression(expr)
+		if tmpErr != nil {
+			err = tmpErr
+			return
 		}
-		if err != nil {
+		tmpResult, tmpErr := queryCiExpression(expr, attrMap, filterMap, permission)
+		if tmpErr != nil {
+			err = tmpErr
 			return
 		}
-		result = getSameElementList(tmpList)
-		return
+		tmpList = append(tmpList, tmpResult)
+	}
+	if len(tmpList) == 1 {
+		return tmpList[0], nil
 	}
-	return getExpressResultList(express, startCiType, filterMap, permission)
+	result = getSameElementList(tmpList)
+	return
 }
 
 func getSameElementList(input [][]string) []string {
@@ -597,259 +595,19 @@ func getSameElementList(input [][]string) []string {
 	return result
 }
 
+// getExpressResultList 解析表达式并查询结果,permission为true时返回第一段数据的guid
+// Example expression -> "host_resource_instance.resource_set>resource_set~(resource_set)unit[{key_name eq 'hhh'},{code in ['u','v']}]:[guid]"
 func getExpressResultList(express, startCiType string, filterMap map[string]string, permission bool) (result []string, err error) {
 	log.Logger.Debug("getExpressResultList", log.String("express", express))
-	// Example expression -> "host_resource_instance.resource_set>resource_set~(resource_set)unit[{key_name eq 'hhh'},{code in ['u','v']}]:[guid]"
-	var ciList, filterParams, tmpSplitList []string
-	// replace content 'xxx' to '$1' in case of content have '>~.:()[]'
-	if strings.Contains(express, "'") {
-		tmpSplitList = strings.Split(express, "'")
-		express = ""
-		for i, v := range tmpSplitList {
-			if i%2 == 0 {
-				if i == len(tmpSplitList)-1 {
-					express += v
-				} else {
-					express += fmt.Sprintf("%s'$%d$'", v, i/2)
-				}
-			} else {
-				filterParams = append(filterParams, strings.ReplaceAll(v, "'", ""))
-			}
-		}
-	}
-	if permission {
-		firstGuidIndex := strings.Index(express, ":[guid]")
-		if firstGuidIndex > 0 {
-			express = express[:firstGuidIndex] + express[firstGuidIndex+7:]
-		}
-	}
-	// split with > or ~
-	var cursor int
-	for i, v := range express {
-		if v == 62 || v == 126 {
-			ciList = append(ciList, express[cursor:i])
-			cursor = i
-		}
-	}
-	ciList = append(ciList, express[cursor:])
-	// analyze each ci segment
-	var expressionSqlList []*expressionSqlObj
-	for i, ci := range ciList {
-		eso := expressionSqlObj{IndexTableName: fmt.Sprintf("t%d", i)}
-		if strings.HasPrefix(ci, ">") {
-			eso.LeftJoinColumn = ciList[i-1][strings.LastIndex(ciList[i-1], ".")+1:]
-			ci = ci[1:]
-		} else if strings.HasPrefix(ci, "~") {
-			eso.RightJoinColumn = ci[2:strings.Index(ci, ")")]
-			eso.RefColumn = eso.RightJoinColumn
-			ci = ci[strings.Index(ci, ")")+1:]
-		}
-		// ASCII . -> 46 , [ -> 91 , ] -> 93 , : -> 58 , { -> 123 , } -> 125
-		for j, v := range ci {
-			if v == 46 || v == 58 || v == 91 {
-				eso.Table = ci[:j]
-				ci = ci[j:]
-				break
-			}
-		}
-		if eso.Table == "" {
-			eso.Table = ci
-		}
-		if ci[0] == 91 {
-			tmpFilterStr := ci[2 : len(ci)-2]
-			for j, v := range ci {
-				if v == 46 || v == 58 {
-					tmpFilterStr = ci[2 : j-2]
-					ci = ci[j:]
-					break
-				}
-			}
-			for _, v := range strings.Split(tmpFilterStr, "},{") {
-				tmpFilterList := strings.Split(v, " ")
-				if len(tmpFilterList) > 2 {
-					eso.WhereSql += " and " + buildConditionSql(fmt.Sprintf("%s.%s", eso.IndexTableName, tmpFilterList[0]), tmpFilterList[1], tmpFilterList[2], []string{})
-				} else {
-					eso.WhereSql += " and " + buildConditionSql(fmt.Sprintf("%s.%s", eso.IndexTableName, tmpFilterList[0]), tmpFilterList[1], "", []string{})
-				}
-			}
-		}
-		if ci[0] == 58 {
-			eso.ResultColumn = ci[2 : len(ci)-1]
-		}
-		if i == 0 && !permission && len(ciList) > 1 {
-			continue
-		}
-		expressionSqlList = append(expressionSqlList, &eso)
-	}
-	eLen := len(expressionSqlList)
-	if eLen == 0 {
+	expr, attrMap, err := parseAndValidateCiExpression(express)
+	if err != nil {
 		return
 	}
-	checkFilterAttrMultiRef(expressionSqlList)
-	eLen = eLen - 1
-	sql := fmt.Sprintf("select %s.%s from ", expressionSqlList[eLen].IndexTableName, expressionSqlList[eLen].ResultColumn)
-	if permission {
-		sql = fmt.Sprintf("select %s.guid from ", expressionSqlList[0].IndexTableName)
-	}
-	var whereSql string
-	for i, v := range expressionSqlList {
-		tmpTableName := v.Table
-		if v.MultiRefTable != "" {
-			tmpTableName = v.MultiRefTable
-		}
-		if i == 0 {
-			sql += fmt.Sprintf(" %s %s ", tmpTableName, v.IndexTableName)
-		} else {
-			if v.LeftJoinColumn != "" {
-				sql += fmt.Sprintf(" left join %s %s on %s.%s=%s.guid ", tmpTableName, v.IndexTableName, expressionSqlList[i-1].IndexTableName, v.LeftJoinColumn, v.IndexTableName)
-			} else if expressionSqlList[i].RightJoinColumn != "" {
-				sql += fmt.Sprintf(" right join %s %s on %s.guid=%s.%s ", tmpTableName, v.IndexTableName, expressionSqlList[i-1].IndexTableName, v.IndexTableName, v.RightJoinColumn)
-			}
-		}
-		if v.WhereSql != "" {
-			whereSql += v.WhereSql
-		}
-		if i == 0 {
-			if filterValueString, b := filterMap[v.Table]; b {
-				whereSql += fmt.Sprintf(" and %s.guid in ('%s') ", v.IndexTableName, strings.Join(transStringToList(filterValueString), "','"))
-				//if strings.Contains(filterMap[v.Table], ",") {
-				//	whereSql += fmt.Sprintf(" and %s.guid in ('%s') ", v.IndexTableName, strings.Join(transStringToList(filterMap[v.Table]), "','"))
-				//} else {
-				//	whereSql += fmt.Sprintf(" and %s.guid='%s' ", v.IndexTableName, filterMap[v.Table])
-				//}
-			} else {
-				joinColumn := ""
-				if v.LeftJoinColumn != "" {
-					joinColumn = v.LeftJoinColumn
-				} else if v.RightJoinColumn != "" {
-					joinColumn = v.RightJoinColumn
-				}
-				if joinFilterValueString, ok := filterMap[joinColumn]; ok {
-					whereSql += fmt.Sprintf(" and %s.guid in ('%s') ", v.IndexTableName, strings.Join(transStringToList(joinFilterValueString), "','"))
-					//if strings.Contains(filterMap[joinColumn], ",") {
-					//	whereSql += fmt.Sprintf(" and %s.guid in ('%s') ", v.IndexTableName, strings.Join(transStringToList(filterMap[joinColumn]), "','"))
-					//} else {
-					//	whereSql += fmt.Sprintf(" and %s.guid='%s' ", v.IndexTableName, filterMap[joinColumn])
-					//}
-				}
-			}
-		}
-	}
-	if whereSql != "" {
-		sql += " where 1=1 " + whereSql
-	}
-	for i, v := range filterParams {
-		sql = strings.ReplaceAll(sql, fmt.Sprintf("$%d$", i), v)
-	}
-	log.Logger.Debug("Expression filter sql", log.String("sql", sql))
-	queryResults, queryErr := x.QueryString(sql)
-	if queryErr != nil {
-		err = fmt.Errorf("Query expression filter sql error,%s ", queryErr.Error())
-	} else {
-		for _, queryRow := range queryResults {
-			if permission {
-				result = append(result, queryRow["guid"])
-			} else {
-				result = append(result, queryRow[expressionSqlList[eLen].ResultColumn])
-			}
-		}
-	}
-	return
-}
-
-func buildConditionSql(column, operator, value string, valueList []string) string {
-	if operator == "in" && len(valueList) == 0 && value != "" {
-		valueList = strings.Split(strings.ReplaceAll(value[1:len(value)-1], "'", ""), ",")
-	}
-	value = strings.ReplaceAll(value, "'", "")
-	var sql string
-	switch operator {
-	case "in":
-		sql = fmt.Sprintf("%s in ('%s')", column, strings.Join(valueList, "','"))
-		break
-	case "eq":
-		sql = fmt.Sprintf("%s='%s'", column, value)
-		break
-	case "ne":
-		sql = fmt.Sprintf("%s!='%s'", column, value)
-		break
-	case "notNull":
-		sql = fmt.Sprintf("%s IS NOT NULL", column)
-		break
-	case "null":
-		sql = fmt.Sprintf("%s IS NULL", column)
-		break
-	case "notEmpty":
-		sql = fmt.Sprintf("%s<>''", column)
-		break
-	case "empty":
-		sql = fmt.Sprintf("%s=''", column)
-		break
-	case "like":
-		sql = fmt.Sprintf("%s LIKE '%%%s%%'", column, value)
-		break
-	}
-	return sql
-}
-
-func checkFilterAttrMultiRef(expressionSqlList []*expressionSqlObj) {
-	for i := 0; i < len(expressionSqlList)-1; i++ {
-		if expressionSqlList[i+1].LeftJoinColumn != "" {
-			expressionSqlList[i].RefColumn = expressionSqlList[i+1].LeftJoinColumn
-		}
-	}
-	var ciAttrSqlList []string
-	for _, v := range expressionSqlList {
-		if v.RefColumn == "" {
-			continue
-		}
-		ciAttrSqlList = append(ciAttrSqlList, fmt.Sprintf("ci_type='%s' and name='%s'", v.Table, v.RefColumn))
-	}
-	if len(ciAttrSqlList) > 0 {
-		var attrTable []*models.SysCiTypeAttrTable
-		err := x.SQL(fmt.Sprintf("select id,ci_type,name,input_type,ref_filter from sys_ci_type_attr where (%s)", strings.Join(ciAttrSqlList, ") or ("))).Find(&attrTable)
-		if err != nil {
-			log.Logger.Error("check filter attribute if multi ref fail", log.Error(err))
-		} else {
-			for i, v := range expressionSqlList {
-				if v.RefColumn == "" {
-					continue
-				}
-				for _, attr := range attrTable {
-					if attr.CiType == v.Table && attr.Name == v.RefColumn {
-						if attr.InputType == models.MultiRefType {
-							expressionSqlList[i].MultiRefTable = fmt.Sprintf("(select %s.*,%s$%s.to_guid as %s from %s left join %s$%s on %s.guid=%s$%s.from_guid)",
-								v.Table, v.Table, v.RefColumn, v.RefColumn, v.Table, v.Table, v.RefColumn, v.Table, v.Table, v.RefColumn)
-						}
-						break
-					}
-				}
-			}
-		}
-	}
+	return queryCiExpression(expr, attrMap, filterMap, permission)
 }
 
-func StartConsumeAffectGuidMap() {
-	log.Logger.Debug("Start consume affect guid map cron job")
-	for {
-		autofillChainMap := <-affectGuidListChan
-		go handleAffectGuidMap(autofillChainMap)
-	}
-}
-
-func StartConsumeAffectCiType() {
-	log.Logger.Debug("Start consume affect ci type cron job")
-	for {
-		ciType := <-affectCiTypeChan
-		go handleAffectCiType(ciType)
-	}
-}
-
-func handleAffectGuidMap(autofillChainMap map[string][]*models.AutofillChainObj) {
-	log.Logger.Debug("Start handle affect guid list")
-	//if len(autofillChainMap) > 0 {
-	//	return
-	//}
+// getAutofillChainGuidList 找出这些数据更新后,自动填充规则用到了更新列的其它ci数据,以及删除多选引用后需要重算的数据
+func getAutofillChainGuidList(autofillChainMap map[string][]*models.AutofillChainObj) (guidList []string) {
 	affectCiMap := make(map[string]*models.AutofillChainCiColumn)
 	for k, rows := range autofillChainMap {
 		ciDepColumnList, tmpErr := getCiTypeAutofillDepColumn(k)
@@ -869,50 +627,33 @@ func handleAffectGuidMap(autofillChainMap map[string][]*models.AutofillChainObj)
 			}
 		}
 	}
-	if len(affectCiMap) == 0 {
-		log.Logger.Debug("End handle affect guid list,no ci to update")
-		return
-	}
-	nowTime := time.Now().Format(models.DateTimeFormat)
+	existMap := make(map[string]bool)
 	for _, attr := range affectCiMap {
 		affectGuidList := findAutofillGuidDepList(attr)
 		log.Logger.Debug("Handle affect autofill guid list", log.StringList("affect", affectGuidList))
-		if len(affectGuidList) == 0 {
-			continue
-		}
-		// Do update affect row
 		for _, row := range affectGuidList {
-			autofillAffectActionFunc(attr.CiTypeId, row, nowTime)
+			if !existMap[row] && strings.HasPrefix(row, attr.CiTypeId+"_") {
+				existMap[row] = true
+				guidList = append(guidList, row)
+			}
 		}
 	}
 	for _, rows := range autofillChainMap {
 		for _, row := range rows {
 			if row.MultiColumnDelMap != nil {
 				log.Logger.Debug("MultiColumnDelMap", log.JsonObj("data", row.MultiColumnDelMap))
-				for _, guidList := range row.MultiColumnDelMap {
-					for _, rowGuid := range guidList {
-						if lastIndex := strings.LastIndex(rowGuid, "_"); lastIndex >= 0 {
-							tmpCiType := rowGuid[:lastIndex]
-							autofillAffectActionFunc(tmpCiType, rowGuid, nowTime)
+				for _, tmpGuidList := range row.MultiColumnDelMap {
+					for _, rowGuid := range tmpGuidList {
+						if !existMap[rowGuid] && strings.LastIndex(rowGuid, "_") > 0 {
+							existMap[rowGuid] = true
+							guidList = append(guidList, rowGuid)
 						}
 					}
 				}
 			}
 		}
 	}
-}
-
-func handleAffectCiType(ciType string) {
-	log.Logger.Info("start handle affect ci type autofill mode", log.String("ciType", ciType))
-	queryRows, err := x.QueryString(fmt.Sprintf("select guid from %s", ciType))
-	if err != nil {
-		log.Logger.Error("Try to handle affect ci type fail,query ci data error", log.Error(err))
-		return
-	}
-	nowTime := time.Now().Format(models.DateTimeFormat)
-	for _, row := range queryRows {
-		autofillAffectActionFunc(ciType, row["guid"], nowTime)
-	}
+	return
 }
 
 // 查询其它ci中自动填充用到该ciType的ci,比如说 A->B.b C->B.c,则查询出自动填充中用了ciType:B的 A[b],C[c]
@@ -1135,53 +876,27 @@ func consumeUniquePathHandle(uniquePathList []*models.AutoActiveHandleParam) {
 	}
 }
 
-func getLeftFilterResultList(left, operator, value string, rightValueList []string, filterMap map[string]string) (valueList []string, err error) {
-	log.Logger.Debug("getLeftFilterResultList", log.String("left before", left))
-	column := left[strings.LastIndex(left, "[")+1 : strings.LastIndex(left, "]")]
-	left = left[:strings.LastIndex(left, ":")] + fmt.Sprintf("[{%s}]", buildLeftExpressCondition(column, operator, value, rightValueList))
-	log.Logger.Debug("getLeftFilterResultList", log.String("left after", left))
-	valueList, err = getExpressResultList(left, "", filterMap, true)
+// getLeftFilterResultList 把过滤条件加到左边表达式的最后一段上,查出第一段满足条件的数据guid
+func getLeftFilterResultList(leftExpr *ciExpression, operator string, rightValueList []string, filterMap map[string]string) (valueList []string, err error) {
+	expr := *leftExpr
+	expr.Segments = make([]*ciExprSegment, len(leftExpr.Segments))
+	copy(expr.Segments, leftExpr.Segments)
+	lastSegment := *expr.Segments[len(expr.Segments)-1]
+	lastSegment.Filters = append(append([]*ciExprFilter{}, lastSegment.Filters...), &ciExprFilter{Pos: lastSegment.ResultPos, Column: lastSegment.ResultColumn, Operator: operator, Values: rightValueList, IsList: operator == "in"})
+	lastSegment.ResultColumn = ""
+	expr.Segments[len(expr.Segments)-1] = &lastSegment
+	log.Logger.Debug("getLeftFilterResultList", log.String("left after", expr.String()))
+	attrMap, err := validateCiExpression(&expr)
+	if err != nil {
+		return
+	}
+	valueList, err = queryCiExpression(&expr, attrMap, filterMap, true)
 	if err != nil {
 		err = fmt.Errorf("Try to analyze filter left express fail,%s ", err.Error())
 	}
 	return
 }
 
-func buildLeftExpressCondition(column, operator, value string, valueList []string) string {
-	if operator == "in" && len(valueList) == 0 && value != "" {
-		valueList = strings.Split(strings.ReplaceAll(value[1:len(value)-1], "'", ""), ",")
-	}
-	value = strings.ReplaceAll(value, "'", "")
-	var sql string
-	switch operator {
-	case "in":
-		sql = fmt.Sprintf("%s in ['%s']", column, strings.Join(valueList, "','"))
-		break
-	case "eq":
-		sql = fmt.Sprintf("%s %s '%s'", column, operator, value)
-		break
-	case "ne":
-		sql = fmt.Sprintf("%s %s '%s'", column, operator, value)
-		break
-	case "notNull":
-		sql = fmt.Sprintf("%s %s", column, operator)
-		break
-	case "null":
-		sql = fmt.Sprintf("%s %s", column, operator)
-		break
-	case "notEmpty":
-		sql = fmt.Sprintf("%s %s", column, operator)
-		break
-	case "empty":
-		sql = fmt.Sprintf("%s %s", column, operator)
-		break
-	case "like":
-		sql = fmt.Sprintf("%s %s '%s'", column, operator, value)
-		break
-	}
-	return sql
-}
-
 func transStringToList(input string) (output []string) {
 	if strings.HasPrefix(input, "[") {
 		if tmpErr := json.Unmarshal([]byte(input), &output); tmpErr == nil {
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const (
+	ciTopologyDefaultDepth = 2
+	ciTopologyMaxDepth     = 10
+	ciTopologyMaxNodes     = 2000
+)
+
+type ciTopologyWalker struct {
+	param     *models.CiTopologyParam
+	roles     []string
+	attrList  []*models.SysCiTypeAttrTable
+	attrMap   map[string]*models.SysCiTypeAttrTable
+	legalMap  map[string]*models.CiDataLegalGuidList
+	nodeMap   map[string]*models.CiTopologyNode
+	edgeMap   map[string]bool
+	result    *models.CiTopologyResult
+	truncated bool
+}
+
+// CiDataTopology 从guid出发按ref属性和multiRef关系表逐层遍历,返回maxDepth层以内有查询权限的数据和引用关系
+func CiDataTopology(guid string, param models.CiTopologyParam, roles []string) (result models.CiTopologyResult, err error) {
+	result = models.CiTopologyResult{Nodes: []*models.CiTopologyNode{}, Edges: []*models.CiTopologyEdge{}}
+	if !strings.Contains(guid, "_") {
+		err = fmt.Errorf("Guid:%s is illegal ", guid)
+		return
+	}
+	if param.Direction == "" {
+		param.Direction = models.TopologyDirectionAll
+	}
+	if param.Direction != models.TopologyDirectionOut && param.Direction != models.TopologyDirectionIn && param.Direction != models.TopologyDirectionAll {
+		err = fmt.Errorf("Direction:%s is illegal,must be one of %s,%s,%s ", param.Direction, models.TopologyDirectionOut, models.TopologyDirectionIn, models.TopologyDirectionAll)
+		return
+	}
+	if param.MaxDepth <= 0 {
+		param.MaxDepth = ciTopologyDefaultDepth
+	}
+	if param.MaxDepth > ciTopologyMaxDepth {
+		err = fmt.Errorf("MaxDepth:%d is too large,max is %d ", param.MaxDepth, ciTopologyMaxDepth)
+		return
+	}
+	// guid前缀会用作表名,先确认是已创建的ci类型
+	ciTypeId := guid[:strings.LastIndex(guid, "_")]
+	ciTypeRows, err := x.QueryString("select id from sys_ci_type where id=? and status='created'", ciTypeId)
+	if err != nil {
+		err = fmt.Errorf("Try to query ci type:%s fail,%s ", ciTypeId, err.Error())
+		return
+	}
+	if len(ciTypeRows) == 0 {
+		err = fmt.Errorf("Guid:%s is illegal,can not find created ci type:%s ", guid, ciTypeId)
+		return
+	}
+	walker := ciTopologyWalker{param: &param, roles: roles, attrMap: make(map[string]*models.SysCiTypeAttrTable), legalMap: make(map[string]*models.CiDataLegalGuidList),
+		nodeMap: make(map[string]*models.CiTopologyNode), edgeMap: make(map[string]bool), result: &result}
+	if err = walker.init(); err != nil {
+		return
+	}
+	startNodes, err := walker.addNodes(map[string][]string{ciTypeId: {guid}}, 0)
+	if err != nil {
+		return
+	}
+	if len(startNodes) == 0 {
+		err = fmt.Errorf("Can not find data with guid:%s or permission deny ", guid)
+		return
+	}
+	frontier := map[string][]string{ciTypeId: {guid}}
+	for depth := 1; depth <= param.MaxDepth && len(frontier) > 0 && !walker.truncated; depth++ {
+		if frontier, err = walker.walk(frontier, depth); err != nil {
+			return
+		}
+	}
+	result.Truncated = walker.truncated
+	return
+}
+
+// init 查出所有已创建ci类型中的引用属性,按参数过滤属性
+func (w *ciTopologyWalker) init() error {
+	var attrList []*models.SysCiTypeAttrTable
+	err := x.SQL("select id,ci_type,name,display_name,input_type,ref_ci_type from sys_ci_type_attr where input_type in ('ref',?) and status='created' and ci_type in (select id from sys_ci_type where status='created') and ref_ci_type in (select id from sys_ci_type where status='created')", models.MultiRefType).Find(&attrList)
+	if err != nil {
+		return fmt.Errorf("Try to query reference attributes fail,%s ", err.Error())
+	}
+	for _, attr := range attrList {
+		if len(w.param.Attrs) > 0 && !inStringList(attr.Id, w.param.Attrs) && !inStringList(attr.Name, w.param.Attrs) {
+			continue
+		}
+		w.attrList = append(w.attrList, attr)
+		w.attrMap[attr.Id] = attr
+	}
+	return nil
+}
+
+// walk 从当前层的数据出发找下一层,返回下一层新加入的数据
+func (w *ciTopologyWalker) walk(frontier map[string][]string, depth int) (nextFrontier map[string][]string, err error) {
+	var edgeList []*models.CiTopologyEdge
+	for _, attr := range w.attrList {
+		if w.param.Direction != models.TopologyDirectionIn {
+			if guidList, b := frontier[attr.CiType]; b {
+				tmpEdgeList, tmpErr := queryCiTopologyEdge(attr, guidList, true)
+				if tmpErr != nil {
+					return nil, tmpErr
+				}
+				edgeList = append(edgeList, tmpEdgeList...)
+			}
+		}
+		if w.param.Direction != models.TopologyDirectionOut {
+			if guidList, b := frontier[attr.RefCiType]; b {
+				tmpEdgeList, tmpErr := queryCiTopologyEdge(attr, guidList, false)
+				if tmpErr != nil {
+					return nil, tmpErr
+				}
+				edgeList = append(edgeList, tmpEdgeList...)
+			}
+		}
+	}
+	// 找出还没访问过的数据,按ci类型过滤后加入,两端的ci类型取属性上配置的类型
+	newGuidMap := make(map[string][]string)
+	for _, edge := range edgeList {
+		attr := w.attrMap[edge.Attr]
+		for i, rowGuid := range []string{edge.From, edge.To} {
+			if _, b := w.nodeMap[rowGuid]; b {
+				continue
+			}
+			rowCiType := attr.CiType
+			if i == 1 {
+				rowCiType = attr.RefCiType
+			}
+			if len(w.param.CiTypes) > 0 && !inStringList(rowCiType, w.param.CiTypes) {
+				continue
+			}
+			if !inStringList(rowGuid, newGuidMap[rowCiType]) {
+				newGuidMap[rowCiType] = append(newGuidMap[rowCiType], rowGuid)
+			}
+		}
+	}
+	if nextFrontier, err = w.addNodes(newGuidMap, depth); err != nil {
+		return
+	}
+	// 两端都在结果中的边才返回
+	for _, edge := range edgeList {
+		edgeKey := edge.From + models.SEPERATOR + edge.To + models.SEPERATOR + edge.Attr
+		if w.edgeMap[edgeKey] {
+			continue
+		}
+		if _, b := w.nodeMap[edge.From]; !b {
+			continue
+		}
+		if _, b := w.nodeMap[edge.To]; !b {
+			continue
+		}
+		w.edgeMap[edgeKey] = true
+		w.result.Edges = append(w.result.Edges, edge)
+	}
+	return
+}
+
+// addNodes 查询数据的key_name和状态,没有查询权限或者已经不存在的数据不加入,超过节点上限时截断
+func (w *ciTopologyWalker) addNodes(guidMap map[string][]string, depth int) (addGuidMap map[string][]string, err error) {
+	addGuidMap = make(map[string][]string)
+	for ciType, guidList := range guidMap {
+		legalObj, tmpErr := w.getLegalGuidList(ciType)
+		if tmpErr != nil {
+			return nil, tmpErr
+		}
+		var queryGuidList []string
+		for _, rowGuid := range guidList {
+			if legalObj.Disable || inStringList(rowGuid, legalObj.GuidList) {
+				queryGuidList = append(queryGuidList, rowGuid)
+			}
+		}
+		if len(queryGuidList) == 0 {
+			continue
+		}
+		specSql, queryParams := createListParams(queryGuidList, "")
+		queryRows, tmpErr := x.QueryString(append([]interface{}{fmt.Sprintf("select guid,key_name,state from %s where guid in (%s)", ciType, specSql)}, queryParams...)...)
+		if tmpErr != nil {
+			return nil, fmt.Errorf("Try to query ci:%s data fail,%s ", ciType, tmpErr.Error())
+		}
+		for _, row := range queryRows {
+			if len(w.nodeMap) >= ciTopologyMaxNodes {
+				w.truncated = true
+				return
+			}
+			node := models.CiTopologyNode{Guid: row["guid"], CiType: ciType, KeyName: row["key_name"], State: row["state"], Depth: depth}
+			w.nodeMap[node.Guid] = &node
+			w.result.Nodes = append(w.result.Nodes, &node)
+			addGuidMap[ciType] = append(addGuidMap[ciType], node.Guid)
+		}
+	}
+	return
+}
+
+func (w *ciTopologyWalker) getLegalGuidList(ciType string) (*models.CiDataLegalGuidList, error) {
+	if legalObj, b := w.legalMap[ciType]; b {
+		return legalObj, nil
+	}
+	permission, err := GetRoleCiDataPermission(w.roles, ciType)
+	if err != nil {
+		return nil, err
+	}
+	legalGuidList, err := GetCiDataPermissionGuidList(&permission, "query")
+	if err != nil {
+		return nil, err
+	}
+	w.legalMap[ciType] = &legalGuidList
+	return &legalGuidList, nil
+}
+
+// queryCiTopologyEdge isOut为true时guidList是引用方,否则guidList是被引用方
+func queryCiTopologyEdge(attr *models.SysCiTypeAttrTable, guidList []string, isOut bool) (result []*models.CiTopologyEdge, err error) {
+	specSql, queryParams := createListParams(guidList, "")
+	var querySql string
+	if attr.InputType == models.MultiRefType {
+		if isOut {
+			querySql = fmt.Sprintf("select from_guid,to_guid from %s$%s where from_guid in (%s)", attr.CiType, attr.Name, specSql)
+		} else {
+			querySql = fmt.Sprintf("select from_guid,to_guid from %s$%s where to_guid in (%s)", attr.CiType, attr.Name, specSql)
+		}
+	} else {
+		if isOut {
+			querySql = fmt.Sprintf("select guid as from_guid,%s as to_guid from %s where guid in (%s)", attr.Name, attr.CiType, specSql)
+		} else {
+			querySql = fmt.Sprintf("select guid as from_guid,%s as to_guid from %s where %s in (%s)", attr.Name, attr.CiType, attr.Name, specSql)
+		}
+	}
+	queryRows, err := x.QueryString(append([]interface{}{querySql}, queryParams...)...)
+	if err != nil {
+		err = fmt.Errorf("Try to query reference data with attribute:%s fail,%s ", attr.Id, err.Error())
+		return
+	}
+	for _, row := range queryRows {
+		if row["from_guid"] == "" || row["to_guid"] == "" {
+			continue
+		}
+		result = append(result, &models.CiTopologyEdge{From: row["from_guid"], To: row["to_guid"], Attr: attr.Id, AttrName: attr.DisplayName, InputType: attr.InputType})
+	}
+	return
+}
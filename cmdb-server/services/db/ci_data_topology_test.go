@@ -0,0 +1,32 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataTopology(t *testing.T) {
+	target := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "p1", "asset_id": "asset-p1", "key_name": "p1"})
+	source := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "p2", "asset_id": "asset-p2", "key_name": "p2", "depend_host": target[0]["guid"]})
+	result, err := CiDataTopology(source[0]["guid"], models.CiTopologyParam{Direction: models.TopologyDirectionOut, MaxDepth: 1}, []string{"tester"})
+	if err != nil {
+		t.Fatalf("query topology fail,%s", err.Error())
+	}
+	if len(result.Nodes) != 2 || len(result.Edges) != 1 || result.Edges[0].To != target[0]["guid"] {
+		t.Fatalf("topology result not match,nodes:%d edges:%d", len(result.Nodes), len(result.Edges))
+	}
+	for _, node := range result.Nodes {
+		if node.CiType != testCiType {
+			t.Fatalf("node ci type not match:%+v", node)
+		}
+	}
+	// guid前缀不是已创建的ci类型时不能拿来当表名查询
+	for _, guid := range []string{"sys_ci_type_abc", "test_host where 1=1 --_abc"} {
+		if _, err = CiDataTopology(guid, models.CiTopologyParam{}, []string{"tester"}); err == nil {
+			t.Fatalf("guid:%s should be illegal", guid)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"sync"
+)
+
+// 同一个ciType的upsert在进程内先串行执行减少冲突,多个进程之间由事务里对sys_ci_type行加锁串行
+var ciDataUpsertLockMap sync.Map
+
+// errCiDataUpsertConflict 提交时发现要新增的唯一属性值已经被其他请求写入
+var errCiDataUpsertConflict = errors.New("Upsert unique attribute value is inserted by other request ")
+
+// ciDataUpsertMaxTry 冲突时重新匹配一次,此时已存在的数据会走更新
+const ciDataUpsertMaxTry = 2
+
+// CiDataUpsert 有guid的行按guid更新,没有guid的行按唯一属性匹配,匹配到的更新,匹配不到的走状态机开始状态新增,新增和更新在同一个事务里提交
+func CiDataUpsert(param models.HandleCiDataParam, uniqueAttr string) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	lock, _ := ciDataUpsertLockMap.LoadOrStore(param.CiTypeId, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	inputData := param.InputData
+	for i := 0; i < ciDataUpsertMaxTry; i++ {
+		param.InputData = copyCiDataList(inputData)
+		outputData, newInputBody, err = doCiDataUpsert(param, uniqueAttr)
+		if !errors.Is(err, errCiDataUpsertConflict) {
+			break
+		}
+		log.Logger.Warn("Upsert unique value conflict,try to match again", log.String("ciType", param.CiTypeId), log.String("uniqueAttr", uniqueAttr))
+	}
+	return
+}
+
+func doCiDataUpsert(param models.HandleCiDataParam, uniqueAttr string) (outputData []models.CiDataMapObj, newInputBody string, err error) {
+	insertList, updateList, err := splitCiDataUpsertRows(param.CiTypeId, uniqueAttr, param.InputData)
+	if err != nil {
+		return
+	}
+	var paramList []models.HandleCiDataParam
+	var preActions []*execAction
+	for _, handleObj := range []struct {
+		Action string
+		Rows   []models.CiDataMapObj
+	}{{"insert", insertList}, {"update", updateList}} {
+		if len(handleObj.Rows) == 0 {
+			continue
+		}
+		handleParam := param
+		handleParam.InputData = handleObj.Rows
+		handleParam.Operation = handleObj.Action
+		handleParam.BareAction = handleObj.Action
+		paramList = append(paramList, handleParam)
+	}
+	preActions = append(preActions, buildCiDataUpsertLockActions(param.CiTypeId, uniqueAttr, insertList)...)
+	if outputData, newInputBody, err = handleCiDataOperationList(paramList, preActions); err != nil {
+		if !errors.Is(err, errCiDataUpsertConflict) {
+			err = fmt.Errorf("Upsert %d rows fail,%s ", len(param.InputData), err.Error())
+		}
+	}
+	return
+}
+
+// buildCiDataUpsertLockActions 不存在的数据行锁不住,先锁住sys_ci_type中该ci类型的行,让同一ci类型的新增在事务里串行,
+// 拿到锁后再查要新增的唯一属性值,查到数据说明匹配之后被其他请求写入了
+func buildCiDataUpsertLockActions(ciType, uniqueAttr string, insertList []models.CiDataMapObj) (actions []*execAction) {
+	if uniqueAttr == "" || uniqueAttr == "guid" || len(insertList) == 0 {
+		return
+	}
+	actions = append(actions, &execAction{Sql: "select id from sys_ci_type where id=?" + dbDialect.ForUpdateSql(), Param: []interface{}{ciType},
+		QueryCheck: func(queryRows []map[string]string) error {
+			if len(queryRows) == 0 {
+				return fmt.Errorf("Can not find ciType:%s ", ciType)
+			}
+			return nil
+		}})
+	var valueList []string
+	for _, row := range insertList {
+		valueList = append(valueList, row[uniqueAttr])
+	}
+	specSql, queryParam := createListParams(valueList, "")
+	actions = append(actions, &execAction{Sql: fmt.Sprintf("select guid from %s where %s in (%s)%s", ciType, uniqueAttr, specSql, dbDialect.ForUpdateSql()), Param: queryParam,
+		QueryCheck: func(queryRows []map[string]string) error {
+			if len(queryRows) > 0 {
+				return errCiDataUpsertConflict
+			}
+			return nil
+		}})
+	return
+}
+
+func splitCiDataUpsertRows(ciType, uniqueAttr string, inputData []models.CiDataMapObj) (insertList, updateList []models.CiDataMapObj, err error) {
+	if uniqueAttr == "guid" {
+		uniqueAttr = ""
+	}
+	if uniqueAttr != "" {
+		attrs, getAttrErr := GetCiAttrByCiType(ciType, true)
+		if getAttrErr != nil {
+			err = getAttrErr
+			return
+		}
+		legalFlag := false
+		for _, attr := range attrs {
+			if attr.Name == uniqueAttr {
+				legalFlag = attr.UniqueConstraint == "yes" || attr.Name == "key_name"
+				break
+			}
+		}
+		if !legalFlag {
+			err = fmt.Errorf("Upsert unique attribute:%s is not a unique attribute of ciType:%s ", uniqueAttr, ciType)
+			return
+		}
+	}
+	var guidList, uniqueValueList []string
+	uniqueValueMap := make(map[string]bool)
+	for i, row := range inputData {
+		if row["guid"] != "" {
+			guidList = append(guidList, row["guid"])
+			continue
+		}
+		if uniqueAttr == "" {
+			continue
+		}
+		if row[uniqueAttr] == "" {
+			err = fmt.Errorf("Row:%d unique attribute:%s can not empty ", i, uniqueAttr)
+			return
+		}
+		if uniqueValueMap[row[uniqueAttr]] {
+			err = fmt.Errorf("Row:%d unique attribute:%s value:%s is duplicate in input data ", i, uniqueAttr, row[uniqueAttr])
+			return
+		}
+		uniqueValueMap[row[uniqueAttr]] = true
+		uniqueValueList = append(uniqueValueList, row[uniqueAttr])
+	}
+	existGuidMap := make(map[string]bool)
+	if len(guidList) > 0 {
+		specSql, queryParam := createListParams(guidList, "")
+		queryParam = append([]interface{}{fmt.Sprintf("select guid from %s where guid in (%s)", ciType, specSql)}, queryParam...)
+		queryRows, queryErr := x.QueryString(queryParam...)
+		if queryErr != nil {
+			err = fmt.Errorf("Try to query upsert exist data fail,%s ", queryErr.Error())
+			return
+		}
+		for _, row := range queryRows {
+			existGuidMap[row["guid"]] = true
+		}
+	}
+	uniqueGuidMap := make(map[string]string)
+	if len(uniqueValueList) > 0 {
+		specSql, queryParam := createListParams(uniqueValueList, "")
+		queryParam = append([]interface{}{fmt.Sprintf("select guid,%s from %s where %s in (%s)", uniqueAttr, ciType, uniqueAttr, specSql)}, queryParam...)
+		queryRows, queryErr := x.QueryString(queryParam...)
+		if queryErr != nil {
+			err = fmt.Errorf("Try to query upsert exist data fail,%s ", queryErr.Error())
+			return
+		}
+		for _, row := range queryRows {
+			uniqueGuidMap[row[uniqueAttr]] = row["guid"]
+		}
+	}
+	for i, row := range inputData {
+		if row["guid"] != "" {
+			if !existGuidMap[row["guid"]] {
+				err = fmt.Errorf("Row:%d can not find data with guid:%s ", i, row["guid"])
+				return
+			}
+			updateList = append(updateList, row)
+			continue
+		}
+		if existGuid, b := uniqueGuidMap[row[uniqueAttr]]; b && uniqueAttr != "" {
+			row["guid"] = existGuid
+			updateList = append(updateList, row)
+		} else {
+			insertList = append(insertList, row)
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+//go:build sqlite
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestCiDataUpsert(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "u1", "asset_id": "asset-u1", "key_name": "u1"})
+	outputData, _, err := CiDataUpsert(models.HandleCiDataParam{CiTypeId: testCiType, Operator: "tester", InputData: []models.CiDataMapObj{
+		{"code": "u1new", "asset_id": "asset-u1", "key_name": "u1"},
+		{"code": "u2", "asset_id": "asset-u2", "key_name": "u2"},
+	}}, "asset_id")
+	if err != nil {
+		t.Fatalf("upsert fail,%s", err.Error())
+	}
+	if len(outputData) != 2 {
+		t.Fatalf("upsert output num:%d", len(outputData))
+	}
+	if row := queryTestRow(t, "select * from test_host where asset_id=?", "asset-u1"); row["code"] != "u1new" {
+		t.Fatalf("upsert update row not match:%v", row)
+	}
+	if num := countTestRows(t, "select * from test_host where asset_id=?", "asset-u2"); num != 1 {
+		t.Fatalf("upsert insert row num:%d", num)
+	}
+}
+
+func TestCiDataUpsertLockAction(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "u3", "asset_id": "asset-u3", "key_name": "u3"})
+	// 匹配时还不存在的值在提交前被其他请求写入,事务里的检查要发现并回滚
+	lockActions := buildCiDataUpsertLockActions(testCiType, "asset_id", []models.CiDataMapObj{{"asset_id": "asset-u3"}})
+	if len(lockActions) != 2 || !strings.Contains(lockActions[0].Sql, "sys_ci_type") {
+		t.Fatalf("upsert should lock ci type row first:%v", lockActions)
+	}
+	insertAction := &execAction{Sql: "insert into test_host(guid,key_name,code,asset_id) value (?,?,?,?)", Param: []interface{}{"test_host_lock", "u4", "u4", "asset-u4"}}
+	if err := transaction(append(lockActions, insertAction)); !errors.Is(err, errCiDataUpsertConflict) {
+		t.Fatalf("lock action should return conflict error,%v", err)
+	}
+	if num := countTestRows(t, "select * from test_host where guid=?", "test_host_lock"); num != 0 {
+		t.Fatalf("conflict transaction should roll back,num:%d", num)
+	}
+	if len(buildCiDataUpsertLockActions(testCiType, "", []models.CiDataMapObj{{"asset_id": "asset-u3"}})) != 0 {
+		t.Fatalf("upsert by guid should not lock")
+	}
+}
+
+func TestCiDataUpsertConcurrentNewValue(t *testing.T) {
+	// 两个请求同时upsert同一个新值,最后只能有一行,另一个请求走更新
+	var wg sync.WaitGroup
+	errList := make([]error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			_, _, errList[index] = CiDataUpsert(models.HandleCiDataParam{CiTypeId: testCiType, Operator: "tester", InputData: []models.CiDataMapObj{
+				{"code": fmt.Sprintf("u5-%d", index), "asset_id": "asset-u5", "key_name": "u5"},
+			}}, "asset_id")
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errList {
+		if err != nil {
+			t.Fatalf("concurrent upsert fail,%s", err.Error())
+		}
+	}
+	if num := countTestRows(t, "select * from test_host where asset_id=?", "asset-u5"); num != 1 {
+		t.Fatalf("concurrent upsert row num:%d", num)
+	}
+	// 绕过进程内的锁,两个请求都按新增匹配后先后提交,后提交的要在事务里发现冲突
+	staleInsertList, _, err := splitCiDataUpsertRows(testCiType, "asset_id", []models.CiDataMapObj{{"code": "u6", "asset_id": "asset-u6", "key_name": "u6"}})
+	if err != nil || len(staleInsertList) != 1 {
+		t.Fatalf("split upsert rows fail,%v", err)
+	}
+	if _, _, err = doCiDataUpsert(models.HandleCiDataParam{CiTypeId: testCiType, Operator: "tester", InputData: []models.CiDataMapObj{{"code": "u6", "asset_id": "asset-u6", "key_name": "u6"}}}, "asset_id"); err != nil {
+		t.Fatalf("first upsert fail,%s", err.Error())
+	}
+	if err = transaction(buildCiDataUpsertLockActions(testCiType, "asset_id", staleInsertList)); !errors.Is(err, errCiDataUpsertConflict) {
+		t.Fatalf("second upsert should find conflict,%v", err)
+	}
+}
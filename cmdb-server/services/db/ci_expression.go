@@ -0,0 +1,415 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// 引用表达式语法,例如 host_resource_instance.resource_set>resource_set~(resource_set)unit[{key_name eq 'hhh'},{code in ['u','v']}]:[guid]
+//   expression := segment { '>' segment | '~' '(' ident ')' segment }
+//   segment    := ident [ '[' filter { ',' filter } ']' ] [ '.' ident ] [ ':' '[' ident ']' ]
+//   filter     := '{' ident operator [ value ] '}'
+//   value      := string | ident | '[' (string|ident) { ',' (string|ident) } ']'
+// a.b>c 表示a的引用属性b指向c, a~(b)c 表示c的引用属性b指向a
+
+const (
+	ciExprTokenEOF = iota
+	ciExprTokenIdent
+	ciExprTokenString
+	ciExprTokenSymbol
+)
+
+const (
+	ciExprJoinRef     = ">"
+	ciExprJoinReverse = "~"
+)
+
+// ciExprOperatorMap 过滤条件支持的操作符,值为是否需要右值
+var ciExprOperatorMap = map[string]bool{
+	"eq":       true,
+	"ne":       true,
+	"in":       true,
+	"like":     true,
+	"notNull":  false,
+	"null":     false,
+	"notEmpty": false,
+	"empty":    false,
+}
+
+type ciExprToken struct {
+	Type  int
+	Value string
+	Pos   int
+}
+
+type ciExpression struct {
+	Source   string
+	Segments []*ciExprSegment
+}
+
+type ciExprSegment struct {
+	Pos          int
+	CiType       string
+	JoinType     string
+	JoinColumn   string
+	JoinPos      int
+	Filters      []*ciExprFilter
+	RefAttr      string
+	RefAttrPos   int
+	ResultColumn string
+	ResultPos    int
+}
+
+type ciExprFilter struct {
+	Pos      int
+	Column   string
+	Operator string
+	Values   []string
+	IsList   bool
+}
+
+func newCiExpressionError(source string, pos int, format string, a ...interface{}) *models.CiExpressionError {
+	return &models.CiExpressionError{Expression: source, Position: pos, Message: fmt.Sprintf(format, a...)}
+}
+
+func isCiExprIdentChar(r rune) bool {
+	return r == '_' || r == '-' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// isCiExprIdentifier 拼进sql的表名和列名只允许字母数字和下划线
+func isCiExprIdentifier(input string) bool {
+	if input == "" {
+		return false
+	}
+	for _, r := range input {
+		if r == '-' || !isCiExprIdentChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lexCiExpression 拆分成标识符、字符串和符号,字符串用单引号包住,里面的单引号和反斜杠用反斜杠转义
+func lexCiExpression(source string) (tokens []*ciExprToken, err error) {
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isCiExprIdentChar(r):
+			start := i
+			for i < len(runes) && isCiExprIdentChar(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, &ciExprToken{Type: ciExprTokenIdent, Value: string(runes[start:i]), Pos: start + 1})
+		case r == '\'':
+			start := i
+			var value []rune
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					value = append(value, runes[i])
+					continue
+				}
+				if runes[i] == '\'' {
+					closed = true
+					i++
+					break
+				}
+				value = append(value, runes[i])
+			}
+			if !closed {
+				return nil, newCiExpressionError(source, start+1, "unterminated string")
+			}
+			tokens = append(tokens, &ciExprToken{Type: ciExprTokenString, Value: string(value), Pos: start + 1})
+		case strings.ContainsRune(".>~()[]{}:,", r):
+			tokens = append(tokens, &ciExprToken{Type: ciExprTokenSymbol, Value: string(r), Pos: i + 1})
+			i++
+		default:
+			return nil, newCiExpressionError(source, i+1, "unexpected character '%c'", r)
+		}
+	}
+	tokens = append(tokens, &ciExprToken{Type: ciExprTokenEOF, Pos: len(runes) + 1})
+	return
+}
+
+type ciExprParser struct {
+	source string
+	tokens []*ciExprToken
+	index  int
+}
+
+// parseCiExpression 解析单个表达式
+func parseCiExpression(source string) (*ciExpression, error) {
+	tokens, err := lexCiExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := ciExprParser{source: source, tokens: tokens}
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Type != ciExprTokenEOF {
+		return nil, p.unexpected(tok, "end of expression")
+	}
+	return expr, nil
+}
+
+// parseCiExpressionList 解析用逗号分隔的多个表达式,权限条件中多个表达式的结果取交集
+func parseCiExpressionList(source string) (result []*ciExpression, err error) {
+	tokens, err := lexCiExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := ciExprParser{source: source, tokens: tokens}
+	for {
+		expr, tmpErr := p.parseExpression()
+		if tmpErr != nil {
+			return nil, tmpErr
+		}
+		result = append(result, expr)
+		tok := p.peek()
+		if tok.Type == ciExprTokenEOF {
+			break
+		}
+		if !p.isSymbol(tok, ",") {
+			return nil, p.unexpected(tok, "',' or end of expression")
+		}
+		p.next()
+	}
+	return
+}
+
+func (p *ciExprParser) peek() *ciExprToken {
+	return p.tokens[p.index]
+}
+
+func (p *ciExprParser) next() *ciExprToken {
+	tok := p.tokens[p.index]
+	if tok.Type != ciExprTokenEOF {
+		p.index++
+	}
+	return tok
+}
+
+func (p *ciExprParser) isSymbol(tok *ciExprToken, symbol string) bool {
+	return tok.Type == ciExprTokenSymbol && tok.Value == symbol
+}
+
+func (p *ciExprParser) unexpected(tok *ciExprToken, expect string) error {
+	if tok.Type == ciExprTokenEOF {
+		return newCiExpressionError(p.source, tok.Pos, "unexpected end of expression,expect %s", expect)
+	}
+	return newCiExpressionError(p.source, tok.Pos, "unexpected '%s',expect %s", tok.Value, expect)
+}
+
+func (p *ciExprParser) expectSymbol(symbol string) (*ciExprToken, error) {
+	tok := p.next()
+	if !p.isSymbol(tok, symbol) {
+		return nil, p.unexpected(tok, fmt.Sprintf("'%s'", symbol))
+	}
+	return tok, nil
+}
+
+func (p *ciExprParser) expectIdent(expect string) (*ciExprToken, error) {
+	tok := p.next()
+	if tok.Type != ciExprTokenIdent {
+		return nil, p.unexpected(tok, expect)
+	}
+	return tok, nil
+}
+
+func (p *ciExprParser) parseExpression() (*ciExpression, error) {
+	expr := ciExpression{Source: p.source}
+	segment, err := p.parseSegment()
+	if err != nil {
+		return nil, err
+	}
+	expr.Segments = append(expr.Segments, segment)
+	for {
+		tok := p.peek()
+		if p.isSymbol(tok, ciExprJoinRef) {
+			p.next()
+			prev := expr.Segments[len(expr.Segments)-1]
+			if prev.RefAttr == "" {
+				return nil, newCiExpressionError(p.source, tok.Pos, "'>' must follow a reference attribute like %s.attr", prev.CiType)
+			}
+			if segment, err = p.parseSegment(); err != nil {
+				return nil, err
+			}
+			segment.JoinType, segment.JoinColumn, segment.JoinPos = ciExprJoinRef, prev.RefAttr, prev.RefAttrPos
+		} else if p.isSymbol(tok, ciExprJoinReverse) {
+			p.next()
+			if _, err = p.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			columnTok, tmpErr := p.expectIdent("reference attribute name")
+			if tmpErr != nil {
+				return nil, tmpErr
+			}
+			if _, err = p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			if segment, err = p.parseSegment(); err != nil {
+				return nil, err
+			}
+			segment.JoinType, segment.JoinColumn, segment.JoinPos = ciExprJoinReverse, columnTok.Value, columnTok.Pos
+		} else {
+			break
+		}
+		expr.Segments = append(expr.Segments, segment)
+	}
+	return &expr, nil
+}
+
+func (p *ciExprParser) parseSegment() (*ciExprSegment, error) {
+	ciTok, err := p.expectIdent("ci type")
+	if err != nil {
+		return nil, err
+	}
+	segment := ciExprSegment{Pos: ciTok.Pos, CiType: ciTok.Value}
+	if p.isSymbol(p.peek(), "[") {
+		p.next()
+		for {
+			filter, tmpErr := p.parseFilter()
+			if tmpErr != nil {
+				return nil, tmpErr
+			}
+			segment.Filters = append(segment.Filters, filter)
+			tok := p.next()
+			if p.isSymbol(tok, "]") {
+				break
+			}
+			if !p.isSymbol(tok, ",") {
+				return nil, p.unexpected(tok, "',' or ']'")
+			}
+		}
+	}
+	if p.isSymbol(p.peek(), ".") {
+		p.next()
+		attrTok, tmpErr := p.expectIdent("attribute name")
+		if tmpErr != nil {
+			return nil, tmpErr
+		}
+		segment.RefAttr, segment.RefAttrPos = attrTok.Value, attrTok.Pos
+	}
+	if p.isSymbol(p.peek(), ":") {
+		p.next()
+		if _, err = p.expectSymbol("["); err != nil {
+			return nil, err
+		}
+		columnTok, tmpErr := p.expectIdent("result attribute name")
+		if tmpErr != nil {
+			return nil, tmpErr
+		}
+		if _, err = p.expectSymbol("]"); err != nil {
+			return nil, err
+		}
+		segment.ResultColumn, segment.ResultPos = columnTok.Value, columnTok.Pos
+	}
+	return &segment, nil
+}
+
+func (p *ciExprParser) parseFilter() (*ciExprFilter, error) {
+	if _, err := p.expectSymbol("{"); err != nil {
+		return nil, err
+	}
+	columnTok, err := p.expectIdent("filter attribute name")
+	if err != nil {
+		return nil, err
+	}
+	filter := ciExprFilter{Pos: columnTok.Pos, Column: columnTok.Value}
+	operatorTok, err := p.expectIdent("filter operator")
+	if err != nil {
+		return nil, err
+	}
+	needValue, b := ciExprOperatorMap[operatorTok.Value]
+	if !b {
+		return nil, newCiExpressionError(p.source, operatorTok.Pos, "unknown operator '%s'", operatorTok.Value)
+	}
+	filter.Operator = operatorTok.Value
+	if needValue {
+		tok := p.next()
+		switch {
+		case tok.Type == ciExprTokenString || tok.Type == ciExprTokenIdent:
+			filter.Values = []string{tok.Value}
+		case p.isSymbol(tok, "["):
+			filter.IsList = true
+			for {
+				valueTok := p.next()
+				if valueTok.Type != ciExprTokenString && valueTok.Type != ciExprTokenIdent {
+					return nil, p.unexpected(valueTok, "filter value")
+				}
+				filter.Values = append(filter.Values, valueTok.Value)
+				tok = p.next()
+				if p.isSymbol(tok, "]") {
+					break
+				}
+				if !p.isSymbol(tok, ",") {
+					return nil, p.unexpected(tok, "',' or ']'")
+				}
+			}
+		default:
+			return nil, p.unexpected(tok, "filter value")
+		}
+		if filter.IsList && filter.Operator != "in" {
+			return nil, newCiExpressionError(p.source, tok.Pos, "operator '%s' can not use list value", filter.Operator)
+		}
+	}
+	if _, err = p.expectSymbol("}"); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// String 按语法重新拼出表达式,字符串值统一用单引号并转义
+func (e *ciExpression) String() string {
+	var builder strings.Builder
+	for _, segment := range e.Segments {
+		switch segment.JoinType {
+		case ciExprJoinRef:
+			builder.WriteString(ciExprJoinRef)
+		case ciExprJoinReverse:
+			builder.WriteString(fmt.Sprintf("%s(%s)", ciExprJoinReverse, segment.JoinColumn))
+		}
+		builder.WriteString(segment.CiType)
+		if len(segment.Filters) > 0 {
+			var filterList []string
+			for _, filter := range segment.Filters {
+				filterList = append(filterList, filter.String())
+			}
+			builder.WriteString("[" + strings.Join(filterList, ",") + "]")
+		}
+		if segment.RefAttr != "" {
+			builder.WriteString("." + segment.RefAttr)
+		}
+		if segment.ResultColumn != "" {
+			builder.WriteString(":[" + segment.ResultColumn + "]")
+		}
+	}
+	return builder.String()
+}
+
+func (f *ciExprFilter) String() string {
+	if !ciExprOperatorMap[f.Operator] {
+		return fmt.Sprintf("{%s %s}", f.Column, f.Operator)
+	}
+	var valueList []string
+	for _, v := range f.Values {
+		valueList = append(valueList, quoteCiExprString(v))
+	}
+	if f.IsList {
+		return fmt.Sprintf("{%s %s [%s]}", f.Column, f.Operator, strings.Join(valueList, ","))
+	}
+	return fmt.Sprintf("{%s %s %s}", f.Column, f.Operator, strings.Join(valueList, ","))
+}
+
+func quoteCiExprString(input string) string {
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(input) + "'"
+}
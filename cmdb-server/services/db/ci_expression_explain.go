@@ -0,0 +1,386 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// 每一跳最多返回的guid数量,总数见Count
+const ciExplainMaxGuidNum = 200
+
+// CiExpressionExplain 解释表达式或属性的引用过滤、自动填充规则,返回解析结果、生成的sql、每一跳的数据和最终结果
+// 表达式本身的错误放在结果中返回,方便模型维护人员定位
+func CiExpressionExplain(param models.CiExpressionExplainParam, roles []string) (result models.CiExpressionExplainResult, err error) {
+	result = models.CiExpressionExplainResult{CiAttr: param.CiAttr, Guid: param.Guid, Expressions: []*models.CiExpressionExplainObj{}, Autofill: []*models.CiAutofillExplainRule{}, Values: []string{}}
+	if param.Expression == "" && param.CiAttr == "" {
+		err = fmt.Errorf("Param expression and ciAttr can not both empty ")
+		return
+	}
+	var rowData map[string]string
+	if param.Guid != "" {
+		if rowData, result.RowData, err = getCiExplainRowData(param.Guid, roles); err != nil {
+			return
+		}
+	}
+	filterMap := make(map[string]string)
+	for k, v := range rowData {
+		filterMap[k] = v
+	}
+	guidFilter := newCiExplainGuidFilter(roles)
+	if param.Expression != "" {
+		explainObj := explainCiExpression("expression", param.Expression, filterMap, param.Permission, guidFilter)
+		result.Expressions = append(result.Expressions, explainObj)
+		result.Values = explainObj.Values
+		return
+	}
+	var attrList []*models.SysCiTypeAttrTable
+	if err = x.SQL("select * from sys_ci_type_attr where id=?", param.CiAttr).Find(&attrList); err != nil {
+		err = fmt.Errorf("Try to query ci attribute fail,%s ", err.Error())
+		return
+	}
+	if len(attrList) == 0 {
+		err = fmt.Errorf("Can not find ci attribute:%s ", param.CiAttr)
+		return
+	}
+	attr := attrList[0]
+	if attr.RefFilter == "" && attr.AutofillRule == "" {
+		err = fmt.Errorf("Ci attribute:%s has no refFilter or autofillRule ", param.CiAttr)
+		return
+	}
+	if attr.RefFilter != "" {
+		explainCiRefFilter(attr, filterMap, &result, guidFilter)
+	}
+	if attr.AutofillRule != "" {
+		var value string
+		if result.Autofill, value, err = explainCiAutofillRule(attr, rowData, guidFilter); err != nil {
+			result.Error, err = err.Error(), nil
+		} else {
+			result.Values = []string{value}
+		}
+	}
+	return
+}
+
+// CiAutofillValueExplain 用数据当前的值重新计算自动填充属性,返回每段规则的计算过程,并和库里保存的值对比是否过期
+func CiAutofillValueExplain(guid, ciAttr string, roles []string) (result models.CiAutofillValueExplainResult, err error) {
+	attr, err := getCiAttrById(ciAttr)
+	if err != nil {
+		return
+	}
+	if !strings.HasPrefix(guid, attr.CiType+"_") {
+		err = fmt.Errorf("Guid:%s is not data of ci attribute:%s ", guid, ciAttr)
+		return
+	}
+	if attr.AutofillAble != "yes" || attr.AutofillRule == "" {
+		err = fmt.Errorf("Ci attribute:%s is not autofill attribute ", ciAttr)
+		return
+	}
+	rowData, _, err := getCiExplainRowData(guid, roles)
+	if err != nil {
+		return
+	}
+	result = models.CiAutofillValueExplainResult{Guid: guid, CiAttr: ciAttr, AutofillType: attr.AutofillType, AutofillRule: attr.AutofillRule, Rules: []*models.CiAutofillExplainRule{}, StoredValue: rowData[attr.Name]}
+	attrList, err := GetCiAttrByCiType(attr.CiType, false)
+	if err != nil {
+		return
+	}
+	// 和重算时一样,空的时间字段不参与计算
+	for _, tmpAttr := range attrList {
+		if tmpAttr.DataType == "datetime" && rowData[tmpAttr.Name] == "" {
+			delete(rowData, tmpAttr.Name)
+		}
+	}
+	if result.Rules, result.ComputedValue, err = explainCiAutofillRule(attr, rowData, newCiExplainGuidFilter(roles)); err != nil {
+		result.Error, err = err.Error(), nil
+		return
+	}
+	result.Stale = result.ComputedValue != result.StoredValue
+	return
+}
+
+// getCiExplainRowData 查询有权限的样例数据,多选引用属性用逗号拼起来,displayData中密码属性不返回明文
+func getCiExplainRowData(guid string, roles []string) (rowData, displayData map[string]string, err error) {
+	if !strings.Contains(guid, "_") {
+		err = fmt.Errorf("Guid:%s is illegal ", guid)
+		return
+	}
+	ciType := guid[:strings.LastIndex(guid, "_")]
+	if !isCiExprIdentifier(ciType) {
+		err = fmt.Errorf("Guid:%s is illegal ", guid)
+		return
+	}
+	permission, err := GetRoleCiDataPermission(roles, ciType)
+	if err != nil {
+		return
+	}
+	legalGuidList, err := GetCiDataPermissionGuidList(&permission, "query")
+	if err != nil {
+		return
+	}
+	if !legalGuidList.Disable && !inStringList(guid, legalGuidList.GuidList) {
+		err = fmt.Errorf("Permission deny with data:%s ", guid)
+		return
+	}
+	queryRows, err := x.QueryString(fmt.Sprintf("select * from %s where guid=?", ciType), guid)
+	if err != nil {
+		err = fmt.Errorf("Try to query ci:%s data fail,%s ", ciType, err.Error())
+		return
+	}
+	if len(queryRows) == 0 {
+		err = fmt.Errorf("Can not find data with guid:%s ", guid)
+		return
+	}
+	rowData = queryRows[0]
+	attrList, err := GetCiAttrByCiType(ciType, true)
+	if err != nil {
+		return
+	}
+	for _, attr := range attrList {
+		if attr.InputType != models.MultiRefType {
+			continue
+		}
+		multiRefData, tmpErr := queryMultiRefMapData(ciType, attr.Name, []string{guid})
+		if tmpErr != nil {
+			err = tmpErr
+			return
+		}
+		rowData[attr.Name] = strings.Join(multiRefData[guid], ",")
+	}
+	displayData = copyCiDataMap(rowData)
+	for _, attr := range attrList {
+		if attr.InputType == models.PasswordInputType && displayData[attr.Name] != "" {
+			displayData[attr.Name] = models.PasswordDisplay
+		}
+	}
+	return
+}
+
+// ciExplainGuidFilter 解释结果中只返回角色有查询权限的数据,按ci类型缓存有权限的guid
+type ciExplainGuidFilter struct {
+	roles    []string
+	legalMap map[string]*models.CiDataLegalGuidList
+}
+
+func newCiExplainGuidFilter(roles []string) *ciExplainGuidFilter {
+	return &ciExplainGuidFilter{roles: roles, legalMap: make(map[string]*models.CiDataLegalGuidList)}
+}
+
+func (f *ciExplainGuidFilter) isLegal(ciType, guid string) (bool, error) {
+	legalGuidList, b := f.legalMap[ciType]
+	if !b {
+		permission, err := GetRoleCiDataPermission(f.roles, ciType)
+		if err != nil {
+			return false, err
+		}
+		tmpLegalGuidList, err := GetCiDataPermissionGuidList(&permission, "query")
+		if err != nil {
+			return false, err
+		}
+		legalGuidList = &tmpLegalGuidList
+		f.legalMap[ciType] = legalGuidList
+	}
+	return legalGuidList.Disable || inStringList(guid, legalGuidList.GuidList), nil
+}
+
+// explainCiExpression permission为true时和权限条件一样返回第一段的guid,否则和引用过滤一样跳过第一段
+// 每一跳和最终结果只保留有查询权限的数据,结果属性是密码时不返回明文
+func explainCiExpression(name, source string, filterMap map[string]string, permission bool, guidFilter *ciExplainGuidFilter) *models.CiExpressionExplainObj {
+	explainObj := models.CiExpressionExplainObj{Name: name, Expression: source, Permission: permission, Segments: []*models.CiExpressionExplainSegment{}, Hops: []*models.CiExpressionExplainHop{}, Values: []string{}}
+	expr, err := parseCiExpression(source)
+	if err != nil {
+		explainObj.Error = toCiExpressionError(source, err)
+		return &explainObj
+	}
+	startIndex := getCiExpressionStartIndex(expr, permission)
+	for i, segment := range expr.Segments {
+		segmentObj := models.CiExpressionExplainSegment{Index: i, CiType: segment.CiType, JoinType: segment.JoinType, JoinColumn: segment.JoinColumn, Filters: []string{},
+			RefAttr: segment.RefAttr, ResultColumn: segment.ResultColumn, Skipped: i < startIndex}
+		for _, filter := range segment.Filters {
+			segmentObj.Filters = append(segmentObj.Filters, filter.String())
+		}
+		explainObj.Segments = append(explainObj.Segments, &segmentObj)
+	}
+	attrMap, err := validateCiExpression(expr)
+	if err != nil {
+		explainObj.Error = toCiExpressionError(source, err)
+		return &explainObj
+	}
+	for i, segment := range expr.Segments {
+		if segment.RefAttr != "" {
+			explainObj.Segments[i].MultiRef = attrMap.isMultiRef(segment.CiType, segment.RefAttr)
+		} else if segment.JoinType == ciExprJoinReverse {
+			explainObj.Segments[i].MultiRef = attrMap.isMultiRef(segment.CiType, segment.JoinColumn)
+		}
+	}
+	sql, queryParams, _, err := buildCiExpressionSql(expr, attrMap, filterMap, permission)
+	if err != nil {
+		explainObj.Error = toCiExpressionError(source, err)
+		return &explainObj
+	}
+	explainObj.Sql, explainObj.Params = sql, queryParams
+	// 每一跳只关联到该段为止,查出该段的guid
+	for i := startIndex; i < len(expr.Segments); i++ {
+		hopSql, hopParams := buildCiExpressionRangeSql(expr, attrMap, filterMap, startIndex, i, i, "guid")
+		hopSql = strings.Replace(hopSql, "select ", "select distinct ", 1)
+		hop := models.CiExpressionExplainHop{Index: i, CiType: expr.Segments[i].CiType, JoinType: expr.Segments[i].JoinType, Sql: hopSql, Params: hopParams, GuidList: []string{}}
+		queryRows, queryErr := x.QueryString(append([]interface{}{hopSql}, hopParams...)...)
+		if queryErr != nil {
+			explainObj.Error = &models.CiExpressionError{Expression: source, Position: expr.Segments[i].Pos, Message: queryErr.Error()}
+			return &explainObj
+		}
+		for _, row := range queryRows {
+			if row["guid"] == "" {
+				continue
+			}
+			legal, legalErr := guidFilter.isLegal(hop.CiType, row["guid"])
+			if legalErr != nil {
+				explainObj.Error = toCiExpressionError(source, legalErr)
+				return &explainObj
+			}
+			if !legal {
+				continue
+			}
+			hop.Count += 1
+			if len(hop.GuidList) < ciExplainMaxGuidNum {
+				hop.GuidList = append(hop.GuidList, row["guid"])
+			}
+		}
+		explainObj.Hops = append(explainObj.Hops, &hop)
+	}
+	// 和queryCiExpression的sql一样,多查出结果所在段的guid用来过滤权限
+	selectIndex, resultColumn := startIndex, "guid"
+	if !permission {
+		selectIndex, resultColumn = len(expr.Segments)-1, expr.Segments[len(expr.Segments)-1].ResultColumn
+	}
+	valueSql, valueParams := buildCiExpressionRangeSql(expr, attrMap, filterMap, startIndex, len(expr.Segments)-1, selectIndex, "guid")
+	valueSql = strings.Replace(valueSql, "select ", fmt.Sprintf("select t%d.%s as explain_value,", selectIndex, resultColumn), 1)
+	queryRows, err := x.QueryString(append([]interface{}{valueSql}, valueParams...)...)
+	if err != nil {
+		explainObj.Error = toCiExpressionError(source, fmt.Errorf("Query expression filter sql error,%s ", err.Error()))
+		return &explainObj
+	}
+	ciType := expr.Segments[selectIndex].CiType
+	isPassword := false
+	if attr, b := attrMap[ciType][resultColumn]; b && attr.InputType == models.PasswordInputType {
+		isPassword = true
+	}
+	for _, row := range queryRows {
+		legal, legalErr := guidFilter.isLegal(ciType, row["guid"])
+		if legalErr != nil {
+			explainObj.Error = toCiExpressionError(source, legalErr)
+			return &explainObj
+		}
+		if !legal {
+			continue
+		}
+		if isPassword && row["explain_value"] != "" {
+			row["explain_value"] = models.PasswordDisplay
+		}
+		explainObj.Values = append(explainObj.Values, row["explain_value"])
+	}
+	return &explainObj
+}
+
+func toCiExpressionError(source string, err error) *models.CiExpressionError {
+	if exprErr, ok := err.(*models.CiExpressionError); ok {
+		return exprErr
+	}
+	return &models.CiExpressionError{Expression: source, Message: err.Error()}
+}
+
+// explainCiRefFilter 和GetCiDataByFilters的处理一样,左边多段时按权限方式取第一段的guid
+func explainCiRefFilter(attr *models.SysCiTypeAttrTable, filterMap map[string]string, result *models.CiExpressionExplainResult, guidFilter *ciExplainGuidFilter) {
+	var filters []map[string]models.CiDataRefFilterObj
+	if err := json.Unmarshal([]byte(attr.RefFilter), &filters); err != nil {
+		result.Error = fmt.Sprintf("Json unmarshal refFilter fail,%s ", err.Error())
+		return
+	}
+	refFilterMap := make(map[string]string)
+	for k, v := range filterMap {
+		refFilterMap[k] = v
+	}
+	delete(refFilterMap, attr.Name)
+	for _, filterObjMap := range filters {
+		for filterName, filter := range filterObjMap {
+			result.Expressions = append(result.Expressions, explainCiExpression(filterName+".left", filter.Left, refFilterMap, strings.ContainsAny(filter.Left, ">~"), guidFilter))
+			if filter.Right.Type == models.FilterTypeExpression {
+				if rightValue, ok := filter.Right.Value.(string); ok {
+					result.Expressions = append(result.Expressions, explainCiExpression(filterName+".right", rightValue, refFilterMap, false, guidFilter))
+				}
+			}
+		}
+	}
+	_, rowData, err := GetCiDataByFilters(attr.Id, filterMap, models.QueryRequestParam{})
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	for _, row := range rowData {
+		guid := fmt.Sprintf("%v", row["guid"])
+		legal, legalErr := guidFilter.isLegal(attr.RefCiType, guid)
+		if legalErr != nil {
+			result.Error = legalErr.Error()
+			return
+		}
+		if legal {
+			result.Values = append(result.Values, guid)
+		}
+	}
+}
+
+// explainCiAutofillRule 逐个rule段记录每一跳查出的数据,最后按自动填充逻辑算出最终值
+func explainCiAutofillRule(attr *models.SysCiTypeAttrTable, rowData map[string]string, guidFilter *ciExplainGuidFilter) (ruleExplainList []*models.CiAutofillExplainRule, value string, err error) {
+	ruleExplainList = []*models.CiAutofillExplainRule{}
+	var ruleList []*models.AutofillObj
+	if err = json.Unmarshal([]byte(attr.AutofillRule), &ruleList); err != nil {
+		err = fmt.Errorf("Json unmarshal autofillRule fail,%s ", err.Error())
+		return
+	}
+	for i, ruleObj := range ruleList {
+		ruleExplain := models.CiAutofillExplainRule{Index: i, Type: ruleObj.Type, Value: ruleObj.Value, Hops: []*models.CiAutofillExplainHop{}, Values: []string{}}
+		if ruleObj.Type == "rule" {
+			values, isTypeAutofill, tmpErr := getRuleValueWithTrace(copyCiDataMap(rowData), ruleObj.Value, func(hop *models.CiAutofillExplainHop, rowDataList []map[string]string) {
+				hop.GuidList, hop.KeyNames = []string{}, []string{}
+				for _, row := range rowDataList {
+					if legal, legalErr := guidFilter.isLegal(hop.CiType, row["guid"]); legalErr != nil || !legal {
+						continue
+					}
+					hop.Count += 1
+					if len(hop.GuidList) < ciExplainMaxGuidNum {
+						hop.GuidList = append(hop.GuidList, row["guid"])
+						hop.KeyNames = append(hop.KeyNames, row["key_name"])
+					}
+				}
+				ruleExplain.Hops = append(ruleExplain.Hops, hop)
+			})
+			if tmpErr != nil {
+				ruleExplain.Error = tmpErr.Error()
+			}
+			// 取到的是自动填充规则类型的值时,和buildAutofillValue一样再按规则算一次
+			for _, v := range values {
+				if !isTypeAutofill {
+					ruleExplain.Values = append(ruleExplain.Values, v)
+					continue
+				}
+				subValues, subErr := buildAutofillValue(copyCiDataMap(rowData), v, models.AutofillRuleType)
+				if subErr != nil {
+					ruleExplain.Error = subErr.Error()
+					break
+				}
+				ruleExplain.Values = append(ruleExplain.Values, getAutofillValueString(subValues, attr.InputType))
+			}
+		} else {
+			ruleExplain.Values = []string{ruleObj.Value}
+		}
+		ruleExplainList = append(ruleExplainList, &ruleExplain)
+	}
+	values, err := buildAutofillValue(copyCiDataMap(rowData), attr.AutofillRule, attr.InputType)
+	if err != nil {
+		return
+	}
+	value = getAutofillValueString(values, attr.InputType)
+	return
+}
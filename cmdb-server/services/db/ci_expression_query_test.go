@@ -0,0 +1,69 @@
+//go:build sqlite
+
+package db
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestQueryCiExpressionReverseJoin(t *testing.T) {
+	base := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "e1", "asset_id": "asset-e1", "key_name": "e1"})
+	baseGuid := base[0]["guid"]
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "e2", "asset_id": "asset-e2", "key_name": "e2", "depend_host": baseGuid},
+		models.CiDataMapObj{"code": "e3", "asset_id": "asset-e3", "key_name": "e3", "depend_host": baseGuid})
+	queryCodes := func(source string, filterMap map[string]string) string {
+		expr, attrMap, err := parseAndValidateCiExpression(source)
+		if err != nil {
+			t.Fatalf("expression:%s illegal,%s", source, err.Error())
+		}
+		// 从第一段开始关联,查询最后一段的结果属性
+		endIndex := len(expr.Segments) - 1
+		sql, queryParams := buildCiExpressionRangeSql(expr, attrMap, filterMap, 0, endIndex, endIndex, "code")
+		queryRows, err := x.QueryString(append([]interface{}{sql}, queryParams...)...)
+		if err != nil {
+			t.Fatalf("query expression:%s fail,%s", source, err.Error())
+		}
+		var result []string
+		for _, row := range queryRows {
+			result = append(result, row["code"])
+		}
+		sort.Strings(result)
+		return strings.Join(result, ",")
+	}
+	if codes := queryCodes("test_host~(depend_host)test_host:[code]", map[string]string{testCiType: baseGuid}); codes != "e2,e3" {
+		t.Fatalf("reverse join result not match:%s", codes)
+	}
+	// 反向引用前面还有关联时会用括号把前面的关联包起来
+	if codes := queryCodes("test_host[{code eq 'e1'}]~(depend_host)test_host[{code eq 'e2'}].depend_host>test_host~(depend_host)test_host:[code]", map[string]string{}); codes != "e2,e3" {
+		t.Fatalf("nested reverse join result not match:%s", codes)
+	}
+}
+
+func TestExplainCiExpressionPasswordValue(t *testing.T) {
+	attr := models.SysCiTypeAttrTable{CiType: testCiType, Name: "login_pwd", DisplayName: "登录密码", InputType: models.PasswordInputType, DataType: "varchar", DataLength: 255,
+		UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "no"}
+	if err := CiAttrCreate(&attr); err != nil {
+		t.Fatalf("create attr fail,%s", err.Error())
+	}
+	if err := CiAttrApply(testCiType, attr.Id, false); err != nil {
+		t.Fatalf("apply attr fail,%s", err.Error())
+	}
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "x1", "asset_id": "asset-x1", "key_name": "x1", "login_pwd": "secret"})
+	// 密码属性作为结果时不返回明文,普通属性照常返回
+	for column, expect := range map[string]string{"login_pwd": models.PasswordDisplay, "code": "x1"} {
+		result, err := CiExpressionExplain(models.CiExpressionExplainParam{Expression: "test_host[{code eq 'x1'}]:[" + column + "]"}, []string{"tester"})
+		if err != nil {
+			t.Fatalf("explain fail,%s", err.Error())
+		}
+		if result.Expressions[0].Error != nil || strings.Join(result.Values, ",") != expect {
+			t.Fatalf("explain value of %s not match:%v %v", column, result.Values, result.Expressions[0].Error)
+		}
+		if len(result.Expressions[0].Hops) != 1 || result.Expressions[0].Hops[0].Count != 1 {
+			t.Fatalf("explain hop not match:%+v", result.Expressions[0].Hops)
+		}
+	}
+}
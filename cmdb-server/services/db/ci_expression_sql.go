@@ -0,0 +1,333 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// ciExprConditionOperatorList 引用过滤和自动填充过滤支持的操作符
+var ciExprConditionOperatorList = []string{"in", "eq", "ne", "gt", "lt", "like", "contains", "notNull", "null", "notEmpty", "empty"}
+
+// ciExprAttrMap ci类型->属性名->属性
+type ciExprAttrMap map[string]map[string]*models.SysCiTypeAttrTable
+
+// loadCiExprAttrMap 查出表达式中用到的ci类型的属性,用于校验标识符和判断多选引用
+func loadCiExprAttrMap(ciTypeList []string) (result ciExprAttrMap, err error) {
+	result = make(ciExprAttrMap)
+	if len(ciTypeList) == 0 {
+		return
+	}
+	specSql, queryParams := createListParams(ciTypeList, "")
+	var attrList []*models.SysCiTypeAttrTable
+	err = x.SQL(fmt.Sprintf("select id,ci_type,name,input_type,ref_ci_type,status from sys_ci_type_attr where ci_type in (%s) and status<>'deleted'", specSql), queryParams...).Find(&attrList)
+	if err != nil {
+		err = fmt.Errorf("Try to query expression ci type attributes fail,%s ", err.Error())
+		return
+	}
+	for _, attr := range attrList {
+		if _, b := result[attr.CiType]; !b {
+			result[attr.CiType] = make(map[string]*models.SysCiTypeAttrTable)
+		}
+		result[attr.CiType][attr.Name] = attr
+	}
+	return
+}
+
+func (m ciExprAttrMap) isMultiRef(ciType, attr string) bool {
+	if attrObj, b := m[ciType][attr]; b {
+		return attrObj.InputType == models.MultiRefType
+	}
+	return false
+}
+
+// validateCiExpression 校验表达式中的ci类型、属性以及引用关系都存在于sys_ci_type_attr中
+func validateCiExpression(expr *ciExpression) (attrMap ciExprAttrMap, err error) {
+	return validateCiExpressionWithAttrs(expr, nil)
+}
+
+// validateCiExpressionWithAttrs extraAttrList是还没保存的属性(如模型包中的属性),会覆盖库里同id的属性一起参与校验
+func validateCiExpressionWithAttrs(expr *ciExpression, extraAttrList []*models.SysCiTypeAttrTable) (attrMap ciExprAttrMap, err error) {
+	var ciTypeList []string
+	for _, segment := range expr.Segments {
+		if !inStringList(segment.CiType, ciTypeList) {
+			ciTypeList = append(ciTypeList, segment.CiType)
+		}
+	}
+	if attrMap, err = loadCiExprAttrMap(ciTypeList); err != nil {
+		return
+	}
+	for _, attr := range extraAttrList {
+		if attr.Status == "deleted" || !inStringList(attr.CiType, ciTypeList) {
+			continue
+		}
+		if _, b := attrMap[attr.CiType]; !b {
+			attrMap[attr.CiType] = make(map[string]*models.SysCiTypeAttrTable)
+		}
+		attrMap[attr.CiType][attr.Name] = attr
+	}
+	checkAttr := func(ciType, attr string, pos int) (*models.SysCiTypeAttrTable, error) {
+		attrObj, b := attrMap[ciType][attr]
+		if !isCiExprIdentifier(attr) || !b {
+			return nil, newCiExpressionError(expr.Source, pos, "ci type %s has no attribute '%s'", ciType, attr)
+		}
+		return attrObj, nil
+	}
+	checkRef := func(attrObj *models.SysCiTypeAttrTable, refCiType string, pos int) error {
+		if attrObj.InputType != "ref" && attrObj.InputType != models.MultiRefType {
+			return newCiExpressionError(expr.Source, pos, "attribute %s.%s is not a reference attribute", attrObj.CiType, attrObj.Name)
+		}
+		if attrObj.RefCiType != refCiType {
+			return newCiExpressionError(expr.Source, pos, "attribute %s.%s reference %s but not %s", attrObj.CiType, attrObj.Name, attrObj.RefCiType, refCiType)
+		}
+		return nil
+	}
+	for i, segment := range expr.Segments {
+		if _, b := attrMap[segment.CiType]; !b || !isCiExprIdentifier(segment.CiType) {
+			return nil, newCiExpressionError(expr.Source, segment.Pos, "unknown ci type '%s'", segment.CiType)
+		}
+		for _, filter := range segment.Filters {
+			if _, err = checkAttr(segment.CiType, filter.Column, filter.Pos); err != nil {
+				return nil, err
+			}
+		}
+		if segment.RefAttr != "" {
+			if _, err = checkAttr(segment.CiType, segment.RefAttr, segment.RefAttrPos); err != nil {
+				return nil, err
+			}
+		}
+		if segment.ResultColumn != "" {
+			if _, err = checkAttr(segment.CiType, segment.ResultColumn, segment.ResultPos); err != nil {
+				return nil, err
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		prev := expr.Segments[i-1]
+		if segment.JoinType == ciExprJoinRef {
+			err = checkRef(attrMap[prev.CiType][segment.JoinColumn], segment.CiType, segment.JoinPos)
+		} else {
+			attrObj, tmpErr := checkAttr(segment.CiType, segment.JoinColumn, segment.JoinPos)
+			if tmpErr != nil {
+				return nil, tmpErr
+			}
+			err = checkRef(attrObj, prev.CiType, segment.JoinPos)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// parseAndValidateCiExpression 解析并校验表达式
+func parseAndValidateCiExpression(source string) (expr *ciExpression, attrMap ciExprAttrMap, err error) {
+	if expr, err = parseCiExpression(source); err != nil {
+		return
+	}
+	attrMap, err = validateCiExpression(expr)
+	return
+}
+
+// validateRefFilterExpression 保存引用属性时校验过滤条件中的表达式,extraAttrList见validateCiExpressionWithAttrs
+func validateRefFilterExpression(refFilter string, extraAttrList []*models.SysCiTypeAttrTable) error {
+	if strings.TrimSpace(refFilter) == "" {
+		return nil
+	}
+	var filters []map[string]models.CiDataRefFilterObj
+	if err := json.Unmarshal([]byte(refFilter), &filters); err != nil {
+		return fmt.Errorf("Json unmarshal refFilter fail,%s ", err.Error())
+	}
+	for _, filterMap := range filters {
+		for _, filter := range filterMap {
+			if err := validateCiExpressionWithResult(filter.Left, extraAttrList); err != nil {
+				return err
+			}
+			if !inStringList(filter.Operator, ciExprConditionOperatorList) {
+				return fmt.Errorf("Filter operator:%s is illegal ", filter.Operator)
+			}
+			if filter.Right.Type != "expression" {
+				continue
+			}
+			if rightValue, ok := filter.Right.Value.(string); ok {
+				if err := validateCiExpressionWithResult(rightValue, extraAttrList); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateCiExpressionWithResult(source string, extraAttrList []*models.SysCiTypeAttrTable) error {
+	expr, err := parseCiExpression(source)
+	if err != nil {
+		return err
+	}
+	if _, err = validateCiExpressionWithAttrs(expr, extraAttrList); err != nil {
+		return err
+	}
+	if expr.Segments[len(expr.Segments)-1].ResultColumn == "" {
+		return newCiExpressionError(source, len([]rune(source))+1, "expression must end with :[attribute]")
+	}
+	return nil
+}
+
+// buildCiExpressionSql 由语法树生成查询sql,所有值都用参数传入
+// permission为true时返回第一段数据的guid,否则跳过第一段(第一段为当前数据,由filterMap传入过滤值)并返回最后一段的结果属性
+func buildCiExpressionSql(expr *ciExpression, attrMap ciExprAttrMap, filterMap map[string]string, permission bool) (sql string, queryParams []interface{}, resultColumn string, err error) {
+	startIndex, endIndex := getCiExpressionStartIndex(expr, permission), len(expr.Segments)-1
+	if permission {
+		resultColumn = "guid"
+		sql, queryParams = buildCiExpressionRangeSql(expr, attrMap, filterMap, startIndex, endIndex, startIndex, resultColumn)
+		return
+	}
+	resultColumn = expr.Segments[endIndex].ResultColumn
+	if resultColumn == "" {
+		err = newCiExpressionError(expr.Source, len([]rune(expr.Source))+1, "expression must end with :[attribute]")
+		return
+	}
+	sql, queryParams = buildCiExpressionRangeSql(expr, attrMap, filterMap, startIndex, endIndex, endIndex, resultColumn)
+	return
+}
+
+func getCiExpressionStartIndex(expr *ciExpression, permission bool) int {
+	if !permission && len(expr.Segments) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// buildCiExpressionRangeSql 只关联startIndex到endIndex的段,查询第selectIndex段的selectColumn
+func buildCiExpressionRangeSql(expr *ciExpression, attrMap ciExprAttrMap, filterMap map[string]string, startIndex, endIndex, selectIndex int, selectColumn string) (sql string, queryParams []interface{}) {
+	segments := expr.Segments
+	fromSql := ""
+	var whereSqlList []string
+	for i := startIndex; i <= endIndex; i++ {
+		segment := segments[i]
+		tableAlias := fmt.Sprintf("t%d", i)
+		// 下一段通过该段的多选引用属性关联,或该段通过自身的多选引用属性反向关联上一段时,需要把关系表展开
+		refColumn := ""
+		if i < endIndex && segments[i+1].JoinType == ciExprJoinRef {
+			refColumn = segments[i+1].JoinColumn
+		} else if segment.JoinType == ciExprJoinReverse {
+			refColumn = segment.JoinColumn
+		}
+		tableSql := segment.CiType
+		if refColumn != "" && attrMap.isMultiRef(segment.CiType, refColumn) {
+			tableSql = fmt.Sprintf("(select %s.*,%s$%s.to_guid as %s from %s left join %s$%s on %s.guid=%s$%s.from_guid)",
+				segment.CiType, segment.CiType, refColumn, refColumn, segment.CiType, segment.CiType, refColumn, segment.CiType, segment.CiType, refColumn)
+		}
+		if i == startIndex {
+			fromSql = fmt.Sprintf("%s %s", tableSql, tableAlias)
+		} else if segment.JoinType == ciExprJoinRef {
+			fromSql += fmt.Sprintf(" left join %s %s on t%d.%s=%s.guid", tableSql, tableAlias, i-1, segment.JoinColumn, tableAlias)
+		} else {
+			// 反向引用要保留该段的所有数据,低版本sqlite不支持right join,把该段放到前面再left join前面已关联的表
+			if i-1 > startIndex {
+				fromSql = "(" + fromSql + ")"
+			}
+			fromSql = fmt.Sprintf("%s %s left join %s on t%d.guid=%s.%s", tableSql, tableAlias, fromSql, i-1, tableAlias, segment.JoinColumn)
+		}
+		for _, filter := range segment.Filters {
+			filterValues := filter.Values
+			if !filter.IsList {
+				filterValues = getCiExprConditionValueList(filter.Operator, filterValues)
+			}
+			filterSql, filterParams := buildCiExprConditionSql(tableAlias+"."+filter.Column, filter.Operator, filterValues)
+			whereSqlList = append(whereSqlList, filterSql)
+			queryParams = append(queryParams, filterParams...)
+		}
+		if i == startIndex {
+			filterValueString, b := filterMap[segment.CiType]
+			if !b && segment.JoinColumn != "" {
+				filterValueString, b = filterMap[segment.JoinColumn]
+			}
+			if b {
+				filterSql, filterParams := buildCiExprConditionSql(tableAlias+".guid", "in", transStringToList(filterValueString))
+				whereSqlList = append(whereSqlList, filterSql)
+				queryParams = append(queryParams, filterParams...)
+			}
+		}
+	}
+	sql = fmt.Sprintf("select t%d.%s from %s", selectIndex, selectColumn, fromSql)
+	if len(whereSqlList) > 0 {
+		sql += " where " + strings.Join(whereSqlList, " and ")
+	}
+	return
+}
+
+// getCiExprConditionValueList in操作兼容 ['a','b'] 和 a,b 的写法
+func getCiExprConditionValueList(operator string, values []string) []string {
+	if operator == "in" && len(values) == 1 {
+		value := strings.TrimSpace(values[0])
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			value = value[1 : len(value)-1]
+		}
+		return strings.Split(strings.ReplaceAll(value, "'", ""), ",")
+	}
+	return values
+}
+
+// buildCiExprConditionSql 生成单个过滤条件,column必须是校验过的标识符
+func buildCiExprConditionSql(column, operator string, values []string) (sql string, queryParams []interface{}) {
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+	switch operator {
+	case "in":
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		specSql, specParams := createListParams(values, "")
+		sql = fmt.Sprintf("%s in (%s)", column, specSql)
+		queryParams = specParams
+	case "eq":
+		sql = fmt.Sprintf("%s=?", column)
+		queryParams = []interface{}{value}
+	case "ne":
+		sql = fmt.Sprintf("%s!=?", column)
+		queryParams = []interface{}{value}
+	case "gt":
+		sql = fmt.Sprintf("%s>?", column)
+		queryParams = []interface{}{value}
+	case "lt":
+		sql = fmt.Sprintf("%s<?", column)
+		queryParams = []interface{}{value}
+	case "like", "contains":
+		sql = fmt.Sprintf("%s LIKE ?", column)
+		queryParams = []interface{}{"%" + value + "%"}
+	case "notNull":
+		sql = fmt.Sprintf("%s IS NOT NULL", column)
+	case "null":
+		sql = fmt.Sprintf("%s IS NULL", column)
+	case "notEmpty":
+		sql = fmt.Sprintf("%s<>''", column)
+	case "empty":
+		sql = fmt.Sprintf("%s=''", column)
+	}
+	return
+}
+
+// queryCiExpression 执行语法树生成的sql,返回结果属性的值
+func queryCiExpression(expr *ciExpression, attrMap ciExprAttrMap, filterMap map[string]string, permission bool) (result []string, err error) {
+	sql, queryParams, resultColumn, err := buildCiExpressionSql(expr, attrMap, filterMap, permission)
+	if err != nil {
+		return
+	}
+	log.Logger.Debug("Expression filter sql", log.String("sql", sql), log.JsonObj("params", queryParams))
+	queryResults, queryErr := x.QueryString(append([]interface{}{sql}, queryParams...)...)
+	if queryErr != nil {
+		err = fmt.Errorf("Query expression filter sql error,%s ", queryErr.Error())
+		return
+	}
+	for _, queryRow := range queryResults {
+		result = append(result, queryRow[resultColumn])
+	}
+	return
+}
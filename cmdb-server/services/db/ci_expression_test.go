@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestLexCiExpression(t *testing.T) {
+	tokens, err := lexCiExpression(`unit[{key_name eq 'a\'b>c'}]:[guid]`)
+	if err != nil {
+		t.Fatalf("lex fail,%s", err.Error())
+	}
+	var valueList []string
+	for _, tok := range tokens {
+		valueList = append(valueList, tok.Value)
+	}
+	expect := []string{"unit", "[", "{", "key_name", "eq", "a'b>c", "}", "]", ":", "[", "guid", "]", ""}
+	if strings.Join(valueList, "|") != strings.Join(expect, "|") {
+		t.Fatalf("tokens not match:%v", valueList)
+	}
+	if tokens[5].Type != ciExprTokenString || tokens[5].Pos != 19 {
+		t.Fatalf("string token not match,type:%d pos:%d", tokens[5].Type, tokens[5].Pos)
+	}
+	if last := tokens[len(tokens)-1]; last.Type != ciExprTokenEOF || last.Pos != len([]rune(`unit[{key_name eq 'a\'b>c'}]:[guid]`))+1 {
+		t.Fatalf("eof token not match,type:%d pos:%d", last.Type, last.Pos)
+	}
+}
+
+func TestParseCiExpression(t *testing.T) {
+	source := "host.unit>unit~(unit)app_instance[{state eq 'running'},{code in ['a', b]},{owner notNull}]:[key_name]"
+	expr, err := parseCiExpression(source)
+	if err != nil {
+		t.Fatalf("parse fail,%s", err.Error())
+	}
+	if len(expr.Segments) != 3 {
+		t.Fatalf("segment num not match:%d", len(expr.Segments))
+	}
+	second, third := expr.Segments[1], expr.Segments[2]
+	if second.CiType != "unit" || second.JoinType != ciExprJoinRef || second.JoinColumn != "unit" || second.JoinPos != 6 {
+		t.Fatalf("ref segment not match:%+v", second)
+	}
+	if third.CiType != "app_instance" || third.JoinType != ciExprJoinReverse || third.JoinColumn != "unit" || third.ResultColumn != "key_name" {
+		t.Fatalf("reverse segment not match:%+v", third)
+	}
+	if len(third.Filters) != 3 {
+		t.Fatalf("filter num not match:%d", len(third.Filters))
+	}
+	if listFilter := third.Filters[1]; !listFilter.IsList || strings.Join(listFilter.Values, ",") != "a,b" {
+		t.Fatalf("list filter not match:%+v", listFilter)
+	}
+	if noValueFilter := third.Filters[2]; noValueFilter.Operator != "notNull" || len(noValueFilter.Values) != 0 {
+		t.Fatalf("notNull filter not match:%+v", noValueFilter)
+	}
+	// 重新拼出的表达式可以再次解析成相同的结果
+	again, err := parseCiExpression(expr.String())
+	if err != nil || again.String() != expr.String() {
+		t.Fatalf("expression string not stable:%s", expr.String())
+	}
+}
+
+func TestParseCiExpressionList(t *testing.T) {
+	exprList, err := parseCiExpressionList("host[{state eq 'a,b'}]:[guid], unit~(unit)host:[guid]")
+	if err != nil {
+		t.Fatalf("parse list fail,%s", err.Error())
+	}
+	if len(exprList) != 2 || exprList[0].Segments[0].Filters[0].Values[0] != "a,b" || len(exprList[1].Segments) != 2 {
+		t.Fatalf("expression list not match")
+	}
+}
+
+func TestParseCiExpressionError(t *testing.T) {
+	testCases := []struct {
+		source   string
+		position int
+		message  string
+	}{
+		{"unit[{key_name eq 'abc}]", 19, "unterminated string"},
+		{"unit[{key_name eq 'abc\\", 19, "unterminated string"},
+		{"unit$code", 5, "unexpected character"},
+		{"unit>host", 5, "'>' must follow a reference attribute"},
+		{"unit~host", 6, "expect '('"},
+		{"unit~(unit", 11, "unexpected end of expression"},
+		{"unit[{code in 'a'", 18, "expect '}'"},
+		{"unit[{code eq ['a','b']}]", 23, "can not use list value"},
+		{"unit[{code in ['a' 'b']}]", 20, "expect ',' or ']'"},
+		{"unit[{code in []}]", 16, "expect filter value"},
+		{"unit[{code between 'a'}]", 12, "unknown operator"},
+		{"unit[{code eq}]", 14, "expect filter value"},
+		{"unit[]", 6, "expect '{'"},
+		{"unit:[", 7, "unexpected end of expression"},
+		{"unit.", 6, "expect attribute name"},
+		{"unit host", 6, "expect end of expression"},
+		{"", 1, "expect ci type"},
+		{">", 1, "expect ci type"},
+		{"unit[{key_name eq 'a'},", 24, "expect '{'"},
+	}
+	for _, testCase := range testCases {
+		_, err := parseCiExpression(testCase.source)
+		if err == nil {
+			t.Fatalf("expression:%s should be illegal", testCase.source)
+		}
+		var exprErr *models.CiExpressionError
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("expression:%s error type not match:%T", testCase.source, err)
+		}
+		if exprErr.Position != testCase.position || !strings.Contains(exprErr.Message, testCase.message) {
+			t.Fatalf("expression:%s error not match,position:%d message:%s", testCase.source, exprErr.Position, exprErr.Message)
+		}
+	}
+}
+
+func TestBuildCiExpressionRangeSqlWithoutRightJoin(t *testing.T) {
+	expr, err := parseCiExpression("unit~(unit)app_instance.host>host[{state eq 'running'}]:[ip]")
+	if err != nil {
+		t.Fatalf("parse fail,%s", err.Error())
+	}
+	attrMap := ciExprAttrMap{
+		"unit":         {},
+		"app_instance": {"host": {CiType: "app_instance", Name: "host", InputType: models.MultiRefType, RefCiType: "host"}},
+		"host":         {},
+	}
+	sql, queryParams := buildCiExpressionRangeSql(expr, attrMap, map[string]string{"unit": "unit_1"}, 0, 2, 2, "ip")
+	if strings.Contains(sql, "right join") {
+		t.Fatalf("sql should not use right join:%s", sql)
+	}
+	expectSql := "select t2.ip from (select app_instance.*,app_instance$host.to_guid as host from app_instance left join app_instance$host on app_instance.guid=app_instance$host.from_guid) t1 " +
+		"left join unit t0 on t0.guid=t1.unit left join host t2 on t1.host=t2.guid where t0.guid in (?) and t2.state=?"
+	if sql != expectSql {
+		t.Fatalf("sql not match:\n%s\n%s", sql, expectSql)
+	}
+	if len(queryParams) != 2 || queryParams[0] != "unit_1" || queryParams[1] != "running" {
+		t.Fatalf("params not match:%v", queryParams)
+	}
+	// 反向引用前面已经有关联时,前面的关联要用括号包起来
+	expr, _ = parseCiExpression("host.unit>unit~(unit)app_instance:[guid]")
+	sql, _ = buildCiExpressionRangeSql(expr, ciExprAttrMap{}, map[string]string{}, 0, 2, 2, "guid")
+	if sql != "select t2.guid from app_instance t2 left join (host t0 left join unit t1 on t0.unit=t1.guid) on t1.guid=t2.unit" {
+		t.Fatalf("nested sql not match:%s", sql)
+	}
+}
@@ -20,7 +20,7 @@ func GetRoleCiPermission(query *models.RolePermissionQuery) error {
 		query.MenuPermissions = append(query.MenuPermissions, menu.MenuGuid)
 	}
 	var ciTypePermissionTable []*models.CiTypePermissionObj
-	err = x.SQL("select t1.*,t2.display_name as 'ci_type_name' from sys_role_ci_type t1 left join sys_ci_type t2 on t1.ci_type=t2.id where t1.role_id=?", query.Role).Find(&ciTypePermissionTable)
+	err = x.SQL("select t1.*,t2.display_name as ci_type_name from sys_role_ci_type t1 left join sys_ci_type t2 on t1.ci_type=t2.id where t1.role_id=?", query.Role).Find(&ciTypePermissionTable)
 	if err != nil {
 		return fmt.Errorf("Try to get role ci type data fail,%s ", err.Error())
 	}
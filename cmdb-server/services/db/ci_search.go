@@ -0,0 +1,372 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const (
+	ciSearchInsertBatchSize  = 200
+	ciSearchMaxLimit         = 500
+	ciSearchRankBatchSize    = 200
+	ciSearchDirtyBatchSize   = 500
+	ciSearchDirtyIntervalSec = 30
+	// ciSearchValueLength 和索引表search_value的长度一致
+	ciSearchValueLength = 255
+	ciSearchMaxTokenNum = 16
+)
+
+var (
+	// searchIndexChan 只用来唤醒消费,待更新的数据记在sys_ci_search_dirty表里,唤醒丢了也会按间隔补上
+	searchIndexChan = make(chan bool, 1)
+	// 这些系统字段不进搜索索引
+	ciSearchIgnoreColumns = []string{"guid", "create_user", "update_user", "state"}
+)
+
+// StartConsumeSearchIndex 索引表为空时先全量重建,之后按待更新表里的guid增量更新
+func StartConsumeSearchIndex() {
+	log.Logger.Info("Start consume search index cron job")
+	countRows, err := x.QueryString("select count(1) as num from sys_ci_search_index")
+	if err != nil {
+		log.Logger.Error("Try to count search index fail", log.Error(err))
+	} else if len(countRows) > 0 && countRows[0]["num"] == "0" {
+		if err = RebuildCiSearchIndex(""); err != nil {
+			log.Logger.Error("Try to rebuild search index fail", log.Error(err))
+		}
+	}
+	t := time.NewTicker(ciSearchDirtyIntervalSec * time.Second).C
+	for {
+		consumeCiSearchDirty()
+		select {
+		case <-t:
+		case <-searchIndexChan:
+		}
+	}
+}
+
+// consumeCiSearchDirty 按ci类型更新待更新表中的数据,更新成功后才删除待更新记录,失败的下一轮重试
+func consumeCiSearchDirty() {
+	for {
+		queryRows, err := x.QueryString("select id,ci_type,guid from sys_ci_search_dirty order by id limit ?", ciSearchDirtyBatchSize)
+		if err != nil {
+			log.Logger.Error("Try to query search index dirty data fail", log.Error(err))
+			return
+		}
+		if len(queryRows) == 0 {
+			return
+		}
+		var ciTypeList []string
+		ciGuidMap, ciIdMap := make(map[string][]string), make(map[string][]string)
+		for _, row := range queryRows {
+			if _, b := ciGuidMap[row["ci_type"]]; !b {
+				ciTypeList = append(ciTypeList, row["ci_type"])
+			}
+			if !inStringList(row["guid"], ciGuidMap[row["ci_type"]]) {
+				ciGuidMap[row["ci_type"]] = append(ciGuidMap[row["ci_type"]], row["guid"])
+			}
+			ciIdMap[row["ci_type"]] = append(ciIdMap[row["ci_type"]], row["id"])
+		}
+		failNum := 0
+		for _, ciType := range ciTypeList {
+			if err = updateCiSearchIndex(ciType, ciGuidMap[ciType]); err != nil && isCiTypeCreated(ciType) {
+				log.Logger.Error("Try to update search index fail", log.String("ciType", ciType), log.Error(err))
+				failNum += 1
+				continue
+			}
+			// ci类型已经不是创建状态时索引更新不了,待更新记录直接删掉
+			specSql, specParams := createListParams(ciIdMap[ciType], "")
+			if _, err = x.Exec(append([]interface{}{fmt.Sprintf("delete from sys_ci_search_dirty where id in (%s)", specSql)}, specParams...)...); err != nil {
+				log.Logger.Error("Try to delete search index dirty data fail", log.String("ciType", ciType), log.Error(err))
+				return
+			}
+		}
+		if failNum > 0 || len(queryRows) < ciSearchDirtyBatchSize {
+			return
+		}
+	}
+}
+
+func isCiTypeCreated(ciType string) bool {
+	queryRows, err := x.QueryString("select id from sys_ci_type where id=? and status='created'", ciType)
+	if err != nil {
+		return true
+	}
+	return len(queryRows) > 0
+}
+
+// buildCiSearchDirtyActions 待更新的guid和数据修改放在同一个事务里写入,提交后调用notifySearchIndex唤醒消费
+func buildCiSearchDirtyActions(ciGuidMap map[string][]string) (actions []*execAction) {
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	var valueSqlList []string
+	var insertParams []interface{}
+	for ciType, guidList := range ciGuidMap {
+		for _, guid := range guidList {
+			valueSqlList = append(valueSqlList, "(?,?,?)")
+			insertParams = append(insertParams, ciType, guid, nowTime)
+			if len(valueSqlList) >= ciSearchInsertBatchSize {
+				actions = append(actions, &execAction{Sql: "insert into sys_ci_search_dirty(ci_type,guid,create_time) values " + strings.Join(valueSqlList, ","), Param: insertParams})
+				valueSqlList, insertParams = []string{}, []interface{}{}
+			}
+		}
+	}
+	if len(valueSqlList) > 0 {
+		actions = append(actions, &execAction{Sql: "insert into sys_ci_search_dirty(ci_type,guid,create_time) values " + strings.Join(valueSqlList, ","), Param: insertParams})
+	}
+	return
+}
+
+func buildCiSearchDirtyActionsByCiData(multiCiData []*models.MultiCiDataObj) []*execAction {
+	ciGuidMap := make(map[string][]string)
+	for _, ciDataObj := range multiCiData {
+		for _, row := range ciDataObj.InputData {
+			if row["guid"] != "" {
+				ciGuidMap[ciDataObj.CiTypeId] = append(ciGuidMap[ciDataObj.CiTypeId], row["guid"])
+			}
+		}
+	}
+	return buildCiSearchDirtyActions(ciGuidMap)
+}
+
+// notifySearchIndex 唤醒索引消费,已经有唤醒在排队时不用重复唤醒
+func notifySearchIndex() {
+	select {
+	case searchIndexChan <- true:
+	default:
+	}
+}
+
+// RebuildCiSearchIndex ciType为空时重建所有已创建的ci类型
+func RebuildCiSearchIndex(ciType string) error {
+	var ciTypeList []*models.SysCiTypeTable
+	var err error
+	if ciType == "" {
+		err = x.SQL("select id from sys_ci_type where status='created'").Find(&ciTypeList)
+	} else {
+		err = x.SQL("select id from sys_ci_type where status='created' and id=?", ciType).Find(&ciTypeList)
+	}
+	if err != nil {
+		return fmt.Errorf("Try to query ci type fail,%s ", err.Error())
+	}
+	if ciType != "" && len(ciTypeList) == 0 {
+		return fmt.Errorf("Can not find created ci type:%s ", ciType)
+	}
+	startTime := time.Now()
+	for _, ciTypeObj := range ciTypeList {
+		if err = updateCiSearchIndex(ciTypeObj.Id, nil); err != nil {
+			return err
+		}
+	}
+	log.Logger.Info("Rebuild search index done", log.Int("ciTypeNum", len(ciTypeList)), log.String("cost", time.Since(startTime).String()))
+	return nil
+}
+
+// updateCiSearchIndex guidList为空时重建整个ci类型的索引,否则只更新这些数据行,已删除的数据会从索引中去掉
+func updateCiSearchIndex(ciType string, guidList []string) error {
+	attrList, err := getCiSearchAttrList(ciType)
+	if err != nil {
+		return err
+	}
+	var actions []*execAction
+	columnList := []string{"guid", "key_name", "state"}
+	for _, attr := range attrList {
+		if attr.Name != "key_name" {
+			columnList = append(columnList, attr.Name)
+		}
+	}
+	querySql := fmt.Sprintf("select %s from %s", strings.Join(columnList, ","), ciType)
+	var queryParams []interface{}
+	if len(guidList) == 0 {
+		actions = append(actions, &execAction{Sql: "delete from sys_ci_search_index where ci_type=?", Param: []interface{}{ciType}})
+	} else {
+		specSql, specParams := createListParams(guidList, "")
+		actions = append(actions, &execAction{Sql: fmt.Sprintf("delete from sys_ci_search_index where guid in (%s)", specSql), Param: specParams})
+		querySql += fmt.Sprintf(" where guid in (%s)", specSql)
+		queryParams = specParams
+	}
+	queryRows, err := x.QueryString(append([]interface{}{querySql}, queryParams...)...)
+	if err != nil {
+		return fmt.Errorf("Try to query ci:%s data fail,%s ", ciType, err.Error())
+	}
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	var valueSqlList []string
+	var insertParams []interface{}
+	for _, row := range queryRows {
+		for _, attr := range attrList {
+			if strings.TrimSpace(row[attr.Name]) == "" {
+				continue
+			}
+			for tokenNo, searchValue := range buildCiSearchValueList(row[attr.Name]) {
+				valueSqlList = append(valueSqlList, "(?,?,?,?,?,?,?,?,?)")
+				insertParams = append(insertParams, ciType, row["guid"], row["key_name"], row["state"], attr.Name, row[attr.Name], searchValue, tokenNo, nowTime)
+				if len(valueSqlList) >= ciSearchInsertBatchSize {
+					actions = append(actions, &execAction{Sql: "insert into sys_ci_search_index(ci_type,guid,key_name,state,attr,value,search_value,token_no,update_time) values " + strings.Join(valueSqlList, ","), Param: insertParams})
+					valueSqlList, insertParams = []string{}, []interface{}{}
+				}
+			}
+		}
+	}
+	if len(valueSqlList) > 0 {
+		actions = append(actions, &execAction{Sql: "insert into sys_ci_search_index(ci_type,guid,key_name,state,attr,value,search_value,token_no,update_time) values " + strings.Join(valueSqlList, ","), Param: insertParams})
+	}
+	if err = transaction(actions); err != nil {
+		return fmt.Errorf("Try to update ci:%s search index fail,%s ", ciType, err.Error())
+	}
+	return nil
+}
+
+// buildCiSearchValueList 第一个是小写后的整个值,后面是从每个单词开头截取的后缀,这样前缀索引也能匹配到值中间的单词
+func buildCiSearchValueList(value string) (result []string) {
+	runeList := []rune(strings.ToLower(value))
+	result = append(result, truncateCiSearchValue(runeList))
+	for i := 1; i < len(runeList) && len(result) <= ciSearchMaxTokenNum; i++ {
+		if isCiSearchWordRune(runeList[i]) && !isCiSearchWordRune(runeList[i-1]) {
+			result = append(result, truncateCiSearchValue(runeList[i:]))
+		}
+	}
+	return
+}
+
+func isCiSearchWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func truncateCiSearchValue(runeList []rune) string {
+	if len(runeList) > ciSearchValueLength {
+		runeList = runeList[:ciSearchValueLength]
+	}
+	return string(runeList)
+}
+
+// getCiSearchAttrList 只索引文本类的属性,密码和引用属性不进索引
+func getCiSearchAttrList(ciType string) (result []*models.SysCiTypeAttrTable, err error) {
+	attrList, err := GetCiAttrByCiType(ciType, true)
+	if err != nil {
+		return nil, fmt.Errorf("Try to get ci:%s attributes fail,%s ", ciType, err.Error())
+	}
+	for _, attr := range attrList {
+		if attr.InputType == models.PasswordInputType || attr.InputType == "ref" || attr.InputType == models.MultiRefType || attr.DataType == "datetime" {
+			continue
+		}
+		if inStringList(attr.Name, ciSearchIgnoreColumns) {
+			continue
+		}
+		result = append(result, attr)
+	}
+	return
+}
+
+// CiSearch 在所有已创建的ci类型中按前缀索引搜索,完全相等>前缀匹配>单词匹配,key_name命中额外加分,同一行多个属性命中时每多一个加1分
+// 分数在数据库里算好排序,按批过滤权限直到取够limit条
+func CiSearch(param models.CiSearchParam, roles []string) (result []*models.CiSearchResultObj, err error) {
+	result = []*models.CiSearchResultObj{}
+	keyword := strings.ToLower(strings.TrimSpace(param.Query))
+	if keyword == "" {
+		return
+	}
+	if param.Limit <= 0 || param.Limit > ciSearchMaxLimit {
+		param.Limit = 50
+	}
+	keyword = truncateCiSearchValue([]rune(keyword))
+	likeValue := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(keyword) + "%"
+	matchSql := `select t1.ci_type,t3.display_name as ci_type_name,t1.guid,t1.key_name,t1.state,t1.attr,t2.display_name as attr_name,t1.value,
+		(case when t1.token_no=0 and t1.search_value=? then 100 when t1.token_no=0 then 60 else 30 end)+(case when t1.attr='key_name' then 20 else 0 end) as score
+		from sys_ci_search_index t1
+		join sys_ci_type_attr t2 on t2.ci_type=t1.ci_type and t2.name=t1.attr
+		join sys_ci_type t3 on t3.id=t1.ci_type
+		where t3.status='created' and t2.status='created' and t2.input_type<>? and t1.search_value like ? escape '!'`
+	matchParams := []interface{}{keyword, models.PasswordInputType, likeValue}
+	if param.CiType != "" {
+		matchSql += " and t1.ci_type=?"
+		matchParams = append(matchParams, param.CiType)
+	}
+	rankSql := fmt.Sprintf("select tt.ci_type,tt.guid,max(tt.key_name) as key_name,max(tt.score)+count(distinct tt.attr)-1 as score,count(distinct tt.attr) as match_num from (%s) tt group by tt.ci_type,tt.guid order by score desc,tt.ci_type,key_name,tt.guid limit ? offset ?", matchSql)
+	for offset := 0; len(result) < param.Limit; offset += ciSearchRankBatchSize {
+		queryRows, queryErr := x.QueryString(append(append([]interface{}{rankSql}, matchParams...), ciSearchRankBatchSize, offset)...)
+		if queryErr != nil {
+			err = fmt.Errorf("Try to query search index fail,%s ", queryErr.Error())
+			return
+		}
+		var rowList []*models.CiSearchResultObj
+		for _, row := range queryRows {
+			tmpRow := models.CiSearchResultObj{CiType: row["ci_type"], Guid: row["guid"], KeyName: row["key_name"]}
+			tmpRow.Score, _ = strconv.Atoi(row["score"])
+			tmpRow.MatchNum, _ = strconv.Atoi(row["match_num"])
+			rowList = append(rowList, &tmpRow)
+		}
+		if rowList, err = filterCiSearchPermission(rowList, roles); err != nil {
+			return
+		}
+		if len(result)+len(rowList) > param.Limit {
+			rowList = rowList[:param.Limit-len(result)]
+		}
+		if err = fillCiSearchMatchAttr(rowList, matchSql, matchParams); err != nil {
+			return
+		}
+		result = append(result, rowList...)
+		if len(queryRows) < ciSearchRankBatchSize {
+			break
+		}
+	}
+	return
+}
+
+// fillCiSearchMatchAttr 每行数据取分数最高的属性作为命中属性
+func fillCiSearchMatchAttr(rowList []*models.CiSearchResultObj, matchSql string, matchParams []interface{}) error {
+	if len(rowList) == 0 {
+		return nil
+	}
+	rowMap := make(map[string]*models.CiSearchResultObj)
+	var guidList []string
+	for _, row := range rowList {
+		rowMap[row.Guid] = row
+		guidList = append(guidList, row.Guid)
+	}
+	specSql, specParams := createListParams(guidList, "")
+	querySql := fmt.Sprintf("select tt.* from (%s) tt where tt.guid in (%s) order by tt.score desc,tt.attr", matchSql, specSql)
+	queryRows, err := x.QueryString(append(append([]interface{}{querySql}, matchParams...), specParams...)...)
+	if err != nil {
+		return fmt.Errorf("Try to query search index match attribute fail,%s ", err.Error())
+	}
+	for _, row := range queryRows {
+		existRow := rowMap[row["guid"]]
+		if existRow == nil || existRow.Attr != "" {
+			continue
+		}
+		existRow.CiTypeName, existRow.State = row["ci_type_name"], row["state"]
+		existRow.Attr, existRow.AttrName, existRow.Value = row["attr"], row["attr_name"], row["value"]
+	}
+	return nil
+}
+
+// filterCiSearchPermission 按角色的数据查询权限过滤结果
+func filterCiSearchPermission(rowList []*models.CiSearchResultObj, roles []string) (result []*models.CiSearchResultObj, err error) {
+	result = []*models.CiSearchResultObj{}
+	legalMap := make(map[string]*models.CiDataLegalGuidList)
+	for _, row := range rowList {
+		legalObj, b := legalMap[row.CiType]
+		if !b {
+			permission, tmpErr := GetRoleCiDataPermission(roles, row.CiType)
+			if tmpErr != nil {
+				err = tmpErr
+				return
+			}
+			legalGuidList, tmpErr := GetCiDataPermissionGuidList(&permission, "query")
+			if tmpErr != nil {
+				err = tmpErr
+				return
+			}
+			legalObj = &legalGuidList
+			legalMap[row.CiType] = legalObj
+		}
+		if legalObj.Disable || inStringList(row.Guid, legalObj.GuidList) {
+			result = append(result, row)
+		}
+	}
+	return
+}
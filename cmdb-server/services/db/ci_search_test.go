@@ -0,0 +1,49 @@
+//go:build sqlite
+
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestBuildCiSearchValueList(t *testing.T) {
+	if valueList := buildCiSearchValueList("Web-Server 01"); strings.Join(valueList, "|") != "web-server 01|server 01|01" {
+		t.Fatalf("search value list not match:%v", valueList)
+	}
+	if valueList := buildCiSearchValueList(strings.Repeat("a", ciSearchValueLength+10)); len([]rune(valueList[0])) != ciSearchValueLength {
+		t.Fatalf("search value should be truncated")
+	}
+}
+
+func TestCiSearchWithDirtyQueue(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "search-alpha", "asset_id": "asset-s1", "key_name": "search-alpha"},
+		models.CiDataMapObj{"code": "s2", "asset_id": "asset-s2", "key_name": "alpha-search"})
+	// 待更新记录和数据一起提交,没有消费时也不会丢
+	if num := countTestRows(t, "select * from sys_ci_search_dirty where ci_type=?", testCiType); num == 0 {
+		t.Fatalf("dirty data should be saved with the operation")
+	}
+	consumeCiSearchDirty()
+	if num := countTestRows(t, "select * from sys_ci_search_dirty"); num != 0 {
+		t.Fatalf("dirty data should be deleted after consume,num:%d", num)
+	}
+	result, err := CiSearch(models.CiSearchParam{Query: "Alpha", CiType: testCiType, Limit: 10}, []string{"tester"})
+	if err != nil {
+		t.Fatalf("search fail,%s", err.Error())
+	}
+	if len(result) != 2 {
+		t.Fatalf("search result num:%d", len(result))
+	}
+	// key_name前缀匹配排在单词匹配前面
+	if result[0].KeyName != "alpha-search" || result[0].Attr != "key_name" || result[0].CiTypeName == "" || result[1].KeyName != "search-alpha" || result[0].Score <= result[1].Score {
+		t.Fatalf("search rank not match:%+v %+v", result[0], result[1])
+	}
+	if result, err = CiSearch(models.CiSearchParam{Query: "alpha", CiType: testCiType, Limit: 1}, []string{"tester"}); err != nil || len(result) != 1 || result[0].KeyName != "alpha-search" {
+		t.Fatalf("search limit not match:%v err:%v", result, err)
+	}
+	if result, err = CiSearch(models.CiSearchParam{Query: "lph", CiType: testCiType}, []string{"tester"}); err != nil || len(result) != 0 {
+		t.Fatalf("search should match word prefix only:%v err:%v", result, err)
+	}
+}
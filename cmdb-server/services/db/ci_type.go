@@ -19,7 +19,7 @@ func CiTypesQuery(query *models.CiTypeQuery) error {
 	var ciTypeQueryFilterSql, specSql string
 	var ciTypeQueryParams, ciTypeSubParams []interface{}
 	ciTypesTable := []*models.CiTypeQueryCiObj{}
-	ciTypeQuerySql := `SELECT t1.id,t1.display_name,t1.description,t1.status,t1.ci_group,t1.ci_layer,t1.ci_template,t1.state_machine,CONCAT(t2.guid,'.',t2.type) as 'image_file' 
+	ciTypeQuerySql := `SELECT t1.id,t1.display_name,t1.description,t1.status,t1.ci_group,t1.ci_layer,t1.ci_template,t1.state_machine,CONCAT(t2.guid,'.',t2.type) as image_file 
 		FROM sys_ci_type t1 
 		left join sys_files t2 on t1.image_file=t2.guid WHERE 1=1`
 	if query.CiTypeId != "" {
@@ -301,7 +301,7 @@ func CreateCiTable(ciTypeId string) error {
 	var multiRefAttr []*models.SysCiTypeAttrTable
 	var columnList, historyColumnList, attrRefCiTypeList []string
 	actions := []*execAction{}
-	historyColumnList = append(historyColumnList, "`id` INT(11) NOT NULL AUTO_INCREMENT PRIMARY KEY")
+	historyColumnList = append(historyColumnList, dbDialect.AutoIncrementColumn("id"))
 	for _, ciAttr := range ciAttrRows {
 		if ciAttr.InputType == models.MultiRefType {
 			attrRefCiTypeList = append(attrRefCiTypeList, ciAttr.RefCiType)
@@ -315,7 +315,7 @@ func CreateCiTable(ciTypeId string) error {
 		columnList = append(columnList, tmpAttrSql)
 		historyColumnList = append(historyColumnList, tmpHistoryAttrSql)
 		if ciAttr.InputType == "ref" {
-			actions = append(actions, &execAction{Sql: fmt.Sprintf("CREATE INDEX idx_%s_%s ON %s (`%s`)", ciTypeId, ciAttr.Name, ciTypeId, ciAttr.Name)})
+			actions = append(actions, &execAction{Sql: dbDialect.CreateIndexSql(fmt.Sprintf("idx_%s_%s", ciTypeId, ciAttr.Name), ciTypeId, ciAttr.Name)})
 		}
 	}
 	// check ciType reference ci is confirmed
@@ -344,7 +344,11 @@ func CreateCiTable(ciTypeId string) error {
 		return fmt.Errorf("Check table exists error,%s ", err.Error())
 	}
 	// create ci table & ci history table
-	_, err = x.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE=InnoDB DEFAULT CHARSET=utf8", ciTypeId, strings.Join(columnList, ",")))
+	for _, createSql := range dbDialect.CreateTableSql(ciTypeId, columnList, nil) {
+		if _, err = x.Exec(createSql); err != nil {
+			break
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("Try to create table %s fail,%s ", ciTypeId, err.Error())
 	}
@@ -353,11 +357,15 @@ func CreateCiTable(ciTypeId string) error {
 			log.Logger.Error("Try to create ci table index fail", log.String("ciType", ciTypeId), log.Error(createIndexErr))
 		}
 	}
-	historyColumnList = append(historyColumnList, "`history_action` VARCHAR(16) NOT NULL")
-	historyColumnList = append(historyColumnList, "`history_state_confirmed` TINYINT DEFAULT 0")
-	historyColumnList = append(historyColumnList, "`history_time` DATETIME NOT NULL")
-	historyColumnList = append(historyColumnList, fmt.Sprintf("INDEX `index_%s%s_guid` (`guid`)", HistoryTablePrefix, ciTypeId))
-	_, err = x.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", HistoryTablePrefix+ciTypeId, strings.Join(historyColumnList, ",")))
+	historyColumnList = append(historyColumnList, buildColumnDefine("history_action", dbDialect.ColumnType("varchar", 16), true))
+	historyColumnList = append(historyColumnList, buildColumnDefine("history_state_confirmed", dbDialect.ColumnType("tinyint", 4), false)+" DEFAULT 0")
+	historyColumnList = append(historyColumnList, buildColumnDefine("history_time", dbDialect.ColumnType("datetime", 0), true))
+	historyIndexList := []*tableIndexObj{{Name: fmt.Sprintf("index_%s%s_guid", HistoryTablePrefix, ciTypeId), Columns: []string{"guid"}}}
+	for _, createSql := range dbDialect.CreateTableSql(HistoryTablePrefix+ciTypeId, historyColumnList, historyIndexList) {
+		if _, err = x.Exec(createSql); err != nil {
+			break
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("Try to create history table %s fail,%s ", HistoryTablePrefix+ciTypeId, err.Error())
 	}
@@ -380,21 +388,23 @@ func buildMultiRefTable(attr *models.SysCiTypeAttrTable) error {
 	tableName := fmt.Sprintf("%s$%s", attr.CiType, attr.Name)
 	historyTableName := HistoryTablePrefix + tableName
 	columnList := []string{
-		"`id` INT(11) NOT NULL AUTO_INCREMENT PRIMARY key",
-		"`from_guid` VARCHAR(64) NOT null",
-		"`to_guid` VARCHAR(64) NOT null",
-		"`seq_no` INT",
-		"`note` VARCHAR(32)",
-	}
-	historyColumnList := columnList
-	columnList = append(columnList,
-		"index `"+fmt.Sprintf("%s_%s_from", attr.CiType, attr.Name)+"` (`from_guid`)")
+		dbDialect.AutoIncrementColumn("id"),
+		buildColumnDefine("from_guid", dbDialect.ColumnType("varchar", 64), true),
+		buildColumnDefine("to_guid", dbDialect.ColumnType("varchar", 64), true),
+		buildColumnDefine("seq_no", dbDialect.ColumnType("int", 11), false),
+		buildColumnDefine("note", dbDialect.ColumnType("varchar", 32), false),
+	}
+	historyColumnList := append([]string{}, columnList...)
 	historyColumnList = append(historyColumnList,
-		"`history_to_id` INT",
-		"`history_time` DATETIME NOT NULL",
-		"index `"+fmt.Sprintf("h_%s_%s_from", attr.CiType, attr.Name)+"` (`from_guid`)")
-	actions := []*execAction{{Sql: fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", tableName, strings.Join(columnList, ","))},
-		{Sql: fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", historyTableName, strings.Join(historyColumnList, ","))}}
+		buildColumnDefine("history_to_id", dbDialect.ColumnType("int", 11), false),
+		buildColumnDefine("history_time", dbDialect.ColumnType("datetime", 0), true))
+	actions := []*execAction{}
+	for _, createSql := range dbDialect.CreateTableSql(tableName, columnList, []*tableIndexObj{{Name: fmt.Sprintf("%s_%s_from", attr.CiType, attr.Name), Columns: []string{"from_guid"}}}) {
+		actions = append(actions, &execAction{Sql: createSql})
+	}
+	for _, createSql := range dbDialect.CreateTableSql(historyTableName, historyColumnList, []*tableIndexObj{{Name: fmt.Sprintf("h_%s_%s_from", attr.CiType, attr.Name), Columns: []string{"from_guid"}}}) {
+		actions = append(actions, &execAction{Sql: createSql})
+	}
 	return transaction(actions)
 }
 
@@ -416,7 +426,8 @@ func CiTypesRollback(ciTypeId string) error {
 
 func checkTableIfExists(tableName string) error {
 	var existTableList []*models.DatabaseTableList
-	err := x.SQL("SELECT `TABLE_NAME` FROM information_schema.`TABLES` WHERE TABLE_SCHEMA=? ", models.Config.Database.DataBase).Find(&existTableList)
+	tableListSql, tableListParam := dbDialect.TableListSql(models.Config.Database.DataBase)
+	err := x.SQL(tableListSql, tableListParam...).Find(&existTableList)
 	if err != nil {
 		return fmt.Errorf("Try to get database table list fail,%s ", err.Error())
 	}
@@ -435,13 +446,7 @@ func checkTableIfExists(tableName string) error {
 }
 
 func buildColumnSqlFromCiAttr(ciAttr *models.SysCiTypeAttrTable) (nowTable, historyTable string) {
-	nowTable = fmt.Sprintf("`%s` %s", ciAttr.Name, ciAttr.DataType)
-	if ciAttr.DataType != "datetime" {
-		nowTable += fmt.Sprintf("(%d)", ciAttr.DataLength)
-	}
-	if ciAttr.Nullable == "no" {
-		nowTable += " NOT NULL"
-	}
+	nowTable = buildColumnDefine(ciAttr.Name, dbDialect.ColumnType(ciAttr.DataType, ciAttr.DataLength), ciAttr.Nullable == "no")
 	historyTable = nowTable
 	if ciAttr.Name == "guid" {
 		nowTable += " PRIMARY KEY"
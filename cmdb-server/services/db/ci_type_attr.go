@@ -15,7 +15,7 @@ var (
 )
 
 func init() {
-	ciAttrInsertSql = getDefaultInsertSqlByStruct(models.SysCiTypeAttrTable{}, "sys_ci_type_attr", []string{"ref_type", "ref_ci_type", "select_list"})
+	ciAttrInsertSql = getDefaultInsertSqlByStruct(models.SysCiTypeAttrTable{}, "sys_ci_type_attr", []string{"ref_type", "ref_ci_type", "select_list", "trigger_operation"})
 	ciRefAttrInsertSql = getDefaultInsertSqlByStruct(models.SysCiTypeAttrTable{}, "sys_ci_type_attr", []string{})
 }
 
@@ -70,6 +70,15 @@ func CiAttrCreate(param *models.SysCiTypeAttrTable) error {
 	if param.EditGroupControl == "" {
 		param.EditGroupControl = "no"
 	}
+	if err := validateTimeTriggerAttr(param.CiType, param.InputType, param.DataType, &param.TriggerOperation, nil); err != nil {
+		return err
+	}
+	if err := validateRefFilterExpression(param.RefFilter, nil); err != nil {
+		return err
+	}
+	if err := checkAutofillRuleCycle(param); err != nil {
+		return err
+	}
 	var err error
 	execSql := ciAttrInsertSql
 	execParams := []interface{}{param.Id, param.CiType, param.Name, param.DisplayName, param.Description, param.Status, param.InputType, param.DataType,
@@ -86,6 +95,11 @@ func CiAttrCreate(param *models.SysCiTypeAttrTable) error {
 		execSql = execSql[:len(execSql)-1] + ",?)"
 		execParams = append(execParams, param.SelectList)
 	}
+	if param.TriggerOperation != "" {
+		execSql = strings.ReplaceAll(execSql, ") VALUE", ",trigger_operation) VALUE")
+		execSql = execSql[:len(execSql)-1] + ",?)"
+		execParams = append(execParams, param.TriggerOperation)
+	}
 	execParams = append([]interface{}{execSql}, execParams...)
 	_, err = x.Exec(execParams...)
 	if err != nil {
@@ -160,14 +174,14 @@ func CiAttrUpdate(param *models.SysCiTypeAttrTable) (updateAutoFill bool, err er
 		}
 		if ciAttrData.DataLength != param.DataLength {
 			if ciAttrData.DataType == "varchar" || ciAttrData.DataType == "int" {
-				alterSql := fmt.Sprintf("alter table %s modify column %s %s(%d)", ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
-				alertHistorySql := fmt.Sprintf("alter table %s%s modify column %s %s(%d)", HistoryTablePrefix, ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
+				if err = checkCiAttrLengthReduce(ciAttrData, param.DataLength); err != nil {
+					return
+				}
+				modifyNullable := ""
 				if param.Nullable == "no" {
-					alterSql += " not null"
-					alertHistorySql += " not null"
+					modifyNullable = "no"
 				}
-				actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
-				actions = append(actions, &execAction{Sql: alertHistorySql, Param: []interface{}{}})
+				actions = append(actions, buildModifyColumnActions(ciAttrData, param.DataLength, modifyNullable)...)
 				extendUpdateColumn += ",data_length=?"
 				execParams = append(execParams, param.DataLength)
 			}
@@ -178,18 +192,12 @@ func CiAttrUpdate(param *models.SysCiTypeAttrTable) (updateAutoFill bool, err er
 			}
 		}
 		if ciAttrData.Nullable == "no" && param.Nullable == "yes" {
-			alterSql := fmt.Sprintf("alter table %s modify column %s %s(%d) default null", ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
-			alertHistorySql := fmt.Sprintf("alter table %s%s modify column %s %s(%d) default null", HistoryTablePrefix, ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
-			actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
-			actions = append(actions, &execAction{Sql: alertHistorySql, Param: []interface{}{}})
+			actions = append(actions, buildModifyColumnActions(ciAttrData, param.DataLength, "yes")...)
 			extendUpdateColumn += ",nullable=?"
 			execParams = append(execParams, param.Nullable)
 		}
 		if ciAttrData.Nullable == "yes" && param.Nullable == "no" {
-			alterSql := fmt.Sprintf("alter table %s modify column %s %s(%d) not null", ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
-			alertHistorySql := fmt.Sprintf("alter table %s%s modify column %s %s(%d) not null", HistoryTablePrefix, ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, param.DataLength)
-			actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
-			actions = append(actions, &execAction{Sql: alertHistorySql, Param: []interface{}{}})
+			actions = append(actions, buildModifyColumnActions(ciAttrData, param.DataLength, "no")...)
 			extendUpdateColumn += ",nullable=?"
 			execParams = append(execParams, param.Nullable)
 		}
@@ -198,6 +206,21 @@ func CiAttrUpdate(param *models.SysCiTypeAttrTable) (updateAutoFill bool, err er
 		extendUpdateColumn += ",select_list=?"
 		execParams = append(execParams, param.SelectList)
 	}
+	inputType, dataType := ciAttrData.InputType, ciAttrData.DataType
+	if ciAttrData.Status == "notCreated" || ciAttrData.Status == "dirty" {
+		inputType, dataType = param.InputType, param.DataType
+	}
+	if err = validateTimeTriggerAttr(ciAttrData.CiType, inputType, dataType, &param.TriggerOperation, nil); err != nil {
+		return
+	}
+	if err = validateRefFilterExpression(param.RefFilter, nil); err != nil {
+		return
+	}
+	if err = checkAutofillRuleCycle(param); err != nil {
+		return
+	}
+	extendUpdateColumn += ",trigger_operation=?"
+	execParams = append(execParams, param.TriggerOperation)
 	execParams = append(execParams, param.Id)
 	execParams[0] = "UPDATE sys_ci_type_attr SET display_name=?,description=?,ui_search_order=?,text_validate=?,reset_on_edit=?,display_by_default=?,ui_nullable=?,nullable=?,editable=?,unique_constraint=?,autofillable=?,autofill_rule=?,autofill_type=?,edit_group_control=?,edit_group_value=?,ref_name=?,ref_filter=?,ref_update_state_validate=?,ref_confirm_state_validate=?,permission_usage=?" + extendUpdateColumn + "  WHERE id=?"
 	_, err = x.Exec(execParams...)
@@ -222,10 +245,7 @@ func CiAttrDelete(ciAttrId string) error {
 		var actions []*execAction
 		actions = append(actions, &execAction{Sql: "UPDATE sys_ci_type_attr SET status='deleted' WHERE id=?", Param: []interface{}{ciAttrId}})
 		if ciAttrData.Nullable == "no" {
-			alterSql := fmt.Sprintf("alter table %s modify column %s %s(%d) default null", ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, ciAttrData.DataLength)
-			alertHistorySql := fmt.Sprintf("alter table %s%s modify column %s %s(%d) default null", HistoryTablePrefix, ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, ciAttrData.DataLength)
-			actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
-			actions = append(actions, &execAction{Sql: alertHistorySql, Param: []interface{}{}})
+			actions = append(actions, buildModifyColumnActions(ciAttrData, ciAttrData.DataLength, "yes")...)
 		}
 		err = transaction(actions)
 	}
@@ -253,10 +273,7 @@ func CiAttrRollback(ciAttrId string) error {
 	var actions []*execAction
 	actions = append(actions, &execAction{Sql: "UPDATE sys_ci_type_attr SET status='created' where id=?", Param: []interface{}{ciAttrId}})
 	if ciAttrData.Nullable == "no" {
-		alterSql := fmt.Sprintf("alter table %s modify column %s %s(%d) not null", ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, ciAttrData.DataLength)
-		alertHistorySql := fmt.Sprintf("alter table %s%s modify column %s %s(%d) not null", HistoryTablePrefix, ciAttrData.CiType, ciAttrData.Name, ciAttrData.DataType, ciAttrData.DataLength)
-		actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
-		actions = append(actions, &execAction{Sql: alertHistorySql, Param: []interface{}{}})
+		actions = append(actions, buildModifyColumnActions(ciAttrData, ciAttrData.DataLength, "no")...)
 	}
 	err = transaction(actions)
 	return err
@@ -322,7 +339,9 @@ func CiAttrApply(ciTypeId, ciAttrId string, updateAutofill bool) error {
 	}
 	if ciAttrData.Status == "created" {
 		if updateAutofill {
-			affectCiTypeChan <- ciTypeId
+			if _, err = CreateAutofillJob(models.AutofillJobParam{CiType: ciTypeId}, "system"); err != nil {
+				return err
+			}
 		}
 		return nil
 	}
@@ -345,10 +364,10 @@ func CiAttrApply(ciTypeId, ciAttrId string, updateAutofill bool) error {
 	} else {
 		attrSql, historyAttrSql := buildColumnSqlFromCiAttr(ciAttrData)
 		var actions []*execAction
-		actions = append(actions, &execAction{Sql: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", ciTypeId, attrSql)})
-		actions = append(actions, &execAction{Sql: fmt.Sprintf("ALTER TABLE %s%s ADD COLUMN %s", HistoryTablePrefix, ciTypeId, historyAttrSql)})
+		actions = append(actions, &execAction{Sql: dbDialect.AddColumnSql(ciTypeId, attrSql)})
+		actions = append(actions, &execAction{Sql: dbDialect.AddColumnSql(HistoryTablePrefix+ciTypeId, historyAttrSql)})
 		if ciAttrData.InputType == "ref" {
-			actions = append(actions, &execAction{Sql: fmt.Sprintf("CREATE INDEX idx_%s_%s ON %s (`%s`)", ciTypeId, ciAttrData.Name, ciTypeId, ciAttrData.Name)})
+			actions = append(actions, &execAction{Sql: dbDialect.CreateIndexSql(fmt.Sprintf("idx_%s_%s", ciTypeId, ciAttrData.Name), ciTypeId, ciAttrData.Name)})
 		}
 		err = transaction(actions)
 		if err != nil {
@@ -379,3 +398,14 @@ func CheckCiAttrIsPassword(ciType, attr string) (isPwd bool, err error) {
 	}
 	return
 }
+
+// buildModifyColumnActions 同时修改数据表和历史表的字段定义
+func buildModifyColumnActions(ciAttr *models.SysCiTypeAttrTable, dataLength int, nullable string) (actions []*execAction) {
+	columnType := dbDialect.ColumnType(ciAttr.DataType, dataLength)
+	for _, tableName := range []string{ciAttr.CiType, HistoryTablePrefix + ciAttr.CiType} {
+		for _, alterSql := range dbDialect.ModifyColumnSql(tableName, ciAttr.Name, columnType, nullable) {
+			actions = append(actions, &execAction{Sql: alterSql, Param: []interface{}{}})
+		}
+	}
+	return
+}
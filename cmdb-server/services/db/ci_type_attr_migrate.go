@@ -0,0 +1,504 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// 字段迁移时按输入类型归类,同类之间只需要校验长度和类型,不同类之间按ciAttrMigrateRouteMap转换数据
+const (
+	migrateCategoryString      = "string"
+	migrateCategoryInt         = "int"
+	migrateCategoryMultiString = "multiString"
+	migrateCategoryMultiInt    = "multiInt"
+	migrateCategoryRef         = "ref"
+	migrateCategoryMultiRef    = models.MultiRefType
+)
+
+var ciAttrMigrateRouteMap = map[string]bool{
+	"string>string": true, "string>int": true, "int>string": true, "int>int": true,
+	"string>multiString": true, "multiString>string": true, "multiString>multiString": true,
+	"int>multiInt": true, "multiInt>int": true, "multiInt>multiInt": true,
+	"string>ref": true, "ref>string": true, "ref>ref": true,
+	"ref>multiRef": true, "multiRef>ref": true, "multiRef>multiRef": true,
+}
+
+type ciAttrMigrateRowObj struct {
+	Table     string
+	Id        string
+	Value     string
+	ValueList []string
+	NewValue  string
+	SetNull   bool
+	Changed   bool
+	Failed    bool
+}
+
+// ciAttrMigrateRowQueryObj 查询待迁移数据的语句,多选引用的数据在关系表里
+type ciAttrMigrateRowQueryObj struct {
+	Table    string
+	IdColumn string
+	Sql      string
+}
+
+func getCiAttrMigrateCategory(inputType string) string {
+	switch inputType {
+	case "text", "longText", "richText", "diffVariable", "select":
+		return migrateCategoryString
+	case "int":
+		return migrateCategoryInt
+	case "multiText", "multiSelect":
+		return migrateCategoryMultiString
+	case "multiInt":
+		return migrateCategoryMultiInt
+	case "ref":
+		return migrateCategoryRef
+	case models.MultiRefType:
+		return migrateCategoryMultiRef
+	}
+	return ""
+}
+
+// CiAttrMigrate 修改已创建属性的输入类型、数据类型或长度,先校验现有数据能否转换,全部通过后修改数据表和历史表字段并转换数据
+func CiAttrMigrate(param *models.SysCiTypeAttrTable, dryRun bool) (result models.CiAttrMigrateResult, err error) {
+	oldAttr, err := getCiAttrById(param.Id)
+	if err != nil {
+		return
+	}
+	if oldAttr.CiType != param.CiType {
+		err = fmt.Errorf("Attribute:%s is not belong to ciType:%s ", param.Id, param.CiType)
+		return
+	}
+	if oldAttr.Status != "created" {
+		err = fmt.Errorf("Attribute:%s is not created,update it directly ", param.Id)
+		return
+	}
+	newAttr := *oldAttr
+	if param.InputType != "" {
+		newAttr.InputType = param.InputType
+	}
+	if param.DataType != "" {
+		newAttr.DataType = param.DataType
+		newAttr.DataLength = param.DataLength
+		if strings.Contains(param.DataType, "(") {
+			newAttr.DataType = param.DataType[:strings.Index(param.DataType, "(")]
+			newAttr.DataLength, _ = strconv.Atoi(param.DataType[strings.Index(param.DataType, "(")+1 : len(param.DataType)-1])
+		}
+	} else if param.DataLength > 0 {
+		newAttr.DataLength = param.DataLength
+	}
+	if param.SelectList != "" {
+		newAttr.SelectList = param.SelectList
+	}
+	result = models.CiAttrMigrateResult{CiAttr: oldAttr.Id, FromInputType: oldAttr.InputType, ToInputType: newAttr.InputType, FromDataType: oldAttr.DataType,
+		ToDataType: newAttr.DataType, FromLength: oldAttr.DataLength, ToLength: newAttr.DataLength, FailRows: []*models.CiAttrMigrateFailRow{}, DryRun: dryRun}
+	fromCategory, toCategory := getCiAttrMigrateCategory(oldAttr.InputType), getCiAttrMigrateCategory(newAttr.InputType)
+	if oldAttr.InputType == newAttr.InputType && fromCategory == "" {
+		// 同类型只改长度,例如密码和对象字段
+		fromCategory, toCategory = migrateCategoryString, migrateCategoryString
+	}
+	if fromCategory == "" || toCategory == "" || !ciAttrMigrateRouteMap[fromCategory+">"+toCategory] {
+		err = fmt.Errorf("Attribute:%s can not migrate from %s to %s ", oldAttr.Id, oldAttr.InputType, newAttr.InputType)
+		return
+	}
+	if toCategory == migrateCategoryRef || toCategory == migrateCategoryMultiRef {
+		if param.RefCiType != "" {
+			newAttr.RefCiType = param.RefCiType
+		}
+		if param.RefType != "" {
+			newAttr.RefType = param.RefType
+		}
+		if newAttr.RefCiType == "" || newAttr.RefType == "" {
+			err = fmt.Errorf("Attribute:%s referenceId and referenceType can not empty ", oldAttr.Id)
+			return
+		}
+		refCiType, getErr := GetCiTypeById(newAttr.RefCiType)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		if refCiType.Status != "created" {
+			err = fmt.Errorf("Attr ref ciType:%s is not created ", newAttr.RefCiType)
+			return
+		}
+	} else {
+		newAttr.RefCiType, newAttr.RefType = "", ""
+	}
+	rows, err := getCiAttrMigrateRows(oldAttr, fromCategory)
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		if row.Table == oldAttr.CiType {
+			result.TotalRows++
+		}
+	}
+	result.FailRows, result.ConvertRows = validateCiAttrMigrateRows(oldAttr, &newAttr, fromCategory, toCategory, rows)
+	var refGuidList []string
+	for _, row := range rows {
+		// 历史数据可能引用已删除的数据,只校验当前数据的引用
+		if !row.Failed && row.Table == oldAttr.CiType && (toCategory == migrateCategoryRef || toCategory == migrateCategoryMultiRef) {
+			refGuidList = append(refGuidList, row.ValueList...)
+			if toCategory == migrateCategoryRef && row.NewValue != "" {
+				refGuidList = append(refGuidList, row.NewValue)
+			}
+		}
+	}
+	if len(refGuidList) > 0 {
+		missingMap, queryErr := getCiAttrMigrateMissingRef(newAttr.RefCiType, refGuidList)
+		if queryErr != nil {
+			err = queryErr
+			return
+		}
+		for _, row := range rows {
+			if row.Table != oldAttr.CiType {
+				continue
+			}
+			for _, refGuid := range append([]string{row.NewValue}, row.ValueList...) {
+				if missingMap[refGuid] {
+					result.FailRows = append(result.FailRows, &models.CiAttrMigrateFailRow{Table: row.Table, Id: row.Id, Value: row.Value, Message: fmt.Sprintf("Can not find reference data:%s in ciType:%s ", refGuid, newAttr.RefCiType)})
+					break
+				}
+			}
+		}
+	}
+	if len(result.FailRows) > 0 || dryRun {
+		return
+	}
+	if fromCategory != migrateCategoryMultiRef && toCategory == migrateCategoryMultiRef {
+		if err = buildMultiRefTable(&newAttr); err != nil {
+			err = fmt.Errorf("Try to create multi ref table:%s$%s fail,%s ", newAttr.CiType, newAttr.Name, err.Error())
+			return
+		}
+	}
+	preActions, actions, postActions := buildCiAttrMigrateActions(oldAttr, &newAttr, fromCategory, toCategory)
+	// 校验和转换之间数据可能被修改,事务里锁住数据重新校验一遍再转换
+	actions = append(buildCiAttrMigrateCheckActions(oldAttr, &newAttr, fromCategory, toCategory), actions...)
+	if err = execCiAttrMigrateDdl(preActions); err != nil {
+		err = fmt.Errorf("Try to alter table before migrate attribute:%s fail,%s ", oldAttr.Id, err.Error())
+		return
+	}
+	if err = transaction(actions); err != nil {
+		err = fmt.Errorf("Try to migrate attribute:%s fail,%s ", oldAttr.Id, err.Error())
+		return
+	}
+	if err = execCiAttrMigrateDdl(postActions); err != nil {
+		err = fmt.Errorf("Attribute:%s data have been migrated,but alter table fail,%s ", oldAttr.Id, err.Error())
+		return
+	}
+	result.Migrated = true
+	log.Logger.Info("Migrate ci attribute success", log.String("ciAttr", oldAttr.Id), log.String("from", oldAttr.InputType), log.String("to", newAttr.InputType), log.Int("convertRows", result.ConvertRows))
+	return
+}
+
+func getCiAttrMigrateRows(attr *models.SysCiTypeAttrTable, category string) (rows []*ciAttrMigrateRowObj, err error) {
+	for _, rowQuery := range getCiAttrMigrateRowQueryList(attr, category) {
+		queryRows, queryErr := x.QueryString(rowQuery.Sql)
+		if queryErr != nil {
+			err = fmt.Errorf("Try to query table %s fail,%s ", rowQuery.Table, queryErr.Error())
+			return
+		}
+		rows = append(rows, buildCiAttrMigrateRows(attr, category, rowQuery, queryRows)...)
+	}
+	return
+}
+
+func getCiAttrMigrateRowQueryList(attr *models.SysCiTypeAttrTable, category string) (queryList []*ciAttrMigrateRowQueryObj) {
+	if category == migrateCategoryMultiRef {
+		multiRefTable := fmt.Sprintf("%s$%s", attr.CiType, attr.Name)
+		return []*ciAttrMigrateRowQueryObj{{Table: attr.CiType, IdColumn: "from_guid", Sql: fmt.Sprintf("select from_guid,to_guid from %s order by from_guid,seq_no", multiRefTable)}}
+	}
+	for _, tableObj := range [][]string{{attr.CiType, "guid"}, {HistoryTablePrefix + attr.CiType, "id"}} {
+		queryList = append(queryList, &ciAttrMigrateRowQueryObj{Table: tableObj[0], IdColumn: tableObj[1], Sql: fmt.Sprintf("select %s,%s from %s", tableObj[1], attr.Name, tableObj[0])})
+	}
+	return
+}
+
+func buildCiAttrMigrateRows(attr *models.SysCiTypeAttrTable, category string, rowQuery *ciAttrMigrateRowQueryObj, queryRows []map[string]string) (rows []*ciAttrMigrateRowObj) {
+	if category != migrateCategoryMultiRef {
+		for _, queryRow := range queryRows {
+			rows = append(rows, &ciAttrMigrateRowObj{Table: rowQuery.Table, Id: queryRow[rowQuery.IdColumn], Value: queryRow[attr.Name]})
+		}
+		return
+	}
+	rowMap := make(map[string]*ciAttrMigrateRowObj)
+	for _, queryRow := range queryRows {
+		row, b := rowMap[queryRow["from_guid"]]
+		if !b {
+			row = &ciAttrMigrateRowObj{Table: rowQuery.Table, Id: queryRow["from_guid"]}
+			rowMap[row.Id] = row
+			rows = append(rows, row)
+		}
+		row.ValueList = append(row.ValueList, queryRow["to_guid"])
+	}
+	for _, row := range rows {
+		row.Value = strings.Join(row.ValueList, ",")
+	}
+	return
+}
+
+// validateCiAttrMigrateRows 转换并校验每行数据,返回转换失败的行和需要转换的行数,引用的数据是否存在另外校验
+func validateCiAttrMigrateRows(oldAttr, newAttr *models.SysCiTypeAttrTable, fromCategory, toCategory string, rows []*ciAttrMigrateRowObj) (failRows []*models.CiAttrMigrateFailRow, convertRows int) {
+	failRows = []*models.CiAttrMigrateFailRow{}
+	for _, row := range rows {
+		if convertErr := convertCiAttrMigrateValue(fromCategory, toCategory, row); convertErr != nil {
+			row.Failed = true
+			failRows = append(failRows, &models.CiAttrMigrateFailRow{Table: row.Table, Id: row.Id, Value: row.Value, Message: convertErr.Error()})
+			continue
+		}
+		if toCategory != migrateCategoryMultiRef {
+			if checkErr := checkCiAttrMigrateValue(newAttr, row.NewValue); checkErr != nil {
+				row.Failed = true
+				failRows = append(failRows, &models.CiAttrMigrateFailRow{Table: row.Table, Id: row.Id, Value: row.Value, Message: checkErr.Error()})
+				continue
+			}
+			row.SetNull = row.NewValue == "" && newAttr.DataType == "int" && oldAttr.DataType != "int"
+		}
+		if fromCategory == migrateCategoryMultiRef || toCategory == migrateCategoryMultiRef {
+			row.Changed = fromCategory != toCategory && (len(row.ValueList) > 0 || row.NewValue != "")
+		} else {
+			row.Changed = row.NewValue != row.Value || row.SetNull
+		}
+		if row.Changed {
+			convertRows++
+		}
+	}
+	return
+}
+
+// buildCiAttrMigrateCheckActions 在转换的事务里加锁查出数据重新转换校验,有不能转换的数据时回滚
+func buildCiAttrMigrateCheckActions(oldAttr, newAttr *models.SysCiTypeAttrTable, fromCategory, toCategory string) (actions []*execAction) {
+	for _, rowQuery := range getCiAttrMigrateRowQueryList(oldAttr, fromCategory) {
+		tmpRowQuery := rowQuery
+		actions = append(actions, &execAction{Sql: tmpRowQuery.Sql + dbDialect.ForUpdateSql(), QueryCheck: func(queryRows []map[string]string) error {
+			failRows, _ := validateCiAttrMigrateRows(oldAttr, newAttr, fromCategory, toCategory, buildCiAttrMigrateRows(oldAttr, fromCategory, tmpRowQuery, queryRows))
+			if len(failRows) > 0 {
+				return fmt.Errorf("Data of table:%s changed after validate,%d rows can not migrate,first row:%s %s", tmpRowQuery.Table, len(failRows), failRows[0].Id, failRows[0].Message)
+			}
+			return nil
+		}})
+	}
+	return
+}
+
+func convertCiAttrMigrateValue(fromCategory, toCategory string, row *ciAttrMigrateRowObj) error {
+	row.NewValue = row.Value
+	switch fromCategory + ">" + toCategory {
+	case "string>multiString":
+		if row.Value != "" {
+			valueBytes, _ := json.Marshal([]string{row.Value})
+			row.NewValue = string(valueBytes)
+		}
+	case "multiString>string":
+		if row.Value == "" {
+			return nil
+		}
+		var valueList []string
+		if err := json.Unmarshal([]byte(row.Value), &valueList); err != nil {
+			return fmt.Errorf("Value is not a json string list ")
+		}
+		if len(valueList) > 1 {
+			return fmt.Errorf("Value has %d items,can not convert to single value ", len(valueList))
+		}
+		row.NewValue = strings.Join(valueList, "")
+	case "int>multiInt":
+		if row.Value != "" {
+			row.NewValue = "[" + row.Value + "]"
+		}
+	case "multiInt>int":
+		if row.Value == "" {
+			return nil
+		}
+		var valueList []int
+		if err := json.Unmarshal([]byte(row.Value), &valueList); err != nil {
+			return fmt.Errorf("Value is not a json int list ")
+		}
+		if len(valueList) > 1 {
+			return fmt.Errorf("Value has %d items,can not convert to single value ", len(valueList))
+		}
+		row.NewValue = ""
+		if len(valueList) == 1 {
+			row.NewValue = strconv.Itoa(valueList[0])
+		}
+	case "ref>multiRef":
+		if row.Value != "" {
+			row.ValueList = []string{row.Value}
+		}
+		row.NewValue = ""
+	case "multiRef>ref":
+		if len(row.ValueList) > 1 {
+			return fmt.Errorf("Value has %d references,can not convert to single reference ", len(row.ValueList))
+		}
+		row.NewValue = strings.Join(row.ValueList, "")
+		row.ValueList = []string{}
+	}
+	return nil
+}
+
+func checkCiAttrMigrateValue(attr *models.SysCiTypeAttrTable, value string) error {
+	if value == "" {
+		if attr.DataType == "int" && attr.Nullable == "no" {
+			return fmt.Errorf("Value is empty but attribute is not nullable ")
+		}
+		return nil
+	}
+	switch attr.DataType {
+	case "int":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("Value is not a integer ")
+		}
+	case "varchar":
+		if valueLength := utf8.RuneCountInString(value); attr.DataLength > 0 && valueLength > attr.DataLength {
+			return fmt.Errorf("Value length %d is more than %d ", valueLength, attr.DataLength)
+		}
+	}
+	return nil
+}
+
+func getCiAttrMigrateMissingRef(refCiType string, guidList []string) (missingMap map[string]bool, err error) {
+	missingMap = make(map[string]bool)
+	existMap := make(map[string]bool)
+	for i := 0; i < len(guidList); i += 500 {
+		end := i + 500
+		if end > len(guidList) {
+			end = len(guidList)
+		}
+		specSql, params := createListParams(guidList[i:end], "")
+		queryRows, queryErr := x.QueryString(append([]interface{}{fmt.Sprintf("select guid from %s where guid in (%s)", refCiType, specSql)}, params...)...)
+		if queryErr != nil {
+			err = fmt.Errorf("Try to query reference ciType:%s data fail,%s ", refCiType, queryErr.Error())
+			return
+		}
+		for _, row := range queryRows {
+			existMap[row["guid"]] = true
+		}
+	}
+	for _, guid := range guidList {
+		if guid != "" && !existMap[guid] {
+			missingMap[guid] = true
+		}
+	}
+	return
+}
+
+// buildCiAttrMigrateActions 数据转换用整表的update语句放在事务里执行,mysql的ddl会隐式提交事务,改表语句按顺序放在事务前后单独执行
+func buildCiAttrMigrateActions(oldAttr, newAttr *models.SysCiTypeAttrTable, fromCategory, toCategory string) (preActions, actions, postActions []*execAction) {
+	historyTable := HistoryTablePrefix + oldAttr.CiType
+	multiRefTable := fmt.Sprintf("%s$%s", oldAttr.CiType, oldAttr.Name)
+	var updateActions []*execAction
+	for _, tableName := range []string{oldAttr.CiType, historyTable} {
+		if convertSql := getCiAttrMigrateConvertSql(fromCategory, toCategory, oldAttr.Name); convertSql != "" {
+			updateActions = append(updateActions, &execAction{Sql: fmt.Sprintf("UPDATE %s SET %s=%s WHERE %s<>''", tableName, oldAttr.Name, convertSql, oldAttr.Name)})
+		}
+		if newAttr.DataType == "int" && oldAttr.DataType != "int" {
+			updateActions = append(updateActions, &execAction{Sql: fmt.Sprintf("UPDATE %s SET %s=NULL WHERE %s=''", tableName, oldAttr.Name, oldAttr.Name)})
+		}
+	}
+	switch {
+	case fromCategory != migrateCategoryMultiRef && toCategory == migrateCategoryMultiRef:
+		actions = append(actions, &execAction{Sql: fmt.Sprintf("INSERT INTO %s(from_guid,to_guid,seq_no) SELECT guid,%s,1 FROM %s WHERE %s<>''", multiRefTable, oldAttr.Name, oldAttr.CiType, oldAttr.Name)})
+		// 每条历史数据都补一份多选引用的历史,按当时引用数据的历史记录关联
+		actions = append(actions, &execAction{Sql: fmt.Sprintf("INSERT INTO %s%s(from_guid,to_guid,seq_no,history_to_id,history_time) SELECT t1.guid,t1.%s,0,"+
+			"COALESCE((SELECT max(t2.id) FROM %s%s t2 WHERE t2.guid=t1.%s AND t2.history_time<=t1.history_time),0),t1.history_time FROM %s t1 WHERE t1.%s<>''",
+			HistoryTablePrefix, multiRefTable, oldAttr.Name, HistoryTablePrefix, newAttr.RefCiType, oldAttr.Name, historyTable, oldAttr.Name)})
+		// 历史表的字段保留旧数据,改成可空避免后续写历史时报错
+		postActions = append(postActions, &execAction{Sql: dbDialect.DropColumnSql(oldAttr.CiType, oldAttr.Name)})
+		for _, alterSql := range dbDialect.ModifyColumnSql(historyTable, oldAttr.Name, dbDialect.ColumnType(oldAttr.DataType, oldAttr.DataLength), "yes") {
+			postActions = append(postActions, &execAction{Sql: alterSql})
+		}
+	case fromCategory == migrateCategoryMultiRef && toCategory != migrateCategoryMultiRef:
+		nowColumnSql, historyColumnSql := buildColumnSqlFromCiAttr(newAttr)
+		preActions = append(preActions, &execAction{Sql: dbDialect.AddColumnSql(oldAttr.CiType, nowColumnSql)})
+		if _, queryErr := x.QueryString(fmt.Sprintf("select %s from %s where 1=0", oldAttr.Name, historyTable)); queryErr != nil {
+			preActions = append(preActions, &execAction{Sql: dbDialect.AddColumnSql(historyTable, historyColumnSql)})
+		} else {
+			for _, alterSql := range dbDialect.ModifyColumnSql(historyTable, oldAttr.Name, dbDialect.ColumnType(newAttr.DataType, newAttr.DataLength), "yes") {
+				preActions = append(preActions, &execAction{Sql: alterSql})
+			}
+		}
+		actions = append(actions, &execAction{Sql: fmt.Sprintf("UPDATE %s SET %s=(SELECT max(to_guid) FROM %s WHERE from_guid=%s.guid) WHERE guid IN (SELECT from_guid FROM %s)",
+			oldAttr.CiType, oldAttr.Name, multiRefTable, oldAttr.CiType, multiRefTable)})
+		actions = append(actions, &execAction{Sql: fmt.Sprintf("delete from %s", multiRefTable)})
+		postActions = append(postActions, &execAction{Sql: dbDialect.CreateIndexSql(fmt.Sprintf("idx_%s_%s", oldAttr.CiType, oldAttr.Name), oldAttr.CiType, oldAttr.Name)})
+	case fromCategory == migrateCategoryMultiRef && toCategory == migrateCategoryMultiRef:
+	default:
+		modifyActions := buildModifyColumnActions(&models.SysCiTypeAttrTable{CiType: oldAttr.CiType, Name: oldAttr.Name, DataType: newAttr.DataType}, newAttr.DataLength, newAttr.Nullable)
+		// 转换后的值变长时先改字段再更新数据,其他情况先更新数据再改字段
+		alterFirst := fromCategory+">"+toCategory == "string>multiString" || fromCategory+">"+toCategory == "int>multiInt" || (oldAttr.DataType == "int" && newAttr.DataType != "int")
+		if alterFirst {
+			preActions = append(preActions, modifyActions...)
+		} else {
+			postActions = append(postActions, modifyActions...)
+		}
+		actions = append(actions, updateActions...)
+		// 引用字段都带索引,改成引用时建索引,改成非引用时删除
+		if toCategory == migrateCategoryRef && fromCategory != migrateCategoryRef {
+			postActions = append(postActions, &execAction{Sql: dbDialect.CreateIndexSql(fmt.Sprintf("idx_%s_%s", oldAttr.CiType, oldAttr.Name), oldAttr.CiType, oldAttr.Name)})
+		} else if fromCategory == migrateCategoryRef && toCategory != migrateCategoryRef {
+			postActions = append(postActions, &execAction{Sql: dbDialect.DropIndexSql(fmt.Sprintf("idx_%s_%s", oldAttr.CiType, oldAttr.Name), oldAttr.CiType)})
+		}
+	}
+	var refCiType, refType, selectList interface{}
+	if newAttr.RefCiType != "" {
+		refCiType, refType = newAttr.RefCiType, newAttr.RefType
+	}
+	if newAttr.SelectList != "" {
+		selectList = newAttr.SelectList
+	}
+	actions = append(actions, &execAction{Sql: "UPDATE sys_ci_type_attr SET input_type=?,data_type=?,data_length=?,ref_ci_type=?,ref_type=?,select_list=? WHERE id=?",
+		Param: []interface{}{newAttr.InputType, newAttr.DataType, newAttr.DataLength, refCiType, refType, selectList, oldAttr.Id}})
+	return
+}
+
+// getCiAttrMigrateConvertSql 返回把旧值转换成新值的表达式,和convertCiAttrMigrateValue的转换规则一致,不需要转换时返回空
+func getCiAttrMigrateConvertSql(fromCategory, toCategory, column string) string {
+	switch fromCategory + ">" + toCategory {
+	case "string>multiString":
+		return dbDialect.JsonArraySql(column)
+	case "multiString>string":
+		return fmt.Sprintf("COALESCE(%s,'')", dbDialect.JsonFirstItemSql(column))
+	case "int>multiInt":
+		return dbDialect.ConcatSql("'['", column, "']'")
+	case "multiInt>int":
+		return fmt.Sprintf("replace(replace(%s,'[',''),']','')", column)
+	}
+	return ""
+}
+
+// execCiAttrMigrateDdl 逐条执行改表语句,不放在事务里
+func execCiAttrMigrateDdl(actions []*execAction) error {
+	for _, action := range actions {
+		if _, err := x.Exec(append([]interface{}{dbDialect.RewriteSql(action.Sql)}, action.Param...)...); err != nil {
+			return fmt.Errorf("Try to exec sql:%s fail,%s ", action.Sql, err.Error())
+		}
+	}
+	return nil
+}
+
+// checkCiAttrLengthReduce 已创建属性缩短长度前检查现有数据是否会被截断
+func checkCiAttrLengthReduce(attr *models.SysCiTypeAttrTable, dataLength int) error {
+	if attr.DataType != "varchar" || dataLength >= attr.DataLength {
+		return nil
+	}
+	rows, err := getCiAttrMigrateRows(attr, migrateCategoryString)
+	if err != nil {
+		return err
+	}
+	checkAttr := models.SysCiTypeAttrTable{DataType: attr.DataType, DataLength: dataLength}
+	failCount := 0
+	for _, row := range rows {
+		if checkCiAttrMigrateValue(&checkAttr, row.Value) != nil {
+			failCount++
+		}
+	}
+	if failCount > 0 {
+		return fmt.Errorf("Attribute:%s has %d rows longer than %d,please use migrate to check detail ", attr.Id, failCount, dataLength)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func createTestCiAttr(t *testing.T, name, inputType, refCiType string) *models.SysCiTypeAttrTable {
+	t.Helper()
+	attr := models.SysCiTypeAttrTable{CiType: testCiType, Name: name, DisplayName: name, InputType: inputType, DataType: "varchar", DataLength: 64,
+		RefCiType: refCiType, UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "no"}
+	if refCiType != "" {
+		attr.RefName, attr.RefType = "depend", "link"
+	}
+	if err := CiAttrCreate(&attr); err != nil {
+		t.Fatalf("create attr:%s fail,%s", name, err.Error())
+	}
+	if err := CiAttrApply(testCiType, attr.Id, false); err != nil {
+		t.Fatalf("apply attr:%s fail,%s", name, err.Error())
+	}
+	return &attr
+}
+
+func TestCiAttrMigrateMultiString(t *testing.T) {
+	attr := createTestCiAttr(t, "tag", "text", "")
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m1", "asset_id": "asset-m1", "key_name": "m1", "tag": `a"b`})
+	guid := inserted[0]["guid"]
+	if _, err := CiAttrMigrate(&models.SysCiTypeAttrTable{Id: attr.Id, CiType: testCiType, InputType: "multiText"}, false); err != nil {
+		t.Fatalf("migrate to multiText fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["tag"] != `["a\"b"]` {
+		t.Fatalf("multiText value not match:%s", row["tag"])
+	}
+	if row := queryTestRow(t, "select * from history_test_host where guid=?", guid); row["tag"] != `["a\"b"]` {
+		t.Fatalf("multiText history value not match:%s", row["tag"])
+	}
+	if _, err := CiAttrMigrate(&models.SysCiTypeAttrTable{Id: attr.Id, CiType: testCiType, InputType: "text"}, false); err != nil {
+		t.Fatalf("migrate to text fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["tag"] != `a"b` {
+		t.Fatalf("text value not match:%s", row["tag"])
+	}
+}
+
+func TestCiAttrMigrateMultiRefToRef(t *testing.T) {
+	attr := createTestCiAttr(t, "peer_host", models.MultiRefType, testCiType)
+	peer := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m2", "asset_id": "asset-m2", "key_name": "m2"})
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m3", "asset_id": "asset-m3", "key_name": "m3", "peer_host": peer[0]["guid"]})
+	if _, err := CiAttrMigrate(&models.SysCiTypeAttrTable{Id: attr.Id, CiType: testCiType, InputType: "ref", RefCiType: testCiType, RefType: "link"}, false); err != nil {
+		t.Fatalf("migrate to ref fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", inserted[0]["guid"]); row["peer_host"] != peer[0]["guid"] {
+		t.Fatalf("ref value not match:%v", row)
+	}
+	if num := countTestRows(t, "select * from test_host$peer_host"); num != 0 {
+		t.Fatalf("multi ref table should be empty,num:%d", num)
+	}
+}
+
+func TestCiAttrMigrateRefToMultiRefHistory(t *testing.T) {
+	oldAttr := createTestCiAttr(t, "owner_host", "ref", testCiType)
+	owner := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m4", "asset_id": "asset-m4", "key_name": "m4"})
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m5", "asset_id": "asset-m5", "key_name": "m5", "owner_host": owner[0]["guid"]})
+	newAttr := *oldAttr
+	newAttr.InputType = models.MultiRefType
+	if err := buildMultiRefTable(&newAttr); err != nil {
+		t.Fatalf("create multi ref table fail,%s", err.Error())
+	}
+	// sqlite 3.32不支持drop column,只执行事务里的数据转换
+	_, actions, _ := buildCiAttrMigrateActions(oldAttr, &newAttr, migrateCategoryRef, migrateCategoryMultiRef)
+	if err := transaction(actions); err != nil {
+		t.Fatalf("migrate data fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from test_host$owner_host where from_guid=?", inserted[0]["guid"]); row == nil || row["to_guid"] != owner[0]["guid"] {
+		t.Fatalf("multi ref row not match:%v", row)
+	}
+	historyRow := queryTestRow(t, "select * from history_test_host$owner_host where from_guid=?", inserted[0]["guid"])
+	ownerHistory := queryTestRow(t, "select * from history_test_host where guid=?", owner[0]["guid"])
+	if historyRow == nil || historyRow["to_guid"] != owner[0]["guid"] || historyRow["history_to_id"] != ownerHistory["id"] {
+		t.Fatalf("multi ref history row not match:%v", historyRow)
+	}
+}
+
+func TestCiAttrMigrateEmptyMultiString(t *testing.T) {
+	attr := createTestCiAttr(t, "label", "multiText", "")
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m6", "asset_id": "asset-m6", "key_name": "m6"})
+	guid := inserted[0]["guid"]
+	if _, err := x.Exec("update test_host set label=? where guid=?", "[]", guid); err != nil {
+		t.Fatalf("update label fail,%s", err.Error())
+	}
+	// 空数组转成单值后是空,不能把[]原样留下
+	if _, err := CiAttrMigrate(&models.SysCiTypeAttrTable{Id: attr.Id, CiType: testCiType, InputType: "text"}, false); err != nil {
+		t.Fatalf("migrate to text fail,%s", err.Error())
+	}
+	if row := queryTestRow(t, "select * from test_host where guid=?", guid); row["label"] != "" {
+		t.Fatalf("empty list should convert to empty value:%s", row["label"])
+	}
+}
+
+func TestCiAttrMigrateCheckInTransaction(t *testing.T) {
+	oldAttr := createTestCiAttr(t, "short_name", "text", "")
+	inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": "m7", "asset_id": "asset-m7", "key_name": "m7", "short_name": "ok"})
+	newAttr := *oldAttr
+	newAttr.DataLength = 3
+	checkActions := buildCiAttrMigrateCheckActions(oldAttr, &newAttr, migrateCategoryString, migrateCategoryString)
+	if err := transaction(checkActions); err != nil {
+		t.Fatalf("data can migrate should pass check,%s", err.Error())
+	}
+	// 校验通过后数据被改成不能转换的值,事务里再校验时要拒绝
+	if _, err := x.Exec("update test_host set short_name=? where guid=?", "too long", inserted[0]["guid"]); err != nil {
+		t.Fatalf("update short name fail,%s", err.Error())
+	}
+	if err := transaction(checkActions); err == nil {
+		t.Fatalf("data changed after validate should be rejected")
+	}
+}
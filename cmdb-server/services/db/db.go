@@ -24,9 +24,11 @@ var (
 )
 
 func InitDatabase() error {
-	connStr := fmt.Sprintf("%s:%s@%s(%s)/%s?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
-		models.Config.Database.User, models.Config.Database.Password, "tcp", fmt.Sprintf("%s:%s", models.Config.Database.Server, models.Config.Database.Port), models.Config.Database.DataBase)
-	engine, err := xorm.NewEngine("mysql", connStr)
+	if err := initDialect(models.Config.Database.Type); err != nil {
+		log.Logger.Error("Init database dialect fail", log.Error(err))
+		return err
+	}
+	engine, err := xorm.NewEngine(dbDialect.DriverName(), dbDialect.ConnStr(&models.Config.Database))
 	if err != nil {
 		log.Logger.Error("Init database connect fail", log.Error(err))
 		return err
@@ -40,7 +42,13 @@ func InitDatabase() error {
 	// 使用驼峰式映射
 	engine.SetMapper(core.SnakeMapper{})
 	x = engine
-	log.Logger.Info("Success init database connect !!")
+	if dbDialect.Name() != DialectMysql {
+		if err = bootstrapSchema(); err != nil {
+			log.Logger.Error("Init database schema fail", log.Error(err))
+			return err
+		}
+	}
+	log.Logger.Info("Success init database connect !!", log.String("dialect", dbDialect.Name()))
 	return nil
 }
 
@@ -120,15 +128,15 @@ func (d *dbLogger) IsShowSQL() bool {
 }
 
 func queryCount(sql string, params ...interface{}) int {
-	sql = "SELECT COUNT(1) FROM ( " + sql + " ) sub_query"
+	sql = "SELECT COUNT(1) AS count_num FROM ( " + sql + " ) sub_query"
 	resultMap := make(map[string]int)
 	_, err := x.SQL(sql, params...).Get(&resultMap)
 	if err != nil {
 		log.Logger.Error("Query sql count message fail", log.Error(err))
 		return 0
 	}
-	if _, b := resultMap["COUNT(1)"]; b {
-		return resultMap["COUNT(1)"]
+	if _, b := resultMap["count_num"]; b {
+		return resultMap["count_num"]
 	}
 	return 0
 }
@@ -225,15 +233,18 @@ func transFiltersToSQL(queryParam *models.QueryRequestParam, transParam *models.
 }
 
 func transPageInfoToSQL(pageInfo models.PageInfo) (pageSql string, param []interface{}) {
-	pageSql = " LIMIT ?,? "
-	param = append(param, pageInfo.StartIndex)
-	param = append(param, pageInfo.PageSize)
-	return
+	return dbDialect.PageSql(pageInfo.StartIndex, pageInfo.PageSize)
 }
 
 type execAction struct {
 	Sql   string
 	Param []interface{}
+	// CheckAffected 为true时语句没有影响任何行会回滚事务,用于带条件的更新
+	CheckAffected bool
+	// AffectedError CheckAffected为true且没有影响任何行时返回的错误,为空时返回通用错误
+	AffectedError error
+	// QueryCheck 不为空时该语句按查询执行,用事务内查到的数据做检查,返回错误时回滚事务
+	QueryCheck func(queryRows []map[string]string) error
 }
 
 func transaction(actions []*execAction) error {
@@ -249,13 +260,7 @@ func transaction(actions []*execAction) error {
 	session := x.NewSession()
 	err := session.Begin()
 	for _, action := range actions {
-		params := make([]interface{}, 0)
-		params = append(params, action.Sql)
-		for _, v := range action.Param {
-			params = append(params, v)
-		}
-		_, err = session.Exec(params...)
-		if err != nil {
+		if err = execSessionAction(session, action); err != nil {
 			session.Rollback()
 			break
 		}
@@ -267,6 +272,32 @@ func transaction(actions []*execAction) error {
 	return err
 }
 
+func execSessionAction(session *xorm.Session, action *execAction) error {
+	params := make([]interface{}, 0)
+	params = append(params, dbDialect.RewriteSql(action.Sql))
+	for _, v := range action.Param {
+		params = append(params, v)
+	}
+	if action.QueryCheck != nil {
+		queryRows, err := session.QueryString(params...)
+		if err != nil {
+			return err
+		}
+		return action.QueryCheck(queryRows)
+	}
+	execResult, err := session.Exec(params...)
+	if err != nil || !action.CheckAffected {
+		return err
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		if action.AffectedError != nil {
+			return action.AffectedError
+		}
+		return fmt.Errorf("SQL:%s affect no rows ", action.Sql)
+	}
+	return nil
+}
+
 func getDefaultInsertSqlByStruct(obj interface{}, tableName string, ignoreColumns []string) string {
 	var columnList, valueList []string
 	t := reflect.TypeOf(obj)
@@ -306,15 +337,9 @@ func transactionWithoutForeignCheck(actions []*execAction) error {
 	if err != nil {
 		return err
 	}
-	session.Exec("SET FOREIGN_KEY_CHECKS=0")
+	session.Exec(dbDialect.ForeignCheckSql(false))
 	for _, action := range actions {
-		params := make([]interface{}, 0)
-		params = append(params, action.Sql)
-		for _, v := range action.Param {
-			params = append(params, v)
-		}
-		_, err = session.Exec(params...)
-		if err != nil {
+		if err = execSessionAction(session, action); err != nil {
 			session.Rollback()
 			break
 		}
@@ -322,7 +347,7 @@ func transactionWithoutForeignCheck(actions []*execAction) error {
 	if err == nil {
 		err = session.Commit()
 	}
-	session.Exec("SET FOREIGN_KEY_CHECKS=1")
+	session.Exec(dbDialect.ForeignCheckSql(true))
 	session.Close()
 	return err
 }
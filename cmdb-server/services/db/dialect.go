@@ -0,0 +1,452 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const (
+	DialectMysql    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSqlite   = "sqlite"
+
+	postgresDriverName = "postgres_cmdb"
+	sqliteDriverName   = "sqlite3_cmdb"
+)
+
+// sqliteDriverEnabled 带sqlite编译标签时由驱动注册设置
+var sqliteDriverEnabled bool
+
+var dbDialect sqlDialect = &mysqlDialect{}
+
+// sqlDialect 屏蔽不同数据库在连接串、分页、建表和改表语句上的差异
+type sqlDialect interface {
+	Name() string
+	DriverName() string
+	ConnStr(config *models.DatabaseConfig) string
+	Quote(name string) string
+	// RewriteSql 把按mysql写法拼出来的语句转成当前数据库的写法
+	RewriteSql(sql string) string
+	PageSql(startIndex, pageSize int) (pageSql string, param []interface{})
+	ColumnType(dataType string, dataLength int) string
+	AutoIncrementColumn(name string) string
+	CreateTableSql(tableName string, columnList []string, indexList []*tableIndexObj) []string
+	CreateIndexSql(indexName, tableName string, columns ...string) string
+	DropIndexSql(indexName, tableName string) string
+	AddColumnSql(tableName, columnDefine string) string
+	DropColumnSql(tableName, column string) string
+	// ModifyColumnSql nullable为yes时改为可空,为no时改为非空,为空时只改类型
+	ModifyColumnSql(tableName, column, columnType, nullable string) []string
+	ForeignCheckSql(enable bool) string
+	// ForUpdateSql 拼在查询语句后面,在事务里锁住查到的数据
+	ForUpdateSql() string
+	ConcatSql(items ...string) string
+	// JsonArraySql 把字段值包成只有一个字符串元素的json数组文本
+	JsonArraySql(column string) string
+	// JsonFirstItemSql 取json数组文本里的第一个字符串元素,空数组返回空
+	JsonFirstItemSql(column string) string
+	TableListSql(database string) (sql string, param []interface{})
+}
+
+type tableIndexObj struct {
+	Name    string
+	Columns []string
+}
+
+func initDialect(name string) error {
+	switch strings.ToLower(name) {
+	case "", DialectMysql:
+		dbDialect = &mysqlDialect{}
+	case DialectPostgres, "postgresql", "pg":
+		dbDialect = &postgresDialect{}
+	case DialectSqlite, "sqlite3":
+		if !sqliteDriverEnabled {
+			return fmt.Errorf("Database type:%s need build with -tags sqlite ", name)
+		}
+		dbDialect = &sqliteDialect{}
+	default:
+		return fmt.Errorf("Database type:%s is not supported ", name)
+	}
+	return nil
+}
+
+func buildColumnDefine(name, columnType string, notNull bool) string {
+	result := fmt.Sprintf("%s %s", dbDialect.Quote(name), columnType)
+	if notNull {
+		result += " NOT NULL"
+	}
+	return result
+}
+
+type mysqlDialect struct{}
+
+func (d *mysqlDialect) Name() string {
+	return DialectMysql
+}
+
+func (d *mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+// ConnStr clientFoundRows让更新影响的行数按匹配的行计算,值没有变化的带条件更新不会被当成没有命中
+func (d *mysqlDialect) ConnStr(config *models.DatabaseConfig) string {
+	return fmt.Sprintf("%s:%s@%s(%s)/%s?collation=utf8mb4_unicode_ci&allowNativePasswords=true&clientFoundRows=true",
+		config.User, config.Password, "tcp", fmt.Sprintf("%s:%s", config.Server, config.Port), config.DataBase)
+}
+
+func (d *mysqlDialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (d *mysqlDialect) RewriteSql(sql string) string {
+	return sql
+}
+
+func (d *mysqlDialect) PageSql(startIndex, pageSize int) (pageSql string, param []interface{}) {
+	return " LIMIT ?,? ", []interface{}{startIndex, pageSize}
+}
+
+func (d *mysqlDialect) ColumnType(dataType string, dataLength int) string {
+	if dataType == "datetime" {
+		return dataType
+	}
+	return fmt.Sprintf("%s(%d)", dataType, dataLength)
+}
+
+func (d *mysqlDialect) AutoIncrementColumn(name string) string {
+	return fmt.Sprintf("`%s` INT(11) NOT NULL AUTO_INCREMENT PRIMARY KEY", name)
+}
+
+func (d *mysqlDialect) CreateTableSql(tableName string, columnList []string, indexList []*tableIndexObj) []string {
+	for _, index := range indexList {
+		columnList = append(columnList, fmt.Sprintf("INDEX `%s` (`%s`)", index.Name, strings.Join(index.Columns, "`,`")))
+	}
+	return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE=InnoDB DEFAULT CHARSET=utf8", tableName, strings.Join(columnList, ","))}
+}
+
+func (d *mysqlDialect) CreateIndexSql(indexName, tableName string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (`%s`)", indexName, tableName, strings.Join(columns, "`,`"))
+}
+
+func (d *mysqlDialect) DropIndexSql(indexName, tableName string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s", indexName, tableName)
+}
+
+func (d *mysqlDialect) AddColumnSql(tableName, columnDefine string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, columnDefine)
+}
+
+func (d *mysqlDialect) DropColumnSql(tableName, column string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column)
+}
+
+func (d *mysqlDialect) ModifyColumnSql(tableName, column, columnType, nullable string) []string {
+	sql := fmt.Sprintf("alter table %s modify column %s %s", tableName, column, columnType)
+	if nullable == "no" {
+		sql += " not null"
+	} else if nullable == "yes" {
+		sql += " default null"
+	}
+	return []string{sql}
+}
+
+func (d *mysqlDialect) ForeignCheckSql(enable bool) string {
+	if enable {
+		return "SET FOREIGN_KEY_CHECKS=1"
+	}
+	return "SET FOREIGN_KEY_CHECKS=0"
+}
+
+func (d *mysqlDialect) ForUpdateSql() string {
+	return " FOR UPDATE"
+}
+
+func (d *mysqlDialect) ConcatSql(items ...string) string {
+	return fmt.Sprintf("CONCAT(%s)", strings.Join(items, ","))
+}
+
+func (d *mysqlDialect) JsonArraySql(column string) string {
+	return fmt.Sprintf("JSON_ARRAY(%s)", column)
+}
+
+func (d *mysqlDialect) JsonFirstItemSql(column string) string {
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s,'$[0]'))", column)
+}
+
+func (d *mysqlDialect) TableListSql(database string) (sql string, param []interface{}) {
+	return "SELECT `TABLE_NAME` FROM information_schema.`TABLES` WHERE TABLE_SCHEMA=? ", []interface{}{database}
+}
+
+type postgresDialect struct{}
+
+func (d *postgresDialect) Name() string {
+	return DialectPostgres
+}
+
+func (d *postgresDialect) DriverName() string {
+	return postgresDriverName
+}
+
+func (d *postgresDialect) ConnStr(config *models.DatabaseConfig) string {
+	sslMode := config.SslMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", config.Server, config.Port, config.User, config.Password, config.DataBase, sslMode)
+}
+
+func (d *postgresDialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+// RewriteSql 反引号换成双引号,mysql的value关键字换成values,单引号内的内容不处理
+func (d *postgresDialect) RewriteSql(sql string) string {
+	sql = rewriteValueKeyword(sql)
+	var buf strings.Builder
+	inQuote := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if c == '\'' {
+			inQuote = !inQuote
+		}
+		if c == '`' && !inQuote {
+			c = '"'
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// rewriteValueKeyword 把mysql支持的insert into ... value (...)换成标准的values
+func rewriteValueKeyword(sql string) string {
+	var buf strings.Builder
+	inQuote := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if c == '\'' {
+			inQuote = !inQuote
+		}
+		if !inQuote && (c == 'v' || c == 'V') && (i == 0 || !isSqlIdentChar(sql[i-1])) && i+5 <= len(sql) && strings.EqualFold(sql[i:i+5], "value") {
+			next := i + 5
+			if next >= len(sql) || !isSqlIdentChar(sql[next]) {
+				if strings.HasPrefix(strings.TrimLeft(sql[next:], " "), "(") {
+					buf.WriteString(sql[i:next])
+					if sql[next-1] == 'e' {
+						buf.WriteByte('s')
+					} else {
+						buf.WriteByte('S')
+					}
+					i = next - 1
+					continue
+				}
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func isSqlIdentChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (d *postgresDialect) PageSql(startIndex, pageSize int) (pageSql string, param []interface{}) {
+	return " LIMIT ? OFFSET ? ", []interface{}{pageSize, startIndex}
+}
+
+func (d *postgresDialect) ColumnType(dataType string, dataLength int) string {
+	switch strings.ToLower(dataType) {
+	case "datetime", "timestamp":
+		return "timestamp"
+	case "int":
+		return "integer"
+	case "tinyint":
+		return "smallint"
+	case "text", "longtext", "mediumtext":
+		return "text"
+	case "blob", "longblob", "mediumblob":
+		return "bytea"
+	}
+	return fmt.Sprintf("%s(%d)", dataType, dataLength)
+}
+
+func (d *postgresDialect) AutoIncrementColumn(name string) string {
+	return fmt.Sprintf(`"%s" SERIAL PRIMARY KEY`, name)
+}
+
+// CreateTableSql postgres不支持在建表语句里建索引,索引单独创建
+func (d *postgresDialect) CreateTableSql(tableName string, columnList []string, indexList []*tableIndexObj) []string {
+	result := []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s)`, tableName, strings.Join(columnList, ","))}
+	for _, index := range indexList {
+		result = append(result, d.CreateIndexSql(index.Name, tableName, index.Columns...))
+	}
+	return result
+}
+
+func (d *postgresDialect) CreateIndexSql(indexName, tableName string, columns ...string) string {
+	return fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" ("%s")`, indexName, tableName, strings.Join(columns, `","`))
+}
+
+func (d *postgresDialect) DropIndexSql(indexName, tableName string) string {
+	return fmt.Sprintf(`DROP INDEX IF EXISTS "%s"`, indexName)
+}
+
+func (d *postgresDialect) AddColumnSql(tableName, columnDefine string) string {
+	return fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN %s`, tableName, columnDefine)
+}
+
+func (d *postgresDialect) DropColumnSql(tableName, column string) string {
+	return fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s"`, tableName, column)
+}
+
+func (d *postgresDialect) ModifyColumnSql(tableName, column, columnType, nullable string) []string {
+	result := []string{fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" TYPE %s`, tableName, column, columnType)}
+	if nullable == "no" {
+		result = append(result, fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET NOT NULL`, tableName, column))
+	} else if nullable == "yes" {
+		result = append(result, fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP NOT NULL`, tableName, column))
+	}
+	return result
+}
+
+// ForeignCheckSql postgres没有全局关闭外键检查的开关,只能把可延迟的约束推迟到提交时检查
+func (d *postgresDialect) ForeignCheckSql(enable bool) string {
+	if enable {
+		return "SET CONSTRAINTS ALL IMMEDIATE"
+	}
+	return "SET CONSTRAINTS ALL DEFERRED"
+}
+
+func (d *postgresDialect) ForUpdateSql() string {
+	return " FOR UPDATE"
+}
+
+func (d *postgresDialect) ConcatSql(items ...string) string {
+	return fmt.Sprintf("CONCAT(%s)", strings.Join(items, ","))
+}
+
+func (d *postgresDialect) JsonArraySql(column string) string {
+	return fmt.Sprintf("json_build_array(%s)::text", column)
+}
+
+func (d *postgresDialect) JsonFirstItemSql(column string) string {
+	return fmt.Sprintf("(%s::json->>0)", column)
+}
+
+func (d *postgresDialect) TableListSql(database string) (sql string, param []interface{}) {
+	return "SELECT table_name AS \"TABLE_NAME\" FROM information_schema.tables WHERE table_catalog=? AND table_schema=current_schema()", []interface{}{database}
+}
+
+// sqliteDialect 用于本地开发和测试,sqlite兼容反引号和LIMIT ?,?的写法
+type sqliteDialect struct{}
+
+func (d *sqliteDialect) Name() string {
+	return DialectSqlite
+}
+
+func (d *sqliteDialect) DriverName() string {
+	return sqliteDriverName
+}
+
+// ConnStr database配置为数据库文件路径,配置为:memory:时使用共享的内存库
+func (d *sqliteDialect) ConnStr(config *models.DatabaseConfig) string {
+	if config.DataBase == ":memory:" {
+		return "file:wecmdb?mode=memory&cache=shared&_busy_timeout=10000"
+	}
+	return fmt.Sprintf("file:%s?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate", config.DataBase)
+}
+
+func (d *sqliteDialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (d *sqliteDialect) RewriteSql(sql string) string {
+	return rewriteValueKeyword(sql)
+}
+
+func (d *sqliteDialect) PageSql(startIndex, pageSize int) (pageSql string, param []interface{}) {
+	return " LIMIT ?,? ", []interface{}{startIndex, pageSize}
+}
+
+// ColumnType 时间字段用text保存,避免驱动把datetime类型的字段转成time.Time
+func (d *sqliteDialect) ColumnType(dataType string, dataLength int) string {
+	switch strings.ToLower(dataType) {
+	case "datetime", "timestamp", "text", "longtext", "mediumtext":
+		return "text"
+	case "int", "tinyint", "bigint":
+		return "integer"
+	case "blob", "longblob", "mediumblob":
+		return "blob"
+	}
+	return fmt.Sprintf("%s(%d)", dataType, dataLength)
+}
+
+func (d *sqliteDialect) AutoIncrementColumn(name string) string {
+	return fmt.Sprintf("`%s` INTEGER PRIMARY KEY AUTOINCREMENT", name)
+}
+
+func (d *sqliteDialect) CreateTableSql(tableName string, columnList []string, indexList []*tableIndexObj) []string {
+	result := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s)", tableName, strings.Join(columnList, ","))}
+	for _, index := range indexList {
+		result = append(result, d.CreateIndexSql(index.Name, tableName, index.Columns...))
+	}
+	return result
+}
+
+func (d *sqliteDialect) CreateIndexSql(indexName, tableName string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s` ON `%s` (`%s`)", indexName, tableName, strings.Join(columns, "`,`"))
+}
+
+func (d *sqliteDialect) DropIndexSql(indexName, tableName string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS `%s`", indexName)
+}
+
+// AddColumnSql sqlite新增非空字段时必须带默认值
+func (d *sqliteDialect) AddColumnSql(tableName, columnDefine string) string {
+	if strings.HasSuffix(columnDefine, " NOT NULL") {
+		columnDefine += " DEFAULT ''"
+	}
+	return fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", tableName, columnDefine)
+}
+
+// DropColumnSql sqlite 3.35以上才支持删除字段
+func (d *sqliteDialect) DropColumnSql(tableName, column string) string {
+	return fmt.Sprintf("ALTER TABLE `%s` DROP COLUMN `%s`", tableName, column)
+}
+
+// ModifyColumnSql sqlite不限制字段长度,修改可空约束需要重建表,这里不做处理
+func (d *sqliteDialect) ModifyColumnSql(tableName, column, columnType, nullable string) []string {
+	return []string{}
+}
+
+func (d *sqliteDialect) ForeignCheckSql(enable bool) string {
+	if enable {
+		return "PRAGMA foreign_keys=ON"
+	}
+	return "PRAGMA foreign_keys=OFF"
+}
+
+// ForUpdateSql sqlite不支持for update,写事务用_txlock=immediate开启,本身就是串行的
+func (d *sqliteDialect) ForUpdateSql() string {
+	return ""
+}
+
+func (d *sqliteDialect) ConcatSql(items ...string) string {
+	return "(" + strings.Join(items, "||") + ")"
+}
+
+// JsonArraySql 驱动没有带json1扩展,按json字符串的转义规则拼接
+func (d *sqliteDialect) JsonArraySql(column string) string {
+	return fmt.Sprintf(`('["'||replace(replace(%s,'\','\\'),'"','\"')||'"]')`, column)
+}
+
+// JsonFirstItemSql 只用于已经校验过最多一个元素的数组,去掉首尾的["和"]后还原转义,空数组和其它数据库一样返回NULL
+func (d *sqliteDialect) JsonFirstItemSql(column string) string {
+	return fmt.Sprintf(`(CASE WHEN %s LIKE '["%%' THEN replace(replace(substr(%s,3,length(%s)-4),'\"','"'),'\\','\') END)`, column, column, column)
+}
+
+func (d *sqliteDialect) TableListSql(database string) (sql string, param []interface{}) {
+	return "SELECT name AS TABLE_NAME FROM sqlite_master WHERE type='table'", []interface{}{}
+}
@@ -0,0 +1,14 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"xorm.io/xorm/dialects"
+)
+
+// postgres驱动为纯go实现,默认编译进来,database.type配置为postgres时使用
+func init() {
+	sql.Register(postgresDriverName, &rewriteSqlDriver{Driver: &pq.Driver{}, rewrite: (&postgresDialect{}).RewriteSql})
+	dialects.RegisterDriver(postgresDriverName, dialects.QueryDriver("postgres"))
+}
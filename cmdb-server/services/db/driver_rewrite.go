@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// rewriteSqlDriver 包装数据库驱动,在预编译前把mysql写法的语句转成当前数据库的写法,
+// 直接调用x.Exec、x.SQL的语句不经过transaction,需要在驱动这一层统一处理
+type rewriteSqlDriver struct {
+	driver.Driver
+	rewrite func(sql string) string
+}
+
+func (d *rewriteSqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rewriteSqlConn{Conn: conn, rewrite: d.rewrite}, nil
+}
+
+type rewriteSqlConn struct {
+	driver.Conn
+	rewrite func(sql string) string
+}
+
+func (c *rewriteSqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(c.rewrite(query))
+}
+
+func (c *rewriteSqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, c.rewrite(query))
+	}
+	return c.Conn.Prepare(c.rewrite(query))
+}
+
+func (c *rewriteSqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
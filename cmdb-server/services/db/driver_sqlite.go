@@ -0,0 +1,37 @@
+//go:build sqlite
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"xorm.io/xorm/dialects"
+)
+
+// 注册一个带mysql常用函数的sqlite驱动,sql里用到的concat在低版本sqlite里没有,
+// sqlite驱动依赖cgo,只用于本地开发和测试,需要加上-tags sqlite编译,避免打进生产包
+func init() {
+	sqliteDriverEnabled = true
+	sqliteDriver := &sqlite3.SQLiteDriver{ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+		return conn.RegisterFunc("concat", func(args ...interface{}) string {
+			// mysql里任意参数为null时结果为null,驱动不支持返回null,这里返回空字符串
+			var buf strings.Builder
+			for _, arg := range args {
+				switch value := arg.(type) {
+				case nil:
+					return ""
+				case []byte:
+					buf.Write(value)
+				default:
+					fmt.Fprint(&buf, value)
+				}
+			}
+			return buf.String()
+		}, true)
+	}}
+	sql.Register(sqliteDriverName, &rewriteSqlDriver{Driver: sqliteDriver, rewrite: rewriteValueKeyword})
+	dialects.RegisterDriver(sqliteDriverName, dialects.QueryDriver("sqlite3"))
+}
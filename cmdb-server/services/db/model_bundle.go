@@ -0,0 +1,708 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+// ExportModelBundle 导出ci类型及其属性、状态机和引用到的基础数据,ciTypeList为空时导出全部未删除的ci类型
+func ExportModelBundle(ciTypeList []string) (bundle models.ModelBundle, err error) {
+	bundle = models.ModelBundle{Version: models.ModelBundleVersion, ExportTime: time.Now().Format(models.DateTimeFormat)}
+	bundle.CiTypes = []*models.SysCiTypeTable{}
+	if len(ciTypeList) > 0 {
+		specSql, params := createListParams(ciTypeList, "")
+		err = x.SQL("select * from sys_ci_type where status<>'deleted' and id in ("+specSql+") order by seq_no", params...).Find(&bundle.CiTypes)
+	} else {
+		err = x.SQL("select * from sys_ci_type where status<>'deleted' order by seq_no").Find(&bundle.CiTypes)
+	}
+	if err != nil {
+		err = fmt.Errorf("Try to query ci type table fail,%s ", err.Error())
+		return
+	}
+	if len(bundle.CiTypes) == 0 {
+		err = fmt.Errorf("Can not find any ci type to export ")
+		return
+	}
+	var exportCiTypeList, machineList, codeIdList, catIdList []string
+	machineMap, codeMap, catMap := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	for _, ciType := range bundle.CiTypes {
+		exportCiTypeList = append(exportCiTypeList, ciType.Id)
+		if !machineMap[ciType.StateMachine] {
+			machineMap[ciType.StateMachine] = true
+			machineList = append(machineList, ciType.StateMachine)
+		}
+		for _, codeId := range []string{ciType.CiGroup, ciType.CiLayer} {
+			if codeId != "" && !codeMap[codeId] {
+				codeMap[codeId] = true
+				codeIdList = append(codeIdList, codeId)
+			}
+		}
+	}
+	bundle.CiTypeAttrs = []*models.SysCiTypeAttrTable{}
+	specSql, params := createListParams(exportCiTypeList, "")
+	err = x.SQL("select * from sys_ci_type_attr where status<>'deleted' and ci_type in ("+specSql+") order by ci_type,ui_form_order", params...).Find(&bundle.CiTypeAttrs)
+	if err != nil {
+		err = fmt.Errorf("Try to query ci type attr table fail,%s ", err.Error())
+		return
+	}
+	for _, attr := range bundle.CiTypeAttrs {
+		if attr.RefType != "" && !codeMap[attr.RefType] {
+			codeMap[attr.RefType] = true
+			codeIdList = append(codeIdList, attr.RefType)
+		}
+		if attr.SelectList != "" && !catMap[attr.SelectList] {
+			catMap[attr.SelectList] = true
+			catIdList = append(catIdList, attr.SelectList)
+		}
+	}
+	if bundle.StateMachines, err = getStateMachineWithTransition(machineList); err != nil {
+		return
+	}
+	// 导出引用到的配置项所在的整个配置组,保证枚举值完整
+	if len(codeIdList) > 0 {
+		var refCodeTable []*models.SysBaseKeyCodeTable
+		specSql, params = createListParams(codeIdList, "")
+		err = x.SQL("select id,cat_id from sys_basekey_code where id in ("+specSql+")", params...).Find(&refCodeTable)
+		if err != nil {
+			err = fmt.Errorf("Try to query base key code table fail,%s ", err.Error())
+			return
+		}
+		for _, code := range refCodeTable {
+			if !catMap[code.CatId] {
+				catMap[code.CatId] = true
+				catIdList = append(catIdList, code.CatId)
+			}
+		}
+	}
+	bundle.BaseKeyCats = []*models.SysBaseKeyCatTable{}
+	bundle.BaseKeyCodes = []*models.SysBaseKeyCodeTable{}
+	if len(catIdList) > 0 {
+		specSql, params = createListParams(catIdList, "")
+		err = x.SQL("select * from sys_basekey_cat where id in ("+specSql+") order by id", params...).Find(&bundle.BaseKeyCats)
+		if err != nil {
+			err = fmt.Errorf("Try to query base key cat table fail,%s ", err.Error())
+			return
+		}
+		err = x.SQL("select * from sys_basekey_code where cat_id in ("+specSql+") order by cat_id,seq_no", params...).Find(&bundle.BaseKeyCodes)
+		if err != nil {
+			err = fmt.Errorf("Try to query base key code table fail,%s ", err.Error())
+			return
+		}
+	}
+	return
+}
+
+func getStateMachineWithTransition(machineList []string) (result []*models.GetStateMachineList, err error) {
+	result = []*models.GetStateMachineList{}
+	if len(machineList) == 0 {
+		return
+	}
+	machineRows, queryErr := GetStateMachineStateList(machineList)
+	if queryErr != nil {
+		err = queryErr
+		return
+	}
+	var transitionTable []*models.SysStateTransitionTable
+	specSql, params := createListParams(machineList, "")
+	err = x.SQL("select * from sys_state_transition where state_machine in ("+specSql+") order by guid", params...).Find(&transitionTable)
+	if err != nil {
+		err = fmt.Errorf("Try to get sys_state_transition table data fail,%s ", err.Error())
+		return
+	}
+	for _, machine := range machineRows {
+		machine.Transitions = []*models.SysStateTransitionTable{}
+		for _, transition := range transitionTable {
+			if transition.StateMachine == machine.Id {
+				machine.Transitions = append(machine.Transitions, transition)
+			}
+		}
+		result = append(result, machine)
+	}
+	return
+}
+
+// modelBundleCurrentObj 导入时当前环境的模型数据
+type modelBundleCurrentObj struct {
+	CiTypeMap        map[string]*models.SysCiTypeTable
+	CiAttrMap        map[string]*models.SysCiTypeAttrTable
+	MachineMap       map[string]*models.GetStateMachineList
+	CatMap           map[string]*models.SysBaseKeyCatTable
+	CodeMap          map[string]*models.SysBaseKeyCodeTable
+	TemplateMap      map[string]*models.SysCiTemplateTable
+	ImageFileMap     map[string]bool
+	BundleCiTypeMap  map[string]*models.SysCiTypeTable
+	BundleMachineMap map[string]bool
+	BundleCatMap     map[string]bool
+	BundleCodeMap    map[string]bool
+}
+
+// ImportModelBundle 对比导入包和当前环境的模型,apply为true时按对比结果新增或更新,除状态机中的状态和迁移外不会删除当前环境已有的模型数据
+func ImportModelBundle(bundle *models.ModelBundle, apply bool) (result models.ModelBundleImportResult, err error) {
+	if bundle.Version != models.ModelBundleVersion {
+		err = fmt.Errorf("Model bundle version:%s is not supported,current version is %s ", bundle.Version, models.ModelBundleVersion)
+		return
+	}
+	current, err := getModelBundleCurrentData(bundle)
+	if err != nil {
+		return
+	}
+	if err = validateModelBundle(bundle, current); err != nil {
+		return
+	}
+	result = diffModelBundle(bundle, current)
+	if !apply || !result.DiffFlag {
+		return
+	}
+	if err = applyModelBundle(bundle, current, &result); err != nil {
+		return
+	}
+	result.Applied = true
+	log.Logger.Info("Import model bundle success", log.Int("items", len(result.Items)), log.Int("stateMachines", len(result.StateMachines)))
+	return
+}
+
+func getModelBundleCurrentData(bundle *models.ModelBundle) (current *modelBundleCurrentObj, err error) {
+	current = &modelBundleCurrentObj{CiTypeMap: make(map[string]*models.SysCiTypeTable), CiAttrMap: make(map[string]*models.SysCiTypeAttrTable),
+		MachineMap: make(map[string]*models.GetStateMachineList), CatMap: make(map[string]*models.SysBaseKeyCatTable), CodeMap: make(map[string]*models.SysBaseKeyCodeTable),
+		TemplateMap: make(map[string]*models.SysCiTemplateTable), ImageFileMap: make(map[string]bool),
+		BundleCiTypeMap: make(map[string]*models.SysCiTypeTable), BundleMachineMap: make(map[string]bool),
+		BundleCatMap: make(map[string]bool), BundleCodeMap: make(map[string]bool)}
+	var ciTypeTable []*models.SysCiTypeTable
+	if err = x.SQL("select * from sys_ci_type").Find(&ciTypeTable); err != nil {
+		return nil, fmt.Errorf("Try to query ci type table fail,%s ", err.Error())
+	}
+	for _, row := range ciTypeTable {
+		current.CiTypeMap[row.Id] = row
+	}
+	var ciAttrTable []*models.SysCiTypeAttrTable
+	if err = x.SQL("select * from sys_ci_type_attr").Find(&ciAttrTable); err != nil {
+		return nil, fmt.Errorf("Try to query ci type attr table fail,%s ", err.Error())
+	}
+	for _, row := range ciAttrTable {
+		current.CiAttrMap[row.Id] = row
+	}
+	var catTable []*models.SysBaseKeyCatTable
+	if err = x.SQL("select * from sys_basekey_cat").Find(&catTable); err != nil {
+		return nil, fmt.Errorf("Try to query base key cat table fail,%s ", err.Error())
+	}
+	for _, row := range catTable {
+		current.CatMap[row.Id] = row
+	}
+	var codeTable []*models.SysBaseKeyCodeTable
+	if err = x.SQL("select * from sys_basekey_code").Find(&codeTable); err != nil {
+		return nil, fmt.Errorf("Try to query base key code table fail,%s ", err.Error())
+	}
+	for _, row := range codeTable {
+		current.CodeMap[row.Id] = row
+	}
+	var templateTable []*models.SysCiTemplateTable
+	if err = x.SQL("select * from sys_ci_template").Find(&templateTable); err != nil {
+		return nil, fmt.Errorf("Try to query ci template table fail,%s ", err.Error())
+	}
+	for _, row := range templateTable {
+		current.TemplateMap[row.Id] = row
+	}
+	fileRows, queryErr := x.QueryString("select guid from sys_files")
+	if queryErr != nil {
+		return nil, fmt.Errorf("Try to query sys_files table fail,%s ", queryErr.Error())
+	}
+	for _, row := range fileRows {
+		current.ImageFileMap[row["guid"]] = true
+	}
+	machineRows, queryErr := x.QueryString("select id from sys_state_machine")
+	if queryErr != nil {
+		return nil, fmt.Errorf("Try to query sys_state_machine table fail,%s ", queryErr.Error())
+	}
+	var machineList []string
+	for _, row := range machineRows {
+		machineList = append(machineList, row["id"])
+	}
+	machineDataList, queryErr := getStateMachineWithTransition(machineList)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	for _, machine := range machineDataList {
+		current.MachineMap[machine.Id] = machine
+	}
+	for _, ciType := range bundle.CiTypes {
+		current.BundleCiTypeMap[ciType.Id] = ciType
+	}
+	for _, machine := range bundle.StateMachines {
+		current.BundleMachineMap[machine.Id] = true
+	}
+	for _, cat := range bundle.BaseKeyCats {
+		current.BundleCatMap[cat.Id] = true
+	}
+	for _, code := range bundle.BaseKeyCodes {
+		current.BundleCodeMap[code.Id] = true
+	}
+	return
+}
+
+func validateModelBundle(bundle *models.ModelBundle, current *modelBundleCurrentObj) error {
+	codeExist := func(codeId string) bool {
+		_, b := current.CodeMap[codeId]
+		return codeId == "" || b || current.BundleCodeMap[codeId]
+	}
+	catExist := func(catId string) bool {
+		_, b := current.CatMap[catId]
+		return catId == "" || b || current.BundleCatMap[catId]
+	}
+	ciTypeExist := func(ciTypeId string) bool {
+		_, b := current.CiTypeMap[ciTypeId]
+		_, bundleExist := current.BundleCiTypeMap[ciTypeId]
+		return ciTypeId == "" || b || bundleExist
+	}
+	for _, code := range bundle.BaseKeyCodes {
+		if !catExist(code.CatId) {
+			return fmt.Errorf("Base key code:%s cat:%s can not find ", code.Id, code.CatId)
+		}
+	}
+	// 导入的状态机和手工编辑的状态机用同样的规则校验
+	for _, machine := range bundle.StateMachines {
+		if err := ValidateStateMachine(machine); err != nil {
+			return fmt.Errorf("Model bundle state machine illegal,%s ", err.Error())
+		}
+	}
+	for _, ciType := range bundle.CiTypes {
+		if _, b := current.TemplateMap[ciType.CiTemplate]; !b {
+			return fmt.Errorf("CiType:%s template:%s can not find ", ciType.Id, ciType.CiTemplate)
+		}
+		if _, b := current.MachineMap[ciType.StateMachine]; !b && !current.BundleMachineMap[ciType.StateMachine] {
+			return fmt.Errorf("CiType:%s state machine:%s can not find ", ciType.Id, ciType.StateMachine)
+		}
+		if !codeExist(ciType.CiGroup) || !codeExist(ciType.CiLayer) {
+			return fmt.Errorf("CiType:%s group:%s or layer:%s can not find ", ciType.Id, ciType.CiGroup, ciType.CiLayer)
+		}
+		// 旧版本导出的包没有确认策略
+		if ciType.ConfirmPolicy == "" {
+			ciType.ConfirmPolicy = models.ConfirmPolicyNone
+		}
+		if ciType.ConfirmPolicy != models.ConfirmPolicyNone && ciType.ConfirmPolicy != models.ConfirmPolicy4Eyes {
+			return fmt.Errorf("CiType:%s confirm policy:%s is illegal ", ciType.Id, ciType.ConfirmPolicy)
+		}
+		existCiType, b := current.CiTypeMap[ciType.Id]
+		if !b || existCiType.Status != "created" {
+			continue
+		}
+		// 已建表的ci类型按模板建好了字段,不能再换模板,换状态机时已有数据所在的状态必须在新状态机中
+		if existCiType.CiTemplate != ciType.CiTemplate {
+			return fmt.Errorf("CiType:%s is created,can not change template from %s to %s ", ciType.Id, existCiType.CiTemplate, ciType.CiTemplate)
+		}
+		if existCiType.StateMachine != ciType.StateMachine {
+			if err := checkModelBundleStateMachineChange(existCiType, getModelBundleStateMachine(ciType.StateMachine, bundle, current)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, attr := range bundle.CiTypeAttrs {
+		if attr.Id != attr.CiType+models.SysTableIdConnector+attr.Name {
+			return fmt.Errorf("Attribute:%s id is illegal ", attr.Id)
+		}
+		if !ciTypeExist(attr.CiType) || !ciTypeExist(attr.RefCiType) {
+			return fmt.Errorf("Attribute:%s ciType:%s or reference ciType:%s can not find ", attr.Id, attr.CiType, attr.RefCiType)
+		}
+		if !codeExist(attr.RefType) || !catExist(attr.SelectList) {
+			return fmt.Errorf("Attribute:%s reference type:%s or select list:%s can not find ", attr.Id, attr.RefType, attr.SelectList)
+		}
+		// 和CiAttrCreate/CiAttrUpdate一样校验引用过滤和定时触发,包里的属性和状态机按导入后的样子参与校验
+		if err := validateRefFilterExpression(attr.RefFilter, bundle.CiTypeAttrs); err != nil {
+			return fmt.Errorf("Attribute:%s reference filter illegal,%s ", attr.Id, err.Error())
+		}
+		if err := validateTimeTriggerAttr(attr.CiType, attr.InputType, attr.DataType, &attr.TriggerOperation, getModelBundleCiTypeTransitions(attr.CiType, bundle, current)); err != nil {
+			return fmt.Errorf("Attribute:%s illegal,%s ", attr.Id, err.Error())
+		}
+		existAttr, b := current.CiAttrMap[attr.Id]
+		if !b {
+			continue
+		}
+		changeColumns := diffModelColumns(existAttr, attr, modelBundleAttrIgnoreColumns)
+		if len(changeColumns) == 0 {
+			continue
+		}
+		if existAttr.Customizable == "no" {
+			return fmt.Errorf("Attribute:%s is not editable ", attr.Id)
+		}
+		if existAttr.Status == "created" && (existAttr.InputType != attr.InputType || existAttr.DataType != attr.DataType) {
+			return fmt.Errorf("Attribute:%s is created,can not change inputType or dataType ", attr.Id)
+		}
+	}
+	// 包里的属性不走CiAttrCreate/CiAttrUpdate,这里统一检查自动填充规则的循环依赖
+	if err := checkAutofillRuleCycleList(bundle.CiTypeAttrs); err != nil {
+		return fmt.Errorf("Model bundle attribute illegal,%s ", err.Error())
+	}
+	return nil
+}
+
+// getModelBundleStateMachine 包里有的状态机以包里为准
+func getModelBundleStateMachine(machineId string, bundle *models.ModelBundle, current *modelBundleCurrentObj) *models.GetStateMachineList {
+	for _, machine := range bundle.StateMachines {
+		if machine.Id == machineId {
+			return machine
+		}
+	}
+	return current.MachineMap[machineId]
+}
+
+// getModelBundleCiTypeTransitions 导入后ci类型所用状态机的迁移
+func getModelBundleCiTypeTransitions(ciTypeId string, bundle *models.ModelBundle, current *modelBundleCurrentObj) []*models.SysStateTransitionTable {
+	machineId := ""
+	if ciType, b := current.BundleCiTypeMap[ciTypeId]; b {
+		machineId = ciType.StateMachine
+	} else if ciType, b := current.CiTypeMap[ciTypeId]; b {
+		machineId = ciType.StateMachine
+	}
+	if machine := getModelBundleStateMachine(machineId, bundle, current); machine != nil {
+		return machine.Transitions
+	}
+	return nil
+}
+
+// checkModelBundleStateMachineChange 和保存状态机时一样,用GetStateMachineImpact统计数据所在的状态
+func checkModelBundleStateMachineChange(ciType *models.SysCiTypeTable, newMachine *models.GetStateMachineList) error {
+	stateMap := make(map[string]bool)
+	if newMachine != nil {
+		for _, state := range newMachine.States {
+			stateMap[state.Id] = true
+		}
+	}
+	impact, err := GetStateMachineImpact(ciType.StateMachine)
+	if err != nil {
+		return err
+	}
+	var missStateList []string
+	for _, impactCiType := range impact.CiTypes {
+		if impactCiType.CiType != ciType.Id {
+			continue
+		}
+		for state, num := range impactCiType.StateRows {
+			if num > 0 && !stateMap[state] {
+				missStateList = append(missStateList, fmt.Sprintf("%s(%d rows)", state, num))
+			}
+		}
+	}
+	if len(missStateList) > 0 {
+		sort.Strings(missStateList)
+		return fmt.Errorf("CiType:%s can not change state machine,data states:[%s] are not in new state machine ", ciType.Id, strings.Join(missStateList, ","))
+	}
+	return nil
+}
+
+var (
+	modelBundleCiTypeIgnoreColumns = []string{"status", "image_file", "file_name", "seq_no"}
+	modelBundleAttrIgnoreColumns   = []string{"status", "source", "customizable"}
+)
+
+func diffModelBundle(bundle *models.ModelBundle, current *modelBundleCurrentObj) (result models.ModelBundleImportResult) {
+	result.Items = []*models.ModelBundleDiffObj{}
+	result.StateMachines = []*models.ImportStateMachineResult{}
+	appendItem := func(itemType, id string, existObj, newObj interface{}, ignoreColumns []string) {
+		if reflect.ValueOf(existObj).IsNil() {
+			result.Items = append(result.Items, &models.ModelBundleDiffObj{Type: itemType, Id: id, Action: "add", Columns: []string{}})
+			return
+		}
+		if changeColumns := diffModelColumns(existObj, newObj, ignoreColumns); len(changeColumns) > 0 {
+			result.Items = append(result.Items, &models.ModelBundleDiffObj{Type: itemType, Id: id, Action: "update", Columns: changeColumns})
+		}
+	}
+	for _, cat := range bundle.BaseKeyCats {
+		appendItem(models.ModelBundleItemBaseKeyCat, cat.Id, current.CatMap[cat.Id], cat, nil)
+	}
+	for _, code := range bundle.BaseKeyCodes {
+		appendItem(models.ModelBundleItemBaseKeyCode, code.Id, current.CodeMap[code.Id], code, nil)
+	}
+	for _, ciType := range bundle.CiTypes {
+		appendItem(models.ModelBundleItemCiType, ciType.Id, current.CiTypeMap[ciType.Id], ciType, modelBundleCiTypeIgnoreColumns)
+		// 导入包里是已创建的ci类型,当前环境还没建表的需要apply
+		if existCiType, b := current.CiTypeMap[ciType.Id]; b && ciType.Status == "created" && existCiType.Status != "created" {
+			result.Items = append(result.Items, &models.ModelBundleDiffObj{Type: models.ModelBundleItemCiType, Id: ciType.Id, Action: "apply", Columns: []string{"status"}})
+		}
+	}
+	for _, attr := range bundle.CiTypeAttrs {
+		appendItem(models.ModelBundleItemCiTypeAttr, attr.Id, current.CiAttrMap[attr.Id], attr, modelBundleAttrIgnoreColumns)
+		if existAttr, b := current.CiAttrMap[attr.Id]; b && attr.Status == "created" && existAttr.Status != "created" {
+			result.Items = append(result.Items, &models.ModelBundleDiffObj{Type: models.ModelBundleItemCiTypeAttr, Id: attr.Id, Action: "apply", Columns: []string{"status"}})
+		}
+	}
+	for _, machine := range bundle.StateMachines {
+		machineResult := models.ImportStateMachineResult{StateMachine: &models.SysStateMachineTable{Id: machine.Id, Description: machine.Description, StartState: machine.StartState, FinalState: machine.FinalState},
+			OldStates: []*models.SysStateTable{}, NewStates: machine.States, OldTransitions: []*models.SysStateTransitionTable{}, NewTransitions: machine.Transitions}
+		existMachine, b := current.MachineMap[machine.Id]
+		if !b {
+			machineResult.DiffFlag = true
+		} else {
+			machineResult.OldStates = existMachine.States
+			machineResult.OldTransitions = existMachine.Transitions
+			existMachineRow := models.SysStateMachineTable{Id: existMachine.Id, Description: existMachine.Description, StartState: existMachine.StartState, FinalState: existMachine.FinalState}
+			machineResult.DiffFlag = len(diffModelColumns(&existMachineRow, machineResult.StateMachine, nil)) > 0 ||
+				diffStateList(existMachine.States, machine.States) || diffTransitionList(existMachine.Transitions, machine.Transitions)
+		}
+		if machineResult.DiffFlag {
+			result.StateMachines = append(result.StateMachines, &machineResult)
+		}
+	}
+	result.DiffFlag = len(result.Items) > 0 || len(result.StateMachines) > 0
+	return
+}
+
+func diffStateList(oldList, newList []*models.SysStateTable) bool {
+	if len(oldList) != len(newList) {
+		return true
+	}
+	oldMap := make(map[string]*models.SysStateTable)
+	for _, state := range oldList {
+		oldMap[state.Id] = state
+	}
+	for _, state := range newList {
+		if oldState, b := oldMap[state.Id]; !b || len(diffModelColumns(oldState, state, nil)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func diffTransitionList(oldList, newList []*models.SysStateTransitionTable) bool {
+	if len(oldList) != len(newList) {
+		return true
+	}
+	oldMap := make(map[string]*models.SysStateTransitionTable)
+	for _, transition := range oldList {
+		oldMap[transition.Guid] = transition
+	}
+	for _, transition := range newList {
+		if oldTransition, b := oldMap[transition.Guid]; !b || len(diffModelColumns(oldTransition, transition, nil)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// diffModelColumns 按xorm标签对比两个同类型结构体,返回值不同的字段列名
+func diffModelColumns(oldObj, newObj interface{}, ignoreColumns []string) (columns []string) {
+	oldValue := reflect.Indirect(reflect.ValueOf(oldObj))
+	newValue := reflect.Indirect(reflect.ValueOf(newObj))
+	t := oldValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tmpXormTag := t.Field(i).Tag.Get("xorm")
+		if tmpXormTag == "-" || tmpXormTag == "" {
+			continue
+		}
+		ignoreFlag := false
+		for _, ignoreColumn := range ignoreColumns {
+			if ignoreColumn == tmpXormTag {
+				ignoreFlag = true
+				break
+			}
+		}
+		if ignoreFlag {
+			continue
+		}
+		if fmt.Sprintf("%v", oldValue.Field(i).Interface()) != fmt.Sprintf("%v", newValue.Field(i).Interface()) {
+			columns = append(columns, tmpXormTag)
+		}
+	}
+	sort.Strings(columns)
+	return
+}
+
+func applyModelBundle(bundle *models.ModelBundle, current *modelBundleCurrentObj, result *models.ModelBundleImportResult) (err error) {
+	changeMap := make(map[string]*models.ModelBundleDiffObj)
+	for _, item := range result.Items {
+		if item.Action != "apply" {
+			changeMap[item.Type+":"+item.Id] = item
+		}
+	}
+	var actions []*execAction
+	for _, cat := range bundle.BaseKeyCats {
+		if item, b := changeMap[models.ModelBundleItemBaseKeyCat+":"+cat.Id]; b {
+			if item.Action == "add" {
+				actions = append(actions, &execAction{Sql: "INSERT INTO sys_basekey_cat(id,name,description) VALUE (?,?,?)", Param: []interface{}{cat.Id, cat.Name, cat.Description}})
+			} else {
+				actions = append(actions, &execAction{Sql: "UPDATE sys_basekey_cat SET name=?,description=? WHERE id=?", Param: []interface{}{cat.Name, cat.Description, cat.Id}})
+			}
+		}
+	}
+	for _, code := range bundle.BaseKeyCodes {
+		if item, b := changeMap[models.ModelBundleItemBaseKeyCode+":"+code.Id]; b {
+			if item.Action == "add" {
+				actions = append(actions, &execAction{Sql: "INSERT INTO sys_basekey_code(id,cat_id,code,value,description,seq_no,status) VALUE (?,?,?,?,?,?,?)",
+					Param: []interface{}{code.Id, code.CatId, code.Code, code.Value, code.Description, code.SeqNo, code.Status}})
+			} else {
+				actions = append(actions, &execAction{Sql: "UPDATE sys_basekey_code SET cat_id=?,code=?,value=?,description=?,seq_no=?,status=? WHERE id=?",
+					Param: []interface{}{code.CatId, code.Code, code.Value, code.Description, code.SeqNo, code.Status, code.Id}})
+			}
+		}
+	}
+	for _, machineResult := range result.StateMachines {
+		machineActions, buildErr := buildStateMachineImportActions(machineResult, current)
+		if buildErr != nil {
+			return buildErr
+		}
+		actions = append(actions, machineActions...)
+	}
+	for _, ciType := range bundle.CiTypes {
+		item, b := changeMap[models.ModelBundleItemCiType+":"+ciType.Id]
+		if !b {
+			continue
+		}
+		if item.Action == "add" {
+			imageFile := ciType.ImageFile
+			if !current.ImageFileMap[imageFile] {
+				imageFile = current.TemplateMap[ciType.CiTemplate].ImageFile
+			}
+			actions = append(actions, &execAction{Sql: "INSERT INTO sys_ci_type(id,display_name,description,image_file,ci_group,ci_layer,ci_template,state_machine,confirm_policy,confirm_role) VALUE (?,?,?,?,?,?,?,?,?,?)",
+				Param: []interface{}{ciType.Id, ciType.DisplayName, ciType.Description, imageFile, ciType.CiGroup, ciType.CiLayer, ciType.CiTemplate, ciType.StateMachine, ciType.ConfirmPolicy, ciType.ConfirmRole}})
+		} else {
+			actions = append(actions, &execAction{Sql: "UPDATE sys_ci_type SET display_name=?,description=?,ci_group=?,ci_layer=?,ci_template=?,state_machine=?,confirm_policy=?,confirm_role=? WHERE id=?",
+				Param: []interface{}{ciType.DisplayName, ciType.Description, ciType.CiGroup, ciType.CiLayer, ciType.CiTemplate, ciType.StateMachine, ciType.ConfirmPolicy, ciType.ConfirmRole, ciType.Id}})
+		}
+	}
+	var updateAttrList []*models.SysCiTypeAttrTable
+	for _, attr := range bundle.CiTypeAttrs {
+		item, b := changeMap[models.ModelBundleItemCiTypeAttr+":"+attr.Id]
+		if !b {
+			continue
+		}
+		if item.Action == "add" {
+			actions = append(actions, buildCiAttrInsertAction(attr))
+		} else {
+			updateAttrList = append(updateAttrList, attr)
+			if current.CiAttrMap[attr.Id].UiFormOrder != attr.UiFormOrder {
+				actions = append(actions, &execAction{Sql: "UPDATE sys_ci_type_attr SET ui_form_order=? WHERE id=?", Param: []interface{}{attr.UiFormOrder, attr.Id}})
+			}
+		}
+	}
+	var doneList []string
+	if len(actions) > 0 {
+		if err = transaction(actions); err != nil {
+			return fmt.Errorf("Try to import model bundle fail,%s ", err.Error())
+		}
+		doneList = append(doneList, "model definitions")
+	}
+	// 修改字段和建表不能和模型定义一起回滚,失败时返回已经完成的部分,修正后重新导入会继续处理未完成的部分
+	updateAutofillMap := make(map[string]bool)
+	for _, attr := range updateAttrList {
+		updateParam := *attr
+		updateAutofill, updateErr := CiAttrUpdate(&updateParam)
+		if updateErr != nil {
+			return buildModelBundlePartialError(doneList, fmt.Errorf("Try to update attribute:%s fail,%s ", attr.Id, updateErr.Error()))
+		}
+		doneList = append(doneList, "update attribute:"+attr.Id)
+		if updateAutofill {
+			updateAutofillMap[attr.Id] = true
+		}
+	}
+	if err = applyModelBundleCiTables(bundle, current, updateAutofillMap, &doneList); err != nil {
+		return buildModelBundlePartialError(doneList, err)
+	}
+	return
+}
+
+func buildModelBundlePartialError(doneList []string, err error) error {
+	if len(doneList) == 0 {
+		return err
+	}
+	return fmt.Errorf("Model bundle is partially applied,done:[%s],%s ", strings.Join(doneList, ","), err.Error())
+}
+
+// applyModelBundleCiTables 按CiTypesApply和CiAttrApply的逻辑为导入包里已创建的ci类型和属性建表建字段
+func applyModelBundleCiTables(bundle *models.ModelBundle, current *modelBundleCurrentObj, updateAutofillMap map[string]bool, doneList *[]string) (err error) {
+	var pendingCiTypeList []string
+	pendingCiTypeMap := make(map[string]bool)
+	for _, ciType := range bundle.CiTypes {
+		if ciType.Status != "created" {
+			continue
+		}
+		if existCiType, b := current.CiTypeMap[ciType.Id]; b && existCiType.Status == "created" {
+			continue
+		}
+		pendingCiTypeList = append(pendingCiTypeList, ciType.Id)
+		pendingCiTypeMap[ciType.Id] = true
+	}
+	// 建表时要求引用的ci类型已创建,每轮只处理能建表的,直到全部完成或者无法继续
+	for len(pendingCiTypeList) > 0 {
+		var remainList []string
+		var lastErr error
+		for _, ciTypeId := range pendingCiTypeList {
+			if createErr := CreateCiTable(ciTypeId); createErr != nil {
+				lastErr = createErr
+				remainList = append(remainList, ciTypeId)
+				continue
+			}
+			UpdateCiTypesStatus(ciTypeId, "created")
+			AutoCreateRoleCiTypeDataByCiType(ciTypeId)
+			*doneList = append(*doneList, "create table:"+ciTypeId)
+		}
+		if len(remainList) == len(pendingCiTypeList) {
+			return fmt.Errorf("Try to create ci table %v fail,%s ", remainList, lastErr.Error())
+		}
+		pendingCiTypeList = remainList
+	}
+	for _, attr := range bundle.CiTypeAttrs {
+		if pendingCiTypeMap[attr.CiType] {
+			continue
+		}
+		if attr.Status != "created" && !updateAutofillMap[attr.Id] {
+			continue
+		}
+		if ciType, b := current.BundleCiTypeMap[attr.CiType]; b && ciType.Status != "created" {
+			continue
+		}
+		if existCiType, b := current.CiTypeMap[attr.CiType]; !b || existCiType.Status != "created" {
+			continue
+		}
+		if existAttr, b := current.CiAttrMap[attr.Id]; b && existAttr.Status == "created" && !updateAutofillMap[attr.Id] {
+			continue
+		}
+		if err = CiAttrApply(attr.CiType, attr.Id, updateAutofillMap[attr.Id]); err != nil {
+			return fmt.Errorf("Try to apply attribute:%s fail,%s ", attr.Id, err.Error())
+		}
+		*doneList = append(*doneList, "apply attribute:"+attr.Id)
+	}
+	return
+}
+
+// buildStateMachineImportActions 状态机以导入包为准,导入包里没有的状态和迁移会删除,被删除或改名的状态还有数据在用时拒绝导入
+func buildStateMachineImportActions(machineResult *models.ImportStateMachineResult, current *modelBundleCurrentObj) (actions []*execAction, err error) {
+	machine := machineResult.StateMachine
+	oldMachine, b := current.MachineMap[machine.Id]
+	if !b {
+		oldMachine = &models.GetStateMachineList{Id: machine.Id}
+	}
+	newMachine := models.GetStateMachineList{Id: machine.Id, Description: machine.Description, StartState: machine.StartState, FinalState: machine.FinalState,
+		States: machineResult.NewStates, Transitions: machineResult.NewTransitions}
+	if actions, err = buildSaveStateMachineActions(oldMachine, &newMachine); err != nil {
+		err = fmt.Errorf("Try to import state machine:%s fail,%s ", machine.Id, err.Error())
+	}
+	return
+}
+
+func buildCiAttrInsertAction(row *models.SysCiTypeAttrTable) *execAction {
+	execSql := ciAttrInsertSql
+	execParams := []interface{}{row.Id, row.CiType, row.Name, row.DisplayName, row.Description, "notCreated", row.InputType, row.DataType,
+		row.DataLength, row.TextValidate, row.RefName, row.RefFilter, row.RefUpdateStateValidate, row.RefConfirmStateValidate, row.UiSearchOrder,
+		row.UiFormOrder, row.UniqueConstraint, row.UiNullable, row.Nullable, row.Editable, row.DisplayByDefault, row.PermissionUsage, row.ResetOnEdit,
+		row.Source, row.Customizable, row.AutofillAble, row.AutofillRule, row.AutofillType, row.EditGroupControl, row.EditGroupValues}
+	if row.RefType != "" && row.RefCiType != "" {
+		execSql = strings.ReplaceAll(execSql, ") VALUE", ",ref_type,ref_ci_type) VALUE")
+		execSql = execSql[:len(execSql)-1] + ",?,?)"
+		execParams = append(execParams, row.RefType, row.RefCiType)
+	}
+	if row.SelectList != "" {
+		execSql = strings.ReplaceAll(execSql, ") VALUE", ",select_list) VALUE")
+		execSql = execSql[:len(execSql)-1] + ",?)"
+		execParams = append(execParams, row.SelectList)
+	}
+	if row.TriggerOperation != "" {
+		execSql = strings.ReplaceAll(execSql, ") VALUE", ",trigger_operation) VALUE")
+		execSql = execSql[:len(execSql)-1] + ",?)"
+		execParams = append(execParams, row.TriggerOperation)
+	}
+	return &execAction{Sql: execSql, Param: execParams}
+}
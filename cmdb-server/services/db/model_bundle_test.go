@@ -0,0 +1,129 @@
+//go:build sqlite
+
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+var errTestBundle = errors.New("create table fail")
+
+func buildTestBundleMachine(stateNameList []string) *models.GetStateMachineList {
+	machineId := "test_bundle"
+	machine := models.GetStateMachineList{Id: machineId, StartState: machineId + "__" + stateNameList[0], FinalState: machineId + "__" + stateNameList[len(stateNameList)-1]}
+	for i, stateName := range stateNameList {
+		stateId := machineId + "__" + stateName
+		machine.States = append(machine.States, &models.SysStateTable{Id: stateId, Name: stateName, StateMachine: machineId, UniquePathTrigger: "no", IsConfirm: "no"})
+		if i == 0 {
+			continue
+		}
+		machine.Transitions = append(machine.Transitions, &models.SysStateTransitionTable{Guid: "test_bundle_" + stateName, StateMachine: machineId, CurrentState: machine.StartState,
+			TargetState: stateId, Operation: "to_" + stateName, OperationEn: "To" + stateName, Action: "update", OperationFormType: "editable_form", OperationMultiple: "yes"})
+	}
+	return &machine
+}
+
+func TestImportModelBundleStateMachine(t *testing.T) {
+	bundle := models.ModelBundle{Version: models.ModelBundleVersion, StateMachines: []*models.GetStateMachineList{buildTestBundleMachine([]string{"start", "middle", "final"})}}
+	if result, err := ImportModelBundle(&bundle, true); err != nil || !result.Applied {
+		t.Fatalf("import bundle fail,%v", err)
+	}
+	// 导入包里去掉的状态要从当前环境删除
+	bundle.StateMachines = []*models.GetStateMachineList{buildTestBundleMachine([]string{"start", "final"})}
+	result, err := ImportModelBundle(&bundle, true)
+	if err != nil || !result.Applied || len(result.StateMachines) != 1 {
+		t.Fatalf("import bundle with removed state fail,%v", err)
+	}
+	if num := countTestRows(t, "select id from sys_state where state_machine=?", "test_bundle"); num != 2 {
+		t.Fatalf("removed state should be deleted,num:%d", num)
+	}
+	// 状态机不合法时不能导入
+	invalidMachine := buildTestBundleMachine([]string{"start", "final"})
+	invalidMachine.Transitions = nil
+	bundle.StateMachines = []*models.GetStateMachineList{invalidMachine}
+	if _, err = ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "can not reach") {
+		t.Fatalf("invalid state machine should be rejected,%v", err)
+	}
+}
+
+func TestBuildModelBundlePartialError(t *testing.T) {
+	if err := buildModelBundlePartialError(nil, errTestBundle); err != errTestBundle {
+		t.Fatalf("error without done step should not change:%v", err)
+	}
+	if err := buildModelBundlePartialError([]string{"model definitions"}, errTestBundle); !strings.Contains(err.Error(), "partially applied,done:[model definitions]") {
+		t.Fatalf("partial error not match:%v", err)
+	}
+}
+
+func TestImportModelBundleAutofillCycle(t *testing.T) {
+	buildAttr := func(name, dependName string) *models.SysCiTypeAttrTable {
+		rule := buildTestAutofillRule(t, []*models.AutofillValueObj{{CiTypeId: testCiType},
+			{CiTypeId: testCiType, ParentRs: &models.AutofillValueAttrObj{AttrId: testCiType + models.SysTableIdConnector + dependName, IsReferedFromParent: 1}}})
+		return &models.SysCiTypeAttrTable{Id: testCiType + models.SysTableIdConnector + name, CiType: testCiType, Name: name, DisplayName: name, InputType: "text", DataType: "varchar", DataLength: 255,
+			UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "yes", AutofillRule: rule, AutofillType: "forced"}
+	}
+	// 包里的属性互相自动填充,导入前就要拒绝
+	bundle := models.ModelBundle{Version: models.ModelBundleVersion, CiTypeAttrs: []*models.SysCiTypeAttrTable{buildAttr("fill_a", "fill_b"), buildAttr("fill_b", "fill_a")}}
+	if _, err := ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "cyclic dependency") {
+		t.Fatalf("bundle with autofill cycle should be rejected,%v", err)
+	}
+	bundle.CiTypeAttrs = bundle.CiTypeAttrs[:1]
+	if _, err := ImportModelBundle(&bundle, false); err != nil {
+		t.Fatalf("bundle without autofill cycle should pass,%v", err)
+	}
+}
+
+func TestImportModelBundleValidateCiType(t *testing.T) {
+	handleTestOperation(t, "Add", models.CiDataMapObj{"code": "b1", "asset_id": "asset-b1", "key_name": "b1"})
+	ciType, err := GetCiTypeById(testCiType)
+	if err != nil {
+		t.Fatalf("get ci type fail,%s", err.Error())
+	}
+	// 测试库里没有ci类型用到的分组和分层
+	ciType.CiGroup, ciType.CiLayer = "", ""
+	machine := buildTestBundleMachine([]string{"start", "final"})
+	// 已有数据所在的状态不在新状态机中,不能换状态机
+	changeMachine := *ciType
+	changeMachine.StateMachine = machine.Id
+	bundle := models.ModelBundle{Version: models.ModelBundleVersion, CiTypes: []*models.SysCiTypeTable{&changeMachine}, StateMachines: []*models.GetStateMachineList{machine}}
+	if _, err = ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "can not change state machine") {
+		t.Fatalf("change state machine with data should be rejected,%v", err)
+	}
+	changeTemplate := *ciType
+	changeTemplate.CiTemplate = "design"
+	bundle = models.ModelBundle{Version: models.ModelBundleVersion, CiTypes: []*models.SysCiTypeTable{&changeTemplate}}
+	if _, err = ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "can not change template") {
+		t.Fatalf("change template of created ci type should be rejected,%v", err)
+	}
+}
+
+func TestImportModelBundleValidateAttr(t *testing.T) {
+	buildAttr := func(name string) *models.SysCiTypeAttrTable {
+		return &models.SysCiTypeAttrTable{Id: testCiType + models.SysTableIdConnector + name, CiType: testCiType, Name: name, DisplayName: name, InputType: "text", DataType: "varchar", DataLength: 255,
+			UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "no"}
+	}
+	refAttr := buildAttr("bundle_ref")
+	refAttr.InputType, refAttr.RefCiType = "ref", testCiType
+	refAttr.RefFilter = `[{"f1":{"left":"test_host:[not_exist]","operator":"eq","right":{"type":"value","value":"a"}}}]`
+	bundle := models.ModelBundle{Version: models.ModelBundleVersion, CiTypeAttrs: []*models.SysCiTypeAttrTable{refAttr}}
+	if _, err := ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "reference filter illegal") {
+		t.Fatalf("illegal ref filter should be rejected,%v", err)
+	}
+	// 过滤条件可以用到包里新增的属性
+	newAttr := buildAttr("bundle_new")
+	refAttr.RefFilter = `[{"f1":{"left":"test_host:[bundle_new]","operator":"eq","right":{"type":"value","value":"a"}}}]`
+	bundle.CiTypeAttrs = []*models.SysCiTypeAttrTable{refAttr, newAttr}
+	if _, err := ImportModelBundle(&bundle, false); err != nil {
+		t.Fatalf("ref filter with bundle attribute should pass,%v", err)
+	}
+	timeAttr := buildAttr("bundle_time")
+	timeAttr.InputType, timeAttr.DataType, timeAttr.TriggerOperation = models.TimeTriggerInputType, "datetime", "NotExistOperation"
+	bundle.CiTypeAttrs = []*models.SysCiTypeAttrTable{timeAttr}
+	if _, err := ImportModelBundle(&bundle, false); err == nil || !strings.Contains(err.Error(), "TriggerOperation") {
+		t.Fatalf("illegal trigger operation should be rejected,%v", err)
+	}
+}
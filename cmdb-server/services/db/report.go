@@ -1691,6 +1691,7 @@ func ImportCiData(param *models.ExportReportResult, operator string) (err error)
 			actions = append(actions, tmpAction...)
 		}
 	}
+	actions = append(actions, buildCiSearchDirtyActionsByCiData(multiCiData)...)
 	err = transaction(actions)
 	if err == nil {
 		// record guid map
@@ -1699,6 +1700,7 @@ func ImportCiData(param *models.ExportReportResult, operator string) (err error)
 				log.Logger.Error("try to record import ci data guid map fail", log.Error(recordErr))
 			}
 		}
+		notifySearchIndex()
 		// do autofill
 		nowTime := time.Now().Format(models.DateTimeFormat)
 		for _, ciObj := range multiCiData {
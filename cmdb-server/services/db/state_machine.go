@@ -0,0 +1,509 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+var (
+	stateTransitionActionList     = []string{"insert", "update", "delete", "confirm", "execute"}
+	stateTransitionPermissionList = []string{"insert", "update", "delete", "execute"}
+)
+
+func GetStateMachineDetail(machineId string) (result *models.GetStateMachineList, err error) {
+	machineList, err := getStateMachineWithTransition([]string{machineId})
+	if err != nil {
+		return
+	}
+	if len(machineList) == 0 {
+		err = fmt.Errorf("Can not find state machine:%s ", machineId)
+		return
+	}
+	result = machineList[0]
+	return
+}
+
+// ValidateStateMachine 校验状态机结构,开始和终止状态要存在,所有状态都要能从开始状态到达,同一状态下的操作不能重复(回退除外)
+func ValidateStateMachine(machine *models.GetStateMachineList) error {
+	if machine.Id == "" {
+		return fmt.Errorf("State machine id can not empty ")
+	}
+	if len(machine.States) == 0 {
+		return fmt.Errorf("State machine:%s states can not empty ", machine.Id)
+	}
+	stateMap := make(map[string]*models.SysStateTable)
+	stateNameMap := make(map[string]bool)
+	for _, state := range machine.States {
+		if state.Id == "" || state.Name == "" {
+			return fmt.Errorf("State machine:%s state id and name can not empty ", machine.Id)
+		}
+		if state.StateMachine != machine.Id {
+			return fmt.Errorf("State:%s is not belong to state machine:%s ", state.Id, machine.Id)
+		}
+		if _, b := stateMap[state.Id]; b {
+			return fmt.Errorf("State:%s is duplicate ", state.Id)
+		}
+		if stateNameMap[state.Name] {
+			return fmt.Errorf("State name:%s is duplicate ", state.Name)
+		}
+		stateMap[state.Id] = state
+		stateNameMap[state.Name] = true
+	}
+	if _, b := stateMap[machine.StartState]; !b {
+		return fmt.Errorf("State machine:%s start state:%s is not in states ", machine.Id, machine.StartState)
+	}
+	if _, b := stateMap[machine.FinalState]; !b {
+		return fmt.Errorf("State machine:%s final state:%s is not in states ", machine.Id, machine.FinalState)
+	}
+	if machine.StartState == machine.FinalState {
+		return fmt.Errorf("State machine:%s start state and final state can not be the same ", machine.Id)
+	}
+	operationMap := make(map[string]string)
+	nextStateMap := make(map[string][]string)
+	for _, transition := range machine.Transitions {
+		if transition.StateMachine != machine.Id {
+			return fmt.Errorf("Transition:%s is not belong to state machine:%s ", transition.Guid, machine.Id)
+		}
+		for _, stateId := range []string{transition.CurrentState, transition.TargetState} {
+			if _, b := stateMap[stateId]; !b {
+				return fmt.Errorf("Transition:%s state:%s is not in state machine:%s ", transition.Guid, stateId, machine.Id)
+			}
+		}
+		if transition.Operation == "" {
+			return fmt.Errorf("Transition:%s operation can not empty ", transition.Guid)
+		}
+		if !inStringList(transition.Action, stateTransitionActionList) {
+			return fmt.Errorf("Transition:%s action:%s is illegal,must be one of %s ", transition.Guid, transition.Action, strings.Join(stateTransitionActionList, ","))
+		}
+		if transition.Permission != "" && !inStringList(transition.Permission, stateTransitionPermissionList) {
+			return fmt.Errorf("Transition:%s permission:%s is illegal,must be one of %s ", transition.Guid, transition.Permission, strings.Join(stateTransitionPermissionList, ","))
+		}
+		if err := validateTransitionGuard(transition.Guard); err != nil {
+			return fmt.Errorf("Transition:%s guard illegal,%s ", transition.Guid, err.Error())
+		}
+		if transition.Action == "insert" && transition.CurrentState != machine.StartState {
+			return fmt.Errorf("Transition:%s with insert action must start from start state:%s ", transition.Guid, machine.StartState)
+		}
+		// 回退操作按数据的历史状态决定目标状态,同一状态下可以有多条指向不同目标的回退
+		operationKey := transition.CurrentState + models.SEPERATOR + transition.Operation
+		if strings.ToLower(transition.OperationEn) == models.RollbackAction {
+			operationKey += models.SEPERATOR + transition.TargetState
+		}
+		if existGuid, b := operationMap[operationKey]; b {
+			return fmt.Errorf("Transition:%s and %s have the same operation:%s from state:%s ", existGuid, transition.Guid, transition.Operation, transition.CurrentState)
+		}
+		operationMap[operationKey] = transition.Guid
+		nextStateMap[transition.CurrentState] = append(nextStateMap[transition.CurrentState], transition.TargetState)
+	}
+	reachMap := map[string]bool{machine.StartState: true}
+	queue := []string{machine.StartState}
+	for len(queue) > 0 {
+		stateId := queue[0]
+		queue = queue[1:]
+		for _, nextState := range nextStateMap[stateId] {
+			if !reachMap[nextState] {
+				reachMap[nextState] = true
+				queue = append(queue, nextState)
+			}
+		}
+	}
+	var unreachableList []string
+	for _, state := range machine.States {
+		if !reachMap[state.Id] {
+			unreachableList = append(unreachableList, state.Id)
+		}
+	}
+	if len(unreachableList) > 0 {
+		return fmt.Errorf("State machine:%s states %s can not reach from start state ", machine.Id, strings.Join(unreachableList, ","))
+	}
+	return nil
+}
+
+func inStringList(value string, list []string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStateMachineImpact 查询使用该状态机的模版、ci类型以及各状态下的数据量
+func GetStateMachineImpact(machineId string) (result *models.StateMachineImpact, err error) {
+	result = &models.StateMachineImpact{StateMachine: machineId, CiTemplates: []string{}, CiTypes: []*models.StateMachineImpactCiType{}}
+	templateRows, queryErr := x.QueryString("select id from sys_ci_template where state_machine=?", machineId)
+	if queryErr != nil {
+		err = fmt.Errorf("Try to query sys_ci_template table fail,%s ", queryErr.Error())
+		return
+	}
+	for _, row := range templateRows {
+		result.CiTemplates = append(result.CiTemplates, row["id"])
+	}
+	var ciTypeTable []*models.SysCiTypeTable
+	err = x.SQL("select id,status from sys_ci_type where state_machine=?", machineId).Find(&ciTypeTable)
+	if err != nil {
+		err = fmt.Errorf("Try to query sys_ci_type table fail,%s ", err.Error())
+		return
+	}
+	for _, ciType := range ciTypeTable {
+		impactCiType := models.StateMachineImpactCiType{CiType: ciType.Id, Status: ciType.Status, StateRows: make(map[string]int)}
+		if ciType.Status != "notCreated" {
+			stateRows, tmpErr := x.QueryString(fmt.Sprintf("select state,count(1) as num from %s group by state", ciType.Id))
+			if tmpErr != nil {
+				err = fmt.Errorf("Try to count ciType:%s data by state fail,%s ", ciType.Id, tmpErr.Error())
+				return
+			}
+			for _, row := range stateRows {
+				num, _ := strconv.Atoi(row["num"])
+				impactCiType.StateRows[row["state"]] = num
+				impactCiType.TotalRows += num
+			}
+		}
+		result.TotalRows += impactCiType.TotalRows
+		result.CiTypes = append(result.CiTypes, &impactCiType)
+	}
+	return
+}
+
+func CreateStateMachine(param *models.GetStateMachineList) (err error) {
+	fillStateMachineDefault(param)
+	if err = ValidateStateMachine(param); err != nil {
+		return
+	}
+	existRows, err := x.QueryString("select id from sys_state_machine where id=?", param.Id)
+	if err != nil {
+		return fmt.Errorf("Try to query sys_state_machine table fail,%s ", err.Error())
+	}
+	if len(existRows) > 0 {
+		return fmt.Errorf("State machine:%s already exist ", param.Id)
+	}
+	return saveStateMachine(&models.GetStateMachineList{Id: param.Id}, param)
+}
+
+// UpdateStateMachine 整体替换状态机的状态和迁移,删除或改名的状态不能有数据在使用
+func UpdateStateMachine(param *models.GetStateMachineList) (err error) {
+	oldMachine, err := GetStateMachineDetail(param.Id)
+	if err != nil {
+		return
+	}
+	fillStateMachineDefault(param)
+	if err = ValidateStateMachine(param); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, param)
+}
+
+func DeleteStateMachine(machineId string) (err error) {
+	oldMachine, err := GetStateMachineDetail(machineId)
+	if err != nil {
+		return
+	}
+	impact, err := GetStateMachineImpact(machineId)
+	if err != nil {
+		return
+	}
+	if len(impact.CiTemplates) > 0 {
+		return fmt.Errorf("State machine:%s is used by ci template:%s ", machineId, strings.Join(impact.CiTemplates, ","))
+	}
+	if len(impact.CiTypes) > 0 {
+		var ciTypeList []string
+		for _, ciType := range impact.CiTypes {
+			ciTypeList = append(ciTypeList, ciType.CiType)
+		}
+		return fmt.Errorf("State machine:%s is used by ciType:%s ", machineId, strings.Join(ciTypeList, ","))
+	}
+	var actions []*execAction
+	actions = append(actions, &execAction{Sql: "DELETE FROM sys_state_transition WHERE state_machine=?", Param: []interface{}{oldMachine.Id}})
+	actions = append(actions, &execAction{Sql: "DELETE FROM sys_state WHERE state_machine=?", Param: []interface{}{oldMachine.Id}})
+	actions = append(actions, &execAction{Sql: "DELETE FROM sys_state_machine WHERE id=?", Param: []interface{}{oldMachine.Id}})
+	if err = transaction(actions); err != nil {
+		err = fmt.Errorf("Try to delete state machine:%s fail,%s ", machineId, err.Error())
+	}
+	return
+}
+
+func CreateState(param *models.StateMachineStateParam) (err error) {
+	oldMachine, err := GetStateMachineDetail(param.StateMachine)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	state := param.SysStateTable
+	newMachine.States = append(newMachine.States, &state)
+	newMachine.Transitions = append(newMachine.Transitions, param.Transitions...)
+	fillStateMachineDefault(newMachine)
+	for _, existState := range oldMachine.States {
+		if existState.Id == state.Id {
+			return fmt.Errorf("State:%s already exist ", state.Id)
+		}
+	}
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+func UpdateState(param *models.SysStateTable) (err error) {
+	oldMachine, err := GetStateMachineDetail(param.StateMachine)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	findFlag := false
+	for i, state := range newMachine.States {
+		if state.Id == param.Id {
+			newState := *param
+			newMachine.States[i] = &newState
+			findFlag = true
+			break
+		}
+	}
+	if !findFlag {
+		return fmt.Errorf("Can not find state:%s in state machine:%s ", param.Id, param.StateMachine)
+	}
+	fillStateMachineDefault(newMachine)
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+// DeleteState 删除状态时一并删除从该状态出发和到达该状态的迁移
+func DeleteState(machineId, stateId string) (err error) {
+	oldMachine, err := GetStateMachineDetail(machineId)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	newMachine.States = []*models.SysStateTable{}
+	for _, state := range oldMachine.States {
+		if state.Id != stateId {
+			newMachine.States = append(newMachine.States, state)
+		}
+	}
+	if len(newMachine.States) == len(oldMachine.States) {
+		return fmt.Errorf("Can not find state:%s in state machine:%s ", stateId, machineId)
+	}
+	newMachine.Transitions = []*models.SysStateTransitionTable{}
+	for _, transition := range oldMachine.Transitions {
+		if transition.CurrentState != stateId && transition.TargetState != stateId {
+			newMachine.Transitions = append(newMachine.Transitions, transition)
+		}
+	}
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+func CreateStateTransition(param *models.SysStateTransitionTable) (err error) {
+	oldMachine, err := GetStateMachineDetail(param.StateMachine)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	transition := *param
+	newMachine.Transitions = append(newMachine.Transitions, &transition)
+	fillStateMachineDefault(newMachine)
+	for _, existTransition := range oldMachine.Transitions {
+		if existTransition.Guid == transition.Guid {
+			return fmt.Errorf("Transition:%s already exist ", transition.Guid)
+		}
+	}
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+func UpdateStateTransition(param *models.SysStateTransitionTable) (err error) {
+	oldMachine, err := GetStateMachineDetail(param.StateMachine)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	findFlag := false
+	for i, transition := range newMachine.Transitions {
+		if transition.Guid == param.Guid {
+			newTransition := *param
+			newMachine.Transitions[i] = &newTransition
+			findFlag = true
+			break
+		}
+	}
+	if !findFlag {
+		return fmt.Errorf("Can not find transition:%s in state machine:%s ", param.Guid, param.StateMachine)
+	}
+	fillStateMachineDefault(newMachine)
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+func DeleteStateTransition(machineId, transitionGuid string) (err error) {
+	oldMachine, err := GetStateMachineDetail(machineId)
+	if err != nil {
+		return
+	}
+	newMachine := copyStateMachine(oldMachine)
+	newMachine.Transitions = []*models.SysStateTransitionTable{}
+	for _, transition := range oldMachine.Transitions {
+		if transition.Guid != transitionGuid {
+			newMachine.Transitions = append(newMachine.Transitions, transition)
+		}
+	}
+	if len(newMachine.Transitions) == len(oldMachine.Transitions) {
+		return fmt.Errorf("Can not find transition:%s in state machine:%s ", transitionGuid, machineId)
+	}
+	if err = ValidateStateMachine(newMachine); err != nil {
+		return
+	}
+	return saveStateMachine(oldMachine, newMachine)
+}
+
+func copyStateMachine(machine *models.GetStateMachineList) *models.GetStateMachineList {
+	result := models.GetStateMachineList{Id: machine.Id, Description: machine.Description, StartState: machine.StartState, FinalState: machine.FinalState,
+		States: []*models.SysStateTable{}, Transitions: []*models.SysStateTransitionTable{}}
+	for _, state := range machine.States {
+		tmpState := *state
+		result.States = append(result.States, &tmpState)
+	}
+	for _, transition := range machine.Transitions {
+		tmpTransition := *transition
+		result.Transitions = append(result.Transitions, &tmpTransition)
+	}
+	return &result
+}
+
+// fillStateMachineDefault 状态id默认为 状态机__状态名,迁移guid为空时自动生成
+func fillStateMachineDefault(machine *models.GetStateMachineList) {
+	for _, state := range machine.States {
+		state.StateMachine = machine.Id
+		if state.Id == "" && state.Name != "" {
+			state.Id = machine.Id + models.SysTableIdConnector + state.Name
+		}
+		if state.UniquePathTrigger == "" {
+			state.UniquePathTrigger = "no"
+		}
+		if state.IsConfirm == "" {
+			state.IsConfirm = "no"
+		}
+	}
+	for _, transition := range machine.Transitions {
+		transition.StateMachine = machine.Id
+		if transition.Guid == "" {
+			transition.Guid = guid.CreateGuid()
+		}
+		if transition.OperationFormType == "" {
+			transition.OperationFormType = "editable_form"
+		}
+		if transition.OperationMultiple == "" {
+			transition.OperationMultiple = "yes"
+		}
+	}
+}
+
+func saveStateMachine(oldMachine, newMachine *models.GetStateMachineList) (err error) {
+	actions, err := buildSaveStateMachineActions(oldMachine, newMachine)
+	if err != nil {
+		return
+	}
+	if err = transaction(actions); err != nil {
+		err = fmt.Errorf("Try to save state machine:%s fail,%s ", newMachine.Id, err.Error())
+		return
+	}
+	log.Logger.Info("Save state machine success", log.String("stateMachine", newMachine.Id), log.Int("states", len(newMachine.States)), log.Int("transitions", len(newMachine.Transitions)))
+	return
+}
+
+// buildSaveStateMachineActions 对比新旧状态机生成增删改语句,被删除或改名的状态如果还有数据在用则拒绝修改
+func buildSaveStateMachineActions(oldMachine, newMachine *models.GetStateMachineList) (actions []*execAction, err error) {
+	newStateMap := make(map[string]*models.SysStateTable)
+	for _, state := range newMachine.States {
+		newStateMap[state.Id] = state
+	}
+	oldStateMap := make(map[string]*models.SysStateTable)
+	var changeStateNameList []string
+	for _, state := range oldMachine.States {
+		oldStateMap[state.Id] = state
+		if newState, b := newStateMap[state.Id]; !b || newState.Name != state.Name {
+			changeStateNameList = append(changeStateNameList, state.Name)
+		}
+	}
+	if len(oldMachine.States) == 0 {
+		// 新建状态机时检查状态id是否已被其它状态机占用
+		stateIdList := []string{}
+		for _, state := range newMachine.States {
+			stateIdList = append(stateIdList, state.Id)
+		}
+		specSql, params := createListParams(stateIdList, "")
+		existRows, queryErr := x.QueryString(append([]interface{}{"select id,state_machine from sys_state where id in (" + specSql + ")"}, params...)...)
+		if queryErr != nil {
+			return nil, fmt.Errorf("Try to query sys_state table fail,%s ", queryErr.Error())
+		}
+		if len(existRows) > 0 {
+			return nil, fmt.Errorf("State:%s already exist in state machine:%s ", existRows[0]["id"], existRows[0]["state_machine"])
+		}
+	}
+	if len(changeStateNameList) > 0 {
+		impact, impactErr := GetStateMachineImpact(oldMachine.Id)
+		if impactErr != nil {
+			return nil, impactErr
+		}
+		for _, ciType := range impact.CiTypes {
+			for _, stateName := range changeStateNameList {
+				if ciType.StateRows[stateName] > 0 {
+					return nil, fmt.Errorf("State:%s is used by %d rows of ciType:%s,can not remove or rename ", stateName, ciType.StateRows[stateName], ciType.CiType)
+				}
+			}
+		}
+	}
+	if len(oldMachine.States) == 0 {
+		actions = append(actions, &execAction{Sql: "INSERT INTO sys_state_machine(id,description,start_state,final_state) VALUE (?,?,?,?)", Param: []interface{}{newMachine.Id, newMachine.Description, newMachine.StartState, newMachine.FinalState}})
+	} else {
+		actions = append(actions, &execAction{Sql: "UPDATE sys_state_machine SET description=?,start_state=?,final_state=? WHERE id=?", Param: []interface{}{newMachine.Description, newMachine.StartState, newMachine.FinalState, newMachine.Id}})
+	}
+	for _, state := range newMachine.States {
+		if oldState, b := oldStateMap[state.Id]; !b {
+			actions = append(actions, &execAction{Sql: "INSERT INTO sys_state(id,name,description,state_machine,unique_path_trigger,is_confirm) VALUE (?,?,?,?,?,?)",
+				Param: []interface{}{state.Id, state.Name, state.Description, state.StateMachine, state.UniquePathTrigger, state.IsConfirm}})
+		} else if *oldState != *state {
+			actions = append(actions, &execAction{Sql: "UPDATE sys_state SET name=?,description=?,unique_path_trigger=?,is_confirm=? WHERE id=?",
+				Param: []interface{}{state.Name, state.Description, state.UniquePathTrigger, state.IsConfirm, state.Id}})
+		}
+	}
+	oldTransitionMap := make(map[string]*models.SysStateTransitionTable)
+	for _, transition := range oldMachine.Transitions {
+		oldTransitionMap[transition.Guid] = transition
+	}
+	newTransitionMap := make(map[string]bool)
+	for _, transition := range newMachine.Transitions {
+		newTransitionMap[transition.Guid] = true
+	}
+	for _, transition := range oldMachine.Transitions {
+		if !newTransitionMap[transition.Guid] {
+			actions = append(actions, &execAction{Sql: "DELETE FROM sys_state_transition WHERE guid=?", Param: []interface{}{transition.Guid}})
+		}
+	}
+	for _, transition := range newMachine.Transitions {
+		if oldTransition, b := oldTransitionMap[transition.Guid]; !b {
+			actions = append(actions, &execAction{Sql: "INSERT INTO sys_state_transition(guid,state_machine,current_state,target_state,operation,operation_en,permission,action,operation_form_type,operation_multiple,guard) VALUE (?,?,?,?,?,?,?,?,?,?,?)",
+				Param: []interface{}{transition.Guid, transition.StateMachine, transition.CurrentState, transition.TargetState, transition.Operation, transition.OperationEn, transition.Permission, transition.Action, transition.OperationFormType, transition.OperationMultiple, transition.Guard}})
+		} else if *oldTransition != *transition {
+			actions = append(actions, &execAction{Sql: "UPDATE sys_state_transition SET current_state=?,target_state=?,operation=?,operation_en=?,permission=?,action=?,operation_form_type=?,operation_multiple=?,guard=? WHERE guid=?",
+				Param: []interface{}{transition.CurrentState, transition.TargetState, transition.Operation, transition.OperationEn, transition.Permission, transition.Action, transition.OperationFormType, transition.OperationMultiple, transition.Guard, transition.Guid}})
+		}
+	}
+	for _, state := range oldMachine.States {
+		if _, b := newStateMap[state.Id]; !b {
+			actions = append(actions, &execAction{Sql: "DELETE FROM sys_state WHERE id=?", Param: []interface{}{state.Id}})
+		}
+	}
+	return
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+var transitionGuardOperatorList = []string{"eq", "ne", "in", "like", "null", "notNull", "empty", "notEmpty"}
+
+// parseTransitionGuard 守卫条件为json数组,所有条件都满足才允许迁移
+// Example: [{"left":"owner","operator":"notNull","message":"owner can not empty"}]
+// Example: [{"left":"host_resource~(host_resource)app_instance[{state eq 'running'}]:[guid]","operator":"empty"}]
+func parseTransitionGuard(guard string) (guardList []*models.StateTransitionGuardObj, err error) {
+	if strings.TrimSpace(guard) == "" {
+		return
+	}
+	if err = json.Unmarshal([]byte(guard), &guardList); err != nil {
+		err = fmt.Errorf("Json unmarshal guard fail,%s ", err.Error())
+		return
+	}
+	for i, guardObj := range guardList {
+		if guardObj.Left == "" {
+			err = fmt.Errorf("Guard condition %d left can not empty ", i)
+			return
+		}
+		if !inStringList(guardObj.Operator, transitionGuardOperatorList) {
+			err = fmt.Errorf("Guard condition %d operator:%s is illegal,must be one of %s ", i, guardObj.Operator, strings.Join(transitionGuardOperatorList, ","))
+			return
+		}
+		if guardObj.Right.Type != "" && guardObj.Right.Type != "value" && guardObj.Right.Type != "array" && guardObj.Right.Type != models.FilterTypeExpression {
+			err = fmt.Errorf("Guard condition %d right type:%s is illegal ", i, guardObj.Right.Type)
+			return
+		}
+	}
+	return
+}
+
+// checkTransitionGuard 用数据行的现有数据和输入数据校验迁移的守卫条件,不满足时返回拒绝原因
+func checkTransitionGuard(param *models.ActionFuncParam) (rejectMessage string, err error) {
+	guardList, err := parseTransitionGuard(param.Transition.Guard)
+	if err != nil || len(guardList) == 0 {
+		return
+	}
+	rowData := make(map[string]string)
+	for k, v := range param.NowData {
+		rowData[k] = v
+	}
+	for k, v := range param.InputData {
+		rowData[k] = v
+	}
+	for _, guardObj := range guardList {
+		leftValues, tmpErr := getTransitionGuardValues(guardObj.Left, param.CiType, rowData)
+		if tmpErr != nil {
+			err = fmt.Errorf("Try to get guard left:%s value fail,%s ", guardObj.Left, tmpErr.Error())
+			return
+		}
+		var rightValues []string
+		switch guardObj.Right.Type {
+		case "array":
+			if valueList, ok := guardObj.Right.Value.([]interface{}); ok {
+				for _, v := range valueList {
+					rightValues = append(rightValues, fmt.Sprintf("%v", v))
+				}
+			}
+		case models.FilterTypeExpression:
+			rightValues, tmpErr = getTransitionGuardValues(fmt.Sprintf("%v", guardObj.Right.Value), param.CiType, rowData)
+			if tmpErr != nil {
+				err = fmt.Errorf("Try to get guard right:%v value fail,%s ", guardObj.Right.Value, tmpErr.Error())
+				return
+			}
+		default:
+			if guardObj.Right.Value != nil {
+				rightValues = transStringToList(fmt.Sprintf("%v", guardObj.Right.Value))
+			}
+		}
+		if !matchTransitionGuard(guardObj.Operator, leftValues, rightValues) {
+			rejectMessage = guardObj.Message
+			if rejectMessage == "" {
+				rejectMessage = fmt.Sprintf("%s %s %s is not satisfied,actual value:[%s]", guardObj.Left, guardObj.Operator, strings.Join(rightValues, ","), strings.Join(leftValues, ","))
+			}
+			return
+		}
+	}
+	return
+}
+
+// parseTransitionGuardLeft 只有一段且不带过滤、引用和结果属性时为当前数据的属性名,否则为至少两段的表达式
+func parseTransitionGuardLeft(left string) (attr string, expr *ciExpression, err error) {
+	if expr, err = parseCiExpression(left); err != nil {
+		return
+	}
+	firstSegment := expr.Segments[0]
+	if len(expr.Segments) == 1 && len(firstSegment.Filters) == 0 && firstSegment.RefAttr == "" && firstSegment.ResultColumn == "" {
+		if !isCiExprIdentifier(firstSegment.CiType) {
+			err = newCiExpressionError(left, firstSegment.Pos, "illegal attribute name '%s'", firstSegment.CiType)
+		}
+		return firstSegment.CiType, nil, err
+	}
+	if len(expr.Segments) < 2 {
+		err = newCiExpressionError(left, len([]rune(left))+1, "expression must join at least one ci type with '>' or '~'")
+		return "", nil, err
+	}
+	if expr.Segments[len(expr.Segments)-1].ResultColumn == "" {
+		err = newCiExpressionError(left, len([]rune(left))+1, "expression must end with :[attribute]")
+		return "", nil, err
+	}
+	return
+}
+
+// validateTransitionGuard 保存迁移时校验守卫条件,表达式中的ci类型和属性要存在
+func validateTransitionGuard(guard string) error {
+	guardList, err := parseTransitionGuard(guard)
+	if err != nil {
+		return err
+	}
+	for i, guardObj := range guardList {
+		sourceList := []string{guardObj.Left}
+		if guardObj.Right.Type == models.FilterTypeExpression {
+			sourceList = append(sourceList, fmt.Sprintf("%v", guardObj.Right.Value))
+		}
+		for _, source := range sourceList {
+			_, expr, tmpErr := parseTransitionGuardLeft(source)
+			if tmpErr == nil && expr != nil {
+				_, tmpErr = validateCiExpression(expr)
+			}
+			if tmpErr != nil {
+				return fmt.Errorf("Guard condition %d %s ", i, tmpErr.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// getTransitionGuardValues left为属性名时取当前数据的值,为表达式时以当前ci类型开头查询
+func getTransitionGuardValues(left, ciType string, rowData map[string]string) (result []string, err error) {
+	attr, expr, err := parseTransitionGuardLeft(left)
+	if err != nil {
+		return
+	}
+	if expr == nil {
+		if rowData[attr] == "" {
+			return []string{}, nil
+		}
+		return transStringToList(rowData[attr]), nil
+	}
+	if expr.Segments[0].CiType != ciType {
+		err = newCiExpressionError(left, expr.Segments[0].Pos, "expression must start with ciType:%s", ciType)
+		return
+	}
+	// 查询会跳过第一段,这里把当前数据转成第二段的过滤条件
+	filterMap := make(map[string]string)
+	nextSegment := *expr.Segments[1]
+	if nextSegment.JoinType == ciExprJoinRef {
+		if rowData[nextSegment.JoinColumn] == "" {
+			return []string{}, nil
+		}
+		filterMap[nextSegment.JoinColumn] = rowData[nextSegment.JoinColumn]
+	} else {
+		nextSegment.Filters = append([]*ciExprFilter{{Pos: nextSegment.JoinPos, Column: nextSegment.JoinColumn, Operator: "eq", Values: []string{rowData["guid"]}}}, nextSegment.Filters...)
+	}
+	expr.Segments[1] = &nextSegment
+	attrMap, err := validateCiExpression(expr)
+	if err != nil {
+		return
+	}
+	queryResult, err := queryCiExpression(expr, attrMap, filterMap, false)
+	if err != nil {
+		return
+	}
+	for _, v := range queryResult {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return
+}
+
+func matchTransitionGuard(operator string, leftValues, rightValues []string) bool {
+	var values []string
+	for _, v := range leftValues {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	switch operator {
+	case "null", "empty":
+		return len(values) == 0
+	case "notNull", "notEmpty":
+		return len(values) > 0
+	}
+	if operator == "ne" {
+		for _, v := range values {
+			if inStringList(v, rightValues) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(values) == 0 || len(rightValues) == 0 {
+		return false
+	}
+	for _, v := range values {
+		switch operator {
+		case "eq":
+			if v != rightValues[0] {
+				return false
+			}
+		case "in":
+			if !inStringList(v, rightValues) {
+				return false
+			}
+		case "like":
+			if !strings.Contains(v, rightValues[0]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package db
+
+import "testing"
+
+func TestParseTransitionGuardLeft(t *testing.T) {
+	attr, expr, err := parseTransitionGuardLeft("owner")
+	if err != nil || attr != "owner" || expr != nil {
+		t.Fatalf("attribute left parse fail,attr:%s err:%v", attr, err)
+	}
+	attr, expr, err = parseTransitionGuardLeft("host~(host)app_instance[{state eq 'running'}]:[guid]")
+	if err != nil || attr != "" || expr == nil || len(expr.Segments) != 2 {
+		t.Fatalf("expression left parse fail,err:%v", err)
+	}
+	// 值里带>或~的单段表达式以前会按表达式处理并越界
+	for _, left := range []string{
+		"host[{key_name eq 'a>b'}]:[guid]",
+		"host[{key_name eq 'a~b'}]",
+		"host.owner",
+		"host~(host)app_instance",
+		"host>",
+		"'a>b'",
+	} {
+		if _, _, err = parseTransitionGuardLeft(left); err == nil {
+			t.Fatalf("left:%s should be illegal", left)
+		}
+	}
+}
+
+func TestGetTransitionGuardValuesAttribute(t *testing.T) {
+	rowData := map[string]string{"owner": "a,b", "empty": ""}
+	values, err := getTransitionGuardValues("owner", "host", rowData)
+	if err != nil || len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Fatalf("attribute values not match:%v err:%v", values, err)
+	}
+	values, err = getTransitionGuardValues("empty", "host", rowData)
+	if err != nil || len(values) != 0 {
+		t.Fatalf("empty attribute values not match:%v err:%v", values, err)
+	}
+	if _, err = getTransitionGuardValues("host[{key_name eq 'a>b'}]:[guid]", "host", rowData); err == nil {
+		t.Fatalf("single segment expression should return error")
+	}
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+const (
+	// timeTriggerStaleSec 执行中的记录超过这个时间没有结束,说明执行实例已经退出
+	timeTriggerStaleSec         = 600
+	timeTriggerMaxRetryDelaySec = 86400
+)
+
+// validateTimeTriggerAttr 定时触发属性必须是时间类型,触发的操作必须是ci类型状态机中的非新增操作,数据操作按英文操作名匹配迁移,中文操作名会转成英文操作名保存
+// transList为空时按ci类型当前的状态机查询迁移
+func validateTimeTriggerAttr(ciType, inputType, dataType string, triggerOperation *string, transList []*models.SysStateTransitionTable) error {
+	if inputType != models.TimeTriggerInputType {
+		*triggerOperation = ""
+		return nil
+	}
+	if dataType != "datetime" {
+		return fmt.Errorf("Attribute with inputType:%s must use datetime propertyType ", models.TimeTriggerInputType)
+	}
+	if *triggerOperation == "" {
+		return fmt.Errorf("Attribute with inputType:%s must config triggerOperation ", models.TimeTriggerInputType)
+	}
+	if len(transList) == 0 {
+		err := x.SQL("select distinct operation,operation_en,action from sys_state_transition where state_machine in (select state_machine from sys_ci_type where id=?)", ciType).Find(&transList)
+		if err != nil {
+			return fmt.Errorf("Try to query ci type:%s state transition fail,%s ", ciType, err.Error())
+		}
+	}
+	var operationList []string
+	for _, trans := range transList {
+		if trans.Action == "insert" {
+			continue
+		}
+		if trans.Operation == *triggerOperation || trans.OperationEn == *triggerOperation {
+			*triggerOperation = trans.OperationEn
+			return nil
+		}
+		if !inStringList(trans.OperationEn, operationList) {
+			operationList = append(operationList, trans.OperationEn)
+		}
+	}
+	return fmt.Errorf("TriggerOperation:%s is illegal,must be one of %s ", *triggerOperation, strings.Join(operationList, ","))
+}
+
+func StartTimeTrigger() {
+	if !models.Config.TimeTrigger.Enable {
+		log.Logger.Info("Time trigger is disable")
+		return
+	}
+	log.Logger.Info("Start time trigger cron job", log.String("instance", jobInstanceId))
+	intervalSec := models.Config.TimeTrigger.IntervalSec
+	if intervalSec <= 0 {
+		intervalSec = 30
+	}
+	t := time.NewTicker(time.Duration(intervalSec) * time.Second).C
+	// 启动时先跑一次,尽快补上停机期间错过的触发
+	handleTimeTrigger()
+	for {
+		<-t
+		handleTimeTrigger()
+	}
+}
+
+// reclaimStaleTimeTriggerLogs 执行实例退出时留下的执行中记录改成失败,按失败重试的逻辑重新触发,其它实例正在执行的记录不受影响
+func reclaimStaleTimeTriggerLogs() {
+	nowTime := time.Now()
+	staleTime := nowTime.Add(-timeTriggerStaleSec * time.Second).Format(models.DateTimeFormat)
+	execResult, err := x.Exec("update sys_time_trigger_log set status=?,message=?,next_time=?,owner=NULL where status=? and (update_time is null or update_time<?)",
+		models.TimeTriggerFailed, "Time trigger instance exit before finish", nowTime.Format(models.DateTimeFormat), models.TimeTriggerRunning, staleTime)
+	if err != nil {
+		log.Logger.Error("Try to reclaim stale time trigger log fail", log.Error(err))
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		log.Logger.Info("Reclaim stale time trigger log", log.Int64("num", affectNum))
+	}
+}
+
+// handleTimeTrigger 时间已到且没有触发记录的数据都会触发,停机期间错过的触发在重启后补上,触发时间被修改后会再次触发,失败的触发按退避时间重试
+func handleTimeTrigger() {
+	reclaimStaleTimeTriggerLogs()
+	var attrList []*models.SysCiTypeAttrTable
+	err := x.SQL("select * from sys_ci_type_attr where input_type=? and status='created' and trigger_operation<>'' and ci_type in (select id from sys_ci_type where status='created')", models.TimeTriggerInputType).Find(&attrList)
+	if err != nil {
+		log.Logger.Error("Try to query time trigger attribute fail", log.Error(err))
+		return
+	}
+	batchSize := models.Config.TimeTrigger.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	for _, attr := range attrList {
+		if err = handleCiAttrTimeTrigger(attr, batchSize); err != nil {
+			log.Logger.Error("Handle time trigger fail", log.String("ciAttr", attr.Id), log.Error(err))
+		}
+	}
+}
+
+func handleCiAttrTimeTrigger(attr *models.SysCiTypeAttrTable, batchSize int) error {
+	// 只触发当前状态下有该操作的数据,其它数据等迁移到可操作的状态后再触发
+	stateRows, err := x.QueryString("select distinct t2.name from sys_state_transition t1 join sys_state t2 on t1.current_state=t2.id where t1.state_machine in (select state_machine from sys_ci_type where id=?) and t1.operation_en=?", attr.CiType, attr.TriggerOperation)
+	if err != nil {
+		return fmt.Errorf("Try to query state with operation:%s fail,%s ", attr.TriggerOperation, err.Error())
+	}
+	if len(stateRows) == 0 {
+		return fmt.Errorf("Can not find any state with operation:%s ", attr.TriggerOperation)
+	}
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	var stateFilterList []string
+	var stateParams []interface{}
+	for _, row := range stateRows {
+		stateFilterList = append(stateFilterList, "?")
+		stateParams = append(stateParams, row["name"])
+	}
+	// 下限用于排除空时间和0000-00-00的时间
+	queryParams := append([]interface{}{"1970-01-01 00:00:00", nowTime}, stateParams...)
+	queryParams = append(queryParams, attr.Id, batchSize)
+	querySql := fmt.Sprintf("select t1.guid,t1.key_name,t1.%s as trigger_time from %s t1 where t1.%s>? and t1.%s<=? and t1.state in (%s) and not exists (select 1 from sys_time_trigger_log t2 where t2.ci_attr=? and t2.row_guid=t1.guid and t2.trigger_time=t1.%s) order by t1.%s limit ?",
+		attr.Name, attr.CiType, attr.Name, attr.Name, strings.Join(stateFilterList, ","), attr.Name, attr.Name)
+	queryRows, err := x.QueryString(append([]interface{}{querySql}, queryParams...)...)
+	if err != nil {
+		return fmt.Errorf("Try to query time trigger data fail,%s ", err.Error())
+	}
+	for _, row := range queryRows {
+		// 先插入执行中的记录抢占,唯一索引避免多实例时重复触发
+		_, execErr := x.Exec("insert into sys_time_trigger_log(ci_type,ci_attr,row_guid,trigger_time,operation,status,retry_count,owner,create_time,update_time) value (?,?,?,?,?,?,0,?,?,?)",
+			attr.CiType, attr.Id, row["guid"], row["trigger_time"], attr.TriggerOperation, models.TimeTriggerRunning, jobInstanceId, nowTime, nowTime)
+		if execErr != nil {
+			log.Logger.Debug("Time trigger log already exist", log.String("guid", row["guid"]), log.Error(execErr))
+			continue
+		}
+		runTimeTrigger(attr, row["guid"], row["key_name"], row["trigger_time"], 0)
+	}
+	// 失败的触发在数据还是同一个触发时间且状态下仍有该操作时按退避时间重试,超过重试次数后不再触发
+	retryParams := append([]interface{}{attr.Id, models.TimeTriggerFailed, getTimeTriggerMaxRetry(), nowTime}, stateParams...)
+	retryParams = append(retryParams, batchSize)
+	retrySql := fmt.Sprintf("select t2.id,t2.retry_count,t1.guid,t1.key_name,t2.trigger_time from sys_time_trigger_log t2 join %s t1 on t1.guid=t2.row_guid and t1.%s=t2.trigger_time where t2.ci_attr=? and t2.status=? and t2.retry_count<? and t2.next_time<=? and t1.state in (%s) order by t2.next_time limit ?",
+		attr.CiType, attr.Name, strings.Join(stateFilterList, ","))
+	retryRows, err := x.QueryString(append([]interface{}{retrySql}, retryParams...)...)
+	if err != nil {
+		return fmt.Errorf("Try to query failed time trigger log fail,%s ", err.Error())
+	}
+	for _, row := range retryRows {
+		execResult, execErr := x.Exec("update sys_time_trigger_log set status=?,retry_count=retry_count+1,owner=?,update_time=? where id=? and status=?",
+			models.TimeTriggerRunning, jobInstanceId, time.Now().Format(models.DateTimeFormat), row["id"], models.TimeTriggerFailed)
+		if execErr != nil {
+			log.Logger.Error("Try to lock failed time trigger log fail", log.String("id", row["id"]), log.Error(execErr))
+			continue
+		}
+		if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+			continue
+		}
+		retryCount, _ := strconv.Atoi(row["retry_count"])
+		runTimeTrigger(attr, row["guid"], row["key_name"], row["trigger_time"], retryCount+1)
+	}
+	return nil
+}
+
+// runTimeTrigger 执行已抢占的触发记录,失败时记下次重试时间
+func runTimeTrigger(attr *models.SysCiTypeAttrTable, guid, keyName, triggerTime string, retryCount int) {
+	log.Logger.Info("Start to handle time trigger", log.String("ciAttr", attr.Id), log.String("guid", guid), log.String("triggerTime", triggerTime), log.String("operation", attr.TriggerOperation), log.Int("retryCount", retryCount))
+	status, message, nextTime := models.TimeTriggerSuccess, "", ""
+	handleParam := models.HandleCiDataParam{InputData: []models.CiDataMapObj{{"guid": guid}}, CiTypeId: attr.CiType, Operation: attr.TriggerOperation, Operator: models.SystemUser, Roles: []string{}, Permission: false, FromCore: false}
+	if _, _, handleErr := HandleCiDataOperation(handleParam); handleErr != nil {
+		log.Logger.Warn("Time trigger operation fail", log.String("ciAttr", attr.Id), log.String("keyName", keyName), log.Int("retryCount", retryCount), log.Error(handleErr))
+		status, message = models.TimeTriggerFailed, handleErr.Error()
+		nextTime = time.Now().Add(time.Duration(getTimeTriggerRetryDelay(retryCount)) * time.Second).Format(models.DateTimeFormat)
+	}
+	var err error
+	if nextTime == "" {
+		_, err = x.Exec("update sys_time_trigger_log set status=?,message=?,update_time=? where ci_attr=? and row_guid=? and trigger_time=? and owner=?",
+			status, message, time.Now().Format(models.DateTimeFormat), attr.Id, guid, triggerTime, jobInstanceId)
+	} else {
+		_, err = x.Exec("update sys_time_trigger_log set status=?,message=?,next_time=?,update_time=? where ci_attr=? and row_guid=? and trigger_time=? and owner=?",
+			status, message, nextTime, time.Now().Format(models.DateTimeFormat), attr.Id, guid, triggerTime, jobInstanceId)
+	}
+	if err != nil {
+		log.Logger.Error("Try to update time trigger log fail", log.String("guid", guid), log.Error(err))
+	}
+}
+
+func getTimeTriggerMaxRetry() int {
+	if models.Config.TimeTrigger.MaxRetry <= 0 {
+		return 3
+	}
+	return models.Config.TimeTrigger.MaxRetry
+}
+
+// getTimeTriggerRetryDelay 按已重试次数指数退避
+func getTimeTriggerRetryDelay(retryCount int) int {
+	delaySec := models.Config.TimeTrigger.RetryDelaySec
+	if delaySec <= 0 {
+		delaySec = 60
+	}
+	for i := 0; i < retryCount; i++ {
+		delaySec = delaySec * 2
+		if delaySec >= timeTriggerMaxRetryDelaySec {
+			return timeTriggerMaxRetryDelaySec
+		}
+	}
+	return delaySec
+}
@@ -0,0 +1,77 @@
+//go:build sqlite
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestReclaimStaleTimeTriggerLogs(t *testing.T) {
+	nowTime := time.Now()
+	for _, triggerLog := range []struct {
+		Guid       string
+		UpdateTime string
+	}{
+		{"time_trigger_stale", nowTime.Add(-timeTriggerStaleSec * 2 * time.Second).Format(models.DateTimeFormat)},
+		{"time_trigger_alive", nowTime.Format(models.DateTimeFormat)},
+	} {
+		if _, err := x.Exec("insert into sys_time_trigger_log(ci_type,ci_attr,row_guid,trigger_time,operation,status,owner,update_time) values (?,?,?,?,?,?,?,?)",
+			testCiType, "test_host__reclaim", triggerLog.Guid, "2020-01-01 00:00:00", "Change", models.TimeTriggerRunning, "other_instance", triggerLog.UpdateTime); err != nil {
+			t.Fatalf("insert time trigger log fail,%s", err.Error())
+		}
+	}
+	reclaimStaleTimeTriggerLogs()
+	if row := queryTestRow(t, "select * from sys_time_trigger_log where row_guid=?", "time_trigger_stale"); row["status"] != models.TimeTriggerFailed || row["next_time"] == "" || row["owner"] != "" {
+		t.Fatalf("stale log should be reclaimed:%v", row)
+	}
+	if row := queryTestRow(t, "select * from sys_time_trigger_log where row_guid=?", "time_trigger_alive"); row["status"] != models.TimeTriggerRunning {
+		t.Fatalf("alive log should keep running:%v", row)
+	}
+}
+
+func TestTimeTriggerRetryFailedLog(t *testing.T) {
+	attr := models.SysCiTypeAttrTable{CiType: testCiType, Name: "expire_time", DisplayName: "过期时间", InputType: models.TimeTriggerInputType, DataType: "datetime",
+		TriggerOperation: "Change", UniqueConstraint: "no", UiNullable: "yes", Nullable: "yes", Editable: "yes", DisplayByDefault: "yes", PermissionUsage: "no", ResetOnEdit: "no", AutofillAble: "no"}
+	if err := CiAttrCreate(&attr); err != nil {
+		t.Fatalf("create attr fail,%s", err.Error())
+	}
+	if err := CiAttrApply(testCiType, attr.Id, false); err != nil {
+		t.Fatalf("apply attr fail,%s", err.Error())
+	}
+	triggerTime, nowTime := "2020-01-01 00:00:00", time.Now()
+	maxRetry := getTimeTriggerMaxRetry()
+	for _, triggerLog := range []struct {
+		Code       string
+		RetryCount int
+		NextTime   string
+	}{
+		{"t1", 0, nowTime.Add(-time.Minute).Format(models.DateTimeFormat)},
+		{"t2", 0, nowTime.Add(time.Hour).Format(models.DateTimeFormat)},
+		{"t3", maxRetry, nowTime.Add(-time.Minute).Format(models.DateTimeFormat)},
+	} {
+		inserted := handleTestOperation(t, "Add", models.CiDataMapObj{"code": triggerLog.Code, "asset_id": "asset-" + triggerLog.Code, "key_name": triggerLog.Code, "expire_time": triggerTime})
+		if _, err := x.Exec("insert into sys_time_trigger_log(ci_type,ci_attr,row_guid,trigger_time,operation,status,retry_count,next_time) values (?,?,?,?,?,?,?,?)",
+			testCiType, attr.Id, inserted[0]["guid"], triggerTime, "Change", models.TimeTriggerFailed, triggerLog.RetryCount, triggerLog.NextTime); err != nil {
+			t.Fatalf("insert time trigger log fail,%s", err.Error())
+		}
+	}
+	if err := handleCiAttrTimeTrigger(&attr, 10); err != nil {
+		t.Fatalf("handle time trigger fail,%s", err.Error())
+	}
+	// 到了重试时间的失败记录会重试,没到时间和超过重试次数的不动
+	for code, expect := range map[string]string{"t1": models.TimeTriggerSuccess, "t2": models.TimeTriggerFailed, "t3": models.TimeTriggerFailed} {
+		row := queryTestRow(t, "select t2.* from sys_time_trigger_log t2 join test_host t1 on t1.guid=t2.row_guid where t1.code=?", code)
+		if row["status"] != expect {
+			t.Fatalf("time trigger log of %s not match:%v", code, row)
+		}
+		if code == "t1" && row["retry_count"] != "1" {
+			t.Fatalf("retry count not match:%v", row)
+		}
+	}
+	if getTimeTriggerRetryDelay(0) != 60 || getTimeTriggerRetryDelay(2) != 240 || getTimeTriggerRetryDelay(20) != timeTriggerMaxRetryDelaySec {
+		t.Fatalf("retry delay not match")
+	}
+}
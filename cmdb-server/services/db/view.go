@@ -148,7 +148,7 @@ func GetRootCiDataWithReportId(reportId string) (ciDataGuidList []string, err er
 	return
 }
 
-func ViewConfirmAction(param models.ViewData, userToken, operator string, userRoles []string) (result []models.CiDataMapObj, err error) {
+func ViewConfirmAction(param models.ViewData, userToken, operator string, userRoles []string, permission bool) (result []models.CiDataMapObj, err error) {
 	result = []models.CiDataMapObj{}
 	rootGuidList := strings.Split(param.RootCi, ",")
 	viewData, queryViewErr := QueryViewById(param.ViewId)
@@ -197,10 +197,6 @@ func ViewConfirmAction(param models.ViewData, userToken, operator string, userRo
 		tmpMap["guid"] = v
 		confirmParam = append(confirmParam, tmpMap)
 	}
-	permission := true
-	if operator == "SYSTEM" {
-		permission = false
-	}
 	handleParam := models.HandleCiDataParam{InputData: confirmParam, CiTypeId: viewData.CiType, Operation: "Confirm", Operator: operator, Roles: userRoles, Permission: permission}
 	handleParam.UserToken = userToken
 	result, _, err = HandleCiDataOperation(handleParam)
@@ -0,0 +1,367 @@
+package db
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/go-common-lib/cipher"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/common/log"
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookMaxRetryDelaySec = 86400
+	webhookDefaultMaxRetry  = 8
+	webhookMaxSecretLength  = 64
+	// 发送中超过这个时间没有结果的事件认为发送实例已退出,重新放回队列
+	webhookSendingStaleSec = 600
+)
+
+func WebhookQuery(param *models.QueryRequestParam) (pageInfo models.PageInfo, rowData []*models.SysWebhookTable, err error) {
+	rowData = []*models.SysWebhookTable{}
+	filterSql, queryColumn, queryParam := transFiltersToSQL(param, &models.TransFiltersParam{IsStruct: true, StructObj: models.SysWebhookTable{}})
+	baseSql := fmt.Sprintf("SELECT %s FROM sys_webhook WHERE 1=1 %s ", queryColumn, filterSql)
+	if param.Paging {
+		pageInfo.StartIndex = param.Pageable.StartIndex
+		pageInfo.PageSize = param.Pageable.PageSize
+		pageInfo.TotalRows = queryCount(baseSql, queryParam...)
+		pageSql, pageParam := transPageInfoToSQL(*param.Pageable)
+		baseSql += pageSql
+		queryParam = append(queryParam, pageParam...)
+	}
+	err = x.SQL(baseSql, queryParam...).Find(&rowData)
+	if err != nil {
+		err = fmt.Errorf("Webhook query fail,%s ", err.Error())
+		return
+	}
+	for _, row := range rowData {
+		if row.Secret != "" {
+			row.Secret = models.PasswordDisplay
+		}
+	}
+	return
+}
+
+func WebhookCreate(param *models.SysWebhookTable, operator string) error {
+	if err := validateWebhookParam(param); err != nil {
+		return err
+	}
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	param.Id = "webhook_" + guid.CreateGuidList(1)[0]
+	if err := encryptWebhookSecret(param); err != nil {
+		return err
+	}
+	_, err := x.Exec("insert into sys_webhook(id,name,url,secret,ci_type,operation,target_state,enable,create_user,create_time,update_user,update_time) value (?,?,?,?,?,?,?,?,?,?,?,?)",
+		param.Id, param.Name, param.Url, param.Secret, param.CiType, param.Operation, param.TargetState, param.Enable, operator, nowTime, operator, nowTime)
+	if err != nil {
+		return fmt.Errorf("Try to insert webhook fail,%s ", err.Error())
+	}
+	return nil
+}
+
+func WebhookUpdate(param *models.SysWebhookTable, operator string) error {
+	if err := validateWebhookParam(param); err != nil {
+		return err
+	}
+	var webhookList []*models.SysWebhookTable
+	err := x.SQL("select * from sys_webhook where id=?", param.Id).Find(&webhookList)
+	if err != nil {
+		return fmt.Errorf("Try to query webhook fail,%s ", err.Error())
+	}
+	if len(webhookList) == 0 {
+		return fmt.Errorf("Can not find webhook with id:%s ", param.Id)
+	}
+	// 查询接口返回的是掩码,没改密钥时保持原值
+	if param.Secret == models.PasswordDisplay {
+		param.Secret = webhookList[0].Secret
+	} else if err = encryptWebhookSecret(param); err != nil {
+		return err
+	}
+	_, err = x.Exec("update sys_webhook set name=?,url=?,secret=?,ci_type=?,operation=?,target_state=?,enable=?,update_user=?,update_time=? where id=?",
+		param.Name, param.Url, param.Secret, param.CiType, param.Operation, param.TargetState, param.Enable, operator, time.Now().Format(models.DateTimeFormat), param.Id)
+	if err != nil {
+		return fmt.Errorf("Try to update webhook fail,%s ", err.Error())
+	}
+	return nil
+}
+
+// encryptWebhookSecret 密钥和密码类型属性一样按id加密保存,发送时再解密计算签名
+func encryptWebhookSecret(param *models.SysWebhookTable) (err error) {
+	if param.Secret == "" {
+		return
+	}
+	if param.Secret, err = cipher.AesEnPasswordByGuid(param.Id, models.Config.Wecube.EncryptSeed, param.Secret, ""); err != nil {
+		err = fmt.Errorf("Try to encrypt webhook secret fail,%s ", err.Error())
+	}
+	return
+}
+
+func WebhookDelete(id string) error {
+	var actions []*execAction
+	actions = append(actions, &execAction{Sql: "delete from sys_webhook_event where webhook=? and status in (?,?)", Param: []interface{}{id, models.WebhookStatusPending, models.WebhookStatusFailed}})
+	actions = append(actions, &execAction{Sql: "delete from sys_webhook where id=?", Param: []interface{}{id}})
+	if err := transaction(actions); err != nil {
+		return fmt.Errorf("Try to delete webhook fail,%s ", err.Error())
+	}
+	return nil
+}
+
+func WebhookEventQuery(param *models.QueryRequestParam) (pageInfo models.PageInfo, rowData []*models.SysWebhookEventTable, err error) {
+	rowData = []*models.SysWebhookEventTable{}
+	filterSql, queryColumn, queryParam := transFiltersToSQL(param, &models.TransFiltersParam{IsStruct: true, StructObj: models.SysWebhookEventTable{}})
+	baseSql := fmt.Sprintf("SELECT %s FROM sys_webhook_event WHERE 1=1 %s ", queryColumn, filterSql)
+	if param.Paging {
+		pageInfo.StartIndex = param.Pageable.StartIndex
+		pageInfo.PageSize = param.Pageable.PageSize
+		pageInfo.TotalRows = queryCount(baseSql, queryParam...)
+		pageSql, pageParam := transPageInfoToSQL(*param.Pageable)
+		baseSql += pageSql
+		queryParam = append(queryParam, pageParam...)
+	}
+	err = x.SQL(baseSql, queryParam...).Find(&rowData)
+	if err != nil {
+		err = fmt.Errorf("Webhook event query fail,%s ", err.Error())
+	}
+	return
+}
+
+func WebhookEventRetry(id int) error {
+	execResult, err := x.Exec("update sys_webhook_event set status=?,retry_count=0,next_time=?,update_time=? where id=? and status=?",
+		models.WebhookStatusPending, time.Now().Format(models.DateTimeFormat), time.Now().Format(models.DateTimeFormat), id, models.WebhookStatusFailed)
+	if err != nil {
+		return fmt.Errorf("Try to reset webhook event fail,%s ", err.Error())
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		return fmt.Errorf("Webhook event:%d is not in failed status ", id)
+	}
+	return nil
+}
+
+func validateWebhookParam(param *models.SysWebhookTable) error {
+	if !strings.HasPrefix(param.Url, "http://") && !strings.HasPrefix(param.Url, "https://") {
+		return fmt.Errorf("Webhook url:%s is illegal,must start with http:// or https:// ", param.Url)
+	}
+	if param.Enable == "" {
+		param.Enable = "yes"
+	}
+	if param.Enable != "yes" && param.Enable != "no" {
+		return fmt.Errorf("Webhook enable must be yes or no ")
+	}
+	// 加密后的密钥要能放进secret字段
+	if param.Secret != models.PasswordDisplay && len(param.Secret) > webhookMaxSecretLength {
+		return fmt.Errorf("Webhook secret is too long,max length is %d ", webhookMaxSecretLength)
+	}
+	return nil
+}
+
+func buildCiDataWebhookEvent(param *models.ActionFuncParam) *models.CiDataWebhookEvent {
+	event := models.CiDataWebhookEvent{EventId: guid.CreateGuidList(1)[0], EventTime: param.NowTime, CiType: param.CiType, Operation: param.Operation,
+		Action: param.Transition.Action, ToState: param.Transition.TargetStateName, Operator: param.Operator, Data: make(models.CiDataMapObj)}
+	rowData := param.InputData
+	if param.NowData != nil {
+		event.FromState = param.NowData["state"]
+		if param.Transition.Action != "insert" && param.Transition.Action != "update" {
+			rowData = param.NowData
+		}
+	}
+	for k, v := range rowData {
+		event.Data[k] = v
+	}
+	for _, attr := range param.Attributes {
+		if attr.InputType == models.PasswordInputType {
+			if _, b := event.Data[attr.Name]; b {
+				event.Data[attr.Name] = models.PasswordDisplay
+			}
+		}
+	}
+	event.Guid = param.InputData["guid"]
+	event.KeyName = event.Data["key_name"]
+	return &event
+}
+
+// getWebhookEventActions 匹配订阅并生成事件入队的SQL,和数据变更放在同一个事务里
+func getWebhookEventActions(eventList []*models.CiDataWebhookEvent) (actions []*execAction, err error) {
+	if !models.Config.Webhook.Enable || len(eventList) == 0 {
+		return
+	}
+	var webhookList []*models.SysWebhookTable
+	err = x.SQL("select * from sys_webhook where enable='yes'").Find(&webhookList)
+	if err != nil {
+		err = fmt.Errorf("Try to query webhook list fail,%s ", err.Error())
+		return
+	}
+	nowTime := time.Now().Format(models.DateTimeFormat)
+	for _, event := range eventList {
+		var payload []byte
+		for _, webhook := range webhookList {
+			if !isWebhookMatch(webhook, event) {
+				continue
+			}
+			if payload == nil {
+				payload, _ = json.Marshal(event)
+			}
+			actions = append(actions, &execAction{Sql: "insert into sys_webhook_event(event_id,webhook,ci_type,row_guid,payload,status,retry_count,next_time,create_time,update_time) value (?,?,?,?,?,?,0,?,?,?)",
+				Param: []interface{}{event.EventId, webhook.Id, event.CiType, event.Guid, string(payload), models.WebhookStatusPending, nowTime, nowTime, nowTime}})
+		}
+	}
+	return
+}
+
+func isWebhookMatch(webhook *models.SysWebhookTable, event *models.CiDataWebhookEvent) bool {
+	if webhook.CiType != "" && !isWebhookFilterContain(webhook.CiType, event.CiType) {
+		return false
+	}
+	if webhook.Operation != "" && !isWebhookFilterContain(webhook.Operation, event.Operation) && !isWebhookFilterContain(webhook.Operation, event.Action) {
+		return false
+	}
+	if webhook.TargetState != "" && !isWebhookFilterContain(webhook.TargetState, event.ToState) {
+		return false
+	}
+	return true
+}
+
+func isWebhookFilterContain(filter, value string) bool {
+	for _, v := range strings.Split(filter, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func StartWebhookDelivery() {
+	if !models.Config.Webhook.Enable {
+		log.Logger.Info("Webhook delivery is disable")
+		return
+	}
+	log.Logger.Info("Start webhook delivery cron job", log.String("instance", jobInstanceId))
+	intervalSec := models.Config.Webhook.IntervalSec
+	if intervalSec <= 0 {
+		intervalSec = 10
+	}
+	t := time.NewTicker(time.Duration(intervalSec) * time.Second).C
+	for {
+		<-t
+		deliverWebhookEvents()
+	}
+}
+
+// reclaimStaleWebhookEvents 发送实例退出时留下的发送中事件重新放回队列,其它实例正在发送的事件不受影响
+func reclaimStaleWebhookEvents() {
+	staleTime := time.Now().Add(-webhookSendingStaleSec * time.Second).Format(models.DateTimeFormat)
+	execResult, err := x.Exec("update sys_webhook_event set status=?,owner=NULL where status=? and (update_time is null or update_time<?)", models.WebhookStatusPending, models.WebhookStatusSending, staleTime)
+	if err != nil {
+		log.Logger.Error("Try to reclaim stale webhook event fail", log.Error(err))
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		log.Logger.Info("Reclaim stale webhook event", log.Int64("num", affectNum))
+	}
+}
+
+func deliverWebhookEvents() {
+	reclaimStaleWebhookEvents()
+	batchSize := models.Config.Webhook.BatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	queryRows, err := x.QueryString("select t1.id,t1.event_id,t1.payload,t1.retry_count,t2.id as webhook_id,t2.url,t2.secret from sys_webhook_event t1 join sys_webhook t2 on t1.webhook=t2.id where t2.enable='yes' and t1.status=? and t1.next_time<=? order by t1.id limit ?",
+		models.WebhookStatusPending, time.Now().Format(models.DateTimeFormat), batchSize)
+	if err != nil {
+		log.Logger.Error("Try to query webhook event fail", log.Error(err))
+		return
+	}
+	for _, row := range queryRows {
+		// 先抢占事件,避免多实例时重复发送
+		execResult, execErr := x.Exec("update sys_webhook_event set status=?,owner=?,update_time=? where id=? and status=?", models.WebhookStatusSending, jobInstanceId, time.Now().Format(models.DateTimeFormat), row["id"], models.WebhookStatusPending)
+		if execErr != nil {
+			log.Logger.Error("Try to lock webhook event fail", log.String("id", row["id"]), log.Error(execErr))
+			continue
+		}
+		if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+			continue
+		}
+		nowTime := time.Now()
+		// 旧数据里没加密的密钥解密时原样返回
+		secret, sendErr := cipher.AesDePasswordByGuid(row["webhook_id"], models.Config.Wecube.EncryptSeed, row["secret"])
+		if sendErr != nil {
+			sendErr = fmt.Errorf("Try to decrypt webhook secret fail,%s ", sendErr.Error())
+		} else {
+			sendErr = sendWebhookEvent(row["url"], secret, row["event_id"], row["payload"])
+		}
+		if sendErr == nil {
+			x.Exec("update sys_webhook_event set status=?,last_error='',update_time=? where id=? and owner=?", models.WebhookStatusSuccess, nowTime.Format(models.DateTimeFormat), row["id"], jobInstanceId)
+			continue
+		}
+		log.Logger.Warn("Webhook event delivery fail", log.String("id", row["id"]), log.String("url", row["url"]), log.Error(sendErr))
+		retryCount, _ := strconv.Atoi(row["retry_count"])
+		retryCount = retryCount + 1
+		status := models.WebhookStatusPending
+		if retryCount >= getWebhookMaxRetry() {
+			status = models.WebhookStatusFailed
+		}
+		nextTime := nowTime.Add(time.Duration(getWebhookRetryDelay(retryCount)) * time.Second).Format(models.DateTimeFormat)
+		x.Exec("update sys_webhook_event set status=?,retry_count=?,next_time=?,last_error=?,update_time=? where id=? and owner=?",
+			status, retryCount, nextTime, sendErr.Error(), nowTime.Format(models.DateTimeFormat), row["id"], jobInstanceId)
+	}
+}
+
+func getWebhookMaxRetry() int {
+	if models.Config.Webhook.MaxRetry <= 0 {
+		return webhookDefaultMaxRetry
+	}
+	return models.Config.Webhook.MaxRetry
+}
+
+// getWebhookRetryDelay 按重试次数指数退避
+func getWebhookRetryDelay(retryCount int) int {
+	delaySec := models.Config.Webhook.RetryDelaySec
+	if delaySec <= 0 {
+		delaySec = 30
+	}
+	for i := 1; i < retryCount; i++ {
+		delaySec = delaySec * 2
+		if delaySec >= webhookMaxRetryDelaySec {
+			return webhookMaxRetryDelaySec
+		}
+	}
+	return delaySec
+}
+
+func sendWebhookEvent(url, secret, eventId, payload string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(payload)))
+	if err != nil {
+		return fmt.Errorf("Try to new http request fail,%s ", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(models.WebhookEventHeader, eventId)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		req.Header.Set(models.WebhookSignHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	timeoutSec := models.Config.Webhook.TimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = 10
+	}
+	client := http.Client{Timeout: time.Duration(timeoutSec) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Try to do http request fail,%s ", err.Error())
+	}
+	respBytes, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Response status:%d body:%s ", resp.StatusCode, string(respBytes))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+//go:build sqlite
+
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/we-cmdb/cmdb-server/models"
+)
+
+func TestWebhookSecretAndDelivery(t *testing.T) {
+	models.Config.Wecube.EncryptSeed = "test-seed"
+	defer func() { models.Config.Wecube.EncryptSeed = "" }()
+	var signList []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("plain-secret"))
+		mac.Write(body)
+		signList = append(signList, r.Header.Get(models.WebhookSignHeader))
+		if r.Header.Get(models.WebhookSignHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	webhook := models.SysWebhookTable{Name: "test", Url: server.URL, Secret: "plain-secret"}
+	if err := WebhookCreate(&webhook, "tester"); err != nil {
+		t.Fatalf("create webhook fail,%s", err.Error())
+	}
+	// 密钥加密保存,更新时传掩码保持原值
+	row := queryTestRow(t, "select * from sys_webhook where id=?", webhook.Id)
+	if row["secret"] == "plain-secret" || !strings.HasPrefix(row["secret"], "{cipher_a}") {
+		t.Fatalf("webhook secret should be encrypted:%s", row["secret"])
+	}
+	webhook.Secret = models.PasswordDisplay
+	if err := WebhookUpdate(&webhook, "tester"); err != nil {
+		t.Fatalf("update webhook fail,%s", err.Error())
+	}
+	if newRow := queryTestRow(t, "select * from sys_webhook where id=?", webhook.Id); newRow["secret"] != row["secret"] {
+		t.Fatalf("masked secret should keep origin value:%s", newRow["secret"])
+	}
+	nowTime := time.Now().Add(-time.Second).Format(models.DateTimeFormat)
+	if _, err := x.Exec("insert into sys_webhook_event(event_id,webhook,payload,status,retry_count,next_time) values (?,?,?,?,0,?)", "event_ok", webhook.Id, `{"a":1}`, models.WebhookStatusPending, nowTime); err != nil {
+		t.Fatalf("insert webhook event fail,%s", err.Error())
+	}
+	deliverWebhookEvents()
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_ok"); event["status"] != models.WebhookStatusSuccess {
+		t.Fatalf("webhook event should be signed with plain secret:%v %v", event, signList)
+	}
+	// 没配置重试次数时按默认次数重试,第一次失败不会直接变成失败
+	if _, err := x.Exec("update sys_webhook set url=? where id=?", server.URL+"/fail", webhook.Id); err != nil {
+		t.Fatalf("update webhook url fail,%s", err.Error())
+	}
+	if _, err := x.Exec("update sys_webhook set secret=? where id=?", "", webhook.Id); err != nil {
+		t.Fatalf("update webhook secret fail,%s", err.Error())
+	}
+	if _, err := x.Exec("insert into sys_webhook_event(event_id,webhook,payload,status,retry_count,next_time) values (?,?,?,?,0,?)", "event_fail", webhook.Id, `{"a":2}`, models.WebhookStatusPending, nowTime); err != nil {
+		t.Fatalf("insert webhook event fail,%s", err.Error())
+	}
+	deliverWebhookEvents()
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_fail"); event["status"] != models.WebhookStatusPending || event["retry_count"] != "1" {
+		t.Fatalf("failed event should wait for retry:%v", event)
+	}
+}
+
+func TestReclaimStaleWebhookEvents(t *testing.T) {
+	var requestNum int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestNum++
+	}))
+	defer server.Close()
+	webhook := models.SysWebhookTable{Name: "test_disable", Url: server.URL, Enable: "no"}
+	if err := WebhookCreate(&webhook, "tester"); err != nil {
+		t.Fatalf("create webhook fail,%s", err.Error())
+	}
+	nowTime := time.Now()
+	for _, event := range []struct {
+		EventId    string
+		Status     string
+		Owner      string
+		UpdateTime string
+	}{
+		{"event_stale", models.WebhookStatusSending, "other_instance", nowTime.Add(-webhookSendingStaleSec * 2 * time.Second).Format(models.DateTimeFormat)},
+		{"event_sending", models.WebhookStatusSending, "other_instance", nowTime.Format(models.DateTimeFormat)},
+		{"event_disable", models.WebhookStatusPending, "", nowTime.Format(models.DateTimeFormat)},
+	} {
+		if _, err := x.Exec("insert into sys_webhook_event(event_id,webhook,payload,status,owner,retry_count,next_time,update_time) values (?,?,?,?,?,0,?,?)",
+			event.EventId, webhook.Id, `{"a":3}`, event.Status, event.Owner, nowTime.Add(-time.Second).Format(models.DateTimeFormat), event.UpdateTime); err != nil {
+			t.Fatalf("insert webhook event fail,%s", err.Error())
+		}
+	}
+	// 其它实例正在发送的事件不动,超时的事件放回队列;停用的订阅不发送
+	deliverWebhookEvents()
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_stale"); event["status"] != models.WebhookStatusPending || event["owner"] != "" {
+		t.Fatalf("stale event should be reclaimed:%v", event)
+	}
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_sending"); event["status"] != models.WebhookStatusSending || event["owner"] != "other_instance" {
+		t.Fatalf("event sending by other instance should not change:%v", event)
+	}
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_disable"); event["status"] != models.WebhookStatusPending || requestNum != 0 {
+		t.Fatalf("event of disable webhook should not be sent:%v num:%d", event, requestNum)
+	}
+	// 启用后由当前实例抢占发送
+	if _, err := x.Exec("update sys_webhook set enable='yes' where id=?", webhook.Id); err != nil {
+		t.Fatalf("enable webhook fail,%s", err.Error())
+	}
+	deliverWebhookEvents()
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_disable"); event["status"] != models.WebhookStatusSuccess || event["owner"] != jobInstanceId {
+		t.Fatalf("event should be sent by current instance:%v", event)
+	}
+	if event := queryTestRow(t, "select * from sys_webhook_event where event_id=?", "event_sending"); event["status"] != models.WebhookStatusSending {
+		t.Fatalf("event sending by other instance should not be sent again:%v", event)
+	}
+	x.Exec("update sys_webhook set enable='no' where id=?", webhook.Id)
+}
@@ -751,4 +751,119 @@ CREATE TABLE `sys_ci_import_guid_map` (
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
 alter table sys_basekey_code modify column `id` varchar(128) NOT NULL COMMENT '主键';
-#@v2.0.9.24-end@;
\ No newline at end of file
+#@v2.0.9.24-end@;
+
+#@v2.1.0-begin@;
+CREATE TABLE `sys_webhook` (
+  `id` varchar(64) NOT NULL COMMENT '主键',
+  `name` varchar(64) NOT NULL COMMENT '名称',
+  `url` varchar(512) NOT NULL COMMENT '推送地址',
+  `secret` varchar(255) DEFAULT NULL COMMENT '签名密钥',
+  `ci_type` varchar(255) DEFAULT NULL COMMENT '订阅ci类型,多个用逗号分隔,空为全部',
+  `operation` varchar(255) DEFAULT NULL COMMENT '订阅操作,多个用逗号分隔,空为全部',
+  `target_state` varchar(255) DEFAULT NULL COMMENT '订阅目标状态,多个用逗号分隔,空为全部',
+  `enable` varchar(16) DEFAULT 'yes' COMMENT '是否启用',
+  `create_user` varchar(64) DEFAULT NULL,
+  `create_time` datetime DEFAULT NULL,
+  `update_user` varchar(64) DEFAULT NULL,
+  `update_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `sys_webhook_event` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `event_id` varchar(64) NOT NULL COMMENT '事件id',
+  `webhook` varchar(64) NOT NULL COMMENT '所属订阅',
+  `ci_type` varchar(64) DEFAULT NULL COMMENT '数据ci类型',
+  `row_guid` varchar(64) DEFAULT NULL COMMENT '数据guid',
+  `payload` longtext COMMENT '推送内容',
+  `status` varchar(16) NOT NULL DEFAULT 'pending' COMMENT '状态 pending|sending|success|failed',
+  `owner` varchar(64) DEFAULT NULL COMMENT '发送实例',
+  `retry_count` int(11) DEFAULT 0 COMMENT '重试次数',
+  `next_time` datetime DEFAULT NULL COMMENT '下次推送时间',
+  `last_error` text COMMENT '最后一次错误',
+  `create_time` datetime DEFAULT NULL,
+  `update_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhook_event_status` (`status`,`next_time`),
+  KEY `idx_webhook_event_webhook` (`webhook`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+alter table sys_state_transition add column `guard` text DEFAULT NULL COMMENT '迁移守卫条件';
+alter table sys_ci_type_attr add column `trigger_operation` varchar(64) DEFAULT NULL COMMENT '定时触发的操作';
+CREATE TABLE `sys_time_trigger_log` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `ci_type` varchar(64) NOT NULL COMMENT '数据ci类型',
+  `ci_attr` varchar(128) NOT NULL COMMENT '定时触发属性',
+  `row_guid` varchar(64) NOT NULL COMMENT '数据guid',
+  `trigger_time` datetime NOT NULL COMMENT '属性中的触发时间',
+  `operation` varchar(64) DEFAULT NULL COMMENT '触发的操作',
+  `status` varchar(16) NOT NULL DEFAULT 'running' COMMENT '状态 running|success|failed',
+  `message` text COMMENT '执行结果',
+  `retry_count` int(11) NOT NULL DEFAULT 0 COMMENT '失败后已重试次数',
+  `next_time` datetime DEFAULT NULL COMMENT '失败后下次重试时间',
+  `owner` varchar(64) DEFAULT NULL COMMENT '执行实例',
+  `create_time` datetime DEFAULT NULL,
+  `update_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_time_trigger_log` (`ci_attr`,`row_guid`,`trigger_time`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+alter table sys_ci_type add column `confirm_policy` varchar(32) DEFAULT 'none' COMMENT '确认策略 none|fourEyes';
+alter table sys_ci_type add column `confirm_role` varchar(255) DEFAULT NULL COMMENT '允许确认的角色,多个用逗号分隔,空为不限制';
+CREATE TABLE `sys_ci_search_index` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `ci_type` varchar(64) NOT NULL COMMENT '数据ci类型',
+  `guid` varchar(64) NOT NULL COMMENT '数据guid',
+  `key_name` varchar(512) DEFAULT NULL COMMENT '数据唯一名称',
+  `state` varchar(64) DEFAULT NULL COMMENT '数据状态',
+  `attr` varchar(64) NOT NULL COMMENT '属性名',
+  `value` text COMMENT '属性值',
+  `search_value` varchar(255) NOT NULL DEFAULT '' COMMENT '小写后用于前缀匹配的值,整个值和每个单词开头的后缀各一行',
+  `token_no` int(11) NOT NULL DEFAULT 0 COMMENT '0为整个值,大于0为单词开头的后缀',
+  `update_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_search_index_guid` (`guid`),
+  KEY `idx_search_index_ci_type` (`ci_type`),
+  KEY `idx_search_index_value` (`search_value`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `sys_ci_search_dirty` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `ci_type` varchar(64) NOT NULL COMMENT '数据ci类型',
+  `guid` varchar(64) NOT NULL COMMENT '数据guid',
+  `create_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `sys_autofill_job` (
+  `id` varchar(64) NOT NULL COMMENT '任务id',
+  `job_type` varchar(16) NOT NULL COMMENT '类型 ciType|attr|chain',
+  `ci_type` varchar(255) DEFAULT NULL COMMENT '重算的ci类型,连锁重算时为多个',
+  `ci_attr` varchar(128) DEFAULT NULL COMMENT '重算的属性',
+  `guid_list` longtext COMMENT '连锁重算的数据guid列表',
+  `chain_map` longtext COMMENT '连锁重算的源数据和更新列,执行时展开成guid_list',
+  `status` varchar(16) NOT NULL DEFAULT 'pending' COMMENT '状态 pending|running|success|failed',
+  `total_num` int(11) DEFAULT 0 COMMENT '总行数',
+  `done_num` int(11) DEFAULT 0 COMMENT '已处理行数',
+  `update_num` int(11) DEFAULT 0 COMMENT '有更新的行数',
+  `fail_num` int(11) DEFAULT 0 COMMENT '失败行数',
+  `last_guid` varchar(64) DEFAULT NULL COMMENT '已处理到的guid,重启后从这里继续',
+  `error_message` text COMMENT '错误信息',
+  `owner` varchar(64) DEFAULT NULL COMMENT '正在执行任务的实例',
+  `heartbeat_time` datetime DEFAULT NULL COMMENT '执行实例的心跳时间,超时后其他实例可以接管',
+  `create_user` varchar(64) DEFAULT NULL,
+  `create_time` datetime DEFAULT NULL,
+  `start_time` datetime DEFAULT NULL,
+  `end_time` datetime DEFAULT NULL,
+  `update_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_autofill_job_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `sys_autofill_job_error` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `job_id` varchar(64) NOT NULL COMMENT '所属任务',
+  `ci_type` varchar(64) DEFAULT NULL COMMENT '数据ci类型',
+  `row_guid` varchar(64) DEFAULT NULL COMMENT '数据guid',
+  `message` text COMMENT '错误信息',
+  `create_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_autofill_job_error_job` (`job_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+#@v2.1.0-end@;